@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/wb-go/wbf/dbpg"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/image-processor/internal/config"
+	imagerepo "github.com/aliskhannn/image-processor/internal/repository/image"
+)
+
+// Supported database backends, selected by database.driver.
+const (
+	dbPostgres = "postgres"
+	dbMySQL    = "mysql"
+	dbSQLite   = "sqlite"
+)
+
+// databaseDriver returns the configured database backend, defaulting to PostgreSQL.
+func databaseDriver(cfg *config.Config) string {
+	if cfg.Database.Driver == "" {
+		return dbPostgres
+	}
+
+	return cfg.Database.Driver
+}
+
+// newRepository connects to the configured database backend.
+func newRepository(ctx context.Context, cfg *config.Config) (imagerepo.Store, error) {
+	switch databaseDriver(cfg) {
+	case dbPostgres:
+		// Connect to PostgreSQL (master and slaves).
+		opts := &dbpg.Options{
+			MaxOpenConns:    cfg.Database.MaxOpenConns,
+			MaxIdleConns:    cfg.Database.MaxIdleConns,
+			ConnMaxLifetime: cfg.Database.ConnMaxLifetime,
+		}
+
+		// Collect slave DSNs for replica connections.
+		slaveDNSs := make([]string, 0, len(cfg.Database.Slaves))
+
+		for _, s := range cfg.Database.Slaves {
+			slaveDNSs = append(slaveDNSs, s.DSN())
+		}
+		zlog.Logger.Info().
+			Str("host", cfg.Database.Master.Host).
+			Str("database", cfg.Database.Master.Name).
+			Int("slaves", len(slaveDNSs)).
+			Msg("connecting to postgres database")
+		db, err := dbpg.New(cfg.Database.Master.DSN(), slaveDNSs, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		return imagerepo.NewRepository(db, cfg.Database.ReadFromReplicas), nil
+	case dbMySQL:
+		opts := &dbpg.Options{
+			MaxOpenConns:    cfg.Database.MaxOpenConns,
+			MaxIdleConns:    cfg.Database.MaxIdleConns,
+			ConnMaxLifetime: cfg.Database.ConnMaxLifetime,
+		}
+
+		zlog.Logger.Info().Str("host", cfg.Database.Master.Host).Msg("connecting to mysql database")
+		return imagerepo.NewMySQLRepository(ctx, cfg.Database.Master.MySQLDSN(), opts)
+	case dbSQLite:
+		zlog.Logger.Info().Str("path", cfg.Database.Path).Msg("opening sqlite database")
+		return imagerepo.NewSQLiteRepository(ctx, cfg.Database.Path)
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", cfg.Database.Driver)
+	}
+}
+
+// runPartitioner creates the missing monthly partitions of the images table on start and then
+// every interval, until ctx is canceled.
+func runPartitioner(ctx context.Context, repo *imagerepo.Repository, interval time.Duration, monthsAhead int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := repo.EnsurePartitions(ctx, monthsAhead)
+		if err != nil {
+			zlog.Logger.Err(err).Msg("failed to create image partitions")
+		} else if n > 0 {
+			zlog.Logger.Info().Int("count", n).Msg("created image partitions")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -3,132 +3,539 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
 	"os/signal"
 	"sync"
 	"syscall"
 	"time"
 
-	"github.com/wb-go/wbf/dbpg"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/wb-go/wbf/ginext"
 	"github.com/wb-go/wbf/retry"
 	"github.com/wb-go/wbf/zlog"
+	"golang.org/x/sync/errgroup"
 
+	"github.com/aliskhannn/image-processor/internal/antivirus"
+	"github.com/aliskhannn/image-processor/internal/api/handlers/admin"
+	"github.com/aliskhannn/image-processor/internal/api/handlers/asset"
+	"github.com/aliskhannn/image-processor/internal/api/handlers/health"
 	"github.com/aliskhannn/image-processor/internal/api/handlers/image"
+	"github.com/aliskhannn/image-processor/internal/api/handlers/session"
 	"github.com/aliskhannn/image-processor/internal/api/router"
 	"github.com/aliskhannn/image-processor/internal/api/server"
+	"github.com/aliskhannn/image-processor/internal/assets/fonts"
+	"github.com/aliskhannn/image-processor/internal/cdn"
 	"github.com/aliskhannn/image-processor/internal/config"
-	"github.com/aliskhannn/image-processor/internal/infra/kafka/consumer"
-	"github.com/aliskhannn/image-processor/internal/infra/kafka/producer"
+	"github.com/aliskhannn/image-processor/internal/errreport"
+	healthcheck "github.com/aliskhannn/image-processor/internal/health"
+	kafkaproducer "github.com/aliskhannn/image-processor/internal/infra/kafka/producer"
+	"github.com/aliskhannn/image-processor/internal/infra/postgres"
+	"github.com/aliskhannn/image-processor/internal/infra/queue"
+	"github.com/aliskhannn/image-processor/internal/infra/queue/codec"
 	imagemsg "github.com/aliskhannn/image-processor/internal/kafka/handlers/image"
+	"github.com/aliskhannn/image-processor/internal/metrics"
+	"github.com/aliskhannn/image-processor/internal/model"
+	"github.com/aliskhannn/image-processor/internal/ocr"
 	"github.com/aliskhannn/image-processor/internal/processor"
 	imagerepo "github.com/aliskhannn/image-processor/internal/repository/image"
+	"github.com/aliskhannn/image-processor/internal/secrets"
+	adminsvc "github.com/aliskhannn/image-processor/internal/service/admin"
+	assetsvc "github.com/aliskhannn/image-processor/internal/service/asset"
 	imagesvc "github.com/aliskhannn/image-processor/internal/service/image"
-	"github.com/aliskhannn/image-processor/internal/storage/file"
+	sessionsvc "github.com/aliskhannn/image-processor/internal/service/session"
+	filestorage "github.com/aliskhannn/image-processor/internal/storage"
+	"github.com/aliskhannn/image-processor/internal/webhook"
+)
+
+// Run modes, selected by the -mode flag, so that the HTTP front-end and the processing
+// workers can be deployed and scaled independently.
+const (
+	modeAll    = "all"    // HTTP server and queue consumers in one process
+	modeAPI    = "api"    // HTTP server only
+	modeWorker = "worker" // queue consumers and background sweepers only
 )
 
 func main() {
+	// run returns once every part of the app was shut down and its pending reports flushed,
+	// so that only the exit status is left to set.
+	err := run()
+	switch {
+	case err == nil:
+		return
+	case errors.Is(err, secrets.ErrRotated):
+		// Exiting with an error gets the app restarted by its supervisor, with the rotated credentials.
+		zlog.Logger.Warn().Err(err).Msg("shut down to restart with the rotated credentials")
+	default:
+		zlog.Logger.Error().Err(err).Msg("image processor failed")
+	}
+
+	os.Exit(1)
+}
+
+// run starts the parts of the app of the selected mode and blocks until they are stopped by
+// SIGINT/SIGTERM or one of them fails. It then shuts them down in order: the HTTP server first,
+// then the queue consumers and background jobs, and the connections to the dependencies last.
+func run() error {
+	mode := flag.String("mode", modeAll, "run mode: all, api (HTTP server only) or worker (queue consumers only)")
+	flag.Parse()
+
+	runAPI := *mode == modeAll || *mode == modeAPI
+	runWorker := *mode == modeAll || *mode == modeWorker
+
 	// Context & signals: used for graceful shutdown on system interrupts.
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	// Every long-running part of the app runs in the group, so that shutdown waits for all of them
+	// and one failing (e.g. the HTTP server unable to listen) shuts the others down.
+	g, ctx := errgroup.WithContext(ctx)
+
 	// Initialize logger and load application configuration.
 	zlog.Init()
 	cfg := config.MustLoad("./config/config.yml")
 
-	// Connect to PostgreSQL (master and slaves).
-	opts := &dbpg.Options{
-		MaxOpenConns:    cfg.Database.MaxOpenConns,
-		MaxIdleConns:    cfg.Database.MaxIdleConns,
-		ConnMaxLifetime: cfg.Database.ConnMaxLifetime,
+	if !runAPI && !runWorker {
+		zlog.Logger.Fatal().Str("mode", *mode).Msg("unknown run mode")
 	}
 
-	// Collect slave DSNs for replica connections.
-	slaveDNSs := make([]string, 0, len(cfg.Database.Slaves))
+	// Report errors and panics to Sentry, if configured; pending events are sent on shutdown.
+	if err := errreport.Init(cfg.ErrorReporting); err != nil {
+		zlog.Logger.Fatal().Err(err).Msg("failed to initialize error reporting")
+	}
+	defer errreport.Flush()
+	// The in-memory queue lives in the process, so its tasks can't be consumed by another one.
+	if queueType(cfg) == queueMemory && *mode != modeAll {
+		zlog.Logger.Fatal().Str("mode", *mode).Msg("the in-memory queue requires running in mode all")
+	}
 
-	for _, s := range cfg.Database.Slaves {
-		slaveDNSs = append(slaveDNSs, s.DSN())
+	// Settings reloaded while the app runs: the retry strategy for the queue and other external
+	// calls, the quotas, the watermark defaults and the log level.
+	live := newSettings(cfg)
+	// Retry strategy for connecting to the database, storage and queue, which may still be starting.
+	startup := retry.Strategy{
+		Attempts: cfg.StartupRetry.Attempts,
+		Delay:    cfg.StartupRetry.Delay,
+		Backoff:  cfg.StartupRetry.Backoff,
 	}
-	zlog.Logger.Info().Msgf("db url: %s", cfg.Database.Master.DSN())
-	db, err := dbpg.New(cfg.Database.Master.DSN(), slaveDNSs, opts)
+
+	// Resolve the credentials referencing secrets of Vault or AWS Secrets Manager.
+	secretResolver, err := secrets.New(ctx, cfg.Secrets)
 	if err != nil {
-		zlog.Logger.Fatal().Err(err).Msg("failed to connect to database")
+		zlog.Logger.Fatal().Err(err).Msg("failed to create secrets resolver")
 	}
+	_, err = connect(ctx, startup, "secrets", func() (struct{}, error) {
+		return struct{}{}, secretResolver.Resolve(ctx, cfg)
+	})
+	if err != nil {
+		zlog.Logger.Fatal().Err(err).Msg("failed to resolve secrets")
+	}
+
+	// Connect to the configured database backend.
+	repo, err := connect(ctx, startup, databaseDriver(cfg), func() (imagerepo.Store, error) {
+		r, err := newRepository(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.Ping(ctx); err != nil {
+			_ = r.Close()
+			return nil, err
+		}
 
-	// Retry strategy for Kafka and other external calls.
-	strategy := retry.Strategy{
-		Attempts: cfg.Retry.Attempts,
-		Delay:    cfg.Retry.Delay,
-		Backoff:  cfg.Retry.Backoff,
+		return r, nil
+	})
+	if err != nil {
+		zlog.Logger.Fatal().Err(err).Msg("failed to connect to database")
 	}
 
-	// Initialize file storage (MinIO).
-	storage, err := file.NewStorage(ctx, cfg.Storage.Endpoint, cfg.Storage.AccessKey, cfg.Storage.SecretKey, cfg.Storage.BucketName, cfg.Storage.UseSSL)
+	// Initialize the configured file storage backend.
+	backend, err := connect(ctx, startup, "storage", func() (filestorage.Storage, error) {
+		return dialStorage(ctx, cfg.Storage, filestorage.New)
+	})
 	if err != nil {
 		zlog.Logger.Fatal().Err(err).Msg("failed to connect to storage")
 	}
+	// Replicate writes to the secondary storage for disaster recovery.
+	var replicated *filestorage.Replicated
+	if cfg.Storage.Replica.Enabled {
+		replica, err := connect(ctx, startup, "storage replica", func() (filestorage.Storage, error) {
+			return dialStorage(ctx, cfg.Storage, filestorage.NewReplica)
+		})
+		if err != nil {
+			zlog.Logger.Fatal().Err(err).Msg("failed to connect to storage replica")
+		}
+		replicated = filestorage.NewReplicated(backend, replica, cfg.Storage.Replica.QueueSize, live.retry)
+		replicated.Start(cfg.Storage.Replica.Workers)
+		backend = replicated
+	}
+	// Fail storage calls fast during outages instead of piling up hung handlers, and probe its health.
+	storage := filestorage.NewBreaker(backend, cfg.Storage.Breaker.Threshold, cfg.Storage.Breaker.Cooldown)
+	g.Go(func() error {
+		storage.Probe(ctx, cfg.Storage.Breaker.ProbeInterval)
+		return nil
+	})
 
-	// Initialize repository, producer, processor, and service layer.
-	repo := imagerepo.NewRepository(db)
-	p := producer.New(&cfg.Kafka, strategy)
-	imageProcessor := processor.New(storage)
-	service := imagesvc.NewService(storage, p, imageProcessor, repo)
+	// Initialize producer, processor, and service layer.
+	messageCodec, err := codec.New(&cfg.Queue)
+	if err != nil {
+		zlog.Logger.Fatal().Err(err).Msg("failed to configure queue message format")
+	}
+	p, err := connect(ctx, startup, queueType(cfg), func() (queue.Publisher, error) {
+		pub, err := newPublisher(ctx, cfg, live.retry, messageCodec)
+		if err != nil {
+			return nil, err
+		}
+		if err := pub.Ping(ctx); err != nil {
+			_ = pub.Close()
+			return nil, err
+		}
+
+		return pub, nil
+	})
+	if err != nil {
+		zlog.Logger.Fatal().Err(err).Msg("failed to create queue producer")
+	}
+	// Fonts of the watermarks, parsed once and cached by size; the default one is embedded.
+	fontRegistry := fonts.NewRegistry(cfg.Fonts.Dir)
+	if _, err := fontRegistry.Face(cfg.Watermark.Font, 12); err != nil {
+		zlog.Logger.Fatal().Err(err).Msg("failed to load watermark font")
+	}
+	imageProcessor := processor.New(storage, repo, processor.Limits{
+		MaxWidth:  cfg.Limits.MaxWidth,
+		MaxHeight: cfg.Limits.MaxHeight,
+		MaxPixels: cfg.Limits.MaxPixels,
+	}, live.watermark, fontRegistry)
+	notifier := webhook.NewNotifier(cfg.Webhook.Secret, cfg.Webhook.Timeout, live.retry)
+
+	// Optional integrations stay nil interfaces when disabled.
+	var (
+		textExtractor interface {
+			Extract(ctx context.Context, src io.Reader) (string, error)
+		}
+		virusScanner interface {
+			Scan(ctx context.Context, src io.Reader) error
+		}
+		deadLetterPublisher interface {
+			Publish(ctx context.Context, dl model.DeadLetter) error
+		}
+		eventPublisher interface {
+			PublishProcessed(ctx context.Context, ev model.ProcessedEvent) error
+		}
+		statusWatcher interface {
+			Subscribe(id uuid.UUID) (changes <-chan struct{}, cancel func())
+		}
+	)
+
+	// Enable the optional OCR step for extracting text from uploaded images.
+	if cfg.OCR.Enabled {
+		textExtractor = ocr.NewTesseract(cfg.OCR.Binary, cfg.OCR.Languages, cfg.OCR.Timeout)
+	}
+
+	// Enable the optional antivirus scan of uploads.
+	if cfg.Antivirus.Enabled {
+		virusScanner = antivirus.NewClamd(cfg.Antivirus.Network, cfg.Antivirus.Address, cfg.Antivirus.Timeout)
+	}
+
+	// Enable the Kafka completion events of processed images for downstream systems.
+	var events *kafkaproducer.EventProducer
+	if queueType(cfg) == queueKafka && cfg.Kafka.EventsTopic != "" {
+		events, err = kafkaproducer.NewEvents(&cfg.Kafka, live.retry)
+		if err != nil {
+			zlog.Logger.Fatal().Err(err).Msg("failed to create kafka events producer")
+		}
+		eventPublisher = events
+	}
+
+	// Stream status changes notified by PostgreSQL to API clients.
+	var statusListener *postgres.StatusListener
+	if runAPI && databaseDriver(cfg) == dbPostgres {
+		statusListener, err = postgres.NewStatusListener(cfg.Database.Master.DSN())
+		if err != nil {
+			zlog.Logger.Fatal().Err(err).Msg("failed to listen for status changes")
+		}
+		statusWatcher = statusListener
+		g.Go(func() error {
+			statusListener.Run(ctx)
+			return nil
+		})
+	}
+
+	// Enable purging of reprocessed and deleted images from the CDN.
+	cdnPurger, err := cdn.New(ctx, cfg.CDN, live.retry)
+	if err != nil {
+		zlog.Logger.Fatal().Err(err).Msg("failed to create cdn purger")
+	}
 
-	// Kafka message handler for uploaded images.
-	uploadedHandler := imagemsg.NewUploadedHandler(service)
+	// Processing latency per action against its objective, tracked in memory over a rolling window.
+	latencyTracker := metrics.NewLatencyTracker(cfg.SLO)
+	prometheus.MustRegister(latencyTracker)
+
+	service := imagesvc.NewService(
+		storage, p, imageProcessor, repo, textExtractor, virusScanner, notifier, eventPublisher, cdnPurger, statusWatcher,
+		latencyTracker, live.quota, cfg.Queue.Outbox.Enabled,
+	)
+	assetService := assetsvc.NewService(storage)
+	// Enable the Kafka dead-letter queue for messages failing processing after retries.
+	var dlq *kafkaproducer.DeadLetterProducer
+	if queueType(cfg) == queueKafka && cfg.Kafka.DLQTopic != "" {
+		dlq, err = kafkaproducer.NewDeadLetter(&cfg.Kafka, live.retry)
+		if err != nil {
+			zlog.Logger.Fatal().Err(err).Msg("failed to create kafka dead-letter producer")
+		}
+		deadLetterPublisher = dlq
+	}
+
+	// Pauses and resumes all queue consumers, from the admin API or SIGUSR1/SIGUSR2.
+	pauser := queue.NewPauser()
+
+	adminService := adminsvc.NewService(storage, p, deadLetterPublisher, pauser, repo, latencyTracker, cfg.Admin.StuckAfter)
+	// Storage usage gauges, loaded from the database on every scrape of /metrics.
+	prometheus.MustRegister(metrics.NewUsageCollector(adminService))
+	// Dependency checks backing the readiness probe and gating the consumer start.
+	checker := healthcheck.NewChecker(3 * time.Second)
+	checker.Add(databaseDriver(cfg), repo.Ping)
+	checker.Add("storage", storage.Ready)
+	checker.Add(queueType(cfg), p.Ping)
+
+	// Actions accepted by uploads and the bounds of their parameters.
+	actionPolicy, err := processor.NewActionPolicy(
+		cfg.Actions.Enabled,
+		processor.Bounds(cfg.Actions.Width),
+		processor.Bounds(cfg.Actions.Height),
+		processor.Bounds(cfg.Actions.Quality),
+		fontRegistry,
+	)
+	if err != nil {
+		zlog.Logger.Fatal().Err(err).Msg("failed to configure actions")
+	}
+	sessionService := sessionsvc.NewService(
+		storage, imageProcessor, repo, actionPolicy, cfg.Session.TTL, cfg.Session.PreviewSize,
+	)
+
+	// Queue message handler for uploaded images.
+	uploadedHandler := imagemsg.NewUploadedHandler(service, messageCodec, cfg.Queue.MaxAttempts)
 
 	// HTTP handler for image routes.
-	imgHandler := image.NewHandler(service)
+	imgHandler := image.NewHandler(service, image.CachePolicy{
+		Image:     cfg.CDN.CacheControl,
+		Transform: cfg.CDN.TransformCacheControl,
+	}, actionPolicy)
+	sessionHandler := session.NewHandler(sessionService)
+	assetHandler := asset.NewHandler(assetService)
+	adminHandler := admin.NewHandler(adminService)
+	healthHandler := health.NewHandler(checker)
+
+	// HTTP routes, served once the consumers are started.
+	var routes *ginext.Engine
+	if runAPI {
+		routes, err = router.Setup(
+			imgHandler, sessionHandler, assetHandler, adminHandler, healthHandler, cfg.CORS, cfg.Users, cfg.Admin.Token,
+		)
+		if err != nil {
+			zlog.Logger.Fatal().Err(err).Msg("failed to configure http routes")
+		}
+	}
 
-	// Kafka consumer for processing uploaded image events.
-	c := consumer.New(&cfg.Kafka, strategy, uploadedHandler)
+	// Queue consumers for processing uploaded image events and their delayed retries.
+	var consumers []queue.Worker
+	if runWorker {
+		consumers, err = newWorkers(ctx, cfg, live.retry, p, uploadedHandler, adminService, messageCodec, pauser)
+		if err != nil {
+			zlog.Logger.Fatal().Err(err).Msg("failed to create queue consumers")
+		}
+	}
+
+	// Consumers stop once the HTTP server is shut down, so that the jobs of its last requests are
+	// still processed by the in-memory queue; other backends redeliver what is left on the next start.
+	consumeCtx, stopConsuming := context.WithCancel(context.WithoutCancel(ctx))
+	defer stopConsuming()
+
+	// Start queue consumers once all dependencies are reachable, and wait for them to drain.
+	if len(consumers) > 0 {
+		g.Go(func() error {
+			if !checker.WaitReady(ctx, 2*time.Second) {
+				return nil
+			}
+
+			var wg sync.WaitGroup
+			wg.Add(len(consumers))
+			for _, c := range consumers {
+				go c.Consume(consumeCtx, &wg)
+			}
+			wg.Wait()
+
+			return nil
+		})
+	}
+
+	// Shut down once referenced secrets are rotated, as the clients keep the credentials they were created with.
+	g.Go(func() error {
+		return secretResolver.Watch(ctx, cfg.Secrets.RefreshInterval)
+	})
+
+	// Reload the settings on SIGHUP or once the config file is written, without restarting the consumers.
+	g.Go(func() error {
+		return reloadSettings(ctx, live, cfg.Reload.WatchFile)
+	})
 
-	// Start Kafka consumer in a separate goroutine.
-	var wg sync.WaitGroup
-	wg.Add(1)
-	go c.Consume(ctx, &wg)
+	// Pause consumption on SIGUSR1 and resume it on SIGUSR2, e.g. to drain during storage maintenance.
+	g.Go(func() error {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+		defer signal.Stop(signals)
 
-	// Start HTTP server in a separate goroutine.
-	r := router.Setup(imgHandler)
-	s := server.New(cfg.Server.HTTPPort, r)
-	go func() {
-		if err := s.ListenAndServe(); err != nil {
-			zlog.Logger.Fatal().Err(err).Msg("failed to start server")
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case sig := <-signals:
+				if sig == syscall.SIGUSR1 {
+					adminService.PauseConsumers()
+				} else {
+					adminService.ResumeConsumers()
+				}
+			}
 		}
-	}()
+	})
+
+	// Expire idle editing sessions in the background; they are held by the API process.
+	if runAPI {
+		g.Go(func() error {
+			sessionService.Run(ctx)
+			return nil
+		})
+	}
+
+	// Publish the tasks stored in the outbox by uploads in the background.
+	if runAPI && cfg.Queue.Outbox.Enabled {
+		g.Go(func() error {
+			service.RunOutboxRelay(ctx, cfg.Queue.Outbox.Interval, cfg.Queue.Outbox.BatchSize)
+			return nil
+		})
+	}
+
+	// Delete images past their expiry time in the background.
+	if runWorker && cfg.Expiry.SweepInterval > 0 {
+		g.Go(func() error {
+			service.RunExpirySweeper(ctx, cfg.Expiry.SweepInterval, cfg.Expiry.BatchSize, cfg.Expiry.DeletedRetention)
+			return nil
+		})
+	}
 
-	// Block until context is canceled (SIGINT/SIGTERM).
-	<-ctx.Done()
-	zlog.Logger.Info().Msg("context done")
+	// Create the monthly partitions of the images table ahead of time in the background.
+	if pg, ok := repo.(*imagerepo.Repository); ok && runWorker && cfg.Database.Partitions.Interval > 0 {
+		g.Go(func() error {
+			runPartitioner(ctx, pg, cfg.Database.Partitions.Interval, cfg.Database.Partitions.MonthsAhead)
+			return nil
+		})
+	}
 
-	// Wait for Kafka consumer goroutine to finish.
-	wg.Wait()
+	// Re-enqueue or fail jobs stuck after lost messages or crashed workers in the background.
+	if runWorker && cfg.Redrive.Interval > 0 {
+		g.Go(func() error {
+			adminService.RunRedriver(ctx, cfg.Redrive.Interval, cfg.Redrive.StuckAfter, cfg.Redrive.MaxRedrives, cfg.Redrive.BatchSize)
+			return nil
+		})
+	}
 
-	// Graceful shutdown with timeout for HTTP server.
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	// Report divergence between the primary storage and its replica in the background.
+	if runWorker && replicated != nil && cfg.Storage.Replica.ReconcileInterval > 0 {
+		g.Go(func() error {
+			replicated.RunReconciler(ctx, cfg.Storage.Replica.ReconcileInterval, cfg.Storage.Replica.ReconcileGrace)
+			return nil
+		})
+	}
 
-	zlog.Logger.Info().Msg("shutting down server")
-	if err := s.Shutdown(shutdownCtx); err != nil {
-		zlog.Logger.Error().Err(err).Msg("failed to shutdown server")
+	// Serve HTTP until shut down.
+	var s *http.Server
+	if runAPI {
+		s = server.New(cfg.Server.HTTPPort, routes)
+		g.Go(func() error {
+			if err := s.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return fmt.Errorf("serve http: %w", err)
+			}
+			return nil
+		})
 	}
-	if errors.Is(shutdownCtx.Err(), context.DeadlineExceeded) {
-		zlog.Logger.Info().Msg("timeout exceeded, forcing shutdown")
+
+	// Once the context is canceled (SIGINT/SIGTERM or a failed part), shut the HTTP server down
+	// gracefully and then stop the consumers.
+	g.Go(func() error {
+		<-ctx.Done()
+		zlog.Logger.Info().Msg("context done")
+		defer stopConsuming()
+
+		if s == nil {
+			return nil
+		}
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		zlog.Logger.Info().Msg("shutting down server")
+		if err := s.Shutdown(shutdownCtx); err != nil {
+			if !errors.Is(err, context.DeadlineExceeded) {
+				return fmt.Errorf("shutdown http server: %w", err)
+			}
+
+			// Long-lived requests (e.g. status streams) are cut off.
+			zlog.Logger.Info().Msg("timeout exceeded, forcing shutdown")
+			if err := s.Close(); err != nil {
+				return fmt.Errorf("close http server: %w", err)
+			}
+		}
+
+		return nil
+	})
+
+	// Block until every part has stopped.
+	runErr := g.Wait()
+	if runErr != nil && !errors.Is(runErr, secrets.ErrRotated) {
+		errreport.Error(context.Background(), runErr)
 	}
 
-	// Close master and slave databases.
-	if err := db.Master.Close(); err != nil {
-		zlog.Logger.Printf("failed to close master DB: %v", err)
+	// Finish pending replications once nothing writes to the storage anymore.
+	if replicated != nil {
+		drainCtx, cancel := context.WithTimeout(context.Background(), cfg.Storage.Replica.DrainTimeout)
+		if err := replicated.Close(drainCtx); err != nil {
+			zlog.Logger.Error().Err(err).Msg("failed to finish storage replication")
+		}
+		cancel()
 	}
-	for i, s := range db.Slaves {
-		if err := s.Close(); err != nil {
-			zlog.Logger.Printf("failed to close slave DB %d: %v", i, err)
+
+	// Close the database connections.
+	if statusListener != nil {
+		if err := statusListener.Close(); err != nil {
+			zlog.Logger.Error().Err(err).Msg("failed to close status listener")
 		}
 	}
+	if err := repo.Close(); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to close database")
+	}
 
-	// Close Kafka producer and consumer clients.
-	if err = p.Client.Close(); err != nil {
-		zlog.Logger.Error().Err(err).Msg("failed to close kafka producer client")
+	// Close queue producer and consumer clients.
+	if err = p.Close(); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to close queue producer client")
 	}
-	if err = c.Client.Close(); err != nil {
-		zlog.Logger.Error().Err(err).Msg("failed to close kafka consumer client")
+	for _, c := range consumers {
+		if err = c.Close(); err != nil {
+			zlog.Logger.Error().Err(err).Msg("failed to close queue consumer client")
+		}
+	}
+	if dlq != nil {
+		if err = dlq.Client.Close(); err != nil {
+			zlog.Logger.Error().Err(err).Msg("failed to close kafka dead-letter producer client")
+		}
 	}
+	if events != nil {
+		if err = events.Client.Close(); err != nil {
+			zlog.Logger.Error().Err(err).Msg("failed to close kafka events producer client")
+		}
+	}
+
+	return runErr
 }
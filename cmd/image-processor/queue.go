@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	_ "github.com/lib/pq" // registers the "postgres" driver of the postgres queue
+	"github.com/wb-go/wbf/retry"
+
+	"github.com/aliskhannn/image-processor/internal/config"
+	kafkaconsumer "github.com/aliskhannn/image-processor/internal/infra/kafka/consumer"
+	kafkaproducer "github.com/aliskhannn/image-processor/internal/infra/kafka/producer"
+	"github.com/aliskhannn/image-processor/internal/infra/memory"
+	natsconsumer "github.com/aliskhannn/image-processor/internal/infra/nats/consumer"
+	natsproducer "github.com/aliskhannn/image-processor/internal/infra/nats/producer"
+	"github.com/aliskhannn/image-processor/internal/infra/postgres"
+	"github.com/aliskhannn/image-processor/internal/infra/queue"
+	"github.com/aliskhannn/image-processor/internal/infra/queue/codec"
+	sqsconsumer "github.com/aliskhannn/image-processor/internal/infra/sqs/consumer"
+	sqsproducer "github.com/aliskhannn/image-processor/internal/infra/sqs/producer"
+	"github.com/aliskhannn/image-processor/internal/model"
+	"github.com/aliskhannn/image-processor/internal/reload"
+)
+
+// Supported queue backends, selected by queue.type.
+const (
+	queueKafka    = "kafka"
+	queueNATS     = "nats"
+	queueSQS      = "sqs"
+	queueMemory   = "memory"
+	queuePostgres = "postgres"
+)
+
+// uploadedHandler defines the interface for handling uploaded image messages.
+type uploadedHandler interface {
+	Handle(ctx context.Context, msg model.QueueMessage) error
+}
+
+// deadLetterRecorder defines the interface for recording messages that failed processing after retries.
+type deadLetterRecorder interface {
+	RecordDeadLetter(ctx context.Context, dl model.DeadLetter) error
+}
+
+// queueType returns the configured queue backend, defaulting to Kafka.
+func queueType(cfg *config.Config) string {
+	if cfg.Queue.Type == "" {
+		return queueKafka
+	}
+
+	return cfg.Queue.Type
+}
+
+// newPublisher creates the publisher of the configured queue backend.
+func newPublisher(ctx context.Context, cfg *config.Config, s *reload.Value[retry.Strategy], c *codec.Codec) (queue.Publisher, error) {
+	switch queueType(cfg) {
+	case queueKafka:
+		return kafkaproducer.New(&cfg.Kafka, s, c)
+	case queueNATS:
+		return natsproducer.New(&cfg.NATS, s, c)
+	case queueSQS:
+		return sqsproducer.New(ctx, &cfg.SQS, s, c)
+	case queueMemory:
+		return memory.NewQueue(&cfg.Memory, c), nil
+	case queuePostgres:
+		// The jobs table lives in the PostgreSQL database, on a pool of its own
+		// as each worker holds a connection while processing its task.
+		db, err := sql.Open("postgres", cfg.Database.Master.DSN())
+		if err != nil {
+			return nil, err
+		}
+		db.SetMaxOpenConns(max(cfg.PGQueue.Workers, 1) + 2)
+
+		return postgres.NewQueue(db, c), nil
+	default:
+		return nil, fmt.Errorf("unknown queue type %q", cfg.Queue.Type)
+	}
+}
+
+// newWorkers creates the consumers of the configured queue backend. The in-memory
+// backend consumes from the queue of its publisher pub.
+func newWorkers(
+	ctx context.Context,
+	cfg *config.Config,
+	s *reload.Value[retry.Strategy],
+	pub queue.Publisher,
+	uh uploadedHandler,
+	dl deadLetterRecorder,
+	c *codec.Codec,
+	p *queue.Pauser,
+) ([]queue.Worker, error) {
+	var workers []queue.Worker
+
+	switch queueType(cfg) {
+	case queueKafka:
+		consumers, err := kafkaconsumer.NewChain(&cfg.Kafka, s, uh, dl, c, p)
+		if err != nil {
+			return nil, err
+		}
+		for _, consumer := range consumers {
+			workers = append(workers, consumer)
+		}
+	case queueNATS:
+		consumer, err := natsconsumer.New(&cfg.NATS, s, uh, dl, c, p)
+		if err != nil {
+			return nil, err
+		}
+		workers = append(workers, consumer)
+	case queueSQS:
+		consumer, err := sqsconsumer.New(ctx, &cfg.SQS, s, uh, dl, c, p)
+		if err != nil {
+			return nil, err
+		}
+		workers = append(workers, consumer)
+	case queueMemory:
+		q, ok := pub.(*memory.Queue)
+		if !ok {
+			return nil, fmt.Errorf("in-memory consumers need an in-memory queue, got %T", pub)
+		}
+		for range max(cfg.Memory.Workers, 1) {
+			workers = append(workers, memory.NewConsumer(q, &cfg.Memory, s, uh, dl, c, p))
+		}
+	case queuePostgres:
+		q, ok := pub.(*postgres.Queue)
+		if !ok {
+			return nil, fmt.Errorf("postgres consumers need a postgres queue, got %T", pub)
+		}
+		for range max(cfg.PGQueue.Workers, 1) {
+			workers = append(workers, postgres.NewConsumer(q.DB(), &cfg.PGQueue, s, uh, dl, c, p))
+		}
+	default:
+		return nil, fmt.Errorf("unknown queue type %q", cfg.Queue.Type)
+	}
+
+	return workers, nil
+}
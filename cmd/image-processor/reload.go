@@ -0,0 +1,140 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"os"
+	"os/signal"
+	"reflect"
+	"slices"
+	"syscall"
+
+	"github.com/rs/zerolog"
+	"github.com/wb-go/wbf/retry"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/image-processor/internal/config"
+	"github.com/aliskhannn/image-processor/internal/model"
+	"github.com/aliskhannn/image-processor/internal/processor"
+	"github.com/aliskhannn/image-processor/internal/reload"
+)
+
+// reloadable lists the sections of the configuration applied while the app runs. The app has no
+// request rate limiter; the daily image and hourly job quotas of the users limit their rate.
+var reloadable = []string{"retry", "quota", "watermark", "log"}
+
+// settings holds the settings replaced when the configuration is reloaded. The components using
+// them read them on every use, so that queue consumers keep processing their backlog meanwhile.
+type settings struct {
+	retry     *reload.Value[retry.Strategy]      // retry strategy of the queue and other external calls
+	quota     *reload.Value[model.QuotaLimits]   // upload and processing quotas of the users
+	watermark *reload.Value[processor.Watermark] // defaults of the watermark action
+}
+
+// newSettings creates the settings of cfg and applies its log level.
+func newSettings(cfg *config.Config) *settings {
+	s := &settings{
+		retry:     reload.NewValue(retry.Strategy{}),
+		quota:     reload.NewValue(model.QuotaLimits{}),
+		watermark: reload.NewValue(processor.Watermark{}),
+	}
+	s.apply(cfg)
+
+	return s
+}
+
+// apply replaces the settings with those of cfg.
+func (s *settings) apply(cfg *config.Config) {
+	s.retry.Store(retry.Strategy{
+		Attempts: cfg.Retry.Attempts,
+		Delay:    cfg.Retry.Delay,
+		Backoff:  cfg.Retry.Backoff,
+	})
+	s.quota.Store(model.QuotaLimits{
+		MaxBytes:     cfg.Quota.MaxBytes,
+		ImagesPerDay: cfg.Quota.ImagesPerDay,
+		JobsPerHour:  cfg.Quota.JobsPerHour,
+	})
+	s.watermark.Store(processor.Watermark{
+		Text:  cfg.Watermark.Text,
+		Font:  cfg.Watermark.Font,
+		Scale: cfg.Watermark.Scale,
+	})
+
+	// The level is validated with the configuration.
+	level, _ := zerolog.ParseLevel(cmp.Or(cfg.Log.Level, "info"))
+	zerolog.SetGlobalLevel(level)
+}
+
+// reloadSettings reloads the settings on SIGHUP and, with watchFile, once the config file is
+// written, until ctx is canceled. An invalid configuration is logged and the current settings are
+// kept; changes to the sections applied on restart only are logged as such.
+func reloadSettings(ctx context.Context, s *settings, watchFile bool) error {
+	// Credentials of the loaded configuration are resolved from secrets, so reloads are compared
+	// with the configuration as read from the file.
+	prev, err := config.Read()
+	if err != nil {
+		return err
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	changes := make(chan struct{}, 1)
+	if watchFile {
+		go func() {
+			err := config.Watch(ctx, func() {
+				select {
+				case changes <- struct{}{}:
+				default:
+				}
+			})
+			if err != nil {
+				zlog.Logger.Error().Err(err).Msg("failed to watch config file")
+			}
+		}()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-signals:
+		case <-changes:
+		}
+
+		next, err := config.Read()
+		if err != nil {
+			zlog.Logger.Error().Err(err).Msg("failed to reload config, keeping the current settings")
+			continue
+		}
+
+		s.apply(next)
+		zlog.Logger.Info().Strs("sections", reloadable).Msg("reloaded config")
+
+		if changed := restartSections(prev, next); len(changed) > 0 {
+			zlog.Logger.Warn().Strs("sections", changed).Msg("changed config sections apply on restart")
+		}
+		prev = next
+	}
+}
+
+// restartSections returns the keys of the sections of the configuration other than the reloadable
+// ones that differ between prev and next.
+func restartSections(prev, next *config.Config) []string {
+	a, b := reflect.ValueOf(*prev), reflect.ValueOf(*next)
+
+	var keys []string
+	for i := range a.NumField() {
+		key := a.Type().Field(i).Tag.Get("mapstructure")
+		if slices.Contains(reloadable, key) {
+			continue
+		}
+		if !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
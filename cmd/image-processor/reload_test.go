@@ -0,0 +1,131 @@
+package main
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/wb-go/wbf/retry"
+
+	"github.com/aliskhannn/image-processor/internal/config"
+	"github.com/aliskhannn/image-processor/internal/model"
+	"github.com/aliskhannn/image-processor/internal/processor"
+)
+
+// testConfig returns a configuration setting every reloadable section.
+func testConfig() *config.Config {
+	return &config.Config{
+		Server:    config.Server{HTTPPort: "8080"},
+		Retry:     config.Retry{Attempts: 3, Delay: time.Second, Backoff: 2},
+		Quota:     config.Quota{MaxBytes: 1 << 30, ImagesPerDay: 500, JobsPerHour: 100},
+		Watermark: config.Watermark{Text: "sample", Font: "DejaVuSans", Scale: 0.05},
+		Log:       config.Log{Level: "info"},
+	}
+}
+
+// TestReloadableSections checks that the reloadable sections name sections of the configuration.
+func TestReloadableSections(t *testing.T) {
+	typ := reflect.TypeFor[config.Config]()
+
+	var keys []string
+	for i := range typ.NumField() {
+		keys = append(keys, typ.Field(i).Tag.Get("mapstructure"))
+	}
+
+	for _, key := range reloadable {
+		if !slices.Contains(keys, key) {
+			t.Errorf("reloadable section %q is not a section of the configuration", key)
+		}
+	}
+}
+
+// TestRestartSections checks that only the changed sections other than the reloadable ones are
+// reported, in the order of the configuration.
+func TestRestartSections(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(cfg *config.Config)
+		want   []string
+	}{
+		{
+			name:   "unchanged",
+			change: func(*config.Config) {},
+		},
+		{
+			name: "reloadable sections",
+			change: func(cfg *config.Config) {
+				cfg.Retry.Attempts = 5
+				cfg.Quota.JobsPerHour = 10
+				cfg.Watermark.Text = "other"
+				cfg.Log.Level = "debug"
+			},
+		},
+		{
+			name: "restart sections",
+			change: func(cfg *config.Config) {
+				cfg.Reload.WatchFile = true
+				cfg.Server.HTTPPort = "9090"
+				cfg.Log.Level = "debug"
+			},
+			want: []string{"server", "reload"},
+		},
+		{
+			name: "nested slice",
+			change: func(cfg *config.Config) {
+				cfg.SQS.RetryDelays = []time.Duration{time.Minute}
+			},
+			want: []string{"sqs"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev, next := testConfig(), testConfig()
+			tt.change(next)
+
+			if got := restartSections(prev, next); !slices.Equal(got, tt.want) {
+				t.Errorf("restartSections() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSettingsApply checks that applying a configuration replaces every reloaded setting.
+func TestSettingsApply(t *testing.T) {
+	level := zerolog.GlobalLevel()
+	t.Cleanup(func() { zerolog.SetGlobalLevel(level) })
+
+	s := newSettings(testConfig())
+	if got := zerolog.GlobalLevel(); got != zerolog.InfoLevel {
+		t.Fatalf("initial log level = %v, want %v", got, zerolog.InfoLevel)
+	}
+
+	next := testConfig()
+	next.Retry = config.Retry{Attempts: 5, Delay: 2 * time.Second, Backoff: 1.5}
+	next.Quota = config.Quota{MaxBytes: 1 << 20, ImagesPerDay: 10, JobsPerHour: 5}
+	next.Watermark = config.Watermark{Text: "other", Font: "Custom", Scale: 0.1}
+	next.Log.Level = "warn"
+	s.apply(next)
+
+	if got, want := s.retry.Load(), (retry.Strategy{Attempts: 5, Delay: 2 * time.Second, Backoff: 1.5}); got != want {
+		t.Errorf("retry = %+v, want %+v", got, want)
+	}
+	if got, want := s.quota.Load(), (model.QuotaLimits{MaxBytes: 1 << 20, ImagesPerDay: 10, JobsPerHour: 5}); got != want {
+		t.Errorf("quota = %+v, want %+v", got, want)
+	}
+	if got, want := s.watermark.Load(), (processor.Watermark{Text: "other", Font: "Custom", Scale: 0.1}); got != want {
+		t.Errorf("watermark = %+v, want %+v", got, want)
+	}
+	if got := zerolog.GlobalLevel(); got != zerolog.WarnLevel {
+		t.Errorf("log level = %v, want %v", got, zerolog.WarnLevel)
+	}
+
+	// An empty level falls back to info.
+	next.Log.Level = ""
+	s.apply(next)
+	if got := zerolog.GlobalLevel(); got != zerolog.InfoLevel {
+		t.Errorf("log level = %v, want %v", got, zerolog.InfoLevel)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/wb-go/wbf/retry"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/image-processor/internal/config"
+	filestorage "github.com/aliskhannn/image-processor/internal/storage"
+)
+
+// connect calls dial with the startup retry strategy until it succeeds, so that the app waits for
+// its dependencies when started along with them (e.g. by docker compose) instead of crash-looping.
+// dial is expected to release what it opened when failing. Retrying stops once ctx is canceled.
+func connect[T any](ctx context.Context, s retry.Strategy, dependency string, dial func() (T, error)) (T, error) {
+	var (
+		conn    T
+		attempt int
+	)
+	s.Attempts = max(s.Attempts, 1)
+
+	err := retry.Do(func() error {
+		// Retrying can't be interrupted, so the remaining attempts are skipped instead.
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		attempt++
+		c, err := dial()
+		if err != nil {
+			zlog.Logger.Warn().Err(err).
+				Str("dependency", dependency).
+				Int("attempt", attempt).
+				Int("attempts", s.Attempts).
+				Msg("dependency unavailable, retrying")
+			return err
+		}
+
+		conn = c
+		return nil
+	}, s)
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		var zero T
+		return zero, fmt.Errorf("connect to %s: %w", dependency, err)
+	}
+
+	return conn, nil
+}
+
+// dialStorage creates a storage backend with newStorage and checks that it is reachable.
+func dialStorage(
+	ctx context.Context,
+	cfg config.Storage,
+	newStorage func(context.Context, config.Storage) (filestorage.Storage, error),
+) (filestorage.Storage, error) {
+	s, err := newStorage(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Ping(ctx); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
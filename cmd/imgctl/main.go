@@ -0,0 +1,380 @@
+// Command imgctl is a command-line client for a running image processor instance.
+//
+// Usage:
+//
+//	imgctl [-server URL] [-user ID] <command> [flags] [args]
+//
+// Commands:
+//
+//	upload   [-action NAME] [-param key=value]... [-watch] <file|dir>...
+//	status   [-watch] <id>...
+//	download [-o PATH] [-variant] <id>
+//	delete   <id>...
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/aliskhannn/image-processor/internal/client"
+	"github.com/aliskhannn/image-processor/internal/model"
+)
+
+// pollInterval is the delay between status requests while watching jobs.
+const pollInterval = time.Second
+
+// imageExts lists the file extensions picked up when uploading directories.
+var imageExts = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true,
+}
+
+// params collects repeated -param key=value flags.
+type params map[string]string
+
+// String implements flag.Value.
+func (p params) String() string {
+	pairs := make([]string, 0, len(p))
+	for k, v := range p {
+		pairs = append(pairs, k+"="+v)
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+// Set implements flag.Value.
+func (p params) Set(s string) error {
+	k, v, ok := strings.Cut(s, "=")
+	if !ok || k == "" {
+		return fmt.Errorf("expected key=value, got %q", s)
+	}
+	p[k] = v
+
+	return nil
+}
+
+func main() {
+	server := flag.String("server", envOr("IMGCTL_SERVER", "http://localhost:8080"), "image processor base URL")
+	user := flag.String("user", os.Getenv("IMGCTL_USER"), "user ID sent as X-User-ID")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	c := client.New(*server, *user)
+	args := flag.Args()[1:]
+
+	var err error
+	switch cmd := flag.Arg(0); cmd {
+	case "upload":
+		err = upload(ctx, c, args)
+	case "status":
+		err = status(ctx, c, args)
+	case "download":
+		err = download(ctx, c, args)
+	case "delete":
+		err = remove(ctx, c, args)
+	default:
+		err = fmt.Errorf("unknown command %q", cmd)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "imgctl:", err)
+		os.Exit(1)
+	}
+}
+
+// usage prints the command-line help.
+func usage() {
+	fmt.Fprint(os.Stderr, `Usage: imgctl [-server URL] [-user ID] <command> [flags] [args]
+
+Commands:
+  upload   [-action NAME] [-param key=value]... [-watch] <file|dir>...
+  status   [-watch] <id>...
+  download [-o PATH] [-variant] <id>
+  delete   <id>...
+
+Global flags:
+`)
+	flag.PrintDefaults()
+}
+
+// upload uploads files and image files found in directories, printing one line per file.
+func upload(ctx context.Context, c *client.Client, args []string) error {
+	fset := flag.NewFlagSet("upload", flag.ExitOnError)
+	action := fset.String("action", "resize", "processing action")
+	p := params{}
+	fset.Var(p, "param", "action parameter as key=value (repeatable)")
+	watch := fset.Bool("watch", false, "wait until all uploaded images are processed")
+	_ = fset.Parse(args)
+
+	files, err := collectFiles(fset.Args())
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return errors.New("upload: no files to upload")
+	}
+
+	var (
+		ids    []uuid.UUID
+		failed int
+	)
+
+	for _, path := range files {
+		res, err := c.Upload(ctx, path, model.Action{Name: *action, Params: p})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\terror: %v\n", path, err)
+			failed++
+			continue
+		}
+
+		fmt.Printf("%s\t%s\n", path, res.ID)
+		ids = append(ids, *res.ID)
+	}
+
+	if *watch {
+		if err := watchJobs(ctx, c, ids); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("upload: %d of %d files failed", failed, len(files))
+	}
+
+	return nil
+}
+
+// status prints the processing status of images, optionally until they finish.
+func status(ctx context.Context, c *client.Client, args []string) error {
+	fset := flag.NewFlagSet("status", flag.ExitOnError)
+	watch := fset.Bool("watch", false, "wait until the images are processed")
+	_ = fset.Parse(args)
+
+	ids, err := parseIDs(fset.Args())
+	if err != nil {
+		return err
+	}
+
+	if *watch {
+		return watchJobs(ctx, c, ids)
+	}
+
+	for _, id := range ids {
+		st, err := c.Status(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		printStatus(st)
+	}
+
+	return nil
+}
+
+// download saves an image, or its first processed variant, to a local file.
+func download(ctx context.Context, c *client.Client, args []string) error {
+	fset := flag.NewFlagSet("download", flag.ExitOnError)
+	out := fset.String("o", "", "output file (default: <id> with the stored extension)")
+	variant := fset.Bool("variant", false, "download the processed variant instead of the original")
+	_ = fset.Parse(args)
+
+	ids, err := parseIDs(fset.Args())
+	if err != nil {
+		return err
+	}
+	if len(ids) != 1 {
+		return errors.New("download: expected exactly one id")
+	}
+
+	id, ext := ids[0], ""
+	if *variant {
+		derived, err := c.Derived(ctx, id)
+		if err != nil {
+			return err
+		}
+		if len(derived) == 0 {
+			return errors.New("download: image has no processed variants yet")
+		}
+
+		id, ext = derived[0].ID, filepath.Ext(derived[0].Path)
+	}
+
+	path := *out
+	if path == "" {
+		path = id.String() + ext
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("download: failed to create file: %w", err)
+	}
+
+	if err := c.Download(ctx, id, f); err != nil {
+		_ = f.Close()
+		_ = os.Remove(path)
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("download: failed to write file: %w", err)
+	}
+
+	fmt.Println(path)
+
+	return nil
+}
+
+// remove deletes images along with their derived images.
+func remove(ctx context.Context, c *client.Client, args []string) error {
+	ids, err := parseIDs(args)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := c.Delete(ctx, id); err != nil {
+			return fmt.Errorf("%s: %w", id, err)
+		}
+
+		fmt.Printf("%s\tdeleted\n", id)
+	}
+
+	return nil
+}
+
+// watchJobs polls the status of the images until each one is done or failed.
+// Every stage change is printed; an error is returned if any job failed.
+func watchJobs(ctx context.Context, c *client.Client, ids []uuid.UUID) error {
+	last := make(map[uuid.UUID]string, len(ids))
+	pending := append([]uuid.UUID(nil), ids...)
+	failed := 0
+
+	for len(pending) > 0 {
+		next := pending[:0]
+		for _, id := range pending {
+			st, err := c.Status(ctx, id)
+			if err != nil {
+				return err
+			}
+
+			if st.Stage != last[id] {
+				printStatus(st)
+				last[id] = st.Stage
+			}
+
+			switch st.Stage {
+			case model.StageDone:
+			case model.StageFailed:
+				failed++
+			default:
+				next = append(next, id)
+			}
+		}
+		pending = next
+
+		if len(pending) == 0 {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d jobs failed", failed, len(ids))
+	}
+
+	return nil
+}
+
+// printStatus prints a single status line.
+func printStatus(st model.ProcessingStatus) {
+	line := fmt.Sprintf("%s\t%s\t%s\tattempts=%d", st.ID, st.Status, st.Stage, st.Attempts)
+	if st.LastError != "" {
+		line += "\terror=" + st.LastError
+	}
+
+	fmt.Println(line)
+}
+
+// collectFiles expands directories into the image files they contain.
+func collectFiles(paths []string) ([]string, error) {
+	var files []string
+
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+
+		err = filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && imageExts[strings.ToLower(filepath.Ext(path))] {
+				files = append(files, path)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// parseIDs parses image IDs from command arguments.
+func parseIDs(args []string) ([]uuid.UUID, error) {
+	if len(args) == 0 {
+		return nil, errors.New("expected at least one image id")
+	}
+
+	ids := make([]uuid.UUID, 0, len(args))
+	for _, a := range args {
+		id, err := uuid.Parse(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid id %q: %w", a, err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// envOr returns the value of the environment variable or def if it is unset.
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+
+	return def
+}
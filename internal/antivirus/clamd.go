@@ -0,0 +1,118 @@
+package antivirus
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInfected is returned when the scanned content matches a virus signature.
+	ErrInfected = errors.New("file is infected")
+	// ErrUnavailable is returned when the scan could not be completed.
+	// Callers are expected to fail closed and reject the upload.
+	ErrUnavailable = errors.New("virus scanner unavailable")
+)
+
+// chunkSize is the size of the chunks streamed to clamd.
+const chunkSize = 64 << 10
+
+// Clamd scans content with a ClamAV daemon using the INSTREAM command.
+type Clamd struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// NewClamd creates a new Clamd scanner.
+// - network: "tcp" or "unix"
+// - address: clamd address, e.g. "clamav:3310" or "/run/clamav/clamd.sock"
+// - timeout: max time a single scan may take
+func NewClamd(network, address string, timeout time.Duration) *Clamd {
+	return &Clamd{
+		network: network,
+		address: address,
+		timeout: timeout,
+	}
+}
+
+// Scan streams src to clamd. It returns nil if the content is clean, an error wrapping
+// ErrInfected with the signature name if a virus was found, and an error wrapping
+// ErrUnavailable if clamd could not be reached or did not produce a verdict.
+func (c *Clamd) Scan(ctx context.Context, src io.Reader) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return fmt.Errorf("%w: failed to connect: %v", ErrUnavailable, err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if err := stream(conn, src); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil {
+		return fmt.Errorf("%w: failed to read reply: %v", ErrUnavailable, err)
+	}
+	reply = strings.TrimSpace(strings.TrimSuffix(reply, "\x00"))
+
+	switch {
+	case strings.HasSuffix(reply, " OK"):
+		return nil
+	case strings.HasSuffix(reply, " FOUND"):
+		signature := strings.TrimSuffix(strings.TrimPrefix(reply, "stream: "), " FOUND")
+		return fmt.Errorf("%w: %s", ErrInfected, signature)
+	default:
+		return fmt.Errorf("%w: unexpected reply: %s", ErrUnavailable, reply)
+	}
+}
+
+// stream sends src to clamd as a sequence of length-prefixed chunks terminated by an empty one.
+func stream(w io.Writer, src io.Reader) error {
+	if _, err := io.WriteString(w, "zINSTREAM\x00"); err != nil {
+		return fmt.Errorf("failed to send command: %w", err)
+	}
+
+	buf := make([]byte, chunkSize)
+	size := make([]byte, 4)
+
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, werr := w.Write(size); werr != nil {
+				return fmt.Errorf("failed to send chunk size: %w", werr)
+			}
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return fmt.Errorf("failed to send chunk: %w", werr)
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read content: %w", err)
+		}
+	}
+
+	if _, err := w.Write([]byte{0, 0, 0, 0}); err != nil {
+		return fmt.Errorf("failed to finish stream: %w", err)
+	}
+
+	return nil
+}
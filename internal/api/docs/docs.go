@@ -0,0 +1,41 @@
+// Package docs serves the OpenAPI specification of the HTTP API and a Swagger UI for browsing it.
+package docs
+
+import (
+	"embed"
+	"net/http"
+
+	"github.com/wb-go/wbf/ginext"
+)
+
+// spec is the OpenAPI 3 specification of the HTTP API.
+// Keep it in sync with the routes in the router package when adding or changing endpoints;
+// the router tests fail on routes missing from it and on paths no longer routed.
+//
+//go:embed openapi.json
+var spec []byte
+
+// ui is the Swagger UI page that renders spec.
+//
+//go:embed swagger.html
+var ui []byte
+
+// assets are the scripts and styles of ui: a pinned swagger-ui-dist release, see swagger-ui/README.md.
+//
+//go:embed swagger-ui/swagger-ui.css swagger-ui/swagger-ui-bundle.js
+var assets embed.FS
+
+// Spec serves the OpenAPI specification as JSON.
+func Spec(c *ginext.Context) {
+	c.Data(http.StatusOK, "application/json; charset=utf-8", spec)
+}
+
+// UI serves the Swagger UI page.
+func UI(c *ginext.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", ui)
+}
+
+// Asset serves the Swagger UI script or stylesheet named by the "asset" path parameter.
+func Asset(c *ginext.Context) {
+	c.FileFromFS("swagger-ui/"+c.Param("asset"), http.FS(assets))
+}
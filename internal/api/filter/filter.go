@@ -0,0 +1,156 @@
+// Package filter parses the image filters shared by the listing and search endpoints.
+package filter
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/ginext"
+
+	"github.com/aliskhannn/image-processor/internal/model"
+)
+
+// Page sizes of image listings.
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+// Parse builds an image filter from the query parameters of a list or search request.
+func Parse(c *ginext.Context) (model.ImageFilter, error) {
+	f := model.ImageFilter{
+		Query:        strings.TrimSpace(c.Query("q")),
+		Status:       c.Query("status"),
+		Action:       c.Query("action"),
+		Owner:        strings.TrimSpace(c.Query("owner")),
+		Filename:     c.Query("filename"),
+		Title:        c.Query("title"),
+		Tags:         queryList(c, "tags"),
+		AnyTags:      queryList(c, "any_tags"),
+		ExcludedTags: queryList(c, "exclude_tags"),
+		Kind:         c.Query("kind"),
+		Formats:      queryList(c, "format"),
+		Limit:        DefaultLimit,
+	}
+
+	var err error
+
+	for i, format := range f.Formats {
+		if format == "jpg" {
+			f.Formats[i] = "jpeg"
+		}
+	}
+	if f.Kind != "" && f.Kind != model.KindOriginal && f.Kind != model.KindDerived {
+		return model.ImageFilter{}, fmt.Errorf("invalid kind: expected %s or %s", model.KindOriginal, model.KindDerived)
+	}
+	if v := c.Query("min_size"); v != "" {
+		if f.MinSize, err = strconv.ParseInt(v, 10, 64); err != nil || f.MinSize < 0 {
+			return model.ImageFilter{}, fmt.Errorf("invalid min_size")
+		}
+	}
+	if v := c.Query("max_size"); v != "" {
+		if f.MaxSize, err = strconv.ParseInt(v, 10, 64); err != nil || f.MaxSize < 0 {
+			return model.ImageFilter{}, fmt.Errorf("invalid max_size")
+		}
+	}
+	if f.MaxSize > 0 && f.MinSize > f.MaxSize {
+		return model.ImageFilter{}, fmt.Errorf("min_size must not exceed max_size")
+	}
+	if v := c.Query("from"); v != "" {
+		if f.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return model.ImageFilter{}, fmt.Errorf("invalid from: expected RFC3339 timestamp")
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if f.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return model.ImageFilter{}, fmt.Errorf("invalid to: expected RFC3339 timestamp")
+		}
+	}
+	if v := c.Query("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit <= 0 {
+			return model.ImageFilter{}, fmt.Errorf("invalid limit")
+		}
+	}
+	if v := c.Query("offset"); v != "" {
+		if f.Offset, err = strconv.Atoi(v); err != nil || f.Offset < 0 {
+			return model.ImageFilter{}, fmt.Errorf("invalid offset")
+		}
+	}
+	if v := c.Query("cursor"); v != "" {
+		switch {
+		case f.Offset > 0:
+			return model.ImageFilter{}, fmt.Errorf("cursor and offset are mutually exclusive")
+		case f.Query != "":
+			return model.ImageFilter{}, fmt.Errorf("cursor is not supported with q, results are ranked by relevance")
+		}
+
+		if f.After, err = decodeCursor(v); err != nil {
+			return model.ImageFilter{}, fmt.Errorf("invalid cursor")
+		}
+	}
+	if f.Limit > MaxLimit {
+		f.Limit = MaxLimit
+	}
+
+	return f, nil
+}
+
+// queryList returns the values of a query parameter given either repeated or comma-separated.
+func queryList(c *ginext.Context, key string) []string {
+	var values []string
+	for _, v := range c.QueryArray(key) {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				values = append(values, strings.ToLower(part))
+			}
+		}
+	}
+
+	return values
+}
+
+// NextCursor returns the cursor continuing a listing after the given page,
+// or an empty string if the page is the last one or ranked by relevance.
+func NextCursor(f model.ImageFilter, images []model.Image) string {
+	if f.Query != "" || len(images) < f.Limit {
+		return ""
+	}
+
+	last := images[len(images)-1]
+
+	return encodeCursor(model.Cursor{CreatedAt: last.CreatedAt, ID: last.ID})
+}
+
+// encodeCursor encodes a listing position as an opaque URL-safe token.
+func encodeCursor(cur model.Cursor) string {
+	raw := cur.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + cur.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor decodes a token produced by encodeCursor.
+func decodeCursor(token string) (*model.Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+
+	ts, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, errors.New("malformed cursor")
+	}
+
+	var cur model.Cursor
+	if cur.CreatedAt, err = time.Parse(time.RFC3339Nano, ts); err != nil {
+		return nil, err
+	}
+	if cur.ID, err = uuid.Parse(id); err != nil {
+		return nil, err
+	}
+
+	return &cur, nil
+}
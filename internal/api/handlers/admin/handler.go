@@ -0,0 +1,377 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/ginext"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/image-processor/internal/api/filter"
+	"github.com/aliskhannn/image-processor/internal/api/respond"
+	"github.com/aliskhannn/image-processor/internal/model"
+	"github.com/aliskhannn/image-processor/internal/repository/image"
+	adminsvc "github.com/aliskhannn/image-processor/internal/service/admin"
+)
+
+// service defines the interface for operator job management.
+type service interface {
+	ListJobs(ctx context.Context, f model.JobFilter) ([]model.Job, error)
+	ListImages(ctx context.Context, f model.ImageFilter) ([]model.Image, int, error)
+	RetryJob(ctx context.Context, id uuid.UUID) error
+	PurgeDerived(ctx context.Context, id uuid.UUID) (int64, error)
+	Stats(ctx context.Context, window time.Duration) (model.Stats, error)
+	LatencySLO() model.SLOReport
+	StorageUsage(ctx context.Context, f model.UsageFilter) (model.UsageReport, error)
+	ListDeadLetters(ctx context.Context, includeReplayed bool, limit, offset int) ([]model.DeadLetter, error)
+	ReplayDeadLetter(ctx context.Context, id uuid.UUID) error
+	ListAuditEvents(ctx context.Context, f model.AuditFilter) ([]model.AuditEvent, error)
+	PauseConsumers() model.ConsumerState
+	ResumeConsumers() model.ConsumerState
+	ConsumerState() model.ConsumerState
+}
+
+const (
+	defaultJobsLimit = 50
+	maxJobsLimit     = 500
+
+	defaultStatsWindow = 24 * time.Hour
+)
+
+// Handler provides HTTP handlers for the admin API.
+type Handler struct {
+	service service
+}
+
+// NewHandler creates a new Handler with the given service.
+func NewHandler(s service) *Handler {
+	return &Handler{service: s}
+}
+
+// Jobs lists failed or stuck processing jobs selected by the "state" query parameter.
+func (h *Handler) Jobs(c *ginext.Context) {
+	f := model.JobFilter{
+		State: c.DefaultQuery("state", model.JobStateFailed),
+		Limit: defaultJobsLimit,
+	}
+
+	if f.State != model.JobStateFailed && f.State != model.JobStateStuck {
+		respond.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid state: expected failed or stuck"))
+		return
+	}
+
+	var err error
+
+	if v := c.Query("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit <= 0 {
+			respond.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid limit"))
+			return
+		}
+	}
+	if v := c.Query("offset"); v != "" {
+		if f.Offset, err = strconv.Atoi(v); err != nil || f.Offset < 0 {
+			respond.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid offset"))
+			return
+		}
+	}
+	if f.Limit > maxJobsLimit {
+		f.Limit = maxJobsLimit
+	}
+
+	jobs, err := h.service.ListJobs(c.Request.Context(), f)
+	if err != nil {
+		zlog.Logger.Err(err).Msg("failed to list jobs")
+		respond.Fail(c, http.StatusInternalServerError, fmt.Errorf("failed to list jobs: %v", err))
+		return
+	}
+
+	respond.OK(c, map[string]interface{}{
+		"jobs":   jobs,
+		"limit":  f.Limit,
+		"offset": f.Offset,
+	})
+}
+
+// Images lists images of all owners with the filters of the public listing and search,
+// e.g. the failed originals of an owner, together with the total number of matches.
+func (h *Handler) Images(c *ginext.Context) {
+	f, err := filter.Parse(c)
+	if err != nil {
+		respond.Fail(c, http.StatusBadRequest, err)
+		return
+	}
+	// Unlike owners, admins can see soft-deleted images.
+	f.IncludeDeleted, _ = strconv.ParseBool(c.Query("include_deleted"))
+
+	images, total, err := h.service.ListImages(c.Request.Context(), f)
+	if err != nil {
+		zlog.Logger.Err(err).Msg("failed to list images")
+		respond.Fail(c, http.StatusInternalServerError, fmt.Errorf("failed to list images: %v", err))
+		return
+	}
+
+	respond.OK(c, map[string]interface{}{
+		"images":      images,
+		"total":       total,
+		"next_cursor": filter.NextCursor(f, images),
+		"limit":       f.Limit,
+		"offset":      f.Offset,
+	})
+}
+
+// Retry re-enqueues the processing job of an image.
+func (h *Handler) Retry(c *ginext.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.RetryJob(c.Request.Context(), id); err != nil {
+		switch {
+		case errors.Is(err, image.ErrImageNotFound):
+			respond.Fail(c, http.StatusNotFound, fmt.Errorf("image not found"))
+		case errors.Is(err, adminsvc.ErrJobNotRetryable):
+			respond.Fail(c, http.StatusConflict, err)
+		default:
+			zlog.Logger.Err(err).Msg("failed to retry job")
+			respond.Fail(c, http.StatusInternalServerError, fmt.Errorf("failed to retry job: %v", err))
+		}
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+// PurgeDerived deletes all images derived from an original, keeping the original itself.
+func (h *Handler) PurgeDerived(c *ginext.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	n, err := h.service.PurgeDerived(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, image.ErrImageNotFound) {
+			respond.Fail(c, http.StatusNotFound, fmt.Errorf("image not found"))
+			return
+		}
+
+		zlog.Logger.Err(err).Msg("failed to purge derived images")
+		respond.Fail(c, http.StatusInternalServerError, fmt.Errorf("failed to purge derived images: %v", err))
+		return
+	}
+
+	respond.OK(c, map[string]interface{}{
+		"purged": n,
+	})
+}
+
+// Stats returns job counts by status and action, throughput, mean processing time, failure rate and the jobs
+// finished per hour over the window given by the "window" query parameter (e.g. "24h"), along with the queue
+// depth, storage usage and recent errors, for a lightweight ops dashboard.
+func (h *Handler) Stats(c *ginext.Context) {
+	window := defaultStatsWindow
+	if v := c.Query("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			respond.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid window: expected duration like 24h"))
+			return
+		}
+		window = d
+	}
+
+	stats, err := h.service.Stats(c.Request.Context(), window)
+	if err != nil {
+		zlog.Logger.Err(err).Msg("failed to get stats")
+		respond.Fail(c, http.StatusInternalServerError, fmt.Errorf("failed to get stats: %v", err))
+		return
+	}
+
+	respond.OK(c, stats)
+}
+
+// SLO reports the p50/p95/p99 processing time of each action over the rolling SLO window with
+// the burn rate of its latency objective; actions burning their error budget are listed as alerts.
+func (h *Handler) SLO(c *ginext.Context) {
+	respond.OK(c, h.service.LatencySLO())
+}
+
+// Usage reports the bytes and objects stored per owner and top-level storage directory,
+// optionally filtered by "owner" and "subdir" and grouped by one of them with "group_by".
+func (h *Handler) Usage(c *ginext.Context) {
+	f := model.UsageFilter{
+		Owner:   c.Query("owner"),
+		Subdir:  c.Query("subdir"),
+		GroupBy: c.Query("group_by"),
+	}
+
+	if f.GroupBy != "" && f.GroupBy != model.UsageByOwner && f.GroupBy != model.UsageBySubdir {
+		respond.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid group_by: expected owner or subdir"))
+		return
+	}
+
+	report, err := h.service.StorageUsage(c.Request.Context(), f)
+	if err != nil {
+		zlog.Logger.Err(err).Msg("failed to get storage usage")
+		respond.Fail(c, http.StatusInternalServerError, fmt.Errorf("failed to get storage usage: %v", err))
+		return
+	}
+
+	respond.OK(c, report)
+}
+
+// DeadLetters lists messages that failed processing after retries, most recent first.
+// Replayed ones are included only with "replayed=true".
+func (h *Handler) DeadLetters(c *ginext.Context) {
+	limit, offset := defaultJobsLimit, 0
+	includeReplayed, _ := strconv.ParseBool(c.Query("replayed"))
+
+	var err error
+
+	if v := c.Query("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			respond.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid limit"))
+			return
+		}
+	}
+	if v := c.Query("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			respond.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid offset"))
+			return
+		}
+	}
+	if limit > maxJobsLimit {
+		limit = maxJobsLimit
+	}
+
+	letters, err := h.service.ListDeadLetters(c.Request.Context(), includeReplayed, limit, offset)
+	if err != nil {
+		zlog.Logger.Err(err).Msg("failed to list dead letters")
+		respond.Fail(c, http.StatusInternalServerError, fmt.Errorf("failed to list dead letters: %v", err))
+		return
+	}
+
+	respond.OK(c, map[string]interface{}{
+		"dead_letters": letters,
+		"limit":        limit,
+		"offset":       offset,
+	})
+}
+
+// Audit lists the audit log events, newest first, filtered by the "image_id", "event", "actor"
+// and "from"/"to" (RFC3339) query parameters.
+func (h *Handler) Audit(c *ginext.Context) {
+	f := model.AuditFilter{
+		Event: c.Query("event"),
+		Actor: c.Query("actor"),
+		Limit: defaultJobsLimit,
+	}
+
+	var err error
+
+	if v := c.Query("image_id"); v != "" {
+		if f.ImageID, err = uuid.Parse(v); err != nil {
+			respond.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid image_id"))
+			return
+		}
+	}
+	if v := c.Query("from"); v != "" {
+		if f.From, err = time.Parse(time.RFC3339, v); err != nil {
+			respond.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid from: expected RFC3339 timestamp"))
+			return
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if f.To, err = time.Parse(time.RFC3339, v); err != nil {
+			respond.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid to: expected RFC3339 timestamp"))
+			return
+		}
+	}
+	if v := c.Query("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit <= 0 {
+			respond.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid limit"))
+			return
+		}
+	}
+	if v := c.Query("offset"); v != "" {
+		if f.Offset, err = strconv.Atoi(v); err != nil || f.Offset < 0 {
+			respond.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid offset"))
+			return
+		}
+	}
+	if f.Limit > maxJobsLimit {
+		f.Limit = maxJobsLimit
+	}
+
+	events, err := h.service.ListAuditEvents(c.Request.Context(), f)
+	if err != nil {
+		zlog.Logger.Err(err).Msg("failed to list audit events")
+		respond.Fail(c, http.StatusInternalServerError, fmt.Errorf("failed to list audit events: %v", err))
+		return
+	}
+
+	respond.OK(c, map[string]interface{}{
+		"events": events,
+		"limit":  f.Limit,
+		"offset": f.Offset,
+	})
+}
+
+// ReplayDeadLetter re-enqueues the processing task held by a dead letter.
+func (h *Handler) ReplayDeadLetter(c *ginext.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.ReplayDeadLetter(c.Request.Context(), id); err != nil {
+		switch {
+		case errors.Is(err, image.ErrDeadLetterNotFound):
+			respond.Fail(c, http.StatusNotFound, fmt.Errorf("dead letter not found"))
+		case errors.Is(err, image.ErrImageNotFound):
+			respond.Fail(c, http.StatusNotFound, fmt.Errorf("image of the dead letter not found"))
+		case errors.Is(err, adminsvc.ErrAlreadyReplayed):
+			respond.Fail(c, http.StatusConflict, err)
+		case errors.Is(err, adminsvc.ErrInvalidPayload):
+			respond.Fail(c, http.StatusUnprocessableEntity, err)
+		default:
+			zlog.Logger.Err(err).Msg("failed to replay dead letter")
+			respond.Fail(c, http.StatusInternalServerError, fmt.Errorf("failed to replay dead letter: %v", err))
+		}
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+// Consumers reports whether queue consumption is paused and how many messages are in flight.
+func (h *Handler) Consumers(c *ginext.Context) {
+	respond.OK(c, h.service.ConsumerState())
+}
+
+// PauseConsumers stops queue consumers from fetching new messages while in-flight ones finish.
+func (h *Handler) PauseConsumers(c *ginext.Context) {
+	respond.OK(c, h.service.PauseConsumers())
+}
+
+// ResumeConsumers lets paused queue consumers continue fetching messages.
+func (h *Handler) ResumeConsumers(c *ginext.Context) {
+	respond.OK(c, h.service.ResumeConsumers())
+}
+
+// parseID parses the ID path parameter and responds with 400 if it is invalid.
+func parseID(c *ginext.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		zlog.Logger.Err(err).Msg("failed to parse id")
+		respond.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid id: %v", err))
+		return uuid.Nil, false
+	}
+
+	return id, true
+}
@@ -0,0 +1,67 @@
+package asset
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/ginext"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/image-processor/internal/api/respond"
+	assetsvc "github.com/aliskhannn/image-processor/internal/service/asset"
+)
+
+// maxLUTSize limits the size of uploaded LUT files.
+const maxLUTSize = 32 << 20
+
+// service defines the interface for asset operations.
+type service interface {
+	SaveLUT(ctx context.Context, file io.Reader) (uuid.UUID, error)
+}
+
+// Handler provides HTTP handlers for processing assets.
+type Handler struct {
+	service service
+}
+
+// NewHandler creates a new Handler with the given service.
+func NewHandler(s service) *Handler {
+	return &Handler{service: s}
+}
+
+// UploadLUT handles uploading a .cube 3D LUT used by the lut action.
+func (h *Handler) UploadLUT(c *ginext.Context) {
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		zlog.Logger.Err(err).Msg("failed to retrieve the lut file")
+		respond.Fail(c, http.StatusBadRequest, fmt.Errorf("failed to retrieve the file"))
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxLUTSize {
+		respond.Fail(c, http.StatusRequestEntityTooLarge, fmt.Errorf("lut file is too large"))
+		return
+	}
+
+	id, err := h.service.SaveLUT(c.Request.Context(), file)
+	if err != nil {
+		if errors.Is(err, assetsvc.ErrInvalidAsset) {
+			respond.Fail(c, http.StatusBadRequest, err)
+			return
+		}
+
+		zlog.Logger.Err(err).Msg("failed to save the lut")
+		respond.Fail(c, http.StatusInternalServerError, fmt.Errorf("failed to save the lut: %v", err))
+		return
+	}
+
+	respond.Created(c, map[string]interface{}{
+		"id":       id,
+		"filename": header.Filename,
+	})
+}
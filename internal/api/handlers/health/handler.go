@@ -0,0 +1,45 @@
+package health
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/wb-go/wbf/ginext"
+
+	"github.com/aliskhannn/image-processor/internal/api/respond"
+	"github.com/aliskhannn/image-processor/internal/health"
+)
+
+// checker defines the interface for checking dependency connectivity.
+type checker interface {
+	Check(ctx context.Context) health.Report
+}
+
+// Handler provides HTTP handlers for liveness and readiness probes.
+type Handler struct {
+	checker checker
+}
+
+// NewHandler creates a new Handler with the given checker.
+func NewHandler(c checker) *Handler {
+	return &Handler{checker: c}
+}
+
+// Live reports that the process is up. It does not touch any dependency,
+// so a slow database doesn't get the pod restarted.
+func (h *Handler) Live(c *ginext.Context) {
+	respond.JSON(c, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Ready reports whether Postgres, MinIO and Kafka are reachable.
+// It responds with 503 and the failing checks if any of them is not.
+func (h *Handler) Ready(c *ginext.Context) {
+	report := h.checker.Check(c.Request.Context())
+
+	status := http.StatusOK
+	if !report.Ready {
+		status = http.StatusServiceUnavailable
+	}
+
+	respond.JSON(c, status, report)
+}
@@ -1,38 +1,98 @@
 package image
 
 import (
+	"archive/zip"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 	"github.com/wb-go/wbf/ginext"
 	"github.com/wb-go/wbf/zlog"
 
+	"github.com/aliskhannn/image-processor/internal/antivirus"
+	"github.com/aliskhannn/image-processor/internal/api/filter"
 	"github.com/aliskhannn/image-processor/internal/api/respond"
+	"github.com/aliskhannn/image-processor/internal/cdn"
+	"github.com/aliskhannn/image-processor/internal/logging"
+	"github.com/aliskhannn/image-processor/internal/middleware"
 	"github.com/aliskhannn/image-processor/internal/model"
+	"github.com/aliskhannn/image-processor/internal/processor"
 	"github.com/aliskhannn/image-processor/internal/repository/image"
+	imagesvc "github.com/aliskhannn/image-processor/internal/service/image"
 )
 
 // service defines the interface for image-related operations.
 type service interface {
-	SaveImage(ctx context.Context, subdir, filename string, file io.Reader, action model.Action) (uuid.UUID, string, error)
+	SaveImage(
+		ctx context.Context,
+		subdir, filename string,
+		file io.Reader,
+		action model.Action,
+		opts model.UploadOptions,
+	) (model.SavedUpload, error)
 	GetImage(ctx context.Context, id uuid.UUID) (model.Image, io.ReadCloser, error)
+	GetMeta(ctx context.Context, id uuid.UUID) (model.Image, error)
 	DeleteImage(ctx context.Context, id uuid.UUID) error
+	ListChanges(ctx context.Context, since int64, limit int) ([]model.Change, error)
+	DetectWatermark(ctx context.Context, file io.Reader) (string, error)
+	ListImages(ctx context.Context, f model.ImageFilter) ([]model.Image, error)
+	SearchImages(ctx context.Context, f model.ImageFilter) ([]model.Image, int, error)
+	ListDerived(ctx context.Context, id uuid.UUID) ([]model.Image, error)
+	GetStatus(ctx context.Context, id uuid.UUID) (model.ProcessingStatus, error)
+	WatchStatus(ctx context.Context, id uuid.UUID) (<-chan model.ProcessingStatus, error)
+	GetBundle(ctx context.Context, id uuid.UUID) ([]model.Image, error)
+	OpenFile(ctx context.Context, path string) (io.ReadCloser, error)
+	Transform(ctx context.Context, id uuid.UUID, opts processor.TransformOptions) (io.ReadCloser, error)
+	GetQuotaUsage(ctx context.Context, owner string) (model.QuotaUsage, error)
+	UpdateMetadata(ctx context.Context, id uuid.UUID, upd model.MetadataUpdate) (model.Image, error)
+}
+
+const (
+	defaultChangesLimit = 100
+	maxChangesLimit     = 1000
+
+	// eventsKeepAlive is how often an idle status stream sends a comment, keeping proxies from closing it.
+	eventsKeepAlive = 15 * time.Second
+)
+
+// CachePolicy holds the Cache-Control headers of served images.
+// An empty header disables caching of the responses.
+type CachePolicy struct {
+	Image     string // images and variants served by ID
+	Transform string // on-the-fly transforms, which never change under their URL
+}
+
+// actionPolicy defines the interface for checking the actions of uploads before they are stored.
+type actionPolicy interface {
+	Check(action model.Action) error
 }
 
 // Handler provides HTTP handlers for image-related endpoints.
 // It depends on a service interface to perform the business logic.
 type Handler struct {
 	service service
+	cache   CachePolicy
+	actions actionPolicy
 }
 
-// NewHandler creates a new Handler with the given service.
-func NewHandler(s service) *Handler {
-	return &Handler{service: s}
+// NewHandler creates a new Handler with the given service, cache policy of served images
+// and policy of the actions accepted by uploads.
+func NewHandler(s service, cache CachePolicy, actions actionPolicy) *Handler {
+	return &Handler{service: s, cache: cache, actions: actions}
 }
 
 // UploadRequest represents the action and its parameters sent by the client.
@@ -41,27 +101,33 @@ type UploadRequest struct {
 	Params map[string]string `json:"params"`
 }
 
-// Upload handles the HTTP request for uploading an image.
-// It reads the multipart form, saves the uploaded file via the service,
-// enqueues background processing tasks, and responds with the saved file info.
+// UploadResult describes the outcome of saving a single uploaded file.
+type UploadResult struct {
+	ID        *uuid.UUID `json:"id,omitempty"`
+	Filename  string     `json:"filename"`
+	Path      string     `json:"path,omitempty"`
+	Duplicate bool       `json:"duplicate,omitempty"` // an identical original already existed and was returned
+	Error     string     `json:"error,omitempty"`
+}
+
+// Upload handles the HTTP request for uploading one or more images.
+// It reads the multipart form, saves every file sent in the "image" field via the service,
+// enqueues one background processing task per file using the shared actions spec,
+// and responds with the saved file info (an array when several files were sent).
 func (h *Handler) Upload(c *ginext.Context) {
 	// Parse the multipart form with a 10MB max memory limit.
 	if err := c.Request.ParseMultipartForm(10 << 20); err != nil {
 		respond.Fail(c, http.StatusBadRequest, fmt.Errorf("parse multipart form failed: %v", err))
+		return
 	}
 
-	// Retrieve the uploaded file from the form.
-	file, header, err := c.Request.FormFile("image")
-	if err != nil {
-		zlog.Logger.Err(err).Msg("failed to upload the file")
+	// Retrieve the uploaded files from the form.
+	headers := c.Request.MultipartForm.File["image"]
+	if len(headers) == 0 {
+		zlog.Logger.Warn().Msg("no files uploaded")
 		respond.Fail(c, http.StatusBadRequest, fmt.Errorf("failed to retrieve the file"))
 		return
 	}
-	defer file.Close()
-
-	zlog.Logger.Printf("uploaded file: %v", header.Filename)
-	zlog.Logger.Printf("file size: %v", header.Size)
-	zlog.Logger.Printf("MIME header: %v", header.Header)
 
 	// Parse the "actions" JSON field from the form.
 	actionsJSON := c.PostForm("actions")
@@ -84,22 +150,164 @@ func (h *Handler) Upload(c *ginext.Context) {
 		Params: req.Params,
 	}
 
+	// Refuse disabled actions and out-of-bounds parameters before anything is stored or enqueued.
+	if err := h.actions.Check(action); err != nil {
+		respond.Fail(c, http.StatusBadRequest, err)
+		return
+	}
+
+	// Optional webhook notified when processing finishes.
+	opts := model.UploadOptions{
+		CallbackURL: c.PostForm("callback_url"),
+		Owner:       owner(c),
+	}
+	if opts.CallbackURL != "" && !isHTTPURL(opts.CallbackURL) {
+		respond.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid callback_url"))
+		return
+	}
+
+	// Duplicates of existing originals are only processed again on request.
+	opts.Reprocess, _ = strconv.ParseBool(c.PostForm("reprocess"))
+
+	// Interactive uploads can jump ahead of bulk backfills.
+	switch opts.Priority = c.DefaultPostForm("priority", model.PriorityNormal); opts.Priority {
+	case model.PriorityHigh, model.PriorityNormal, model.PriorityLow:
+	default:
+		respond.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid priority: expected high, normal or low"))
+		return
+	}
+
+	// Optional expiry after which the images are deleted, e.g. for temporary shares.
+	if v := c.PostForm("expires_at"); v != "" {
+		expiresAt, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			respond.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid expires_at: expected RFC3339 timestamp"))
+			return
+		}
+		if !expiresAt.After(time.Now()) {
+			respond.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid expires_at: must be in the future"))
+			return
+		}
+
+		opts.ExpiresAt = &expiresAt
+	}
+
+	// Keep the single-file response shape for existing clients.
+	if len(headers) == 1 {
+		res, err := h.saveFile(c.Request.Context(), headers[0], action, opts)
+		if err != nil {
+			if status, ok := uploadErrorStatus(err); ok {
+				respond.Fail(c, status, err)
+				return
+			}
+
+			zlog.Logger.Err(err).Msg("failed to save the image")
+			respond.Fail(c, http.StatusInternalServerError, fmt.Errorf("failed to save the image: %v", err))
+			return
+		}
+
+		respond.OK(c, map[string]interface{}{
+			"id":        res.ID,
+			"filename":  res.Filename,
+			"path":      res.Path,
+			"duplicate": res.Duplicate,
+		})
+		return
+	}
+
+	results := make([]UploadResult, 0, len(headers))
+	for _, header := range headers {
+		res, err := h.saveFile(c.Request.Context(), header, action, opts)
+		if err != nil {
+			zlog.Logger.Err(err).Str("filename", header.Filename).Msg("failed to save the image")
+			res.Error = fmt.Sprintf("failed to save the image: %v", err)
+		}
+
+		results = append(results, res)
+	}
+
+	respond.OK(c, results)
+}
+
+// saveFile saves a single uploaded file via the service and enqueues its processing.
+func (h *Handler) saveFile(
+	ctx context.Context,
+	header *multipart.FileHeader,
+	action model.Action,
+	opts model.UploadOptions,
+) (UploadResult, error) {
+	res := UploadResult{Filename: header.Filename}
+
+	file, err := header.Open()
+	if err != nil {
+		return res, fmt.Errorf("failed to open the file: %w", err)
+	}
+	defer file.Close()
+
+	ctx = logging.With(ctx, logging.Fields{Action: action.Name, Tenant: opts.Owner})
+	logging.FromContext(ctx).Info().
+		Str("filename", header.Filename).
+		Int64("size", header.Size).
+		Str("content_type", header.Header.Get("Content-Type")).
+		Msg("file uploaded")
+
 	// Save the uploaded image via the service.
-	id, dst, err := h.service.SaveImage(c.Request.Context(), "original", header.Filename, file, action)
+	saved, err := h.service.SaveImage(ctx, "original", header.Filename, file, action, opts)
 	if err != nil {
-		zlog.Logger.Err(err).Msg("failed to save the image")
-		respond.Fail(c, http.StatusInternalServerError, fmt.Errorf("failed to save the image: %v", err))
+		return res, err
+	}
+
+	logging.ForImage(ctx, saved.ID).Info().
+		Str("path", saved.Path).
+		Bool("duplicate", saved.Duplicate).
+		Msg("file saved")
+
+	res.ID = &saved.ID
+	res.Path = saved.Path
+	res.Duplicate = saved.Duplicate
+
+	return res, nil
+}
+
+// Quota returns the quota usage and limits of the requesting user.
+func (h *Handler) Quota(c *ginext.Context) {
+	usage, err := h.service.GetQuotaUsage(c.Request.Context(), owner(c))
+	if err != nil {
+		zlog.Logger.Err(err).Msg("failed to get quota usage")
+		respond.Fail(c, http.StatusInternalServerError, fmt.Errorf("failed to get quota usage: %v", err))
 		return
 	}
 
-	zlog.Logger.Printf("saved file: %v", dst)
+	respond.OK(c, usage)
+}
+
+// owner returns the user making the request, falling back to the default owner.
+func owner(c *ginext.Context) string {
+	if user := strings.TrimSpace(c.GetHeader(middleware.UserHeader)); user != "" {
+		return user
+	}
+
+	return model.DefaultOwner
+}
 
-	// Respond with file info.
-	respond.OK(c, map[string]interface{}{
-		"id":       id,
-		"filename": header.Filename,
-		"path":     dst,
-	})
+// uploadErrorStatus maps upload rejections (quotas, unsupported or infected content) to their HTTP status code.
+func uploadErrorStatus(err error) (int, bool) {
+	switch {
+	case errors.Is(err, processor.ErrUnsupportedFormat):
+		return http.StatusUnsupportedMediaType, true
+	case errors.Is(err, processor.ErrImageTooLarge):
+		return http.StatusRequestEntityTooLarge, true
+	case errors.Is(err, antivirus.ErrInfected):
+		return http.StatusUnprocessableEntity, true
+	case errors.Is(err, antivirus.ErrUnavailable):
+		return http.StatusServiceUnavailable, true
+	case errors.Is(err, imagesvc.ErrStorageQuotaExceeded):
+		return http.StatusRequestEntityTooLarge, true
+	case errors.Is(err, imagesvc.ErrRateQuotaExceeded):
+		return http.StatusTooManyRequests, true
+	default:
+		return 0, false
+	}
 }
 
 // Get serves the actual image bytes for a given image ID.
@@ -120,7 +328,7 @@ func (h *Handler) Get(c *ginext.Context) {
 	}
 
 	// Retrieve the image from the service.
-	_, reader, err := h.service.GetImage(c.Request.Context(), id)
+	img, reader, err := h.service.GetImage(c.Request.Context(), id)
 	if err != nil {
 		if errors.Is(err, image.ErrImageNotFound) {
 			zlog.Logger.Warn().Msg("image not found")
@@ -134,15 +342,26 @@ func (h *Handler) Get(c *ginext.Context) {
 	}
 	defer reader.Close()
 
-	// Disable browser caching to always fetch the latest image.
-	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
-	c.Header("Pragma", "no-cache")
-	c.Header("Expires", "0")
+	setCacheHeaders(c, h.cache.Image, id)
 
-	respond.JPEG(c, http.StatusOK, reader)
+	// Optionally ask the browser to save the file instead of displaying it.
+	if download, _ := strconv.ParseBool(c.Query("download")); download {
+		name := sanitizeFilename(c.DefaultQuery("filename", img.Filename))
+		if name == "" {
+			name = id.String()
+		}
+		if filepath.Ext(name) == "" {
+			name += extension(img)
+		}
+
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	}
+
+	respond.Image(c, http.StatusOK, contentType(img), reader)
 }
 
-// GetMeta returns metadata about the image (filename, status, etc.) without serving the file itself..
+// GetMeta returns metadata about the image (filename, status, etc.) without serving the file itself.
+// The status of originals follows their processing: pending, processing, then processed or failed.
 func (h *Handler) GetMeta(c *ginext.Context) {
 	idStr := c.Param("id")
 	id, err := uuid.Parse(idStr)
@@ -151,9 +370,255 @@ func (h *Handler) GetMeta(c *ginext.Context) {
 		return
 	}
 
-	img, _, err := h.service.GetImage(c.Request.Context(), id)
+	img, err := h.service.GetMeta(c.Request.Context(), id)
 	if err != nil {
-		respond.Fail(c, http.StatusNotFound, fmt.Errorf("image not found"))
+		if errors.Is(err, image.ErrImageNotFound) {
+			respond.Fail(c, http.StatusNotFound, fmt.Errorf("image not found"))
+			return
+		}
+
+		zlog.Logger.Err(err).Msg("failed to get image metadata")
+		respond.Fail(c, http.StatusInternalServerError, fmt.Errorf("failed to get image metadata: %v", err))
+		return
+	}
+
+	respond.OK(c, img)
+}
+
+// Status returns the detailed processing status of an image: stage, attempt count,
+// timestamps and the last error.
+func (h *Handler) Status(c *ginext.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		zlog.Logger.Err(err).Msg("failed to parse id")
+		respond.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid id: %v", err))
+		return
+	}
+
+	st, err := h.service.GetStatus(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, image.ErrImageNotFound) {
+			respond.Fail(c, http.StatusNotFound, fmt.Errorf("image not found"))
+			return
+		}
+
+		zlog.Logger.Err(err).Msg("failed to get image status")
+		respond.Fail(c, http.StatusInternalServerError, fmt.Errorf("failed to get image status: %v", err))
+		return
+	}
+
+	respond.OK(c, st)
+}
+
+// Events streams the processing status of an image as server-sent events: a "status" event with the
+// current status, then one after each change, until the client disconnects.
+func (h *Handler) Events(c *ginext.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		zlog.Logger.Err(err).Msg("failed to parse id")
+		respond.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid id: %v", err))
+		return
+	}
+
+	updates, err := h.service.WatchStatus(c.Request.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, image.ErrImageNotFound):
+			respond.Fail(c, http.StatusNotFound, fmt.Errorf("image not found"))
+		case errors.Is(err, imagesvc.ErrWatchUnavailable):
+			respond.Fail(c, http.StatusNotImplemented, err)
+		default:
+			zlog.Logger.Err(err).Msg("failed to watch image status")
+			respond.Fail(c, http.StatusInternalServerError, fmt.Errorf("failed to watch image status: %v", err))
+		}
+		return
+	}
+
+	// The stream outlives the write timeout of the server.
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		zlog.Logger.Err(err).Msg("failed to clear write deadline of status stream")
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no") // disable response buffering in nginx
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case st, ok := <-updates:
+			if !ok {
+				return false
+			}
+			c.SSEvent("status", st)
+		case <-keepAlive.C:
+			_, _ = io.WriteString(w, ": keep-alive\n\n")
+		}
+
+		return true
+	})
+}
+
+// Transform serves the image resized on the fly, e.g. ?w=400&h=300&mode=fit&format=webp.
+// Results are cached in storage, so only the first request for a variant does the work.
+func (h *Handler) Transform(c *ginext.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		zlog.Logger.Err(err).Msg("failed to parse id")
+		respond.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid id: %v", err))
+		return
+	}
+
+	opts, err := processor.ParseTransformOptions(c.Query("w"), c.Query("h"), c.Query("mode"), c.Query("format"))
+	if err != nil {
+		respond.Fail(c, http.StatusBadRequest, err)
+		return
+	}
+
+	reader, err := h.service.Transform(c.Request.Context(), id, opts)
+	if err != nil {
+		if errors.Is(err, image.ErrImageNotFound) {
+			respond.Fail(c, http.StatusNotFound, fmt.Errorf("image not found"))
+			return
+		}
+
+		zlog.Logger.Err(err).Msg("failed to transform image")
+		respond.Fail(c, http.StatusInternalServerError, fmt.Errorf("failed to transform image: %v", err))
+		return
+	}
+	defer reader.Close()
+
+	setCacheHeaders(c, h.cache.Transform, id)
+	respond.Image(c, http.StatusOK, opts.ContentType(), reader)
+}
+
+// Download streams a ZIP archive with the original image and all of its derived variants.
+func (h *Handler) Download(c *ginext.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		zlog.Logger.Err(err).Msg("failed to parse id")
+		respond.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid id: %v", err))
+		return
+	}
+
+	if format := c.DefaultQuery("format", "zip"); format != "zip" {
+		respond.Fail(c, http.StatusBadRequest, fmt.Errorf("unsupported format: %s", format))
+		return
+	}
+
+	images, err := h.service.GetBundle(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, image.ErrImageNotFound) {
+			respond.Fail(c, http.StatusNotFound, fmt.Errorf("image not found"))
+			return
+		}
+
+		zlog.Logger.Err(err).Msg("failed to get image bundle")
+		respond.Fail(c, http.StatusInternalServerError, fmt.Errorf("failed to get image bundle: %v", err))
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, id))
+	c.Status(http.StatusOK)
+
+	// The response is streamed, so errors past this point can only be logged.
+	zw := zip.NewWriter(c.Writer)
+	seen := make(map[string]bool, len(images))
+
+	for _, img := range images {
+		name := img.Path
+		if seen[name] {
+			name = path.Join(path.Dir(img.Path), img.ID.String()+"_"+path.Base(img.Path))
+		}
+		seen[name] = true
+
+		if err := h.writeZipEntry(c.Request.Context(), zw, name, img.Path); err != nil {
+			zlog.Logger.Err(err).Str("path", img.Path).Msg("failed to write zip entry")
+			return
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		zlog.Logger.Err(err).Msg("failed to finish zip archive")
+	}
+}
+
+// writeZipEntry copies a stored file into the archive under the given name.
+func (h *Handler) writeZipEntry(ctx context.Context, zw *zip.Writer, name, filePath string) error {
+	reader, err := h.service.OpenFile(ctx, filePath)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	// Images are already compressed, so store them as-is.
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: time.Now()})
+	if err != nil {
+		return fmt.Errorf("failed to create zip entry: %w", err)
+	}
+
+	if _, err := io.Copy(w, reader); err != nil {
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+
+	return nil
+}
+
+// Derived returns all images derived from the original with the given ID
+// (resized, thumbnails, watermarked, etc.).
+func (h *Handler) Derived(c *ginext.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		zlog.Logger.Err(err).Msg("failed to parse id")
+		respond.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid id: %v", err))
+		return
+	}
+
+	derived, err := h.service.ListDerived(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, image.ErrImageNotFound) {
+			respond.Fail(c, http.StatusNotFound, fmt.Errorf("image not found"))
+			return
+		}
+
+		zlog.Logger.Err(err).Msg("failed to list derived images")
+		respond.Fail(c, http.StatusInternalServerError, fmt.Errorf("failed to list derived images: %v", err))
+		return
+	}
+
+	respond.OK(c, derived)
+}
+
+// UpdateMeta updates the user-supplied title, description and tags of an image.
+// Fields missing from the body are left unchanged.
+func (h *Handler) UpdateMeta(c *ginext.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		zlog.Logger.Err(err).Msg("failed to parse id")
+		respond.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid id: %v", err))
+		return
+	}
+
+	var upd model.MetadataUpdate
+	if err := c.ShouldBindJSON(&upd); err != nil {
+		respond.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid request body"))
+		return
+	}
+
+	img, err := h.service.UpdateMetadata(c.Request.Context(), id, upd)
+	if err != nil {
+		switch {
+		case errors.Is(err, image.ErrImageNotFound):
+			respond.Fail(c, http.StatusNotFound, fmt.Errorf("image not found"))
+		case errors.Is(err, imagesvc.ErrInvalidMetadata):
+			respond.Fail(c, http.StatusBadRequest, err)
+		default:
+			zlog.Logger.Err(err).Msg("failed to update image metadata")
+			respond.Fail(c, http.StatusInternalServerError, fmt.Errorf("failed to update image metadata: %v", err))
+		}
 		return
 	}
 
@@ -190,3 +655,208 @@ func (h *Handler) Delete(c *ginext.Context) {
 
 	c.Status(http.StatusNoContent)
 }
+
+// List returns stored images filtered by status, action, filename substring
+// and creation date range. Pages are continued with the returned next_cursor;
+// limit/offset pagination is kept for existing clients.
+func (h *Handler) List(c *ginext.Context) {
+	f, err := filter.Parse(c)
+	if err != nil {
+		respond.Fail(c, http.StatusBadRequest, err)
+		return
+	}
+
+	images, err := h.service.ListImages(c.Request.Context(), f)
+	if err != nil {
+		zlog.Logger.Err(err).Msg("failed to list images")
+		respond.Fail(c, http.StatusInternalServerError, fmt.Errorf("failed to list images: %v", err))
+		return
+	}
+
+	respond.OK(c, map[string]interface{}{
+		"images":      images,
+		"next_cursor": filter.NextCursor(f, images),
+		"limit":       f.Limit,
+		"offset":      f.Offset,
+	})
+}
+
+// Search returns images matching a full-text query over filenames, tags and
+// OCR text, ranked by relevance, and tag combinations (all, any, excluded), kind
+// (original or derived), formats and size range on top of the List filters,
+// together with the total number of matches.
+func (h *Handler) Search(c *ginext.Context) {
+	f, err := filter.Parse(c)
+	if err != nil {
+		respond.Fail(c, http.StatusBadRequest, err)
+		return
+	}
+
+	images, total, err := h.service.SearchImages(c.Request.Context(), f)
+	if err != nil {
+		zlog.Logger.Err(err).Msg("failed to search images")
+		respond.Fail(c, http.StatusInternalServerError, fmt.Errorf("failed to search images: %v", err))
+		return
+	}
+
+	respond.OK(c, map[string]interface{}{
+		"images":      images,
+		"total":       total,
+		"next_cursor": filter.NextCursor(f, images),
+		"limit":       f.Limit,
+		"offset":      f.Offset,
+	})
+}
+
+// Changes returns an ordered feed of created, updated and deleted image records
+// recorded after the "since" cursor, so downstream mirrors can sync incrementally.
+func (h *Handler) Changes(c *ginext.Context) {
+	var since int64
+	if s := c.Query("since"); s != "" {
+		var err error
+		since, err = strconv.ParseInt(s, 10, 64)
+		if err != nil || since < 0 {
+			respond.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid since cursor"))
+			return
+		}
+	}
+
+	limit := defaultChangesLimit
+	if l := c.Query("limit"); l != "" {
+		var err error
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit <= 0 {
+			respond.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid limit"))
+			return
+		}
+	}
+	if limit > maxChangesLimit {
+		limit = maxChangesLimit
+	}
+
+	changes, err := h.service.ListChanges(c.Request.Context(), since, limit)
+	if err != nil {
+		zlog.Logger.Err(err).Msg("failed to list changes")
+		respond.Fail(c, http.StatusInternalServerError, fmt.Errorf("failed to list changes: %v", err))
+		return
+	}
+
+	// The next cursor stays at the request cursor when there are no new changes.
+	next := since
+	if len(changes) > 0 {
+		next = changes[len(changes)-1].Seq
+	}
+
+	respond.OK(c, map[string]interface{}{
+		"changes":     changes,
+		"next_cursor": strconv.FormatInt(next, 10),
+	})
+}
+
+// DetectWatermark extracts the owner ID embedded by the forensic_watermark action
+// from an uploaded image, for tracing the source of leaked files.
+func (h *Handler) DetectWatermark(c *ginext.Context) {
+	file, _, err := c.Request.FormFile("image")
+	if err != nil {
+		zlog.Logger.Err(err).Msg("failed to retrieve the file")
+		respond.Fail(c, http.StatusBadRequest, fmt.Errorf("failed to retrieve the file"))
+		return
+	}
+	defer file.Close()
+
+	owner, err := h.service.DetectWatermark(c.Request.Context(), file)
+	if err != nil {
+		if errors.Is(err, processor.ErrWatermarkNotFound) {
+			respond.OK(c, map[string]interface{}{
+				"found": false,
+			})
+			return
+		}
+
+		zlog.Logger.Err(err).Msg("failed to detect watermark")
+		respond.Fail(c, http.StatusBadRequest, fmt.Errorf("failed to detect watermark: %v", err))
+		return
+	}
+
+	respond.OK(c, map[string]interface{}{
+		"found": true,
+		"owner": owner,
+	})
+}
+
+// maxFilenameLen limits the length of download filenames in bytes.
+const maxFilenameLen = 200
+
+// sanitizeFilename makes a client-supplied filename safe for Content-Disposition:
+// directory components, control characters and quotes are removed and the length is bounded.
+func sanitizeFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, `\`, "/"))
+	if name == "." || name == "/" || name == ".." {
+		return ""
+	}
+
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '"' || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+
+	for len(name) > maxFilenameLen {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+
+	return name
+}
+
+// isHTTPURL reports whether s is an absolute http(s) URL.
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// setCacheHeaders sets the Cache-Control header of an image response and tags it with the
+// surrogate key of the image, so that the CDN purges it once the image changes.
+// Without a Cache-Control header, browsers and proxies are told not to cache the response.
+func setCacheHeaders(c *ginext.Context, cacheControl string, id uuid.UUID) {
+	c.Header(cdn.SurrogateKeyHeader, cdn.SurrogateKey(id))
+
+	if cacheControl != "" {
+		c.Header("Cache-Control", cacheControl)
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
+	c.Header("Pragma", "no-cache")
+	c.Header("Expires", "0")
+}
+
+// contentType returns the MIME type of a stored image based on its format or, for images
+// recorded without one, its extension, defaulting to JPEG, which is what most processing actions produce.
+func contentType(img model.Image) string {
+	if img.Format != "" {
+		return processor.ContentType(img.Format)
+	}
+
+	if ct := mime.TypeByExtension(filepath.Ext(img.Path)); ct != "" {
+		return ct
+	}
+
+	return "image/jpeg"
+}
+
+// extension returns the file extension of a stored image, from its format if known.
+// Originals are stored by content hash without an extension.
+func extension(img model.Image) string {
+	if ext := filepath.Ext(img.Path); ext != "" {
+		return ext
+	}
+
+	if exts, _ := mime.ExtensionsByType(contentType(img)); len(exts) > 0 {
+		return exts[0]
+	}
+
+	return ""
+}
@@ -0,0 +1,195 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/ginext"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/image-processor/internal/api/respond"
+	"github.com/aliskhannn/image-processor/internal/assets/fonts"
+	"github.com/aliskhannn/image-processor/internal/model"
+	"github.com/aliskhannn/image-processor/internal/processor"
+	"github.com/aliskhannn/image-processor/internal/repository/image"
+	sessionsvc "github.com/aliskhannn/image-processor/internal/service/session"
+)
+
+// service defines the interface for editing session operations.
+type service interface {
+	Open(ctx context.Context, imageID uuid.UUID) (model.Session, error)
+	Get(id uuid.UUID) (model.Session, error)
+	Apply(ctx context.Context, id uuid.UUID, action model.Action) (model.Session, error)
+	Preview(ctx context.Context, id uuid.UUID) (io.ReadCloser, error)
+	Commit(ctx context.Context, id uuid.UUID) (uuid.UUID, error)
+	Discard(ctx context.Context, id uuid.UUID) error
+}
+
+// Handler provides HTTP handlers for interactive editing sessions.
+type Handler struct {
+	service service
+}
+
+// NewHandler creates a new Handler with the given service.
+func NewHandler(s service) *Handler {
+	return &Handler{service: s}
+}
+
+// OpenRequest represents the body of a request to open an editing session.
+type OpenRequest struct {
+	ImageID uuid.UUID `json:"image_id"`
+}
+
+// ActionRequest represents a single action applied to a session.
+type ActionRequest struct {
+	Action string            `json:"action"`
+	Params map[string]string `json:"params"`
+}
+
+// Open starts a new editing session on an existing image.
+func (h *Handler) Open(c *ginext.Context) {
+	var req OpenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		zlog.Logger.Err(err).Msg("failed to bind open session request")
+		respond.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid request body"))
+		return
+	}
+
+	sess, err := h.service.Open(c.Request.Context(), req.ImageID)
+	if err != nil {
+		if errors.Is(err, image.ErrImageNotFound) {
+			respond.Fail(c, http.StatusNotFound, fmt.Errorf("image not found"))
+			return
+		}
+
+		zlog.Logger.Err(err).Msg("failed to open session")
+		respond.Fail(c, http.StatusInternalServerError, fmt.Errorf("failed to open session: %v", err))
+		return
+	}
+
+	respond.Created(c, sess)
+}
+
+// Get returns the current state of a session.
+func (h *Handler) Get(c *ginext.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	sess, err := h.service.Get(id)
+	if err != nil {
+		failSession(c, err, "failed to get session")
+		return
+	}
+
+	respond.OK(c, sess)
+}
+
+// Apply appends an action to the session and re-renders its preview.
+func (h *Handler) Apply(c *ginext.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	var req ActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Action == "" {
+		respond.Fail(c, http.StatusBadRequest, fmt.Errorf("action is required"))
+		return
+	}
+
+	sess, err := h.service.Apply(c.Request.Context(), id, model.Action{Name: req.Action, Params: req.Params})
+	if err != nil {
+		failSession(c, err, "failed to apply action")
+		return
+	}
+
+	respond.OK(c, sess)
+}
+
+// Preview serves the latest preview of the session.
+func (h *Handler) Preview(c *ginext.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	reader, err := h.service.Preview(c.Request.Context(), id)
+	if err != nil {
+		failSession(c, err, "failed to get preview")
+		return
+	}
+	defer reader.Close()
+
+	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	respond.JPEG(c, http.StatusOK, reader)
+}
+
+// Commit applies the session pipeline to the original and stores the result as a derivative.
+func (h *Handler) Commit(c *ginext.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	derivedID, err := h.service.Commit(c.Request.Context(), id)
+	if err != nil {
+		failSession(c, err, "failed to commit session")
+		return
+	}
+
+	respond.Created(c, map[string]interface{}{
+		"id": derivedID,
+	})
+}
+
+// Discard closes the session without saving anything.
+func (h *Handler) Discard(c *ginext.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Discard(c.Request.Context(), id); err != nil {
+		failSession(c, err, "failed to discard session")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// parseID parses the session ID path parameter and responds with 400 if it is invalid.
+func parseID(c *ginext.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		zlog.Logger.Err(err).Msg("failed to parse session id")
+		respond.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid id: %v", err))
+		return uuid.Nil, false
+	}
+
+	return id, true
+}
+
+// failSession maps session service errors to HTTP responses: actions refused by the policy
+// are the client's fault.
+func failSession(c *ginext.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, sessionsvc.ErrSessionNotFound):
+		respond.Fail(c, http.StatusNotFound, fmt.Errorf("session not found"))
+		return
+	case errors.Is(err, processor.ErrActionNotAllowed),
+		errors.Is(err, processor.ErrParamOutOfBounds),
+		errors.Is(err, fonts.ErrUnknownFont):
+		respond.Fail(c, http.StatusBadRequest, err)
+		return
+	}
+
+	zlog.Logger.Err(err).Msg(msg)
+	respond.Fail(c, http.StatusInternalServerError, fmt.Errorf("%s: %v", msg, err))
+}
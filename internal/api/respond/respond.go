@@ -23,6 +23,11 @@ func JPEG(c *ginext.Context, status int, reader io.Reader) {
 	c.DataFromReader(status, -1, "image/jpeg", reader, nil)
 }
 
+// Image streams an image of the given content type directly from an io.Reader as the HTTP response.
+func Image(c *ginext.Context, status int, contentType string, reader io.Reader) {
+	c.DataFromReader(status, -1, contentType, reader, nil)
+}
+
 // JSON sends a JSON response with the specified HTTP status code and data.
 // It uses the Gin context to encode the data into JSON format.
 func JSON(c *ginext.Context, status int, data interface{}) {
@@ -1,25 +1,124 @@
 package router
 
 import (
+	"fmt"
+
 	"github.com/wb-go/wbf/ginext"
 
+	"github.com/aliskhannn/image-processor/internal/api/docs"
+	"github.com/aliskhannn/image-processor/internal/api/handlers/admin"
+	"github.com/aliskhannn/image-processor/internal/api/handlers/asset"
+	"github.com/aliskhannn/image-processor/internal/api/handlers/health"
 	"github.com/aliskhannn/image-processor/internal/api/handlers/image"
+	"github.com/aliskhannn/image-processor/internal/api/handlers/session"
+	"github.com/aliskhannn/image-processor/internal/config"
+	"github.com/aliskhannn/image-processor/internal/metrics"
 	"github.com/aliskhannn/image-processor/internal/middleware"
 )
 
-func Setup(h *image.Handler) *ginext.Engine {
+// API route prefixes. The unversioned prefix is a deprecated alias of v1
+// kept so that existing integrations don't break.
+const (
+	legacyPrefix = "/api"
+	v1Prefix     = "/api/v1"
+)
+
+func Setup(
+	h *image.Handler,
+	sh *session.Handler,
+	ah *asset.Handler,
+	adh *admin.Handler,
+	hh *health.Handler,
+	cors config.CORS,
+	users config.Users,
+	adminToken string,
+) (*ginext.Engine, error) {
+	userMiddleware, err := middleware.UserMiddleware(users)
+	if err != nil {
+		return nil, fmt.Errorf("setup router: %w", err)
+	}
+
 	r := ginext.New()
 
-	r.Use(middleware.CORSMiddleware())
-	r.Use(ginext.Logger())
+	// Probes and metrics are registered before the logger so that they don't flood the logs.
+	r.GET("/healthz", hh.Live)         // liveness probe
+	r.GET("/readyz", hh.Ready)         // readiness probe
+	r.GET("/metrics", metrics.Handler) // prometheus metrics
+
+	r.Use(middleware.TraceMiddleware())
+	r.Use(userMiddleware)
+	r.Use(middleware.ActorMiddleware())
+	r.Use(middleware.CORSMiddleware(cors))
+	r.Use(middleware.AccessLogMiddleware())
 	r.Use(ginext.Recovery())
+	r.Use(middleware.ReportPanicMiddleware())
+
+	v1 := r.Group(v1Prefix, middleware.APIVersionMiddleware("v1"))
+	registerV1(v1, h, sh, ah, adh, adminToken)
+
+	legacy := r.Group(
+		legacyPrefix,
+		middleware.APIVersionMiddleware("v1"),
+		middleware.DeprecatedAliasMiddleware(legacyPrefix, v1Prefix),
+	)
+	registerV1(legacy, h, sh, ah, adh, adminToken)
+
+	return r, nil
+}
+
+// registerV1 registers the routes of the v1 API on the group.
+// Later versions get their own register function, reusing the v1 handlers for unchanged routes.
+func registerV1(
+	api *ginext.RouterGroup,
+	h *image.Handler,
+	sh *session.Handler,
+	ah *asset.Handler,
+	adh *admin.Handler,
+	adminToken string,
+) {
+	api.GET("/openapi.json", docs.Spec) // OpenAPI specification
+	api.GET("/docs", docs.UI)           // Swagger UI
+	api.GET("/docs/:asset", docs.Asset) // Swagger UI scripts and styles
+
+	api.POST("/upload", h.Upload)                // uploading image
+	api.GET("/image/:id", h.Get)                 // getting image by id
+	api.GET("/image/:id/meta", h.GetMeta)        // getting image by id
+	api.PATCH("/image/:id", h.UpdateMeta)        // updating title, description and tags
+	api.GET("/image/:id/status", h.Status)       // getting detailed processing status
+	api.GET("/image/:id/events", h.Events)       // streaming status changes as server-sent events
+	api.GET("/image/:id/derived", h.Derived)     // getting images derived from an original
+	api.GET("/image/:id/download", h.Download)   // downloading original and variants as zip
+	api.GET("/image/:id/transform", h.Transform) // resizing on the fly with cached results
+	api.DELETE("/image/:id", h.Delete)           // deleting image by id
+	api.GET("/images", h.List)                   // listing images with filters
+	api.GET("/search", h.Search)                 // searching images by tags and attributes
+	api.GET("/changes", h.Changes)               // change feed for downstream mirrors
+	api.GET("/quota", h.Quota)                   // getting quota usage of the requesting user
+
+	api.POST("/forensic/detect", h.DetectWatermark) // extracting forensic watermark owner
+
+	api.POST("/session", sh.Open)               // opening editing session on an image
+	api.GET("/session/:id", sh.Get)             // getting session state
+	api.POST("/session/:id/actions", sh.Apply)  // applying action to session preview
+	api.GET("/session/:id/preview", sh.Preview) // getting session preview
+	api.POST("/session/:id/commit", sh.Commit)  // committing session as derivative
+	api.DELETE("/session/:id", sh.Discard)      // discarding session
 
-	api := r.Group("/api")
+	api.POST("/assets/luts", ah.UploadLUT) // uploading .cube LUT for the lut action
 
-	api.POST("/upload", h.Upload)         // uploading image
-	api.GET("/image/:id", h.Get)          // getting image by id
-	api.GET("/image/:id/meta", h.GetMeta) // getting image by id
-	api.DELETE("/image/:id", h.Delete)    // deleting image by id
+	adm := api.Group("/admin", middleware.AdminAuthMiddleware(adminToken))
 
-	return r
+	adm.GET("/jobs", adh.Jobs)                          // listing failed or stuck jobs
+	adm.GET("/images", adh.Images)                      // listing images of all owners with filters
+	adm.POST("/jobs/:id/retry", adh.Retry)              // re-enqueueing a job
+	adm.DELETE("/images/:id/derived", adh.PurgeDerived) // purging derived images of an original
+	adm.GET("/stats", adh.Stats)                        // job counts, throughput and failure rates
+	adm.GET("/slo", adh.SLO)                            // processing latency percentiles and SLO burn per action
+	adm.GET("/usage", adh.Usage)                        // storage usage per owner and directory
+	adm.GET("/dlq", adh.DeadLetters)                    // listing messages that failed after retries
+	adm.POST("/dlq/:id/replay", adh.ReplayDeadLetter)   // re-enqueueing a dead letter
+	adm.GET("/audit", adh.Audit)                        // listing image lifecycle audit events
+	adm.GET("/consumers", adh.Consumers)                // getting kafka consumption state
+	adm.POST("/consumers/pause", adh.PauseConsumers)    // pausing kafka consumption
+	adm.POST("/consumers/resume", adh.ResumeConsumers)  // resuming kafka consumption
 }
@@ -0,0 +1,86 @@
+package router
+
+import (
+	"encoding/json"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aliskhannn/image-processor/internal/config"
+)
+
+// specFile is the OpenAPI specification served by the docs package.
+const specFile = "../docs/openapi.json"
+
+// undocumented lists the routes left out of the specification: the docs themselves and the
+// Prometheus metrics, which are not part of the API.
+var undocumented = []string{
+	"GET /docs",
+	"GET /docs/{asset}",
+	"GET /metrics",
+	"GET /openapi.json",
+}
+
+// pathParam matches the path parameters of gin routes.
+var pathParam = regexp.MustCompile(`:(\w+)`)
+
+// TestSpecMatchesRoutes checks that the OpenAPI specification documents every v1 and probe route
+// registered by Setup, and nothing else.
+func TestSpecMatchesRoutes(t *testing.T) {
+	data, err := os.ReadFile(specFile)
+	if err != nil {
+		t.Fatalf("read spec: %v", err)
+	}
+
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		t.Fatalf("parse spec: %v", err)
+	}
+
+	var documented []string
+	for path, item := range spec.Paths {
+		for method := range item {
+			switch method {
+			case "get", "post", "put", "patch", "delete":
+				documented = append(documented, strings.ToUpper(method)+" "+path)
+			}
+		}
+	}
+
+	r, err := Setup(nil, nil, nil, nil, nil, config.CORS{}, config.Users{}, "")
+	if err != nil {
+		t.Fatalf("setup router: %v", err)
+	}
+
+	var routed []string
+	for _, route := range r.Routes() {
+		path, ok := strings.CutPrefix(route.Path, v1Prefix)
+		switch {
+		case ok:
+		case strings.HasPrefix(route.Path, legacyPrefix+"/"):
+			continue // deprecated alias of the v1 routes
+		default:
+			path = route.Path
+		}
+
+		key := route.Method + " " + pathParam.ReplaceAllString(path, "{$1}")
+		if !slices.Contains(undocumented, key) {
+			routed = append(routed, key)
+		}
+	}
+
+	for _, key := range routed {
+		if !slices.Contains(documented, key) {
+			t.Errorf("route %s is missing from %s", key, specFile)
+		}
+	}
+	for _, key := range documented {
+		if !slices.Contains(routed, key) {
+			t.Errorf("%s documents %s, which is not routed", specFile, key)
+		}
+	}
+}
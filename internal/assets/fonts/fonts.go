@@ -0,0 +1,211 @@
+// Package fonts provides the fonts drawn on images: DejaVu Sans, embedded in the binary, and the
+// TrueType fonts of a configurable directory, selected by file name without the extension.
+package fonts
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// Default is the name of the embedded font, used when no font is named.
+const Default = "DejaVuSans"
+
+// maxFaces bounds the cached faces; sizes follow the image width, so they are many.
+const maxFaces = 256
+
+// ErrUnknownFont is returned for fonts found neither in the directory nor embedded.
+var ErrUnknownFont = errors.New("unknown font")
+
+//go:embed DejaVuSans.ttf
+var defaultTTF []byte
+
+// faceKey identifies a cached face.
+type faceKey struct {
+	name string
+	size float64
+}
+
+// Registry loads fonts by name, parsing each once and caching their faces by size, so that
+// jobs do not read and parse the font file every time they draw text.
+type Registry struct {
+	dir string // directory of the TrueType fonts, empty for the embedded font only
+
+	mu    sync.Mutex
+	fonts map[string]*truetype.Font
+	faces map[faceKey]font.Face
+}
+
+// NewRegistry creates a registry of the fonts of dir and the embedded font.
+// A font of dir named like the embedded one replaces it.
+func NewRegistry(dir string) *Registry {
+	return &Registry{
+		dir:   dir,
+		fonts: make(map[string]*truetype.Font),
+		faces: make(map[faceKey]font.Face),
+	}
+}
+
+// Face returns the face of the named font, or of Default if name is empty, at the size in points,
+// rounded to whole points. Faces are safe for concurrent use.
+func (r *Registry) Face(name string, size float64) (font.Face, error) {
+	if name == "" {
+		name = Default
+	}
+	key := faceKey{name: name, size: max(math.Round(size), 1)}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if face, ok := r.faces[key]; ok {
+		return face, nil
+	}
+
+	f, err := r.font(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(r.faces) >= maxFaces {
+		clear(r.faces)
+	}
+	// Faces cache rendered glyphs, so drawing with them is serialized.
+	face := &lockedFace{face: truetype.NewFace(f, &truetype.Options{Size: key.size})}
+	r.faces[key] = face
+
+	return face, nil
+}
+
+// Check returns ErrUnknownFont if the named font is found neither in the directory nor embedded,
+// or an error if it can't be read or parsed, so that requests naming it are refused up front.
+// The font is loaded and kept for the faces drawn with it later.
+func (r *Registry) Check(name string) error {
+	if name == "" {
+		name = Default
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, err := r.font(name)
+	return err
+}
+
+// font returns the parsed named font, loading it on first use. The caller must hold r.mu.
+func (r *Registry) font(name string) (*truetype.Font, error) {
+	if f, ok := r.fonts[name]; ok {
+		return f, nil
+	}
+
+	// Names are file names, never paths out of the directory.
+	if name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFont, name)
+	}
+
+	data, err := r.read(name)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := truetype.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse font %s: %w", name, err)
+	}
+	r.fonts[name] = f
+
+	return f, nil
+}
+
+// read returns the TrueType data of the named font of the directory, or of the embedded font.
+func (r *Registry) read(name string) ([]byte, error) {
+	if r.dir != "" {
+		data, err := os.ReadFile(filepath.Join(r.dir, name+".ttf"))
+		switch {
+		case err == nil:
+			return data, nil
+		case !errors.Is(err, os.ErrNotExist):
+			return nil, fmt.Errorf("failed to read font %s: %w", name, err)
+		}
+	}
+
+	if name == Default {
+		return defaultTTF, nil
+	}
+
+	return nil, fmt.Errorf("%w: %q", ErrUnknownFont, name)
+}
+
+// lockedFace serializes the use of a face shared by concurrent jobs.
+type lockedFace struct {
+	mu   sync.Mutex
+	face font.Face
+}
+
+// Close implements font.Face.
+func (f *lockedFace) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.face.Close()
+}
+
+// Glyph implements font.Face.
+func (f *lockedFace) Glyph(dot fixed.Point26_6, r rune) (image.Rectangle, image.Image, image.Point, fixed.Int26_6, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dr, mask, maskp, advance, ok := f.face.Glyph(dot, r)
+	if !ok {
+		return dr, mask, maskp, advance, ok
+	}
+
+	// The mask is the glyph cache of the face, overwritten by later calls, so the glyph is
+	// copied out of it before the lock is released.
+	glyph := image.NewAlpha(image.Rectangle{Max: dr.Size()})
+	draw.Draw(glyph, glyph.Bounds(), mask, maskp, draw.Src)
+
+	return dr, glyph, image.Point{}, advance, ok
+}
+
+// GlyphBounds implements font.Face.
+func (f *lockedFace) GlyphBounds(r rune) (fixed.Rectangle26_6, fixed.Int26_6, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.face.GlyphBounds(r)
+}
+
+// GlyphAdvance implements font.Face.
+func (f *lockedFace) GlyphAdvance(r rune) (fixed.Int26_6, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.face.GlyphAdvance(r)
+}
+
+// Kern implements font.Face.
+func (f *lockedFace) Kern(r0, r1 rune) fixed.Int26_6 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.face.Kern(r0, r1)
+}
+
+// Metrics implements font.Face.
+func (f *lockedFace) Metrics() font.Metrics {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.face.Metrics()
+}
@@ -0,0 +1,117 @@
+package fonts
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestRegistry returns a registry of a directory holding a copy of the embedded font named
+// Custom, next to another copy named Outside out of the directory.
+func newTestRegistry(t *testing.T) *Registry {
+	t.Helper()
+
+	root := t.TempDir()
+	dir := filepath.Join(root, "fonts")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatalf("create font dir: %v", err)
+	}
+	for _, path := range []string{filepath.Join(dir, "Custom.ttf"), filepath.Join(root, "Outside.ttf")} {
+		if err := os.WriteFile(path, defaultTTF, 0o644); err != nil {
+			t.Fatalf("write font: %v", err)
+		}
+	}
+
+	return NewRegistry(dir)
+}
+
+// TestRegistryFonts checks which names resolve to fonts: the default one, those of the directory
+// and no others, nor any path out of the directory.
+func TestRegistryFonts(t *testing.T) {
+	tests := []struct {
+		name    string
+		font    string
+		unknown bool
+	}{
+		{name: "default", font: ""},
+		{name: "embedded", font: Default},
+		{name: "directory", font: "Custom"},
+		{name: "unknown", font: "Missing", unknown: true},
+		{name: "extension", font: "Custom.ttf", unknown: true},
+		{name: "parent", font: "../Outside", unknown: true},
+		{name: "subdirectory", font: "fonts/Custom", unknown: true},
+		{name: "absolute", font: "/etc/passwd", unknown: true},
+		{name: "dot", font: ".", unknown: true},
+		{name: "dot dot", font: "..", unknown: true},
+		{name: "hidden", font: ".Custom", unknown: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRegistry(t)
+
+			face, err := r.Face(tt.font, 12)
+			if tt.unknown {
+				if !errors.Is(err, ErrUnknownFont) {
+					t.Fatalf("Face(%q) error = %v, want ErrUnknownFont", tt.font, err)
+				}
+				if err := r.Check(tt.font); !errors.Is(err, ErrUnknownFont) {
+					t.Fatalf("Check(%q) error = %v, want ErrUnknownFont", tt.font, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Face(%q) error = %v", tt.font, err)
+			}
+			if _, ok := face.GlyphAdvance('A'); !ok {
+				t.Errorf("Face(%q) has no glyph for A", tt.font)
+			}
+			if err := r.Check(tt.font); err != nil {
+				t.Errorf("Check(%q) error = %v", tt.font, err)
+			}
+		})
+	}
+}
+
+// TestRegistryCache checks that fonts are parsed once and faces reused per rounded size.
+func TestRegistryCache(t *testing.T) {
+	r := newTestRegistry(t)
+
+	face, err := r.Face("Custom", 12.2)
+	if err != nil {
+		t.Fatalf("Face() error = %v", err)
+	}
+	parsed := r.fonts["Custom"]
+
+	again, err := r.Face("Custom", 11.8)
+	if err != nil {
+		t.Fatalf("Face() error = %v", err)
+	}
+	if again != face {
+		t.Error("Face() of the same rounded size returned another face")
+	}
+
+	larger, err := r.Face("Custom", 24)
+	if err != nil {
+		t.Fatalf("Face() error = %v", err)
+	}
+	if larger == face {
+		t.Error("Face() of another size returned the same face")
+	}
+	if r.fonts["Custom"] != parsed {
+		t.Error("font parsed again for another size")
+	}
+
+	// Once parsed, the font no longer depends on its file.
+	if err := os.Remove(filepath.Join(r.dir, "Custom.ttf")); err != nil {
+		t.Fatalf("remove font: %v", err)
+	}
+	if err := r.Check("Custom"); err != nil {
+		t.Errorf("Check() of a parsed font error = %v", err)
+	}
+	if _, err := r.Face("Custom", 36); err != nil {
+		t.Errorf("Face() of a parsed font error = %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+// Package audit carries the actor of a request through contexts and builds
+// the entries of the audit log of image lifecycle events.
+package audit
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/aliskhannn/image-processor/internal/model"
+	"github.com/aliskhannn/image-processor/internal/trace"
+)
+
+// Actors that are not users.
+const (
+	ActorAdmin  = "admin"  // operator using the admin API
+	ActorSystem = "system" // background work such as queue workers, the re-driver and the expiry sweeper
+)
+
+// contextKey is the key the actor is stored under in a context.Context.
+type contextKey struct{}
+
+// WithActor returns a copy of ctx carrying the actor.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, contextKey{}, actor)
+}
+
+// Actor returns the actor carried by ctx, or ActorSystem if there is none.
+func Actor(ctx context.Context) string {
+	if actor, ok := ctx.Value(contextKey{}).(string); ok && actor != "" {
+		return actor
+	}
+
+	return ActorSystem
+}
+
+// Event returns the audit event of the image with the actor and request ID carried by ctx.
+func Event(ctx context.Context, event string, imageID uuid.UUID, details string) model.AuditEvent {
+	return model.AuditEvent{
+		Event:     event,
+		ImageID:   imageID,
+		Actor:     Actor(ctx),
+		RequestID: trace.FromContext(ctx).RequestID,
+		Details:   details,
+	}
+}
@@ -0,0 +1,58 @@
+// Package cdn purges the images cached by the CDN in front of the API once they change.
+package cdn
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/retry"
+
+	"github.com/aliskhannn/image-processor/internal/config"
+	"github.com/aliskhannn/image-processor/internal/reload"
+)
+
+// Purge providers selected by config.CDN.Provider.
+const (
+	ProviderFastly     = "fastly"
+	ProviderCloudFront = "cloudfront"
+)
+
+// SurrogateKeyHeader tags served responses with the surrogate key of their image, so that
+// CDNs supporting it (Fastly) purge all responses of an image at once.
+const SurrogateKeyHeader = "Surrogate-Key"
+
+// Purger purges the cached responses of images from the CDN.
+type Purger interface {
+	Purge(ctx context.Context, ids []uuid.UUID) error
+}
+
+// SurrogateKey returns the surrogate key of the responses of the image.
+func SurrogateKey(id uuid.UUID) string {
+	return "image-" + id.String()
+}
+
+// New creates the purger of the configured provider, or nil if purging is disabled.
+func New(ctx context.Context, cfg config.CDN, s *reload.Value[retry.Strategy]) (Purger, error) {
+	client := &http.Client{Timeout: cfg.Timeout}
+
+	switch cfg.Provider {
+	case "":
+		return nil, nil
+	case ProviderFastly:
+		if cfg.Fastly.ServiceID == "" || cfg.Fastly.APIToken == "" {
+			return nil, fmt.Errorf("cdn provider %s requires a service ID and an API token", cfg.Provider)
+		}
+
+		return newFastly(client, cfg.Fastly.ServiceID, cfg.Fastly.APIToken, s), nil
+	case ProviderCloudFront:
+		if cfg.CloudFront.DistributionID == "" {
+			return nil, fmt.Errorf("cdn provider %s requires a distribution ID", cfg.Provider)
+		}
+
+		return newCloudFront(ctx, client, cfg.CloudFront.DistributionID, cfg.PathPrefixes, s)
+	default:
+		return nil, fmt.Errorf("unknown cdn provider %q", cfg.Provider)
+	}
+}
@@ -0,0 +1,126 @@
+package cdn
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/retry"
+
+	"github.com/aliskhannn/image-processor/internal/reload"
+)
+
+// CloudFront API endpoint; the API is global and signed for us-east-1.
+const (
+	cloudFrontAPI    = "https://cloudfront.amazonaws.com/2020-05-31"
+	cloudFrontRegion = "us-east-1"
+)
+
+// invalidationBatch is the body of a CloudFront CreateInvalidation request.
+type invalidationBatch struct {
+	XMLName         xml.Name `xml:"http://cloudfront.amazonaws.com/doc/2020-05-31/ InvalidationBatch"`
+	Paths           paths    `xml:"Paths"`
+	CallerReference string   `xml:"CallerReference"`
+}
+
+// paths lists the invalidated paths.
+type paths struct {
+	Quantity int      `xml:"Quantity"`
+	Items    []string `xml:"Items>Path"`
+}
+
+// cloudFront purges images from a CloudFront distribution by invalidating their paths.
+type cloudFront struct {
+	client         *http.Client
+	distributionID string
+	prefixes       []string // API route prefixes the image paths are served under
+	credentials    aws.CredentialsProvider
+	signer         *v4.Signer
+	strategy       *reload.Value[retry.Strategy]
+}
+
+// newCloudFront creates a purger of the distribution. Credentials come from the default AWS chain.
+func newCloudFront(
+	ctx context.Context,
+	client *http.Client,
+	distributionID string,
+	prefixes []string,
+	s *reload.Value[retry.Strategy],
+) (*cloudFront, error) {
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(cloudFrontRegion))
+	if err != nil {
+		return nil, fmt.Errorf("cloudfront: failed to load aws config: %w", err)
+	}
+
+	return &cloudFront{
+		client:         client,
+		distributionID: distributionID,
+		prefixes:       prefixes,
+		credentials:    awsCfg.Credentials,
+		signer:         v4.NewSigner(),
+		strategy:       s,
+	}, nil
+}
+
+// Purge invalidates the paths of the images, with all their sub-resources and query strings,
+// under every route prefix in one invalidation.
+func (c *cloudFront) Purge(ctx context.Context, ids []uuid.UUID) error {
+	batch := invalidationBatch{CallerReference: uuid.NewString()}
+	for _, prefix := range c.prefixes {
+		for _, id := range ids {
+			batch.Paths.Items = append(batch.Paths.Items, path.Join("/", prefix, "image", id.String())+"*")
+		}
+	}
+	batch.Paths.Quantity = len(batch.Paths.Items)
+
+	if batch.Paths.Quantity == 0 {
+		return nil
+	}
+
+	body, err := xml.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("cloudfront purge: failed to marshal invalidation: %w", err)
+	}
+	sum := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(sum[:])
+
+	return retry.Do(func() error {
+		creds, err := c.credentials.Retrieve(ctx)
+		if err != nil {
+			return fmt.Errorf("cloudfront purge: failed to retrieve credentials: %w", err)
+		}
+
+		endpoint := cloudFrontAPI + "/distribution/" + c.distributionID + "/invalidation"
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("cloudfront purge: failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "text/xml")
+
+		if err := c.signer.SignHTTP(ctx, creds, req, payloadHash, "cloudfront", cloudFrontRegion, time.Now()); err != nil {
+			return fmt.Errorf("cloudfront purge: failed to sign request: %w", err)
+		}
+
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("cloudfront purge: failed to send request: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusCreated {
+			return fmt.Errorf("cloudfront purge: unexpected status %s", resp.Status)
+		}
+
+		return nil
+	}, c.strategy.Load())
+}
@@ -0,0 +1,58 @@
+package cdn
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/retry"
+
+	"github.com/aliskhannn/image-processor/internal/reload"
+)
+
+// fastlyAPI is the base URL of the Fastly API.
+const fastlyAPI = "https://api.fastly.com"
+
+// fastly purges images from Fastly by their surrogate keys.
+type fastly struct {
+	client    *http.Client
+	serviceID string
+	token     string
+	strategy  *reload.Value[retry.Strategy]
+}
+
+// newFastly creates a purger of the Fastly service.
+func newFastly(client *http.Client, serviceID, token string, s *reload.Value[retry.Strategy]) *fastly {
+	return &fastly{client: client, serviceID: serviceID, token: token, strategy: s}
+}
+
+// Purge purges all responses tagged with the surrogate keys of the images in one request.
+func (f *fastly) Purge(ctx context.Context, ids []uuid.UUID) error {
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, SurrogateKey(id))
+	}
+
+	return retry.Do(func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, fastlyAPI+"/service/"+f.serviceID+"/purge", nil)
+		if err != nil {
+			return fmt.Errorf("fastly purge: failed to create request: %w", err)
+		}
+		req.Header.Set("Fastly-Key", f.token)
+		req.Header.Set(SurrogateKeyHeader, strings.Join(keys, " "))
+
+		resp, err := f.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("fastly purge: failed to send request: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("fastly purge: unexpected status %s", resp.Status)
+		}
+
+		return nil
+	}, f.strategy.Load())
+}
@@ -0,0 +1,221 @@
+// Package client implements a minimal HTTP client for the image processor API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/aliskhannn/image-processor/internal/model"
+)
+
+// ErrNotFound is returned when the requested image does not exist.
+var ErrNotFound = errors.New("not found")
+
+// UploadResult describes the outcome of uploading a single file.
+type UploadResult struct {
+	ID        *uuid.UUID `json:"id,omitempty"`
+	Filename  string     `json:"filename"`
+	Path      string     `json:"path,omitempty"`
+	Duplicate bool       `json:"duplicate,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// Client talks to a running image processor instance.
+type Client struct {
+	baseURL string
+	user    string
+	http    *http.Client
+}
+
+// New creates a new Client for the API at baseURL (e.g. "http://localhost:8080").
+// A non-empty user is sent as X-User-ID so uploads count against that user's quotas; the API
+// only trusts it from its users.trusted_proxies.
+func New(baseURL, user string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/") + "/api/v1",
+		user:    user,
+		http:    &http.Client{},
+	}
+}
+
+// Upload uploads the file at path with the given action.
+func (c *Client) Upload(ctx context.Context, path string, action model.Action) (UploadResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return UploadResult{}, fmt.Errorf("upload: failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	actions, err := json.Marshal(map[string]interface{}{
+		"action": action.Name,
+		"params": action.Params,
+	})
+	if err != nil {
+		return UploadResult{}, fmt.Errorf("upload: failed to marshal actions: %w", err)
+	}
+
+	body := bytes.NewBuffer(nil)
+	mw := multipart.NewWriter(body)
+
+	part, err := mw.CreateFormFile("image", filepath.Base(path))
+	if err != nil {
+		return UploadResult{}, fmt.Errorf("upload: failed to create form file: %w", err)
+	}
+	if _, err := io.Copy(part, f); err != nil {
+		return UploadResult{}, fmt.Errorf("upload: failed to read file: %w", err)
+	}
+	if err := mw.WriteField("actions", string(actions)); err != nil {
+		return UploadResult{}, fmt.Errorf("upload: failed to write actions: %w", err)
+	}
+	if err := mw.Close(); err != nil {
+		return UploadResult{}, fmt.Errorf("upload: failed to finish form: %w", err)
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPost, "/upload", body)
+	if err != nil {
+		return UploadResult{}, fmt.Errorf("upload: %w", err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	var res UploadResult
+	if err := c.doJSON(req, &res); err != nil {
+		return UploadResult{}, fmt.Errorf("upload: %w", err)
+	}
+
+	return res, nil
+}
+
+// Status returns the detailed processing status of an image.
+func (c *Client) Status(ctx context.Context, id uuid.UUID) (model.ProcessingStatus, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, "/image/"+id.String()+"/status", nil)
+	if err != nil {
+		return model.ProcessingStatus{}, fmt.Errorf("status: %w", err)
+	}
+
+	var st model.ProcessingStatus
+	if err := c.doJSON(req, &st); err != nil {
+		return model.ProcessingStatus{}, fmt.Errorf("status: %w", err)
+	}
+
+	return st, nil
+}
+
+// Derived lists the images derived from an original.
+func (c *Client) Derived(ctx context.Context, id uuid.UUID) ([]model.Image, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, "/image/"+id.String()+"/derived", nil)
+	if err != nil {
+		return nil, fmt.Errorf("derived: %w", err)
+	}
+
+	var images []model.Image
+	if err := c.doJSON(req, &images); err != nil {
+		return nil, fmt.Errorf("derived: %w", err)
+	}
+
+	return images, nil
+}
+
+// Download writes the file of an image to dst.
+func (c *Client) Download(ctx context.Context, id uuid.UUID, dst io.Writer) error {
+	req, err := c.newRequest(ctx, http.MethodGet, "/image/"+id.String(), nil)
+	if err != nil {
+		return fmt.Errorf("download: %w", err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("download: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.Copy(dst, resp.Body); err != nil {
+		return fmt.Errorf("download: failed to read body: %w", err)
+	}
+
+	return nil
+}
+
+// Delete deletes an image and its derived images.
+func (c *Client) Delete(ctx context.Context, id uuid.UUID) error {
+	req, err := c.newRequest(ctx, http.MethodDelete, "/image/"+id.String(), nil)
+	if err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	return resp.Body.Close()
+}
+
+// newRequest builds a request to the given API path.
+func (c *Client) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if c.user != "" {
+		req.Header.Set("X-User-ID", c.user)
+	}
+
+	return req, nil
+}
+
+// do sends the request and converts non-2xx responses into errors.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+
+	var e struct {
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&e); err != nil || e.Message == "" {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return nil, fmt.Errorf("%s: %s", resp.Status, e.Message)
+}
+
+// doJSON sends the request and decodes the "result" field of the response into v.
+func (c *Client) doJSON(req *http.Request, v interface{}) error {
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body := struct {
+		Result interface{} `json:"result"`
+	}{Result: v}
+
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
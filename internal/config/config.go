@@ -1,7 +1,10 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"net/netip"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -10,11 +13,38 @@ import (
 
 // Config holds the main configuration for the application.
 type Config struct {
-	Server   Server   `mapstructure:"server"`
-	Database Database `mapstructure:"database"`
-	Storage  Storage  `mapstructure:"storage"`
-	Kafka    Kafka    `mapstructure:"kafka"`
-	Retry    Retry    `mapstructure:"retry"`
+	Server    Server    `mapstructure:"server"`
+	Database  Database  `mapstructure:"database"`
+	Storage   Storage   `mapstructure:"storage"`
+	Queue     Queue     `mapstructure:"queue"`
+	Kafka     Kafka     `mapstructure:"kafka"`
+	NATS      NATS      `mapstructure:"nats"`
+	SQS       SQS       `mapstructure:"sqs"`
+	Memory    Memory    `mapstructure:"memory"`
+	PGQueue   PGQueue   `mapstructure:"pg_queue"`
+	Retry     Retry     `mapstructure:"retry"`
+	Session   Session   `mapstructure:"session"`
+	OCR       OCR       `mapstructure:"ocr"`
+	Webhook   Webhook   `mapstructure:"webhook"`
+	Quota     Quota     `mapstructure:"quota"`
+	Users     Users     `mapstructure:"users"`
+	Admin     Admin     `mapstructure:"admin"`
+	CORS      CORS      `mapstructure:"cors"`
+	Limits    Limits    `mapstructure:"limits"`
+	Actions   Actions   `mapstructure:"actions"`
+	Fonts     Fonts     `mapstructure:"fonts"`
+	Antivirus Antivirus `mapstructure:"antivirus"`
+	Expiry    Expiry    `mapstructure:"expiry"`
+	Redrive   Redrive   `mapstructure:"redrive"`
+	CDN       CDN       `mapstructure:"cdn"`
+
+	ErrorReporting ErrorReporting `mapstructure:"error_reporting"`
+	StartupRetry   Retry          `mapstructure:"startup_retry"` // connecting to the dependencies on start
+	Secrets        Secrets        `mapstructure:"secrets"`
+	SLO            SLO            `mapstructure:"slo"`
+	Log            Log            `mapstructure:"log"`
+	Watermark      Watermark      `mapstructure:"watermark"`
+	Reload         Reload         `mapstructure:"reload"`
 }
 
 // Server holds HTTP server-related configuration.
@@ -24,12 +54,30 @@ type Server struct {
 
 // Database holds database master and slave configuration.
 type Database struct {
+	// Driver selects the database backend: "postgres" (default), "mysql" for MySQL or MariaDB,
+	// or "sqlite", an embedded database file for single-binary deployments. MySQL connects to
+	// the master node; slaves and read_from_replicas apply to PostgreSQL only.
+	Driver string `mapstructure:"driver"`
+	Path   string `mapstructure:"path"` // SQLite database file, created if missing
+
 	Master DatabaseNode   `mapstructure:"master"`
 	Slaves []DatabaseNode `mapstructure:"slaves"`
 
+	// ReadFromReplicas routes the image reads and listings of clients to the slaves; other queries stay on the master.
+	ReadFromReplicas bool `mapstructure:"read_from_replicas"`
+
 	MaxOpenConns    int           `mapstructure:"max_open_conns"`
 	MaxIdleConns    int           `mapstructure:"max_idle_conns"`
 	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
+
+	Partitions Partitions `mapstructure:"partitions"`
+}
+
+// Partitions holds configuration of the creation of the monthly partitions of the images table,
+// which applies to PostgreSQL only.
+type Partitions struct {
+	Interval    time.Duration `mapstructure:"interval"`     // How often missing partitions are created, zero disables it
+	MonthsAhead int           `mapstructure:"months_ahead"` // Months after the current one to create partitions for
 }
 
 // DatabaseNode holds connection parameters for a single database node.
@@ -44,18 +92,257 @@ type DatabaseNode struct {
 
 // Storage holds configuration for the file storage backend.
 type Storage struct {
+	Type       string `mapstructure:"type"` // Storage backend: "minio" (default)
+	Endpoint   string `mapstructure:"endpoint"`
+	AccessKey  string `mapstructure:"access_key"`
+	SecretKey  string `mapstructure:"secret_key"`
+	BucketName string `mapstructure:"bucket_name"`
+	UseSSL     bool   `mapstructure:"use_ssl"`
+	// PartSize is the part size in bytes of multipart uploads of objects streamed without a known
+	// size, bounding the memory they take (at least 5 MiB); zero uses the minio default.
+	PartSize uint64 `mapstructure:"part_size"`
+	// CacheControl is the Cache-Control header stored with objects and served by presigned URLs and CDNs.
+	CacheControl string `mapstructure:"cache_control"`
+	// Encryption is the server-side encryption applied to stored objects.
+	Encryption StorageEncryption `mapstructure:"encryption"`
+	// Lifecycle holds the bucket lifecycle rules managed by the service.
+	Lifecycle StorageLifecycle `mapstructure:"lifecycle"`
+	// Breaker fails storage calls fast during outages and probes the storage health.
+	Breaker StorageBreaker `mapstructure:"breaker"`
+	// Replica is the optional secondary storage written to asynchronously for disaster recovery.
+	Replica StorageReplica `mapstructure:"replica"`
+}
+
+// StorageBreaker holds the circuit breaker and health probing of the storage.
+type StorageBreaker struct {
+	Threshold     int           `mapstructure:"threshold"`      // Consecutive failures opening the breaker; 0 disables it
+	Cooldown      time.Duration `mapstructure:"cooldown"`       // Time calls fail fast once the breaker opened
+	ProbeInterval time.Duration `mapstructure:"probe_interval"` // Interval of background health probes; 0 disables them
+}
+
+// StorageReplica holds the secondary MinIO/S3 bucket every write is replicated to in the background.
+// Objects are stored there with the same options as in the primary storage.
+type StorageReplica struct {
+	Enabled    bool   `mapstructure:"enabled"`
 	Endpoint   string `mapstructure:"endpoint"`
 	AccessKey  string `mapstructure:"access_key"`
 	SecretKey  string `mapstructure:"secret_key"`
 	BucketName string `mapstructure:"bucket_name"`
 	UseSSL     bool   `mapstructure:"use_ssl"`
+
+	QueueSize         int           `mapstructure:"queue_size"`         // Pending replications; writes beyond it are only caught by reconciliation
+	Workers           int           `mapstructure:"workers"`            // Concurrent replications
+	DrainTimeout      time.Duration `mapstructure:"drain_timeout"`      // Time given to pending replications on shutdown
+	ReconcileInterval time.Duration `mapstructure:"reconcile_interval"` // Interval of the divergence check; 0 disables it
+	ReconcileGrace    time.Duration `mapstructure:"reconcile_grace"`    // Objects changed more recently are not compared yet
+}
+
+// StorageLifecycle holds the lifecycle rules of the bucket. When managed, the rules of the
+// bucket are replaced by the configured ones on startup; no rules remove them all.
+type StorageLifecycle struct {
+	Managed bool            `mapstructure:"managed"`
+	Rules   []LifecycleRule `mapstructure:"rules"`
+}
+
+// LifecycleRule expires or transitions the objects under a prefix some days after their creation.
+type LifecycleRule struct {
+	ID                  string `mapstructure:"id"`
+	Prefix              string `mapstructure:"prefix"`
+	ExpireAfterDays     int    `mapstructure:"expire_after_days"`     // Zero never expires the objects
+	TransitionAfterDays int    `mapstructure:"transition_after_days"` // Zero never transitions the objects
+	StorageClass        string `mapstructure:"storage_class"`         // Storage class (tier) objects are transitioned to
+}
+
+// StorageEncryption holds the server-side encryption of stored objects.
+type StorageEncryption struct {
+	// Type is "sse-s3", "sse-kms" or "sse-c" (customer key, requires TLS); empty disables encryption.
+	Type       string            `mapstructure:"type"`
+	KMSKeyID   string            `mapstructure:"kms_key_id"`  // KMS key encrypting the objects with sse-kms
+	KMSContext map[string]string `mapstructure:"kms_context"` // Optional encryption context of sse-kms
+	// CustomerKey is the base64-encoded 256-bit key of sse-c, needed to read the objects back.
+	CustomerKey string `mapstructure:"customer_key"`
+}
+
+// Queue selects the message broker processing tasks are queued on.
+type Queue struct {
+	Type string `mapstructure:"type"` // "kafka" (default), "nats", "sqs", "memory" or "postgres"
+	// Format of the job messages: "json" (default), "avro" or "protobuf". Avro and Protobuf
+	// register their schema with SchemaRegistry and use the Confluent wire format.
+	Format         string         `mapstructure:"format"`
+	SchemaRegistry SchemaRegistry `mapstructure:"schema_registry"`
+	// MaxAttempts caps the processing attempts of a single message across redeliveries and
+	// retry tiers. Messages exceeding it get their image marked failed and are acknowledged.
+	// Zero disables the cap.
+	MaxAttempts int `mapstructure:"max_attempts"`
+
+	Outbox Outbox `mapstructure:"outbox"` // Transactional outbox of upload tasks
+}
+
+// Outbox holds configuration for enqueueing upload tasks through the transactional outbox.
+type Outbox struct {
+	// Enabled stores the tasks of uploads in the transaction recording their image,
+	// published by a relay in the API process, instead of producing them directly.
+	Enabled   bool          `mapstructure:"enabled"`
+	Interval  time.Duration `mapstructure:"interval"`   // How often the outbox is relayed
+	BatchSize int           `mapstructure:"batch_size"` // Max tasks published per transaction
+}
+
+// NATS holds configuration for the NATS JetStream message queue.
+type NATS struct {
+	URL     string        `mapstructure:"url"`      // Server URL, e.g. nats://nats:4222
+	Stream  string        `mapstructure:"stream"`   // JetStream stream holding the tasks, created if missing
+	Subject string        `mapstructure:"subject"`  // Subject tasks are published to
+	Durable string        `mapstructure:"durable"`  // Durable consumer shared by the workers
+	AckWait time.Duration `mapstructure:"ack_wait"` // Time a task may go unacknowledged before redelivery; extended while processing
+	// RetryDelays are the delays before failed tasks are redelivered, tried in order before
+	// the task is recorded as a dead letter.
+	RetryDelays []time.Duration `mapstructure:"retry_delays"`
+}
+
+// SQS holds configuration for the AWS SQS message queue.
+// Credentials are taken from the default AWS chain (environment, shared config, instance role).
+type SQS struct {
+	Region   string `mapstructure:"region"`    // AWS region of the queue
+	QueueURL string `mapstructure:"queue_url"` // URL of the tasks queue
+	Endpoint string `mapstructure:"endpoint"`  // Custom endpoint, e.g. LocalStack; empty uses AWS
+	// VisibilityTimeout is how long a received task stays hidden from other workers;
+	// it is extended while the task is processed.
+	VisibilityTimeout time.Duration `mapstructure:"visibility_timeout"`
+	WaitTime          time.Duration `mapstructure:"wait_time"` // Long-polling wait of receives, at most 20s
+	// DrainTimeout bounds finishing and deleting the task in flight on shutdown (default 30s).
+	DrainTimeout time.Duration `mapstructure:"drain_timeout"`
+	// RetryDelays are the delays before failed tasks become visible again, tried in order
+	// before the task is recorded as a dead letter. Each is at most 12h.
+	RetryDelays []time.Duration `mapstructure:"retry_delays"`
+}
+
+// Memory holds configuration for the in-process queue used in local development and tests.
+// Queued tasks are lost when the process exits.
+type Memory struct {
+	Buffer  int `mapstructure:"buffer"`  // Number of tasks queued before publishing blocks
+	Workers int `mapstructure:"workers"` // Number of tasks processed concurrently
+	// RetryDelays are the delays before failed tasks are requeued, tried in order before
+	// the task is recorded as a dead letter.
+	RetryDelays []time.Duration `mapstructure:"retry_delays"`
+}
+
+// PGQueue holds configuration for the queue kept in the jobs table of the PostgreSQL database,
+// so that small deployments run without a broker.
+type PGQueue struct {
+	Workers      int           `mapstructure:"workers"`       // Number of tasks processed concurrently
+	PollInterval time.Duration `mapstructure:"poll_interval"` // How often idle workers look for due tasks
+	// DrainTimeout bounds finishing the tasks in flight on shutdown (default 30s).
+	DrainTimeout time.Duration `mapstructure:"drain_timeout"`
+	// RetryDelays are the delays before failed tasks are run again, tried in order before
+	// the task is recorded as a dead letter.
+	RetryDelays []time.Duration `mapstructure:"retry_delays"`
 }
 
 // Kafka holds configuration for the Kafka message queue.
 type Kafka struct {
-	GroupID string   `mapstructure:"group_id"` // Consumer group ID
-	Topic   string   `mapstructure:"topic"`    // Kafka topic name
-	Brokers []string `mapstructure:"brokers"`  // List of Kafka broker addresses
+	GroupID  string   `mapstructure:"group_id"`  // Consumer group ID
+	Topic    string   `mapstructure:"topic"`     // Kafka topic name
+	DLQTopic string   `mapstructure:"dlq_topic"` // Topic of messages failing after retries, empty disables the DLQ
+	Brokers  []string `mapstructure:"brokers"`   // List of Kafka broker addresses
+
+	RetryTopics []RetryTopic `mapstructure:"retry_topics"` // Delayed retry tiers, tried in order before the DLQ
+
+	// EventsTopic receives an "image.processed" event after each processed job; empty disables the events.
+	EventsTopic string `mapstructure:"events_topic"`
+
+	// ActionTopics routes the jobs of an action to its own topic, so that cheap and expensive
+	// actions can be scaled independently. Unmapped actions use Topic.
+	ActionTopics map[string]string `mapstructure:"action_topics"`
+	// PriorityTopics routes the jobs of a priority ("high", "low") to its own topic, taking
+	// precedence over ActionTopics. Normal priority jobs and unmapped priorities are routed by action.
+	PriorityTopics map[string]string `mapstructure:"priority_topics"`
+	// ConsumeTopics limits the topics consumed by this instance; empty consumes all of them.
+	ConsumeTopics []string `mapstructure:"consume_topics"`
+	// Producer tunes compression and batching of the writers of all produced topics.
+	Producer KafkaProducer `mapstructure:"producer"`
+	// Commit selects how consumers commit the offsets of handled messages.
+	Commit KafkaCommit `mapstructure:"commit"`
+	// DrainTimeout bounds finishing and committing the messages in flight on shutdown (default 30s).
+	DrainTimeout time.Duration `mapstructure:"drain_timeout"`
+	// Group tunes the consumer group membership, e.g. to shorten rebalances during rolling deploys.
+	Group KafkaGroup `mapstructure:"group"`
+	// Batch makes consumers of the job topics fetch and process messages in batches.
+	Batch KafkaBatch `mapstructure:"batch"`
+	// PartitionWorkers processes each assigned partition in its own goroutine, in order, so that
+	// throughput scales with the partitions while jobs of an image keep their order.
+	// Batched consumption takes precedence on the job topics.
+	PartitionWorkers bool `mapstructure:"partition_workers"`
+	// SASL and TLS secure the connections to the brokers, e.g. of a managed cluster.
+	SASL KafkaSASL `mapstructure:"sasl"`
+	TLS  KafkaTLS  `mapstructure:"tls"`
+}
+
+// KafkaSASL holds the SASL authentication of the Kafka clients.
+type KafkaSASL struct {
+	// Mechanism is "plain", "scram-sha-256" or "scram-sha-512"; empty disables SASL.
+	Mechanism string `mapstructure:"mechanism"`
+	Username  string `mapstructure:"username"`
+	Password  string `mapstructure:"password"`
+}
+
+// KafkaTLS holds the TLS settings of the Kafka clients.
+type KafkaTLS struct {
+	Enabled bool   `mapstructure:"enabled"` // Connect to the brokers over TLS
+	CAFile  string `mapstructure:"ca_file"` // PEM CA bundle verifying the brokers, empty uses the system pool
+	// CertFile and KeyFile hold a PEM client certificate for mutual TLS; both empty disable it.
+	CertFile           string `mapstructure:"cert_file"`
+	KeyFile            string `mapstructure:"key_file"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"` // Skip verifying the broker certificates, for testing only
+}
+
+// KafkaCommit holds the offset commit strategy of consumers.
+type KafkaCommit struct {
+	// Mode is "sync" (default) to commit every message before fetching the next one, or "async"
+	// to commit in the background every Interval or once BatchSize messages are pending.
+	Mode      string        `mapstructure:"mode"`
+	Interval  time.Duration `mapstructure:"interval"`   // Max time offsets stay uncommitted in async mode (default 1s)
+	BatchSize int           `mapstructure:"batch_size"` // Pending messages triggering an async commit (default 100)
+}
+
+// KafkaBatch holds the batched consumption settings of the job topics; retry topics are
+// always consumed message by message, as their messages wait for their delay.
+type KafkaBatch struct {
+	Size        int           `mapstructure:"size"`        // Max messages per batch; 0 or 1 consumes one message at a time
+	Wait        time.Duration `mapstructure:"wait"`        // Max time spent filling a batch once its first message arrived (default 100ms)
+	Concurrency int           `mapstructure:"concurrency"` // Partitions of a batch processed concurrently (default all), each in order
+}
+
+// KafkaGroup holds the consumer group membership settings of consumers.
+// Zero values keep the kafka-go defaults.
+type KafkaGroup struct {
+	SessionTimeout    time.Duration `mapstructure:"session_timeout"`    // Time without heartbeats before a member is evicted (default 30s)
+	RebalanceTimeout  time.Duration `mapstructure:"rebalance_timeout"`  // Time members get to rejoin during a rebalance (default 30s)
+	HeartbeatInterval time.Duration `mapstructure:"heartbeat_interval"` // Frequency of heartbeats (default 3s)
+	// Balancers are the partition assignment strategies offered to the group, in order of
+	// preference: "range", "round-robin" or "rack-affinity" (default range, round-robin).
+	Balancers []string `mapstructure:"balancers"`
+	Rack      string   `mapstructure:"rack"` // Rack of this instance, used by the rack-affinity balancer
+}
+
+// KafkaProducer holds the compression and batching settings of Kafka writers.
+// Zero values keep the kafka-go defaults.
+type KafkaProducer struct {
+	Compression string        `mapstructure:"compression"` // none, gzip, snappy, lz4 or zstd
+	BatchSize   int           `mapstructure:"batch_size"`  // Max messages per batch (default 100)
+	BatchBytes  int64         `mapstructure:"batch_bytes"` // Max size of a batch in bytes (default 1 MiB)
+	Linger      time.Duration `mapstructure:"linger"`      // Max time to wait for a batch to fill up (default 1s)
+}
+
+// SchemaRegistry holds the connection settings of a Confluent-compatible schema registry.
+type SchemaRegistry struct {
+	URL     string        `mapstructure:"url"`     // Base URL of the registry, e.g. http://schema-registry:8081
+	Timeout time.Duration `mapstructure:"timeout"` // Timeout of registry requests
+}
+
+// RetryTopic holds a retry tier: failed messages are re-processed from the topic after the delay.
+type RetryTopic struct {
+	Topic string        `mapstructure:"topic"` // Kafka topic of the tier
+	Delay time.Duration `mapstructure:"delay"` // Time a message waits in the topic before it is processed again
 }
 
 // Retry defines retry policy configuration.
@@ -65,6 +352,250 @@ type Retry struct {
 	Backoff  float64       `mapstructure:"backoff"`  // Backoff multiplier for delays
 }
 
+// Session holds configuration for interactive editing sessions.
+type Session struct {
+	TTL         time.Duration `mapstructure:"ttl"`          // Idle time after which a session is discarded
+	PreviewSize int           `mapstructure:"preview_size"` // Max width/height of preview derivatives in pixels
+}
+
+// OCR holds configuration for the optional text extraction step.
+type OCR struct {
+	Enabled   bool          `mapstructure:"enabled"`   // Run OCR on uploaded images during processing
+	Binary    string        `mapstructure:"binary"`    // Path to the tesseract executable
+	Languages string        `mapstructure:"languages"` // Tesseract languages, e.g. "eng+rus"
+	Timeout   time.Duration `mapstructure:"timeout"`   // Max duration of a single extraction
+}
+
+// Antivirus holds configuration for the optional ClamAV scan of uploads.
+type Antivirus struct {
+	Enabled bool          `mapstructure:"enabled"` // Scan uploads before they are stored; uploads fail if clamd is down
+	Network string        `mapstructure:"network"` // "tcp" or "unix"
+	Address string        `mapstructure:"address"` // clamd address, e.g. "clamav:3310"
+	Timeout time.Duration `mapstructure:"timeout"` // Max duration of a single scan
+}
+
+// Webhook holds configuration for outbound processing webhooks.
+type Webhook struct {
+	Secret  string        `mapstructure:"secret"`  // Shared secret used to sign payloads
+	Timeout time.Duration `mapstructure:"timeout"` // Timeout of a single delivery attempt
+}
+
+// CDN holds the caching headers of served images and the purging of the CDN in front of the API.
+type CDN struct {
+	CacheControl          string        `mapstructure:"cache_control"`           // Cache-Control of originals and variants served by ID
+	TransformCacheControl string        `mapstructure:"transform_cache_control"` // Cache-Control of on-the-fly transforms
+	Provider              string        `mapstructure:"provider"`                // Purge provider: "fastly", "cloudfront" or "" to disable purging
+	PathPrefixes          []string      `mapstructure:"path_prefixes"`           // API route prefixes purged by path, e.g. "/api/v1"
+	Timeout               time.Duration `mapstructure:"timeout"`                 // Timeout of a single purge request
+
+	Fastly     CDNFastly     `mapstructure:"fastly"`
+	CloudFront CDNCloudFront `mapstructure:"cloudfront"`
+}
+
+// CDNFastly holds the Fastly service purged by surrogate key.
+type CDNFastly struct {
+	ServiceID string `mapstructure:"service_id"`
+	APIToken  string `mapstructure:"api_token"`
+}
+
+// CDNCloudFront holds the CloudFront distribution purged by path invalidation.
+// AWS credentials come from the default credential chain.
+type CDNCloudFront struct {
+	DistributionID string `mapstructure:"distribution_id"`
+}
+
+// Quota holds per-user resource limits. Zero disables a limit.
+type Quota struct {
+	MaxBytes     int64 `mapstructure:"max_bytes"`      // Max total bytes stored per user
+	ImagesPerDay int   `mapstructure:"images_per_day"` // Max images uploaded per user in 24 hours
+	JobsPerHour  int   `mapstructure:"jobs_per_hour"`  // Max processing jobs per user in an hour
+}
+
+// Users holds configuration of how the users requests are made by are identified. Users are named
+// by the X-User-ID header, which only the authenticating proxies in front of the API may set.
+type Users struct {
+	// TrustedProxies lists the IP addresses or CIDR networks of the proxies whose X-User-ID header
+	// is trusted; the header of requests from other peers is removed.
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+}
+
+// Proxies returns the networks of the trusted proxies, addresses as single-address networks.
+func (u Users) Proxies() ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(u.TrustedProxies))
+	for _, s := range u.TrustedProxies {
+		s = strings.TrimSpace(s)
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", s, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", s, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+// Admin holds configuration for the operator admin API.
+type Admin struct {
+	Token      string        `mapstructure:"token"`       // Bearer token required by /api/admin, empty disables it
+	StuckAfter time.Duration `mapstructure:"stuck_after"` // Idle time after which an unfinished job is stuck
+}
+
+// CORS holds the Cross-Origin Resource Sharing policy of the HTTP API.
+// With no allowed origins, cross-origin requests are rejected.
+type CORS struct {
+	AllowedOrigins   []string      `mapstructure:"allowed_origins"`   // Exact origins, or "*" for any
+	AllowedMethods   []string      `mapstructure:"allowed_methods"`   // Methods allowed in preflight responses
+	AllowedHeaders   []string      `mapstructure:"allowed_headers"`   // Request headers allowed in preflight responses
+	AllowCredentials bool          `mapstructure:"allow_credentials"` // Allow cookies and auth headers
+	MaxAge           time.Duration `mapstructure:"max_age"`           // How long browsers may cache preflight results
+}
+
+// Limits bounds the dimensions of images accepted for upload and decoding. Zero disables a limit.
+type Limits struct {
+	MaxWidth  int   `mapstructure:"max_width"`  // Max image width in pixels
+	MaxHeight int   `mapstructure:"max_height"` // Max image height in pixels
+	MaxPixels int64 `mapstructure:"max_pixels"` // Max width*height, guards against decompression bombs
+}
+
+// Actions restricts the actions accepted by uploads and the values of their parameters.
+type Actions struct {
+	Enabled []string `mapstructure:"enabled"` // Actions accepted by uploads, empty enables all
+	Width   Bounds   `mapstructure:"width"`   // Bounds of the width parameter in pixels
+	Height  Bounds   `mapstructure:"height"`  // Bounds of the height parameter in pixels
+	Quality Bounds   `mapstructure:"quality"` // Bounds of the JPEG quality parameter, from 1 to 100
+}
+
+// Bounds limits the values of an integer parameter. Zero disables a bound.
+type Bounds struct {
+	Min int `mapstructure:"min"` // Min value accepted
+	Max int `mapstructure:"max"` // Max value accepted
+}
+
+// Redrive holds configuration of the re-driver of stuck processing jobs, which covers
+// lost queue messages and crashed workers.
+type Redrive struct {
+	Interval    time.Duration `mapstructure:"interval"`     // How often stuck jobs are looked for, zero disables the re-driver
+	StuckAfter  time.Duration `mapstructure:"stuck_after"`  // Idle time after which an unfinished job is re-driven
+	MaxRedrives int           `mapstructure:"max_redrives"` // Times a job is re-enqueued before it is failed instead
+	BatchSize   int           `mapstructure:"batch_size"`   // Max jobs handled per query
+}
+
+// ErrorReporting holds configuration of the reporting of errors and panics to Sentry
+// or a compatible service (e.g. GlitchTip).
+type ErrorReporting struct {
+	DSN          string        `mapstructure:"dsn"`           // Project DSN, from SENTRY_DSN; empty disables reporting
+	Environment  string        `mapstructure:"environment"`   // Environment the events are tagged with
+	Release      string        `mapstructure:"release"`       // Release the events are tagged with
+	SampleRate   float64       `mapstructure:"sample_rate"`   // Share of the errors reported, between 0 and 1
+	FlushTimeout time.Duration `mapstructure:"flush_timeout"` // Time given to the pending events to be sent on shutdown
+}
+
+// SLO holds the processing latency objectives of the actions, tracked in memory over a rolling window.
+type SLO struct {
+	Window           time.Duration               `mapstructure:"window"`             // Rolling window of the tracked jobs
+	MaxSamples       int                         `mapstructure:"max_samples"`        // Max jobs kept per action within the window
+	Default          LatencyObjective            `mapstructure:"default"`            // Objective of actions without their own
+	Actions          map[string]LatencyObjective `mapstructure:"actions"`            // Objectives by action
+	WarnBurnRate     float64                     `mapstructure:"warn_burn_rate"`     // Burn rate of the warning state
+	CriticalBurnRate float64                     `mapstructure:"critical_burn_rate"` // Burn rate of the critical state
+}
+
+// Log holds the logging configuration.
+type Log struct {
+	Level string `mapstructure:"level"` // Min level logged: debug, info (default), warn or error
+}
+
+// Watermark holds the defaults of the watermark action, for jobs not setting them.
+type Watermark struct {
+	Text  string  `mapstructure:"text"`  // Text drawn on the image
+	Font  string  `mapstructure:"font"`  // Name of the font, empty for the embedded DejaVuSans
+	Scale float64 `mapstructure:"scale"` // Font size as a share of the image width
+}
+
+// Fonts holds configuration of the fonts text is drawn with.
+type Fonts struct {
+	Dir string `mapstructure:"dir"` // Directory of TrueType fonts, named by file name without .ttf; empty for the embedded font only
+}
+
+// Reload holds configuration of reloading the settings while the app runs: retry, quota, watermark
+// and log. They are reloaded on SIGHUP and, with WatchFile, once the config file is written.
+// Other settings apply on restart.
+type Reload struct {
+	WatchFile bool `mapstructure:"watch_file"` // Reload once the config file changes
+}
+
+// LatencyObjective requires a share of the jobs of an action to be processed within a threshold.
+type LatencyObjective struct {
+	Threshold time.Duration `mapstructure:"threshold"` // Processing time jobs should finish within
+	Target    float64       `mapstructure:"target"`    // Share of jobs finishing within the threshold, e.g. 0.99
+}
+
+// SecretRefPrefix prefixes the credentials settings referencing a secret of the secrets backend
+// instead of holding the credential, as "secret:<path>#<key>".
+const SecretRefPrefix = "secret:"
+
+// Secrets holds the backend resolving the credentials referencing its secrets on startup.
+type Secrets struct {
+	Provider string `mapstructure:"provider"` // "vault", "aws" (Secrets Manager) or "" to disable
+	// RefreshInterval is how often the referenced secrets are checked for rotation; zero disables it.
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"`
+	Timeout         time.Duration `mapstructure:"timeout"` // Timeout of a single secret read
+
+	Vault SecretsVault `mapstructure:"vault"`
+	AWS   SecretsAWS   `mapstructure:"aws"`
+}
+
+// SecretsVault holds the HashiCorp Vault server whose KV version 2 secrets are referenced.
+type SecretsVault struct {
+	Address   string `mapstructure:"address"`   // Server URL, e.g. https://vault:8200
+	Token     string `mapstructure:"token"`     // Token reading the secrets, from VAULT_TOKEN
+	Namespace string `mapstructure:"namespace"` // Enterprise namespace, empty for none
+}
+
+// SecretsAWS holds the AWS Secrets Manager region whose secrets are referenced.
+// Credentials are taken from the default AWS chain.
+type SecretsAWS struct {
+	Region   string `mapstructure:"region"`
+	Endpoint string `mapstructure:"endpoint"` // Custom endpoint, e.g. LocalStack; empty uses AWS
+}
+
+// Credentials returns the settings that may reference a secret, by key.
+func (c *Config) Credentials() map[string]*string {
+	creds := map[string]*string{
+		"database.master.user":       &c.Database.Master.User,
+		"database.master.pass":       &c.Database.Master.Pass,
+		"storage.access_key":         &c.Storage.AccessKey,
+		"storage.secret_key":         &c.Storage.SecretKey,
+		"storage.replica.access_key": &c.Storage.Replica.AccessKey,
+		"storage.replica.secret_key": &c.Storage.Replica.SecretKey,
+		"kafka.sasl.username":        &c.Kafka.SASL.Username,
+		"kafka.sasl.password":        &c.Kafka.SASL.Password,
+	}
+	for i := range c.Database.Slaves {
+		creds[fmt.Sprintf("database.slaves[%d].user", i)] = &c.Database.Slaves[i].User
+		creds[fmt.Sprintf("database.slaves[%d].pass", i)] = &c.Database.Slaves[i].Pass
+	}
+
+	return creds
+}
+
+// Expiry holds configuration for the background deletion of images past their expires_at
+// and of the files and records of deleted images.
+type Expiry struct {
+	SweepInterval    time.Duration `mapstructure:"sweep_interval"`    // How often expired images are looked for, zero disables sweeping
+	BatchSize        int           `mapstructure:"batch_size"`        // Max images deleted per query
+	DeletedRetention time.Duration `mapstructure:"deleted_retention"` // How long deleted images are kept before the sweeper purges them
+}
+
 // DSN returns the PostgreSQL DSN string for connecting to this database node.
 func (n DatabaseNode) DSN() string {
 	return fmt.Sprintf(
@@ -73,16 +604,41 @@ func (n DatabaseNode) DSN() string {
 	)
 }
 
+// MySQLDSN returns the MySQL DSN string for connecting to this database node.
+// Times are exchanged in UTC, and updates report the rows matched rather than changed.
+func (n DatabaseNode) MySQLDSN() string {
+	tls := "false"
+	if n.SSLMode != "" && n.SSLMode != "disable" {
+		tls = "true"
+	}
+
+	return fmt.Sprintf(
+		"%s:%s@tcp(%s:%s)/%s?tls=%s&parseTime=true&loc=UTC&time_zone=%%27%%2B00%%3A00%%27"+
+			"&clientFoundRows=true&multiStatements=true",
+		n.User, n.Pass, n.Host, n.Port, n.Name, tls,
+	)
+}
+
 // mustBindEnv binds critical environment variables to Viper keys.
 //
 // It panics if any environment variable cannot be bound.
 func mustBindEnv() {
 	bindings := map[string]string{
-		"database.master.host": "DB_HOST",
-		"database.master.port": "DB_PORT",
-		"database.master.user": "DB_USER",
-		"database.master.pass": "DB_PASSWORD",
-		"database.master.name": "DB_NAME",
+		"database.master.host":            "DB_HOST",
+		"database.master.port":            "DB_PORT",
+		"database.master.user":            "DB_USER",
+		"database.master.pass":            "DB_PASSWORD",
+		"database.master.name":            "DB_NAME",
+		"webhook.secret":                  "WEBHOOK_SECRET",
+		"admin.token":                     "ADMIN_TOKEN",
+		"kafka.sasl.username":             "KAFKA_SASL_USERNAME",
+		"kafka.sasl.password":             "KAFKA_SASL_PASSWORD",
+		"storage.encryption.customer_key": "STORAGE_SSE_CUSTOMER_KEY",
+		"cors.allowed_origins":            "CORS_ALLOWED_ORIGINS",
+		"users.trusted_proxies":           "TRUSTED_PROXIES",
+		"cdn.fastly.api_token":            "FASTLY_API_TOKEN",
+		"error_reporting.dsn":             "SENTRY_DSN",
+		"secrets.vault.token":             "VAULT_TOKEN",
 	}
 
 	for key, env := range bindings {
@@ -93,7 +649,8 @@ func mustBindEnv() {
 }
 
 // MustLoad loads the configuration from the specified file path.
-// It panics if the configuration file cannot be loaded or unmarshaled.
+// It panics if the configuration file cannot be loaded or unmarshaled, and exits listing
+// the problems found if it is invalid.
 func MustLoad(path string) *Config {
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
@@ -111,5 +668,33 @@ func MustLoad(path string) *Config {
 		zlog.Logger.Panic().Err(err).Msgf("failed to unmarshal config: %v", err)
 	}
 
+	// Report every invalid setting at once instead of failing on the first client using one.
+	if err := cfg.Validate(); err != nil {
+		var verr *ValidationError
+		if errors.As(err, &verr) {
+			zlog.Logger.Fatal().Strs("problems", verr.Problems).Msgf("invalid config: %d problems", len(verr.Problems))
+		}
+		zlog.Logger.Fatal().Err(err).Msg("invalid config")
+	}
+
 	return &cfg
 }
+
+// Read reads the configuration loaded by MustLoad again and validates it, returning an error
+// instead of exiting, so that the app keeps its settings if the file is broken while it runs.
+func Read() (*Config, error) {
+	if err := viper.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var cfg Config
+	if err := viper.Unmarshal(&cfg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
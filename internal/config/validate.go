@@ -0,0 +1,487 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits of the settings checked by Validate.
+const (
+	minPartSize       = 5 << 20 // smallest part of multipart uploads accepted by S3
+	maxSQSWaitTime    = 20 * time.Second
+	maxSQSRetryDelay  = 12 * time.Hour
+	defaultDriver     = "postgres"
+	defaultStorage    = "minio"
+	defaultQueue      = "kafka"
+	defaultFormat     = "json"
+	defaultCommitMode = "sync"
+)
+
+// ValidationError lists all the problems found in a configuration, each prefixed with the key of
+// the setting, e.g. "kafka.brokers: must not be empty".
+type ValidationError struct {
+	Problems []string
+}
+
+// Error implements error.
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid config: %s", strings.Join(e.Problems, "; "))
+}
+
+// problems collects the problems found by Validate.
+type problems []string
+
+// add records a problem with the setting of the key.
+func (p *problems) add(key, format string, args ...any) {
+	*p = append(*p, key+": "+fmt.Sprintf(format, args...))
+}
+
+// required records the setting of the key as missing if empty.
+func (p *problems) required(key, v string) {
+	if strings.TrimSpace(v) == "" {
+		p.add(key, "must not be empty")
+	}
+}
+
+// oneOf records the setting of the key as invalid unless it is one of the allowed values.
+func (p *problems) oneOf(key, v string, allowed ...string) {
+	if !slices.Contains(allowed, v) {
+		p.add(key, "unknown value %q, expected one of %s", v, strings.Join(allowed, ", "))
+	}
+}
+
+// nonNegative records the durations of the keys as invalid if negative, in the order of the keys.
+func (p *problems) nonNegative(durations map[string]time.Duration) {
+	for _, key := range slices.Sorted(maps.Keys(durations)) {
+		if durations[key] < 0 {
+			p.add(key, "must not be negative, got %s", durations[key])
+		}
+	}
+}
+
+// port records the setting of the key as invalid unless it is a port number.
+func (p *problems) port(key, v string) {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > 65535 {
+		p.add(key, "invalid port %q, expected a number from 1 to 65535", v)
+	}
+}
+
+// hostPort records the setting of the key as invalid unless it is an address such as "host:9092"
+// or ":8080".
+func (p *problems) hostPort(key, v string) {
+	_, port, err := net.SplitHostPort(v)
+	if err != nil {
+		p.add(key, "invalid address %q, expected host:port", v)
+		return
+	}
+
+	p.port(key, port)
+}
+
+// Validate checks the settings of the selected backends and features and returns a
+// *ValidationError listing all the problems found, so that they can be fixed at once rather than
+// failing one by one deep in the constructors of the clients.
+func (c *Config) Validate() error {
+	var p problems
+
+	c.validateServer(&p)
+	c.validateDatabase(&p)
+	c.validateStorage(&p)
+	c.validateQueue(&p)
+	c.validateFeatures(&p)
+	c.validateSecrets(&p)
+
+	if len(p) > 0 {
+		return &ValidationError{Problems: p}
+	}
+
+	return nil
+}
+
+// validateServer checks the HTTP server settings.
+func (c *Config) validateServer(p *problems) {
+	p.hostPort("server.http_port", c.Server.HTTPPort)
+}
+
+// validateDatabase checks the connection settings of the selected database backend.
+func (c *Config) validateDatabase(p *problems) {
+	db := c.Database
+
+	driver := db.Driver
+	if driver == "" {
+		driver = defaultDriver
+	}
+	p.oneOf("database.driver", driver, "postgres", "mysql", "sqlite")
+
+	switch driver {
+	case "postgres", "mysql":
+		validateNode(p, "database.master", db.Master)
+		if driver == "postgres" {
+			for i, s := range db.Slaves {
+				validateNode(p, fmt.Sprintf("database.slaves[%d]", i), s)
+			}
+		}
+	case "sqlite":
+		p.required("database.path", db.Path)
+	}
+
+	if db.MaxOpenConns < 0 {
+		p.add("database.max_open_conns", "must not be negative, got %d", db.MaxOpenConns)
+	}
+	if db.MaxIdleConns < 0 {
+		p.add("database.max_idle_conns", "must not be negative, got %d", db.MaxIdleConns)
+	}
+	if db.Partitions.MonthsAhead < 0 {
+		p.add("database.partitions.months_ahead", "must not be negative, got %d", db.Partitions.MonthsAhead)
+	}
+	p.nonNegative(map[string]time.Duration{
+		"database.conn_max_lifetime":   db.ConnMaxLifetime,
+		"database.partitions.interval": db.Partitions.Interval,
+	})
+}
+
+// validateNode checks the connection settings of a database node.
+func validateNode(p *problems, key string, n DatabaseNode) {
+	p.required(key+".host", n.Host)
+	p.port(key+".port", n.Port)
+	p.required(key+".user", n.User)
+	p.required(key+".name", n.Name)
+}
+
+// validateStorage checks the settings of the file storage and its replica.
+func (c *Config) validateStorage(p *problems) {
+	s := c.Storage
+
+	typ := s.Type
+	if typ == "" {
+		typ = defaultStorage
+	}
+	p.oneOf("storage.type", typ, "minio")
+
+	p.required("storage.endpoint", s.Endpoint)
+	p.required("storage.access_key", s.AccessKey)
+	p.required("storage.secret_key", s.SecretKey)
+	p.required("storage.bucket_name", s.BucketName)
+
+	if s.PartSize != 0 && s.PartSize < minPartSize {
+		p.add("storage.part_size", "must be at least %d bytes (5 MiB), got %d", minPartSize, s.PartSize)
+	}
+
+	switch s.Encryption.Type {
+	case "", "sse-s3":
+	case "sse-kms":
+		p.required("storage.encryption.kms_key_id", s.Encryption.KMSKeyID)
+	case "sse-c":
+		p.required("storage.encryption.customer_key", s.Encryption.CustomerKey)
+		if !s.UseSSL {
+			p.add("storage.use_ssl", "must be enabled with sse-c encryption")
+		}
+	default:
+		p.oneOf("storage.encryption.type", s.Encryption.Type, "sse-s3", "sse-kms", "sse-c")
+	}
+
+	for i, r := range s.Lifecycle.Rules {
+		key := fmt.Sprintf("storage.lifecycle.rules[%d]", i)
+		p.required(key+".id", r.ID)
+		if r.ExpireAfterDays < 0 || r.TransitionAfterDays < 0 {
+			p.add(key, "days must not be negative")
+		}
+		if r.TransitionAfterDays > 0 {
+			p.required(key+".storage_class", r.StorageClass)
+		}
+	}
+
+	if s.Replica.Enabled {
+		p.required("storage.replica.endpoint", s.Replica.Endpoint)
+		p.required("storage.replica.access_key", s.Replica.AccessKey)
+		p.required("storage.replica.secret_key", s.Replica.SecretKey)
+		p.required("storage.replica.bucket_name", s.Replica.BucketName)
+	}
+
+	p.nonNegative(map[string]time.Duration{
+		"storage.breaker.cooldown":           s.Breaker.Cooldown,
+		"storage.breaker.probe_interval":     s.Breaker.ProbeInterval,
+		"storage.replica.drain_timeout":      s.Replica.DrainTimeout,
+		"storage.replica.reconcile_interval": s.Replica.ReconcileInterval,
+		"storage.replica.reconcile_grace":    s.Replica.ReconcileGrace,
+	})
+}
+
+// validateQueue checks the message format and the settings of the selected queue backend.
+func (c *Config) validateQueue(p *problems) {
+	q := c.Queue
+
+	typ := q.Type
+	if typ == "" {
+		typ = defaultQueue
+	}
+	p.oneOf("queue.type", typ, "kafka", "nats", "sqs", "memory", "postgres")
+
+	format := q.Format
+	if format == "" {
+		format = defaultFormat
+	}
+	p.oneOf("queue.format", format, "json", "avro", "protobuf")
+	if format != "json" {
+		p.required("queue.schema_registry.url", q.SchemaRegistry.URL)
+	}
+
+	if q.MaxAttempts < 0 {
+		p.add("queue.max_attempts", "must not be negative, got %d", q.MaxAttempts)
+	}
+	if q.Outbox.Enabled && q.Outbox.Interval <= 0 {
+		p.add("queue.outbox.interval", "must be positive when the outbox is enabled")
+	}
+
+	switch typ {
+	case "kafka":
+		c.validateKafka(p)
+	case "nats":
+		p.required("nats.url", c.NATS.URL)
+		p.required("nats.stream", c.NATS.Stream)
+		p.required("nats.subject", c.NATS.Subject)
+		p.required("nats.durable", c.NATS.Durable)
+		p.nonNegative(map[string]time.Duration{"nats.ack_wait": c.NATS.AckWait})
+		validateDelays(p, "nats.retry_delays", c.NATS.RetryDelays, 0)
+	case "sqs":
+		p.required("sqs.region", c.SQS.Region)
+		p.required("sqs.queue_url", c.SQS.QueueURL)
+		if c.SQS.WaitTime < 0 || c.SQS.WaitTime > maxSQSWaitTime {
+			p.add("sqs.wait_time", "must be between 0 and %s, got %s", maxSQSWaitTime, c.SQS.WaitTime)
+		}
+		p.nonNegative(map[string]time.Duration{"sqs.visibility_timeout": c.SQS.VisibilityTimeout})
+		validateDelays(p, "sqs.retry_delays", c.SQS.RetryDelays, maxSQSRetryDelay)
+	case "memory":
+		validateDelays(p, "memory.retry_delays", c.Memory.RetryDelays, 0)
+	case "postgres":
+		if c.Database.Driver != "" && c.Database.Driver != "postgres" {
+			p.add("queue.type", "the postgres queue requires the postgres database driver")
+		}
+		p.nonNegative(map[string]time.Duration{
+			"pg_queue.poll_interval": c.PGQueue.PollInterval,
+			"pg_queue.drain_timeout": c.PGQueue.DrainTimeout,
+		})
+		validateDelays(p, "pg_queue.retry_delays", c.PGQueue.RetryDelays, 0)
+	}
+
+	if c.Retry.Attempts < 1 {
+		p.add("retry.attempts", "must be at least 1, got %d", c.Retry.Attempts)
+	}
+	if c.Retry.Backoff < 0 || c.StartupRetry.Backoff < 0 {
+		p.add("retry.backoff", "backoff multipliers must not be negative")
+	}
+	if c.StartupRetry.Attempts < 0 {
+		p.add("startup_retry.attempts", "must not be negative, got %d", c.StartupRetry.Attempts)
+	}
+	p.nonNegative(map[string]time.Duration{
+		"retry.delay":         c.Retry.Delay,
+		"startup_retry.delay": c.StartupRetry.Delay,
+	})
+}
+
+// validateKafka checks the Kafka brokers, topics and client settings.
+func (c *Config) validateKafka(p *problems) {
+	k := c.Kafka
+
+	if len(k.Brokers) == 0 {
+		p.add("kafka.brokers", "must not be empty")
+	}
+	for i, b := range k.Brokers {
+		p.hostPort(fmt.Sprintf("kafka.brokers[%d]", i), b)
+	}
+	p.required("kafka.topic", k.Topic)
+	p.required("kafka.group_id", k.GroupID)
+
+	for i, rt := range k.RetryTopics {
+		key := fmt.Sprintf("kafka.retry_topics[%d]", i)
+		p.required(key+".topic", rt.Topic)
+		if rt.Delay <= 0 {
+			p.add(key+".delay", "must be positive, got %s", rt.Delay)
+		}
+	}
+
+	if k.Producer.BatchSize < 0 || k.Producer.BatchBytes < 0 {
+		p.add("kafka.producer", "batch limits must not be negative")
+	}
+	if k.Producer.Compression != "" {
+		p.oneOf("kafka.producer.compression", k.Producer.Compression, "none", "gzip", "snappy", "lz4", "zstd")
+	}
+
+	mode := k.Commit.Mode
+	if mode == "" {
+		mode = defaultCommitMode
+	}
+	p.oneOf("kafka.commit.mode", mode, "sync", "async")
+
+	for i, b := range k.Group.Balancers {
+		p.oneOf(fmt.Sprintf("kafka.group.balancers[%d]", i), b, "range", "round-robin", "rack-affinity")
+	}
+
+	if k.SASL.Mechanism != "" {
+		p.oneOf("kafka.sasl.mechanism", k.SASL.Mechanism, "plain", "scram-sha-256", "scram-sha-512")
+		p.required("kafka.sasl.username", k.SASL.Username)
+		p.required("kafka.sasl.password", k.SASL.Password)
+	}
+	if (k.TLS.CertFile == "") != (k.TLS.KeyFile == "") {
+		p.add("kafka.tls", "cert_file and key_file must be set together")
+	}
+
+	p.nonNegative(map[string]time.Duration{
+		"kafka.producer.linger":          k.Producer.Linger,
+		"kafka.commit.interval":          k.Commit.Interval,
+		"kafka.drain_timeout":            k.DrainTimeout,
+		"kafka.group.session_timeout":    k.Group.SessionTimeout,
+		"kafka.group.rebalance_timeout":  k.Group.RebalanceTimeout,
+		"kafka.group.heartbeat_interval": k.Group.HeartbeatInterval,
+		"kafka.batch.wait":               k.Batch.Wait,
+		"queue.schema_registry.timeout":  c.Queue.SchemaRegistry.Timeout,
+	})
+}
+
+// validateDelays checks the retry delays of the key, each positive and at most limit if set.
+func validateDelays(p *problems, key string, delays []time.Duration, limit time.Duration) {
+	for i, d := range delays {
+		if d <= 0 || (limit > 0 && d > limit) {
+			p.add(fmt.Sprintf("%s[%d]", key, i), "invalid delay %s", d)
+		}
+	}
+}
+
+// validateFeatures checks the settings of the enabled optional features.
+func (c *Config) validateFeatures(p *problems) {
+	if c.OCR.Enabled {
+		p.required("ocr.binary", c.OCR.Binary)
+	}
+
+	if c.Antivirus.Enabled {
+		p.oneOf("antivirus.network", c.Antivirus.Network, "tcp", "unix")
+		p.required("antivirus.address", c.Antivirus.Address)
+	}
+
+	switch c.CDN.Provider {
+	case "":
+	case "fastly":
+		p.required("cdn.fastly.service_id", c.CDN.Fastly.ServiceID)
+		p.required("cdn.fastly.api_token", c.CDN.Fastly.APIToken)
+	case "cloudfront":
+		p.required("cdn.cloudfront.distribution_id", c.CDN.CloudFront.DistributionID)
+	default:
+		p.oneOf("cdn.provider", c.CDN.Provider, "fastly", "cloudfront")
+	}
+
+	if _, err := c.Users.Proxies(); err != nil {
+		p.add("users.trusted_proxies", "%v", err)
+	}
+
+	if c.CORS.AllowCredentials && slices.Contains(c.CORS.AllowedOrigins, "*") {
+		p.add("cors.allowed_origins", `"*" can't be combined with allow_credentials; list the origins instead`)
+	}
+
+	if r := c.ErrorReporting.SampleRate; r < 0 || r > 1 {
+		p.add("error_reporting.sample_rate", "must be between 0 and 1, got %g", r)
+	}
+
+	for _, action := range slices.Sorted(maps.Keys(c.SLO.Actions)) {
+		if t := c.SLO.Actions[action].Target; t < 0 || t > 1 {
+			p.add("slo.actions."+action+".target", "must be between 0 and 1, got %g", t)
+		}
+	}
+	if t := c.SLO.Default.Target; t < 0 || t > 1 {
+		p.add("slo.default.target", "must be between 0 and 1, got %g", t)
+	}
+
+	if c.Limits.MaxWidth < 0 || c.Limits.MaxHeight < 0 || c.Limits.MaxPixels < 0 {
+		p.add("limits", "limits must not be negative")
+	}
+	validateBounds(p, "actions.width", c.Actions.Width)
+	validateBounds(p, "actions.height", c.Actions.Height)
+	validateBounds(p, "actions.quality", c.Actions.Quality)
+	if c.Actions.Quality.Max > 100 {
+		p.add("actions.quality.max", "must be at most 100, got %d", c.Actions.Quality.Max)
+	}
+
+	if c.Quota.MaxBytes < 0 || c.Quota.ImagesPerDay < 0 || c.Quota.JobsPerHour < 0 {
+		p.add("quota", "limits must not be negative")
+	}
+	if c.Fonts.Dir != "" {
+		if info, err := os.Stat(c.Fonts.Dir); err != nil || !info.IsDir() {
+			p.add("fonts.dir", "must be a directory, got %q", c.Fonts.Dir)
+		}
+	}
+	if s := c.Watermark.Scale; s < 0 || s > 1 {
+		p.add("watermark.scale", "must be between 0 and 1, got %g", s)
+	}
+
+	if c.Log.Level != "" {
+		p.oneOf("log.level", c.Log.Level, "debug", "info", "warn", "error")
+	}
+
+	p.nonNegative(map[string]time.Duration{
+		"session.ttl":                   c.Session.TTL,
+		"ocr.timeout":                   c.OCR.Timeout,
+		"antivirus.timeout":             c.Antivirus.Timeout,
+		"webhook.timeout":               c.Webhook.Timeout,
+		"cdn.timeout":                   c.CDN.Timeout,
+		"admin.stuck_after":             c.Admin.StuckAfter,
+		"cors.max_age":                  c.CORS.MaxAge,
+		"expiry.sweep_interval":         c.Expiry.SweepInterval,
+		"expiry.deleted_retention":      c.Expiry.DeletedRetention,
+		"redrive.interval":              c.Redrive.Interval,
+		"redrive.stuck_after":           c.Redrive.StuckAfter,
+		"error_reporting.flush_timeout": c.ErrorReporting.FlushTimeout,
+		"slo.window":                    c.SLO.Window,
+	})
+}
+
+// validateBounds checks that the bounds of the key are not negative and not inverted.
+func validateBounds(p *problems, key string, b Bounds) {
+	if b.Min < 0 || b.Max < 0 {
+		p.add(key, "bounds must not be negative")
+	}
+	if b.Min > 0 && b.Max > 0 && b.Min > b.Max {
+		p.add(key, "min %d is greater than max %d", b.Min, b.Max)
+	}
+}
+
+// validateSecrets checks the settings of the secrets backend and the references to its secrets.
+func (c *Config) validateSecrets(p *problems) {
+	s := c.Secrets
+
+	switch s.Provider {
+	case "":
+	case "vault":
+		p.required("secrets.vault.address", s.Vault.Address)
+		p.required("secrets.vault.token", s.Vault.Token)
+	case "aws":
+		p.required("secrets.aws.region", s.AWS.Region)
+	default:
+		p.oneOf("secrets.provider", s.Provider, "vault", "aws")
+	}
+
+	creds := c.Credentials()
+	for _, key := range slices.Sorted(maps.Keys(creds)) {
+		ref, ok := strings.CutPrefix(*creds[key], SecretRefPrefix)
+		if !ok {
+			continue
+		}
+
+		if s.Provider == "" {
+			p.add(key, "references a secret, but no secrets.provider is configured")
+		}
+		if path, _, _ := strings.Cut(ref, "#"); path == "" {
+			p.add(key, "invalid secret reference %q, expected %s<path>#<key>", *creds[key], SecretRefPrefix)
+		}
+	}
+
+	p.nonNegative(map[string]time.Duration{
+		"secrets.refresh_interval": s.RefreshInterval,
+		"secrets.timeout":          s.Timeout,
+	})
+}
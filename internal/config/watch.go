@@ -0,0 +1,61 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	"github.com/wb-go/wbf/zlog"
+)
+
+// Watch calls onChange whenever the config file loaded by MustLoad is written, until ctx is
+// canceled. The directory of the file is watched rather than the file itself, so that files
+// replaced by editors or swapped through symlinks (e.g. Kubernetes ConfigMaps) are noticed too.
+func Watch(ctx context.Context, onChange func()) error {
+	file := filepath.Clean(viper.ConfigFileUsed())
+	if file == "." {
+		return fmt.Errorf("watch config: no config file loaded")
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("watch config: failed to create watcher: %w", err)
+	}
+	defer w.Close()
+
+	if err := w.Add(filepath.Dir(file)); err != nil {
+		return fmt.Errorf("watch config: failed to watch %s: %w", filepath.Dir(file), err)
+	}
+
+	// The target of a symlinked file changes without an event naming the file.
+	target, _ := filepath.EvalSymlinks(file)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err, ok := <-w.Errors:
+			if !ok {
+				return nil
+			}
+			zlog.Logger.Warn().Err(err).Msg("config watcher error")
+		case ev, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			if !ev.Has(fsnotify.Write) && !ev.Has(fsnotify.Create) {
+				continue
+			}
+
+			current, _ := filepath.EvalSymlinks(file)
+			if filepath.Clean(ev.Name) != file && current == target {
+				continue
+			}
+			target = current
+
+			onChange()
+		}
+	}
+}
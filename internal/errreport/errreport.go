@@ -0,0 +1,105 @@
+// Package errreport reports errors and panics to Sentry or a compatible service (e.g. GlitchTip),
+// tagged with the request ID, trace ID and the image, action and tenant of the job they occurred in.
+// Reporting is a no-op until Init is called with a DSN.
+package errreport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/google/uuid"
+
+	"github.com/aliskhannn/image-processor/internal/config"
+	"github.com/aliskhannn/image-processor/internal/logging"
+	"github.com/aliskhannn/image-processor/internal/trace"
+)
+
+// defaultFlushTimeout bounds the time taken by Flush when no flush timeout is configured.
+const defaultFlushTimeout = 2 * time.Second
+
+// flushTimeout is the configured time given to pending events by Flush.
+var flushTimeout = defaultFlushTimeout
+
+// Init enables reporting to the service of the configured DSN. An empty DSN leaves reporting disabled.
+func Init(cfg config.ErrorReporting) error {
+	if cfg.DSN == "" {
+		return nil
+	}
+
+	sampleRate := cfg.SampleRate
+	if sampleRate <= 0 {
+		sampleRate = 1
+	}
+
+	err := sentry.Init(sentry.ClientOptions{
+		Dsn:         cfg.DSN,
+		Environment: cfg.Environment,
+		Release:     cfg.Release,
+		SampleRate:  sampleRate,
+	})
+	if err != nil {
+		return fmt.Errorf("init error reporting: %w", err)
+	}
+
+	if cfg.FlushTimeout > 0 {
+		flushTimeout = cfg.FlushTimeout
+	}
+
+	return nil
+}
+
+// Flush waits for the pending events to be sent, up to the configured flush timeout.
+func Flush() {
+	sentry.Flush(flushTimeout)
+}
+
+// Error reports err with the context of ctx. Context cancellations aren't reported.
+func Error(ctx context.Context, err error) {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return
+	}
+
+	hub(ctx).CaptureException(err)
+}
+
+// Panic reports the value recovered from a panic with the context of ctx. Handlers aborted
+// with http.ErrAbortHandler aren't reported, as the panic only stops the response.
+func Panic(ctx context.Context, v any) {
+	if err, ok := v.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+		return
+	}
+
+	hub(ctx).RecoverWithContext(ctx, v)
+}
+
+// hub returns a hub reporting to the configured service, with a scope tagged with the context of ctx.
+func hub(ctx context.Context) *sentry.Hub {
+	h := sentry.CurrentHub().Clone()
+	scope := h.Scope()
+
+	tc := trace.FromContext(ctx)
+	if tc.RequestID != "" {
+		scope.SetTag("request_id", tc.RequestID)
+	}
+	if id := trace.TraceID(tc.TraceParent); id != "" {
+		scope.SetTag("trace_id", id)
+	}
+
+	f := logging.FieldsFromContext(ctx)
+	if f.ImageID != uuid.Nil {
+		scope.SetTag("image_id", f.ImageID.String())
+	}
+	if f.Action != "" {
+		scope.SetTag("action", f.Action)
+	}
+	if f.Tenant != "" {
+		scope.SetUser(sentry.User{ID: f.Tenant})
+		scope.SetTag("tenant", f.Tenant)
+	}
+
+	return h
+}
@@ -0,0 +1,97 @@
+// Package health aggregates connectivity checks of the service dependencies.
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/wb-go/wbf/zlog"
+)
+
+// Check reports whether a single dependency is reachable.
+type Check func(ctx context.Context) error
+
+// namedCheck is a check registered under a dependency name.
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Report is the outcome of running all registered checks.
+type Report struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]string `json:"checks"` // dependency name -> "ok" or the error message
+}
+
+// Checker runs the registered dependency checks concurrently.
+type Checker struct {
+	checks  []namedCheck
+	timeout time.Duration
+}
+
+// NewChecker creates a new Checker; each check is canceled after timeout.
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Add registers a check under the given dependency name.
+func (c *Checker) Add(name string, check Check) {
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// Check runs all registered checks and reports which dependencies are unreachable.
+func (c *Checker) Check(ctx context.Context) Report {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+
+	report := Report{Ready: true, Checks: make(map[string]string, len(c.checks))}
+
+	for _, nc := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			status := "ok"
+			if err := nc.check(ctx); err != nil {
+				status = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			report.Checks[nc.name] = status
+			if status != "ok" {
+				report.Ready = false
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return report
+}
+
+// WaitReady blocks until all checks pass or the context is canceled, retrying every interval.
+// Returns false if the context was canceled first.
+func (c *Checker) WaitReady(ctx context.Context, interval time.Duration) bool {
+	for {
+		report := c.Check(ctx)
+		if report.Ready {
+			return true
+		}
+
+		zlog.Logger.Warn().Interface("checks", report.Checks).Msg("dependencies not ready, waiting")
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(interval):
+		}
+	}
+}
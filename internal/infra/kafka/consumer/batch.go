@@ -0,0 +1,146 @@
+package consumer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/wb-go/wbf/zlog"
+)
+
+// defaultBatchWait bounds filling a batch when no wait is configured.
+const defaultBatchWait = 100 * time.Millisecond
+
+// consumeBatches continuously fetches batches of up to batch.Size messages, processes the
+// partitions of each batch concurrently, and the messages of a partition in order, and commits
+// the processed ones together.
+// It stops fetching while paused and returns on context cancellation, once the batch
+// in flight was processed and committed with drainCtx.
+func (c *Consumer) consumeBatches(ctx, drainCtx context.Context) {
+	for {
+		// Exit if context is canceled (graceful shutdown).
+		if ctx.Err() != nil {
+			zlog.Logger.Info().Str("topic", c.topic).Msg("shutdown signal received, stopping consumer")
+			return
+		}
+
+		// Don't fetch new messages while consumption is paused.
+		if !c.pauser.Wait(ctx) {
+			continue
+		}
+
+		batch, err := c.fetchBatch(ctx)
+		if err != nil {
+			// Log error and retry after a short backoff.
+			zlog.Logger.Err(err).Msg("failed to fetch message")
+			time.Sleep(500 * time.Millisecond)
+			continue
+		}
+
+		c.committer.commit(drainCtx, c.processBatch(drainCtx, batch)...)
+	}
+}
+
+// fetchBatch blocks until a message is fetched, then adds the messages fetched within the
+// batch wait, up to the batch size.
+func (c *Consumer) fetchBatch(ctx context.Context) ([]kafka.Message, error) {
+	first, err := c.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	wait := c.batch.Wait
+	if wait <= 0 {
+		wait = defaultBatchWait
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+
+	batch := append(make([]kafka.Message, 0, c.batch.Size), first)
+	for len(batch) < c.batch.Size {
+		msg, err := c.Client.Fetch(fetchCtx)
+		if err != nil {
+			break
+		}
+		batch = append(batch, msg)
+	}
+
+	return batch, nil
+}
+
+// processBatch processes the messages of each partition in offset order, with up to
+// batch.Concurrency partitions at a time, so that the jobs of an image keep their order. It returns,
+// for each partition, the last message up to which all messages can be committed.
+func (c *Consumer) processBatch(ctx context.Context, batch []kafka.Message) []kafka.Message {
+	var (
+		partitions [][]kafka.Message
+		index      = make(map[int]int)
+	)
+	for _, msg := range batch {
+		i, ok := index[msg.Partition]
+		if !ok {
+			i = len(partitions)
+			index[msg.Partition] = i
+			partitions = append(partitions, nil)
+		}
+		partitions[i] = append(partitions[i], msg)
+	}
+
+	concurrency := c.batch.Concurrency
+	if concurrency <= 0 || concurrency > len(partitions) {
+		concurrency = len(partitions)
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		committed = make([]kafka.Message, 0, len(partitions))
+		slots     = make(chan struct{}, concurrency)
+	)
+
+	for _, msgs := range partitions {
+		slots <- struct{}{}
+		wg.Add(1)
+
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			if last, ok := c.processRun(ctx, msgs); ok {
+				mu.Lock()
+				committed = append(committed, last)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return committed
+}
+
+// processRun processes the messages of a partition one after the other and returns the
+// last message of the leading run that can be committed, if any. Committing an offset commits
+// those before it, so messages following one left uncommitted are processed but not committed.
+func (c *Consumer) processRun(ctx context.Context, msgs []kafka.Message) (kafka.Message, bool) {
+	var (
+		last kafka.Message
+		ok   bool
+		gap  bool
+	)
+
+	for _, msg := range msgs {
+		if !c.process(ctx, msg) {
+			gap = true
+		}
+		if !gap {
+			last, ok = msg, true
+		}
+	}
+
+	return last, ok
+}
@@ -0,0 +1,162 @@
+package consumer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	wbfkafka "github.com/wb-go/wbf/kafka"
+	"github.com/wb-go/wbf/retry"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/image-processor/internal/config"
+	"github.com/aliskhannn/image-processor/internal/reload"
+)
+
+// Commit modes.
+const (
+	commitSync  = "sync"
+	commitAsync = "async"
+)
+
+// Defaults of the async commit mode.
+const (
+	defaultCommitInterval  = time.Second
+	defaultCommitBatchSize = 100
+)
+
+// committer commits the offsets of handled messages, either right away or, in async mode,
+// in batches from a background loop so that processing never waits for the broker.
+type committer struct {
+	client    *wbfkafka.Consumer
+	strategy  *reload.Value[retry.Strategy]
+	async     bool
+	interval  time.Duration
+	batchSize int
+
+	mu      sync.Mutex
+	pending map[int]kafka.Message // highest handled message by partition
+	count   int                   // messages handled since the last commit
+	full    chan struct{}         // signals that batchSize messages are pending
+}
+
+// newCommitter creates a committer for the client with the configured commit strategy.
+func newCommitter(client *wbfkafka.Consumer, s *reload.Value[retry.Strategy], cfg config.KafkaCommit) *committer {
+	c := &committer{
+		client:    client,
+		strategy:  s,
+		async:     cfg.Mode == commitAsync,
+		interval:  cfg.Interval,
+		batchSize: cfg.BatchSize,
+		pending:   make(map[int]kafka.Message),
+		full:      make(chan struct{}, 1),
+	}
+
+	if c.interval <= 0 {
+		c.interval = defaultCommitInterval
+	}
+	if c.batchSize <= 0 {
+		c.batchSize = defaultCommitBatchSize
+	}
+
+	return c
+}
+
+// commit marks the messages as handled. In sync mode their offsets are committed immediately,
+// in a single request; in async mode they are committed by the next flush of the background loop.
+func (c *committer) commit(ctx context.Context, msgs ...kafka.Message) {
+	if len(msgs) == 0 {
+		return
+	}
+
+	if !c.async {
+		c.commitMessages(ctx, msgs...)
+		return
+	}
+
+	c.mu.Lock()
+	for _, msg := range msgs {
+		if prev, ok := c.pending[msg.Partition]; !ok || prev.Offset < msg.Offset {
+			c.pending[msg.Partition] = msg
+		}
+	}
+	c.count += len(msgs)
+	full := c.count >= c.batchSize
+	c.mu.Unlock()
+
+	if full {
+		select {
+		case c.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// run flushes pending offsets every interval or once a batch is full, until the context
+// is canceled, and flushes them one last time before returning. It returns immediately in sync mode.
+func (c *committer) run(ctx context.Context) {
+	if !c.async {
+		return
+	}
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+			c.flush(flushCtx)
+			cancel()
+			return
+		case <-ticker.C:
+			c.flush(ctx)
+		case <-c.full:
+			c.flush(ctx)
+		}
+	}
+}
+
+// flush commits the pending offsets. Offsets failing to commit stay pending for the next flush,
+// unless newer messages of their partition were handled in the meantime.
+func (c *committer) flush(ctx context.Context) {
+	c.mu.Lock()
+	if len(c.pending) == 0 {
+		c.mu.Unlock()
+		return
+	}
+
+	msgs := make([]kafka.Message, 0, len(c.pending))
+	for _, msg := range c.pending {
+		msgs = append(msgs, msg)
+	}
+	c.pending = make(map[int]kafka.Message)
+	c.count = 0
+	c.mu.Unlock()
+
+	if c.commitMessages(ctx, msgs...) {
+		return
+	}
+
+	c.mu.Lock()
+	for _, msg := range msgs {
+		if _, ok := c.pending[msg.Partition]; !ok {
+			c.pending[msg.Partition] = msg
+		}
+	}
+	c.mu.Unlock()
+}
+
+// commitMessages commits the messages with retries and reports whether it succeeded.
+func (c *committer) commitMessages(ctx context.Context, msgs ...kafka.Message) bool {
+	err := retry.Do(func() error {
+		return c.client.Reader.CommitMessages(ctx, msgs...)
+	}, c.strategy.Load())
+	if err != nil {
+		zlog.Logger.Err(err).Int("messages", len(msgs)).Msg("failed to commit messages after retries")
+		return false
+	}
+
+	return true
+}
@@ -2,6 +2,8 @@ package consumer
 
 import (
 	"context"
+	"fmt"
+	"slices"
 	"sync"
 	"time"
 
@@ -11,65 +13,202 @@ import (
 	"github.com/wb-go/wbf/zlog"
 
 	"github.com/aliskhannn/image-processor/internal/config"
+	"github.com/aliskhannn/image-processor/internal/infra/kafka/producer"
+	"github.com/aliskhannn/image-processor/internal/infra/queue"
+	"github.com/aliskhannn/image-processor/internal/metrics"
+	"github.com/aliskhannn/image-processor/internal/model"
+	"github.com/aliskhannn/image-processor/internal/reload"
+	"github.com/aliskhannn/image-processor/internal/trace"
 )
 
+// passOnInterval is how often passing on a failed message to the next retry tier or the dead
+// letters is tried again while it keeps failing.
+const passOnInterval = 5 * time.Second
+
 // uploadedHandler defines the interface for handling uploaded image messages.
 type uploadedHandler interface {
-	Handle(ctx context.Context, msg kafka.Message) error
+	Handle(ctx context.Context, msg model.QueueMessage) error
+}
+
+// deadLetterRecorder defines the interface for recording messages that failed processing after retries.
+type deadLetterRecorder interface {
+	RecordDeadLetter(ctx context.Context, dl model.DeadLetter) error
 }
 
-// Consumer represents a Kafka consumer along with its configuration
+// decoder defines the interface for decoding tasks from message values.
+type decoder interface {
+	Decode(data []byte) (model.Task, error)
+}
+
+// Consumer represents a Kafka consumer of a single topic along with its configuration
 // and the handler that processes uploaded image messages.
 type Consumer struct {
 	Client          *wbfkafka.Consumer
 	uploadedHandler uploadedHandler
-	cfg             *config.Kafka
-	strategy        retry.Strategy
+	deadLetters     deadLetterRecorder
+	decoder         decoder
+	committer       *committer
+	pauser          *queue.Pauser
+	next            *wbfkafka.Producer // next retry tier; nil sends failures to the dead-letter queue
+	nextDelay       time.Duration      // delay before messages are processed again by the next tier
+	tier            int                // number of retry tiers messages of this consumer went through
+	topic           string
+	strategy        *reload.Value[retry.Strategy]
+	batch           config.KafkaBatch // batched consumption of job topics; unused by retry tiers
+	drainTimeout    time.Duration     // time given to in-flight messages on shutdown
+	perPartition    bool              // process partitions in parallel, each in order
 }
 
-// New creates a new Consumer.
+// NewChain creates the consumers of the main, per-priority and per-action topics, followed by one
+// consumer per configured retry topic. Messages still failing after the in-process retries are
+// forwarded to the next retry topic with its delay, and from the last one to the dead-letter queue.
+// Only the topics listed in cfg.ConsumeTopics are consumed, if any are listed.
 // - cfg: Kafka configuration struct
 // - s: retry strategy
 // - uh: handler for processing uploaded image messages
-func New(
+// - dl: recorder of messages failing after all retries, publishing them to the DLQ topic if any
+// - d: decoder of the message format, used to record dead letters as JSON
+// - p: pauser shared by the consumers
+func NewChain(
 	cfg *config.Kafka,
-	s retry.Strategy,
+	s *reload.Value[retry.Strategy],
 	uh uploadedHandler,
-) *Consumer {
-	consumer := wbfkafka.NewConsumer(cfg.Brokers, cfg.Topic, cfg.GroupID)
+	dl deadLetterRecorder,
+	d decoder,
+	p *queue.Pauser,
+) ([]*Consumer, error) {
+	if mode := cfg.Commit.Mode; mode != "" && mode != commitSync && mode != commitAsync {
+		return nil, fmt.Errorf("unknown kafka commit mode %q", mode)
+	}
+
+	dialer, err := producer.Dialer(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var consumers []*Consumer
+
+	add := func(topic string, tier int) error {
+		if len(cfg.ConsumeTopics) > 0 && !slices.Contains(cfg.ConsumeTopics, topic) {
+			return nil
+		}
+
+		readerCfg, err := readerConfig(cfg, topic, dialer)
+		if err != nil {
+			return err
+		}
+
+		client := &wbfkafka.Consumer{Reader: kafka.NewReader(readerCfg)}
+		c := &Consumer{
+			Client:          client,
+			committer:       newCommitter(client, s, cfg.Commit),
+			uploadedHandler: uh,
+			deadLetters:     dl,
+			decoder:         d,
+			pauser:          p,
+			tier:            tier,
+			topic:           topic,
+			strategy:        s,
+			drainTimeout:    cfg.DrainTimeout,
+			perPartition:    cfg.PartitionWorkers,
+		}
+		if tier == 0 {
+			c.batch = cfg.Batch
+		}
+
+		if tier < len(cfg.RetryTopics) {
+			next, err := producer.NewClient(cfg, cfg.RetryTopics[tier].Topic)
+			if err != nil {
+				return err
+			}
+			c.next = next
+			c.nextDelay = cfg.RetryTopics[tier].Delay
+		}
+
+		consumers = append(consumers, c)
+		return nil
+	}
+
+	for _, topic := range sourceTopics(cfg) {
+		if err := add(topic, 0); err != nil {
+			return nil, err
+		}
+	}
+	for i, rt := range cfg.RetryTopics {
+		if err := add(rt.Topic, i+1); err != nil {
+			return nil, err
+		}
+	}
+
+	return consumers, nil
+}
 
-	return &Consumer{
-		Client:          consumer,
-		uploadedHandler: uh,
-		cfg:             cfg,
-		strategy:        s,
+// sourceTopics returns the topics jobs are produced to: the main topic followed by the
+// distinct per-priority and per-action topics in sorted order.
+func sourceTopics(cfg *config.Kafka) []string {
+	topics := []string{cfg.Topic}
+	for _, routes := range []map[string]string{cfg.PriorityTopics, cfg.ActionTopics} {
+		for _, topic := range routes {
+			if !slices.Contains(topics, topic) {
+				topics = append(topics, topic)
+			}
+		}
 	}
+
+	slices.Sort(topics[1:])
+
+	return topics
 }
 
 // Consume continuously fetches messages from Kafka, processes them using the handler,
-// and commits offsets after successful processing. It stops gracefully on context cancellation.
+// and commits offsets after successful processing, per message or in background batches.
+// Messages still failing after retries are forwarded to the next retry tier or recorded as dead
+// letters. While paused, it stops fetching and holds back
+// fetched messages that aren't in flight yet. On context cancellation it stops fetching,
+// finishes and commits the messages in flight within the drain timeout, flushes pending
+// commits and returns.
 func (c *Consumer) Consume(ctx context.Context, wg *sync.WaitGroup) {
 	defer wg.Done()
 
+	// In-flight messages are processed and committed with a context outliving ctx.
+	drainCtx, cancelDrain := queue.Drain(ctx, c.drainTimeout)
+
+	flushed := make(chan struct{})
+	go func() {
+		defer close(flushed)
+		c.committer.run(drainCtx)
+	}()
+	defer func() {
+		cancelDrain()
+		<-flushed
+	}()
+
 	zlog.Logger.Info().
-		Str("topic", c.cfg.Topic).
+		Str("topic", c.topic).
 		Msg("starting consumer")
 
+	switch {
+	case c.batch.Size > 1:
+		c.consumeBatches(ctx, drainCtx)
+		return
+	case c.perPartition:
+		c.consumePartitions(ctx, drainCtx)
+		return
+	}
+
 	for {
 		// Exit if context is canceled (graceful shutdown).
 		if ctx.Err() != nil {
-			zlog.Logger.Info().Msg("shutdown signal received, stopping consumer")
+			zlog.Logger.Info().Str("topic", c.topic).Msg("shutdown signal received, stopping consumer")
 			return
 		}
 
-		// Fetch a message from Kafka with retries.
-		var msg kafka.Message
-		err := retry.Do(func() error {
-			var fetchErr error
-			msg, fetchErr = c.Client.Fetch(ctx)
-			return fetchErr
-		}, c.strategy)
+		// Don't fetch new messages while consumption is paused.
+		if !c.pauser.Wait(ctx) {
+			continue
+		}
 
+		msg, err := c.fetch(ctx)
 		if err != nil {
 			// Log error and retry after a short backoff.
 			zlog.Logger.Err(err).Msg("failed to fetch message")
@@ -77,25 +216,175 @@ func (c *Consumer) Consume(ctx context.Context, wg *sync.WaitGroup) {
 			continue
 		}
 
-		// Process message using the uploadedHandler.
-		if err := c.uploadedHandler.Handle(ctx, msg); err != nil {
-			zlog.Logger.Err(err).
-				Str("message", string(msg.Value)).
-				Msg("failed to process image")
+		// Messages of retry topics are held back until their delay has passed,
+		// and aren't processed if consumption was paused in the meantime.
+		if !waitUntilDue(ctx, msg) || !c.pauser.Wait(ctx) {
 			continue
 		}
 
-		// Commit the message with retries.
-		err = retry.Do(func() error {
-			return c.Client.Commit(ctx, msg)
-		}, c.strategy)
-		if err != nil {
-			zlog.Logger.Err(err).Msg("failed to commit message after retries")
+		c.handle(drainCtx, msg)
+	}
+}
+
+// fetch fetches a message from Kafka with retries and reports the consumer lag of the topic
+// as its fetch backlog.
+func (c *Consumer) fetch(ctx context.Context) (kafka.Message, error) {
+	var msg kafka.Message
+	err := retry.Do(func() error {
+		var fetchErr error
+		msg, fetchErr = c.Client.Fetch(ctx)
+		return fetchErr
+	}, c.strategy.Load())
+	if err == nil {
+		metrics.SetFetchBacklog(c.topic, c.Client.Reader.Stats().Lag)
+	}
+
+	return msg, err
+}
+
+// handle processes the message and commits it unless it is left uncommitted.
+func (c *Consumer) handle(ctx context.Context, msg kafka.Message) {
+	if c.process(ctx, msg) {
+		c.committer.commit(ctx, msg)
+	}
+}
+
+// process processes the message, counted as in flight until it returns, and reports whether
+// it can be committed: after successful processing, or once a failure was passed on.
+func (c *Consumer) process(ctx context.Context, msg kafka.Message) bool {
+	defer c.pauser.Begin()()
+
+	// Continue the trace of the request that enqueued the job.
+	msgCtx := trace.Extract(ctx, func(key string) string { return header(msg, key) })
+	tc := trace.FromContext(msgCtx)
+
+	// Process message using the uploadedHandler, retrying transient failures.
+	err := retry.Do(func() error {
+		return c.uploadedHandler.Handle(msgCtx, queueMessage(msg))
+	}, c.strategy.Load())
+	if err != nil {
+		zlog.Logger.Err(err).
+			Str("topic", c.topic).
+			Str("request_id", tc.RequestID).
+			Str("trace_id", trace.TraceID(tc.TraceParent)).
+			Str("key", string(msg.Key)).
+			Int("partition", msg.Partition).
+			Int64("offset", msg.Offset).
+			Msg("failed to process image")
+
+		// On shutdown the message stays uncommitted and is processed again after restart.
+		if ctx.Err() != nil {
+			return false
+		}
+
+		return c.handleFailure(ctx, msg, err)
+	}
+
+	zlog.Logger.Info().
+		Str("topic", c.topic).
+		Str("request_id", tc.RequestID).
+		Str("trace_id", trace.TraceID(tc.TraceParent)).
+		Str("key", string(msg.Key)).
+		Int("partition", msg.Partition).
+		Int64("offset", msg.Offset).
+		Msg("message handled successfully")
+
+	return true
+}
+
+// handleFailure forwards a message that failed processing to the next retry tier or, after
+// the last one, records it as a dead letter. The commit of a later offset would skip the message,
+// so passing it on is retried until it succeeds, holding back the messages after it. It reports
+// whether the message was passed on and can be committed, which is only not the case on shutdown.
+func (c *Consumer) handleFailure(ctx context.Context, msg kafka.Message, err error) bool {
+	if c.next != nil {
+		forward := func() error { return c.forward(ctx, msg, err) }
+		if !c.passOn(ctx, msg, forward, "failed to forward message to retry topic") {
+			return false
+		}
+
+		zlog.Logger.Warn().
+			Int64("offset", msg.Offset).
+			Str("retry_topic", c.next.Writer.Topic).
+			Msg("message scheduled for retry")
+		return true
+	}
+
+	record := func() error { return c.deadLetters.RecordDeadLetter(ctx, c.deadLetter(msg, err)) }
+	if !c.passOn(ctx, msg, record, "failed to record dead letter") {
+		return false
+	}
+
+	zlog.Logger.Warn().Int64("offset", msg.Offset).Msg("message moved to dead-letter queue")
+	return true
+}
+
+// passOn runs pass with the retry strategy, again every passOnInterval until it succeeds, and
+// reports whether it did before ctx was done. Failures are logged with msg.
+func (c *Consumer) passOn(ctx context.Context, msg kafka.Message, pass func() error, failure string) bool {
+	for {
+		err := retry.Do(pass, c.strategy.Load())
+		if err == nil {
+			return true
 		}
 
-		zlog.Logger.Info().
+		zlog.Logger.Err(err).
+			Str("topic", msg.Topic).
+			Int("partition", msg.Partition).
 			Int64("offset", msg.Offset).
-			Str("message", string(msg.Value)).
-			Msg("message handled successfully")
+			Msg(failure)
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(passOnInterval):
+		}
+	}
+}
+
+// Close closes the consumer client and the producer of the next retry tier.
+func (c *Consumer) Close() error {
+	if c.next != nil {
+		if err := c.next.Close(); err != nil {
+			return err
+		}
+	}
+
+	return c.Client.Close()
+}
+
+// deadLetter describes a message that failed processing with the given error.
+func (c *Consumer) deadLetter(msg kafka.Message, err error) model.DeadLetter {
+	return model.DeadLetter{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Key:       string(msg.Key),
+		Payload:   queue.Payload(c.decoder, msg.Value),
+		Error:     err.Error(),
+		Attempts:  c.strategy.Load().Attempts * (c.tier + 1),
+		FailedAt:  time.Now(),
+	}
+}
+
+// queueMessage converts the Kafka message for the handler. Messages produced without
+// a message ID header are identified by their topic, partition and offset.
+func queueMessage(msg kafka.Message) model.QueueMessage {
+	headers := make(map[string]string, len(msg.Headers))
+	for _, h := range msg.Headers {
+		headers[h.Key] = string(h.Value)
+	}
+
+	id := headers[model.MessageIDHeader]
+	if id == "" {
+		id = fmt.Sprintf("%s/%d/%d", msg.Topic, msg.Partition, msg.Offset)
+	}
+
+	return model.QueueMessage{
+		ID:      id,
+		Topic:   msg.Topic,
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: headers,
 	}
 }
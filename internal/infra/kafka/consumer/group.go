@@ -0,0 +1,62 @@
+package consumer
+
+import (
+	"fmt"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/aliskhannn/image-processor/internal/config"
+)
+
+// Supported partition assignment strategies, selected by kafka.group.balancers.
+const (
+	balancerRange        = "range"
+	balancerRoundRobin   = "round-robin"
+	balancerRackAffinity = "rack-affinity"
+)
+
+// readerConfig returns the configuration of the reader of the topic, applying the consumer
+// group settings of cfg.Group.
+func readerConfig(cfg *config.Kafka, topic string, dialer *kafka.Dialer) (kafka.ReaderConfig, error) {
+	balancers, err := groupBalancers(cfg.Group)
+	if err != nil {
+		return kafka.ReaderConfig{}, err
+	}
+
+	return kafka.ReaderConfig{
+		Brokers:           cfg.Brokers,
+		Topic:             topic,
+		GroupID:           cfg.GroupID,
+		Dialer:            dialer,
+		GroupBalancers:    balancers,
+		SessionTimeout:    cfg.Group.SessionTimeout,
+		RebalanceTimeout:  cfg.Group.RebalanceTimeout,
+		HeartbeatInterval: cfg.Group.HeartbeatInterval,
+	}, nil
+}
+
+// groupBalancers returns the configured assignment strategies, or nil for the kafka-go defaults.
+func groupBalancers(cfg config.KafkaGroup) ([]kafka.GroupBalancer, error) {
+	if len(cfg.Balancers) == 0 {
+		return nil, nil
+	}
+
+	balancers := make([]kafka.GroupBalancer, 0, len(cfg.Balancers))
+	for _, name := range cfg.Balancers {
+		switch name {
+		case balancerRange:
+			balancers = append(balancers, kafka.RangeGroupBalancer{})
+		case balancerRoundRobin:
+			balancers = append(balancers, kafka.RoundRobinGroupBalancer{})
+		case balancerRackAffinity:
+			if cfg.Rack == "" {
+				return nil, fmt.Errorf("kafka group balancer %s requires a rack", name)
+			}
+			balancers = append(balancers, kafka.RackAffinityGroupBalancer{Rack: cfg.Rack})
+		default:
+			return nil, fmt.Errorf("unknown kafka group balancer %q", name)
+		}
+	}
+
+	return balancers, nil
+}
@@ -0,0 +1,81 @@
+package consumer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/wb-go/wbf/zlog"
+)
+
+// partitionBuffer is the number of fetched messages held for a partition while its worker is busy.
+const partitionBuffer = 16
+
+// consumePartitions continuously fetches messages and hands them to one worker goroutine per
+// partition, so that throughput scales with the assigned partitions while the messages of a
+// partition, and thus of an image keyed on its ID, are still processed and committed in order.
+// It stops fetching while paused and returns on context cancellation, once the messages in
+// flight were processed and committed with drainCtx; messages not started yet stay uncommitted.
+func (c *Consumer) consumePartitions(ctx, drainCtx context.Context) {
+	var wg sync.WaitGroup
+	workers := make(map[int]chan kafka.Message)
+	defer func() {
+		for _, msgs := range workers {
+			close(msgs)
+		}
+		wg.Wait()
+	}()
+
+	for {
+		// Exit if context is canceled (graceful shutdown).
+		if ctx.Err() != nil {
+			zlog.Logger.Info().Str("topic", c.topic).Msg("shutdown signal received, stopping consumer")
+			return
+		}
+
+		// Don't fetch new messages while consumption is paused.
+		if !c.pauser.Wait(ctx) {
+			continue
+		}
+
+		msg, err := c.fetch(ctx)
+		if err != nil {
+			// Log error and retry after a short backoff.
+			zlog.Logger.Err(err).Msg("failed to fetch message")
+			time.Sleep(500 * time.Millisecond)
+			continue
+		}
+
+		msgs, ok := workers[msg.Partition]
+		if !ok {
+			msgs = make(chan kafka.Message, partitionBuffer)
+			workers[msg.Partition] = msgs
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.processPartition(ctx, drainCtx, msgs)
+			}()
+		}
+
+		select {
+		case msgs <- msg:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// processPartition handles the messages of a partition one at a time, in order. Once ctx is
+// canceled the remaining messages are skipped, so no offset is committed past an unprocessed one.
+func (c *Consumer) processPartition(ctx, drainCtx context.Context, msgs <-chan kafka.Message) {
+	for msg := range msgs {
+		// Messages of retry topics are held back until their delay has passed,
+		// and aren't processed if consumption was paused in the meantime.
+		if ctx.Err() != nil || !waitUntilDue(ctx, msg) || !c.pauser.Wait(ctx) {
+			continue
+		}
+
+		c.handle(drainCtx, msg)
+	}
+}
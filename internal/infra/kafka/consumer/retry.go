@@ -0,0 +1,73 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Headers set on messages forwarded to retry topics.
+const (
+	headerRetryAt   = "retry-at"   // RFC3339 time before which the message must not be processed
+	headerLastError = "last-error" // error of the failed processing attempt
+)
+
+// forward publishes the message to the next retry topic, due after the delay of that tier.
+// The payload and the headers of the original, such as its message ID, are kept as is,
+// so retried messages are handled exactly like the original.
+func (c *Consumer) forward(ctx context.Context, msg kafka.Message, cause error) error {
+	headers := []kafka.Header{
+		{Key: headerRetryAt, Value: []byte(time.Now().Add(c.nextDelay).UTC().Format(time.RFC3339Nano))},
+		{Key: headerLastError, Value: []byte(cause.Error())},
+	}
+	for _, h := range msg.Headers {
+		if h.Key != headerRetryAt && h.Key != headerLastError {
+			headers = append(headers, h)
+		}
+	}
+
+	err := c.next.Writer.WriteMessages(ctx, kafka.Message{
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: headers,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write message to %s: %w", c.next.Writer.Topic, err)
+	}
+
+	return nil
+}
+
+// waitUntilDue blocks until the message is due for processing according to its retry-at header.
+// Messages without the header are due immediately. It returns false if the context was canceled first.
+func waitUntilDue(ctx context.Context, msg kafka.Message) bool {
+	due, _ := time.Parse(time.RFC3339Nano, header(msg, headerRetryAt))
+
+	wait := time.Until(due)
+	if wait <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// header returns the value of the message header with the given key, or an empty string.
+func header(msg kafka.Message, key string) string {
+	for _, h := range msg.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,37 @@
+package producer
+
+import (
+	"fmt"
+
+	"github.com/segmentio/kafka-go"
+	wbfkafka "github.com/wb-go/wbf/kafka"
+
+	"github.com/aliskhannn/image-processor/internal/config"
+)
+
+// NewClient creates a producer of the topic with the compression and batching settings of cfg.Producer
+// and the SASL and TLS settings of cfg. Zero settings keep the kafka-go defaults.
+func NewClient(cfg *config.Kafka, topic string) (*wbfkafka.Producer, error) {
+	var compression kafka.Compression
+	if cfg.Producer.Compression != "" {
+		if err := compression.UnmarshalText([]byte(cfg.Producer.Compression)); err != nil {
+			return nil, fmt.Errorf("invalid kafka producer compression: %w", err)
+		}
+	}
+
+	transport, err := Transport(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	client := wbfkafka.NewProducer(cfg.Brokers, topic)
+	if transport != nil {
+		client.Writer.Transport = transport
+	}
+	client.Writer.Compression = compression
+	client.Writer.BatchSize = cfg.Producer.BatchSize
+	client.Writer.BatchBytes = cfg.Producer.BatchBytes
+	client.Writer.BatchTimeout = cfg.Producer.Linger
+
+	return client, nil
+}
@@ -0,0 +1,50 @@
+package producer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	wbfkafka "github.com/wb-go/wbf/kafka"
+	"github.com/wb-go/wbf/retry"
+
+	"github.com/aliskhannn/image-processor/internal/config"
+	"github.com/aliskhannn/image-processor/internal/model"
+	"github.com/aliskhannn/image-processor/internal/reload"
+)
+
+// DeadLetterProducer publishes messages that failed processing to the dead-letter topic.
+type DeadLetterProducer struct {
+	Client   *wbfkafka.Producer
+	strategy *reload.Value[retry.Strategy]
+}
+
+// NewDeadLetter creates a new DeadLetterProducer writing to the configured DLQ topic.
+// - cfg: Kafka configuration struct
+// - s: retry strategy
+func NewDeadLetter(cfg *config.Kafka, s *reload.Value[retry.Strategy]) (*DeadLetterProducer, error) {
+	client, err := NewClient(cfg, cfg.DLQTopic)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DeadLetterProducer{
+		Client:   client,
+		strategy: s,
+	}, nil
+}
+
+// Publish serializes the dead letter, including the original message and its error,
+// to JSON and sends it to the DLQ topic under the key of the original message.
+func (p *DeadLetterProducer) Publish(ctx context.Context, dl model.DeadLetter) error {
+	data, err := json.Marshal(dl)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter: %v", err)
+	}
+
+	if err = p.Client.SendWithRetry(ctx, p.strategy.Load(), []byte(dl.Key), data); err != nil {
+		return fmt.Errorf("failed to send dead letter: %v", err)
+	}
+
+	return nil
+}
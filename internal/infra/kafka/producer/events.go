@@ -0,0 +1,50 @@
+package producer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	wbfkafka "github.com/wb-go/wbf/kafka"
+	"github.com/wb-go/wbf/retry"
+
+	"github.com/aliskhannn/image-processor/internal/config"
+	"github.com/aliskhannn/image-processor/internal/model"
+	"github.com/aliskhannn/image-processor/internal/reload"
+)
+
+// EventProducer publishes completion events of processed images to the events topic.
+type EventProducer struct {
+	Client   *wbfkafka.Producer
+	strategy *reload.Value[retry.Strategy]
+}
+
+// NewEvents creates a new EventProducer writing to the configured events topic.
+// - cfg: Kafka configuration struct
+// - s: retry strategy
+func NewEvents(cfg *config.Kafka, s *reload.Value[retry.Strategy]) (*EventProducer, error) {
+	client, err := NewClient(cfg, cfg.EventsTopic)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EventProducer{
+		Client:   client,
+		strategy: s,
+	}, nil
+}
+
+// PublishProcessed serializes the event to JSON and sends it to the events topic
+// under the image ID, so that the events of an image stay ordered.
+func (p *EventProducer) PublishProcessed(ctx context.Context, ev model.ProcessedEvent) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %v", err)
+	}
+
+	if err = p.Client.SendWithRetry(ctx, p.strategy.Load(), []byte(ev.ImageID.String()), data); err != nil {
+		return fmt.Errorf("failed to send event: %v", err)
+	}
+
+	return nil
+}
@@ -2,53 +2,158 @@ package producer
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
 	wbfkafka "github.com/wb-go/wbf/kafka"
 	"github.com/wb-go/wbf/retry"
 
 	"github.com/aliskhannn/image-processor/internal/config"
+	"github.com/aliskhannn/image-processor/internal/infra/queue/codec"
 	"github.com/aliskhannn/image-processor/internal/model"
+	"github.com/aliskhannn/image-processor/internal/reload"
+	"github.com/aliskhannn/image-processor/internal/trace"
 )
 
 // Producer represents a Kafka producer.
+// Tasks are sent to the topic of their priority, else to the topic of their action,
+// or to the default topic if neither is mapped.
 type Producer struct {
-	Client   *wbfkafka.Producer
-	strategy retry.Strategy
-	cfg      *config.Kafka
+	Client     *wbfkafka.Producer            // producer of the default topic
+	priorities map[string]*wbfkafka.Producer // producers of the per-priority topics by priority
+	actions    map[string]*wbfkafka.Producer // producers of the per-action topics by action
+	dialer     *kafka.Dialer                 // dialer of broker connections checked by Ping
+	codec      *codec.Codec
+	strategy   *reload.Value[retry.Strategy]
+	cfg        *config.Kafka
 }
 
 // New creates a new Producer.
 // - cfg: Kafka configuration struct
 // - s: retry strategy
-// - uh: handler for processing uploaded image messages
+// - c: codec encoding the tasks in the configured message format
 func New(
 	cfg *config.Kafka,
-	s retry.Strategy,
-) *Producer {
-	producer := wbfkafka.NewProducer(cfg.Brokers, cfg.Topic)
+	s *reload.Value[retry.Strategy],
+	c *codec.Codec,
+) (*Producer, error) {
+	producer, err := NewClient(cfg, cfg.Topic)
+	if err != nil {
+		return nil, err
+	}
 
-	return &Producer{
-		Client:   producer,
-		cfg:      cfg,
-		strategy: s,
+	dialer, err := Dialer(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Routes sharing a topic share its producer.
+	byTopic := make(map[string]*wbfkafka.Producer)
+	routes := func(topics map[string]string) map[string]*wbfkafka.Producer {
+		producers := make(map[string]*wbfkafka.Producer, len(topics))
+		for name, topic := range topics {
+			if _, ok := byTopic[topic]; !ok {
+				// The settings were validated by the default topic's client above.
+				byTopic[topic], _ = NewClient(cfg, topic)
+			}
+			producers[name] = byTopic[topic]
+		}
+
+		return producers
 	}
+
+	return &Producer{
+		Client:     producer,
+		priorities: routes(cfg.PriorityTopics),
+		actions:    routes(cfg.ActionTopics),
+		dialer:     dialer,
+		codec:      c,
+		cfg:        cfg,
+		strategy:   s,
+	}, nil
 }
 
-// Produce serializes the Task to JSON and sends it to Kafka using the producer.
+// Produce serializes the Task in the configured format and sends it to the topic of its action.
 // The Task ID is used as the message key for partitioning and ordering.
+// Each message carries a unique ID header, so that consumers can recognize redeliveries,
+// along with the request ID and trace context of ctx.
 func (p *Producer) Produce(ctx context.Context, img model.Image) error {
-	data, err := json.Marshal(img)
+	client, ok := p.priorities[img.Priority]
+	if !ok {
+		client, ok = p.actions[img.Action.Name]
+	}
+	if !ok {
+		client = p.Client
+	}
+
+	data, err := p.codec.Encode(ctx, client.Writer.Topic, model.NewTask(img))
 	if err != nil {
 		return fmt.Errorf("failed to marshal task: %v", err)
 	}
 
 	key := []byte(img.ID.String())
 
-	if err = p.Client.SendWithRetry(ctx, p.strategy, key, data); err != nil {
+	msg := kafka.Message{
+		Key:     key,
+		Value:   data,
+		Headers: []kafka.Header{{Key: model.MessageIDHeader, Value: []byte(uuid.NewString())}},
+	}
+	trace.Inject(ctx, func(key, value string) {
+		msg.Headers = append(msg.Headers, kafka.Header{Key: key, Value: []byte(value)})
+	})
+
+	err = retry.Do(func() error {
+		return client.Writer.WriteMessages(ctx, msg)
+	}, p.strategy.Load())
+	if err != nil {
 		return fmt.Errorf("failed to send task: %v", err)
 	}
 
 	return nil
 }
+
+// Close closes the producers of the default, per-priority and per-action topics.
+func (p *Producer) Close() error {
+	errs := []error{p.Client.Close()}
+
+	closed := make(map[*wbfkafka.Producer]bool)
+	for _, routes := range []map[string]*wbfkafka.Producer{p.priorities, p.actions} {
+		for _, client := range routes {
+			if !closed[client] {
+				closed[client] = true
+				errs = append(errs, client.Close())
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Ping checks that at least one of the configured brokers is reachable.
+func (p *Producer) Ping(ctx context.Context) error {
+	var errs []error
+
+	for _, broker := range p.cfg.Brokers {
+		conn, err := p.dialer.DialContext(ctx, "tcp", broker)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if deadline, ok := ctx.Deadline(); ok {
+			_ = conn.SetDeadline(deadline)
+		}
+
+		_, err = conn.Brokers()
+		_ = conn.Close()
+		if err == nil {
+			return nil
+		}
+
+		errs = append(errs, err)
+	}
+
+	return fmt.Errorf("no kafka broker is reachable: %w", errors.Join(errs...))
+}
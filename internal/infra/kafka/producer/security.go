@@ -0,0 +1,134 @@
+package producer
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+
+	"github.com/aliskhannn/image-processor/internal/config"
+)
+
+// Supported SASL mechanisms, selected by kafka.sasl.mechanism.
+const (
+	saslPlain       = "plain"
+	saslSCRAMSHA256 = "scram-sha-256"
+	saslSCRAMSHA512 = "scram-sha-512"
+)
+
+// dialTimeout bounds establishing a secured broker connection, as kafka.DefaultDialer does.
+const dialTimeout = 10 * time.Second
+
+// Transport returns the transport of writers with the SASL and TLS settings of cfg,
+// or nil to use the kafka-go default when neither is configured.
+func Transport(cfg *config.Kafka) (*kafka.Transport, error) {
+	mechanism, tlsCfg, err := security(cfg)
+	if err != nil || (mechanism == nil && tlsCfg == nil) {
+		return nil, err
+	}
+
+	return &kafka.Transport{
+		DialTimeout: dialTimeout,
+		SASL:        mechanism,
+		TLS:         tlsCfg,
+	}, nil
+}
+
+// Dialer returns the dialer of readers and broker connections with the SASL and TLS
+// settings of cfg, or kafka.DefaultDialer when neither is configured.
+func Dialer(cfg *config.Kafka) (*kafka.Dialer, error) {
+	mechanism, tlsCfg, err := security(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if mechanism == nil && tlsCfg == nil {
+		return kafka.DefaultDialer, nil
+	}
+
+	return &kafka.Dialer{
+		Timeout:       dialTimeout,
+		DualStack:     true,
+		SASLMechanism: mechanism,
+		TLS:           tlsCfg,
+	}, nil
+}
+
+// security builds the SASL mechanism and TLS configuration of cfg; each is nil when disabled.
+func security(cfg *config.Kafka) (sasl.Mechanism, *tls.Config, error) {
+	mechanism, err := saslMechanism(cfg.SASL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tlsCfg, err := tlsConfig(cfg.TLS)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return mechanism, tlsCfg, nil
+}
+
+// saslMechanism returns the configured SASL mechanism, or nil if SASL is disabled.
+func saslMechanism(cfg config.KafkaSASL) (sasl.Mechanism, error) {
+	switch cfg.Mechanism {
+	case "":
+		return nil, nil
+	case saslPlain:
+		return plain.Mechanism{Username: cfg.Username, Password: cfg.Password}, nil
+	case saslSCRAMSHA256, saslSCRAMSHA512:
+		algo := scram.SHA256
+		if cfg.Mechanism == saslSCRAMSHA512 {
+			algo = scram.SHA512
+		}
+
+		mechanism, err := scram.Mechanism(algo, cfg.Username, cfg.Password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create kafka sasl mechanism: %w", err)
+		}
+
+		return mechanism, nil
+	default:
+		return nil, fmt.Errorf("unknown kafka sasl mechanism %q", cfg.Mechanism)
+	}
+}
+
+// tlsConfig returns the configured TLS settings, or nil if TLS is disabled.
+func tlsConfig(cfg config.KafkaTLS) (*tls.Config, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	tlsCfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read kafka ca file: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in kafka ca file %s", cfg.CAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load kafka client certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsCfg, nil
+}
@@ -0,0 +1,167 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/wb-go/wbf/retry"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/image-processor/internal/config"
+	"github.com/aliskhannn/image-processor/internal/infra/queue"
+	"github.com/aliskhannn/image-processor/internal/metrics"
+	"github.com/aliskhannn/image-processor/internal/model"
+	"github.com/aliskhannn/image-processor/internal/reload"
+	"github.com/aliskhannn/image-processor/internal/trace"
+)
+
+// uploadedHandler defines the interface for handling uploaded image messages.
+type uploadedHandler interface {
+	Handle(ctx context.Context, msg model.QueueMessage) error
+}
+
+// deadLetterRecorder defines the interface for recording messages that failed processing after retries.
+type deadLetterRecorder interface {
+	RecordDeadLetter(ctx context.Context, dl model.DeadLetter) error
+}
+
+// decoder defines the interface for decoding tasks from message values.
+type decoder interface {
+	Decode(data []byte) (model.Task, error)
+}
+
+// Consumer processes tasks from a Queue. Failed tasks are requeued after the configured
+// retry delays and then recorded as dead letters.
+type Consumer struct {
+	queue           *Queue
+	uploadedHandler uploadedHandler
+	deadLetters     deadLetterRecorder
+	decoder         decoder
+	pauser          *queue.Pauser
+	strategy        *reload.Value[retry.Strategy]
+	cfg             *config.Memory
+}
+
+// NewConsumer creates a new Consumer.
+// - q: queue shared with the publisher
+// - cfg: in-memory queue configuration struct
+// - s: retry strategy
+// - uh: handler for processing uploaded image messages
+// - dl: recorder of messages failing after all retries; nil drops them
+// - d: decoder of the message format, used to record dead letters as JSON
+// - p: pauser shared by the consumers
+func NewConsumer(
+	q *Queue,
+	cfg *config.Memory,
+	s *reload.Value[retry.Strategy],
+	uh uploadedHandler,
+	dl deadLetterRecorder,
+	d decoder,
+	p *queue.Pauser,
+) *Consumer {
+	return &Consumer{
+		queue:           q,
+		uploadedHandler: uh,
+		deadLetters:     dl,
+		decoder:         d,
+		pauser:          p,
+		strategy:        s,
+		cfg:             cfg,
+	}
+}
+
+// Consume continuously takes tasks from the queue and processes them using the handler.
+// It stops taking tasks while paused and stops gracefully on context cancellation or when
+// the queue is closed.
+func (c *Consumer) Consume(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	zlog.Logger.Info().Str("queue", Topic).Msg("starting consumer")
+
+	for {
+		// Don't take new tasks while consumption is paused.
+		if !c.pauser.Wait(ctx) {
+			zlog.Logger.Info().Str("queue", Topic).Msg("shutdown signal received, stopping consumer")
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			zlog.Logger.Info().Str("queue", Topic).Msg("shutdown signal received, stopping consumer")
+			return
+		case <-c.queue.done:
+			return
+		case d := <-c.queue.tasks:
+			metrics.SetFetchBacklog(Topic, int64(len(c.queue.tasks)))
+			c.handle(ctx, d)
+		}
+	}
+}
+
+// Close releases the consumer. The queue itself is closed by its publisher.
+func (c *Consumer) Close() error {
+	return nil
+}
+
+// handle processes the delivered task and requeues or dead-letters it on failure.
+func (c *Consumer) handle(ctx context.Context, d delivery) {
+	defer c.pauser.Begin()()
+
+	d.deliveries++
+	msgCtx := trace.Extract(ctx, func(key string) string { return d.msg.Headers[key] })
+	tc := trace.FromContext(msgCtx)
+
+	err := retry.Do(func() error {
+		return c.uploadedHandler.Handle(msgCtx, d.msg)
+	}, c.strategy.Load())
+	if err == nil {
+		zlog.Logger.Info().
+			Str("queue", Topic).
+			Str("request_id", tc.RequestID).
+			Str("trace_id", trace.TraceID(tc.TraceParent)).
+			Str("message_id", d.msg.ID).
+			Msg("message handled successfully")
+		return
+	}
+
+	zlog.Logger.Err(err).
+		Str("queue", Topic).
+		Str("request_id", tc.RequestID).
+		Str("trace_id", trace.TraceID(tc.TraceParent)).
+		Int("delivery", d.deliveries).
+		Msg("failed to process image")
+
+	// On shutdown the task is lost along with the rest of the queue.
+	if ctx.Err() != nil {
+		return
+	}
+
+	if tier := d.deliveries - 1; tier < len(c.cfg.RetryDelays) {
+		c.queue.requeue(d, c.cfg.RetryDelays[tier])
+
+		zlog.Logger.Warn().
+			Str("message_id", d.msg.ID).
+			Dur("delay", c.cfg.RetryDelays[tier]).
+			Msg("message scheduled for retry")
+		return
+	}
+
+	if c.deadLetters == nil {
+		return
+	}
+
+	dl := model.DeadLetter{
+		Topic:    Topic,
+		Payload:  queue.Payload(c.decoder, d.msg.Value),
+		Error:    err.Error(),
+		Attempts: c.strategy.Load().Attempts * d.deliveries,
+		FailedAt: time.Now(),
+	}
+	if dlErr := c.deadLetters.RecordDeadLetter(ctx, dl); dlErr != nil {
+		zlog.Logger.Err(dlErr).Str("message_id", d.msg.ID).Msg("failed to record dead letter")
+		return
+	}
+
+	zlog.Logger.Warn().Str("message_id", d.msg.ID).Msg("message moved to dead-letter queue")
+}
@@ -0,0 +1,112 @@
+// Package memory implements an in-process, channel-backed queue of processing tasks, so that
+// the upload and processing flow can run in local development and tests without a broker.
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/aliskhannn/image-processor/internal/config"
+	"github.com/aliskhannn/image-processor/internal/infra/queue/codec"
+	"github.com/aliskhannn/image-processor/internal/model"
+	"github.com/aliskhannn/image-processor/internal/trace"
+)
+
+// Topic is the name the in-memory queue reports for its messages.
+const Topic = "memory"
+
+// defaultBuffer is the queue capacity used when none is configured.
+const defaultBuffer = 100
+
+// ErrClosed is returned when publishing to a closed queue.
+var ErrClosed = errors.New("queue closed")
+
+// delivery is a queued message together with the number of times it has been delivered.
+type delivery struct {
+	msg        model.QueueMessage
+	deliveries int
+}
+
+// Queue holds processing tasks in a buffered channel shared by the publisher and the consumers.
+// Tasks still queued when the process exits are lost.
+type Queue struct {
+	tasks chan delivery
+	codec *codec.Codec
+
+	done      chan struct{} // closed on Close
+	closeOnce sync.Once
+}
+
+// NewQueue creates a new Queue.
+// - cfg: in-memory queue configuration struct
+// - c: codec encoding the tasks in the configured message format
+func NewQueue(cfg *config.Memory, c *codec.Codec) *Queue {
+	buffer := cfg.Buffer
+	if buffer <= 0 {
+		buffer = defaultBuffer
+	}
+
+	return &Queue{
+		tasks: make(chan delivery, buffer),
+		codec: c,
+		done:  make(chan struct{}),
+	}
+}
+
+// Produce serializes the Task in the configured format and queues it, blocking while the queue is full.
+func (q *Queue) Produce(ctx context.Context, img model.Image) error {
+	data, err := q.codec.Encode(ctx, Topic, model.NewTask(img))
+	if err != nil {
+		return fmt.Errorf("failed to marshal task: %v", err)
+	}
+
+	headers := map[string]string{model.MessageIDHeader: uuid.NewString()}
+	trace.Inject(ctx, func(key, value string) { headers[key] = value })
+
+	d := delivery{msg: model.QueueMessage{
+		ID:      headers[model.MessageIDHeader],
+		Topic:   Topic,
+		Value:   data,
+		Headers: headers,
+	}}
+
+	select {
+	case <-q.done:
+		return fmt.Errorf("failed to send task: %w", ErrClosed)
+	case <-ctx.Done():
+		return fmt.Errorf("failed to send task: %w", ctx.Err())
+	case q.tasks <- d:
+		return nil
+	}
+}
+
+// Ping reports whether the queue still accepts tasks.
+func (q *Queue) Ping(ctx context.Context) error {
+	select {
+	case <-q.done:
+		return ErrClosed
+	default:
+		return nil
+	}
+}
+
+// Close stops the queue from accepting tasks. Queued tasks are dropped.
+func (q *Queue) Close() error {
+	q.closeOnce.Do(func() { close(q.done) })
+	return nil
+}
+
+// requeue queues the delivery again after the delay, unless the queue is closed first.
+func (q *Queue) requeue(d delivery, delay time.Duration) {
+	time.AfterFunc(delay, func() {
+		select {
+		case <-q.done:
+		case q.tasks <- d:
+		}
+	})
+}
@@ -0,0 +1,313 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/wb-go/wbf/retry"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/image-processor/internal/config"
+	"github.com/aliskhannn/image-processor/internal/infra/nats/producer"
+	"github.com/aliskhannn/image-processor/internal/infra/queue"
+	"github.com/aliskhannn/image-processor/internal/metrics"
+	"github.com/aliskhannn/image-processor/internal/model"
+	"github.com/aliskhannn/image-processor/internal/reload"
+	"github.com/aliskhannn/image-processor/internal/trace"
+)
+
+// fetchWait is how long a fetch waits for a message before the pause and shutdown state is checked again.
+const fetchWait = 5 * time.Second
+
+// uploadedHandler defines the interface for handling uploaded image messages.
+type uploadedHandler interface {
+	Handle(ctx context.Context, msg model.QueueMessage) error
+}
+
+// deadLetterRecorder defines the interface for recording messages that failed processing after retries.
+type deadLetterRecorder interface {
+	RecordDeadLetter(ctx context.Context, dl model.DeadLetter) error
+}
+
+// decoder defines the interface for decoding tasks from message values.
+type decoder interface {
+	Decode(data []byte) (model.Task, error)
+}
+
+// Consumer consumes processing tasks from a durable NATS JetStream consumer.
+// Failed tasks are redelivered after the configured retry delays and then recorded as dead letters.
+type Consumer struct {
+	Conn            *nats.Conn
+	js              jetstream.JetStream
+	uploadedHandler uploadedHandler
+	deadLetters     deadLetterRecorder
+	decoder         decoder
+	pauser          *queue.Pauser
+	strategy        *reload.Value[retry.Strategy]
+	cfg             *config.NATS
+}
+
+// New creates a new Consumer.
+// - cfg: NATS configuration struct
+// - s: retry strategy
+// - uh: handler for processing uploaded image messages
+// - dl: recorder of messages failing after all retries; nil leaves them in the stream unacknowledged
+// - d: decoder of the message format, used to record dead letters as JSON
+// - p: pauser shared by the consumers
+func New(
+	cfg *config.NATS,
+	s *reload.Value[retry.Strategy],
+	uh uploadedHandler,
+	dl deadLetterRecorder,
+	d decoder,
+	p *queue.Pauser,
+) (*Consumer, error) {
+	nc, js, err := producer.Connect(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Consumer{
+		Conn:            nc,
+		js:              js,
+		uploadedHandler: uh,
+		deadLetters:     dl,
+		decoder:         d,
+		pauser:          p,
+		strategy:        s,
+		cfg:             cfg,
+	}, nil
+}
+
+// Consume continuously fetches tasks, processes them using the handler and acknowledges them
+// after successful processing. It stops fetching while paused and stops gracefully on
+// context cancellation; unacknowledged tasks are redelivered after the ack wait.
+func (c *Consumer) Consume(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	zlog.Logger.Info().
+		Str("subject", c.cfg.Subject).
+		Msg("starting consumer")
+
+	cons, err := c.consumer(ctx)
+	if err != nil {
+		zlog.Logger.Err(err).Msg("failed to create nats consumer")
+		return
+	}
+
+	for {
+		// Exit if context is canceled (graceful shutdown).
+		if ctx.Err() != nil {
+			zlog.Logger.Info().Str("subject", c.cfg.Subject).Msg("shutdown signal received, stopping consumer")
+			return
+		}
+
+		// Don't fetch new messages while consumption is paused.
+		if !c.pauser.Wait(ctx) {
+			continue
+		}
+
+		batch, err := cons.Fetch(1, jetstream.FetchMaxWait(fetchWait))
+		if err != nil {
+			zlog.Logger.Err(err).Msg("failed to fetch message")
+			time.Sleep(500 * time.Millisecond)
+			continue
+		}
+
+		for msg := range batch.Messages() {
+			c.handle(ctx, msg)
+		}
+		if err := batch.Error(); err != nil && !errors.Is(err, nats.ErrTimeout) {
+			zlog.Logger.Err(err).Msg("failed to fetch message")
+		}
+	}
+}
+
+// Close closes the connection to the NATS server.
+func (c *Consumer) Close() error {
+	c.Conn.Close()
+	return nil
+}
+
+// consumer creates the stream and the durable consumer, retrying until they are created
+// or the context is canceled.
+func (c *Consumer) consumer(ctx context.Context) (jetstream.Consumer, error) {
+	ackWait := c.cfg.AckWait
+	if ackWait <= 0 {
+		ackWait = 30 * time.Second
+	}
+
+	for {
+		err := producer.EnsureStream(ctx, c.js, c.cfg)
+		if err == nil {
+			var cons jetstream.Consumer
+			cons, err = c.js.CreateOrUpdateConsumer(ctx, c.cfg.Stream, jetstream.ConsumerConfig{
+				Durable:       c.cfg.Durable,
+				FilterSubject: c.cfg.Subject,
+				AckPolicy:     jetstream.AckExplicitPolicy,
+				AckWait:       ackWait,
+				MaxDeliver:    len(c.cfg.RetryDelays) + 1,
+			})
+			if err == nil {
+				return cons, nil
+			}
+		}
+
+		zlog.Logger.Err(err).Msg("failed to set up nats stream, retrying")
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// handle processes the message, extending its ack deadline while the handler runs, and
+// acknowledges, redelivers or dead-letters it depending on the outcome.
+func (c *Consumer) handle(ctx context.Context, msg jetstream.Msg) {
+	defer c.pauser.Begin()()
+
+	meta, err := msg.Metadata()
+	if err != nil {
+		zlog.Logger.Err(err).Msg("failed to read message metadata")
+		return
+	}
+	metrics.SetFetchBacklog(c.cfg.Subject, int64(meta.NumPending))
+
+	qm := queueMessage(msg, meta)
+	msgCtx := trace.Extract(ctx, msg.Headers().Get)
+	tc := trace.FromContext(msgCtx)
+
+	stop := c.keepAlive(ctx, msg)
+	err = retry.Do(func() error {
+		return c.uploadedHandler.Handle(msgCtx, qm)
+	}, c.strategy.Load())
+	stop()
+
+	if err == nil {
+		if ackErr := msg.Ack(); ackErr != nil {
+			zlog.Logger.Err(ackErr).Str("message_id", qm.ID).Msg("failed to acknowledge message")
+			return
+		}
+
+		zlog.Logger.Info().
+			Str("subject", qm.Topic).
+			Str("request_id", tc.RequestID).
+			Str("trace_id", trace.TraceID(tc.TraceParent)).
+			Uint64("sequence", meta.Sequence.Stream).
+			Msg("message handled successfully")
+		return
+	}
+
+	zlog.Logger.Err(err).
+		Str("subject", qm.Topic).
+		Str("request_id", tc.RequestID).
+		Str("trace_id", trace.TraceID(tc.TraceParent)).
+		Uint64("delivery", meta.NumDelivered).
+		Msg("failed to process image")
+
+	// On shutdown the message is redelivered after the ack wait.
+	if ctx.Err() != nil {
+		return
+	}
+
+	c.handleFailure(ctx, msg, meta, err)
+}
+
+// handleFailure redelivers the message after the retry delay of its delivery or, after the
+// last one, records it as a dead letter and terminates its redelivery.
+func (c *Consumer) handleFailure(ctx context.Context, msg jetstream.Msg, meta *jetstream.MsgMetadata, err error) {
+	if tier := int(meta.NumDelivered) - 1; tier < len(c.cfg.RetryDelays) {
+		if nakErr := msg.NakWithDelay(c.cfg.RetryDelays[tier]); nakErr != nil {
+			zlog.Logger.Err(nakErr).Msg("failed to schedule message for retry")
+			return
+		}
+
+		zlog.Logger.Warn().
+			Uint64("sequence", meta.Sequence.Stream).
+			Dur("delay", c.cfg.RetryDelays[tier]).
+			Msg("message scheduled for retry")
+		return
+	}
+
+	if c.deadLetters == nil {
+		return
+	}
+
+	dl := model.DeadLetter{
+		Topic:    msg.Subject(),
+		Offset:   int64(meta.Sequence.Stream),
+		Payload:  queue.Payload(c.decoder, msg.Data()),
+		Error:    err.Error(),
+		Attempts: c.strategy.Load().Attempts * int(meta.NumDelivered),
+		FailedAt: time.Now(),
+	}
+	if dlErr := c.deadLetters.RecordDeadLetter(ctx, dl); dlErr != nil {
+		zlog.Logger.Err(dlErr).Uint64("sequence", meta.Sequence.Stream).Msg("failed to record dead letter")
+		return
+	}
+
+	if termErr := msg.Term(); termErr != nil {
+		zlog.Logger.Err(termErr).Msg("failed to terminate dead-lettered message")
+		return
+	}
+
+	zlog.Logger.Warn().Uint64("sequence", meta.Sequence.Stream).Msg("message moved to dead-letter queue")
+}
+
+// keepAlive extends the ack deadline of the message every half ack wait until the returned
+// function is called, so that long processing doesn't cause a redelivery.
+func (c *Consumer) keepAlive(ctx context.Context, msg jetstream.Msg) (stop func()) {
+	interval := c.cfg.AckWait / 2
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := msg.InProgress(); err != nil {
+					zlog.Logger.Err(err).Msg("failed to extend message ack deadline")
+				}
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// queueMessage converts the JetStream message for the handler. Messages published without
+// a message ID header are identified by their stream and sequence.
+func queueMessage(msg jetstream.Msg, meta *jetstream.MsgMetadata) model.QueueMessage {
+	headers := make(map[string]string, len(msg.Headers()))
+	for key := range msg.Headers() {
+		headers[key] = msg.Headers().Get(key)
+	}
+
+	id := headers[model.MessageIDHeader]
+	if id == "" {
+		id = fmt.Sprintf("%s/%d", meta.Stream, meta.Sequence.Stream)
+	}
+
+	return model.QueueMessage{
+		ID:      id,
+		Topic:   msg.Subject(),
+		Value:   msg.Data(),
+		Headers: headers,
+	}
+}
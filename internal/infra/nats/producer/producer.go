@@ -0,0 +1,154 @@
+package producer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/wb-go/wbf/retry"
+
+	"github.com/aliskhannn/image-processor/internal/config"
+	"github.com/aliskhannn/image-processor/internal/infra/queue/codec"
+	"github.com/aliskhannn/image-processor/internal/model"
+	"github.com/aliskhannn/image-processor/internal/reload"
+	"github.com/aliskhannn/image-processor/internal/trace"
+)
+
+// pingTimeout bounds Ping when the context has no deadline.
+const pingTimeout = 5 * time.Second
+
+// Producer publishes processing tasks to a NATS JetStream stream.
+type Producer struct {
+	Conn     *nats.Conn
+	js       jetstream.JetStream
+	codec    *codec.Codec
+	strategy *reload.Value[retry.Strategy]
+	cfg      *config.NATS
+
+	mu     sync.Mutex
+	stream bool // whether the stream is known to exist
+}
+
+// New creates a new Producer. The connection is retried in the background until the server
+// is reachable, and the stream is created on the first publish.
+// - cfg: NATS configuration struct
+// - s: retry strategy
+// - c: codec encoding the tasks in the configured message format
+func New(cfg *config.NATS, s *reload.Value[retry.Strategy], c *codec.Codec) (*Producer, error) {
+	nc, js, err := Connect(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Producer{
+		Conn:     nc,
+		js:       js,
+		codec:    c,
+		strategy: s,
+		cfg:      cfg,
+	}, nil
+}
+
+// Connect connects to the NATS server, retrying in the background while it is unreachable.
+func Connect(cfg *config.NATS) (*nats.Conn, jetstream.JetStream, error) {
+	nc, err := nats.Connect(cfg.URL,
+		nats.Name("image-processor"),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to nats: %w", err)
+	}
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		nc.Close()
+		return nil, nil, fmt.Errorf("failed to create jetstream context: %w", err)
+	}
+
+	return nc, js, nil
+}
+
+// EnsureStream creates the stream of the tasks subject, or updates it to the configuration.
+func EnsureStream(ctx context.Context, js jetstream.JetStream, cfg *config.NATS) error {
+	_, err := js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:     cfg.Stream,
+		Subjects: []string{cfg.Subject},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create stream %s: %w", cfg.Stream, err)
+	}
+
+	return nil
+}
+
+// Produce serializes the Task in the configured format and publishes it to the tasks subject.
+// The message ID doubles as the JetStream deduplication ID, so retried publishes aren't stored twice.
+func (p *Producer) Produce(ctx context.Context, img model.Image) error {
+	if err := p.ensureStream(ctx); err != nil {
+		return err
+	}
+
+	data, err := p.codec.Encode(ctx, p.cfg.Subject, model.NewTask(img))
+	if err != nil {
+		return fmt.Errorf("failed to marshal task: %v", err)
+	}
+
+	id := uuid.NewString()
+	msg := nats.NewMsg(p.cfg.Subject)
+	msg.Data = data
+	msg.Header.Set(model.MessageIDHeader, id)
+	trace.Inject(ctx, msg.Header.Set)
+
+	err = retry.Do(func() error {
+		_, pubErr := p.js.PublishMsg(ctx, msg, jetstream.WithMsgID(id))
+		return pubErr
+	}, p.strategy.Load())
+	if err != nil {
+		return fmt.Errorf("failed to send task: %v", err)
+	}
+
+	return nil
+}
+
+// Ping checks that the NATS server is reachable.
+func (p *Producer) Ping(ctx context.Context) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, pingTimeout)
+		defer cancel()
+	}
+
+	if err := p.Conn.FlushWithContext(ctx); err != nil {
+		return fmt.Errorf("nats is not reachable: %w", err)
+	}
+
+	return nil
+}
+
+// Close closes the connection to the NATS server.
+func (p *Producer) Close() error {
+	p.Conn.Close()
+	return nil
+}
+
+// ensureStream creates the stream once per process.
+func (p *Producer) ensureStream(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stream {
+		return nil
+	}
+
+	if err := EnsureStream(ctx, p.js, p.cfg); err != nil {
+		return err
+	}
+	p.stream = true
+
+	return nil
+}
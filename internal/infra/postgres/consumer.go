@@ -0,0 +1,277 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/wb-go/wbf/retry"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/image-processor/internal/config"
+	"github.com/aliskhannn/image-processor/internal/infra/queue"
+	"github.com/aliskhannn/image-processor/internal/model"
+	"github.com/aliskhannn/image-processor/internal/reload"
+	"github.com/aliskhannn/image-processor/internal/trace"
+)
+
+// defaultPollInterval is how often idle workers look for due tasks when no interval is configured.
+const defaultPollInterval = time.Second
+
+// uploadedHandler defines the interface for handling uploaded image messages.
+type uploadedHandler interface {
+	Handle(ctx context.Context, msg model.QueueMessage) error
+}
+
+// deadLetterRecorder defines the interface for recording messages that failed processing after retries.
+type deadLetterRecorder interface {
+	RecordDeadLetter(ctx context.Context, dl model.DeadLetter) error
+}
+
+// decoder defines the interface for decoding tasks from message values.
+type decoder interface {
+	Decode(data []byte) (model.Task, error)
+}
+
+// job is a task claimed from the jobs table.
+type job struct {
+	id         int64
+	msg        model.QueueMessage
+	deliveries int // previous deliveries
+}
+
+// Consumer processes tasks from the jobs table. A task is claimed with FOR UPDATE SKIP LOCKED
+// in a transaction held while it is processed, so that concurrent workers skip it and a crashed
+// worker releases it. Failed tasks are run again after the configured retry delays and then
+// recorded as dead letters.
+type Consumer struct {
+	db              *sql.DB
+	uploadedHandler uploadedHandler
+	deadLetters     deadLetterRecorder
+	decoder         decoder
+	pauser          *queue.Pauser
+	strategy        *reload.Value[retry.Strategy]
+	cfg             *config.PGQueue
+	pollInterval    time.Duration
+}
+
+// NewConsumer creates a new Consumer.
+// - db: connection to the PostgreSQL database holding the jobs table, shared with the Queue
+// - cfg: queue configuration struct
+// - s: retry strategy
+// - uh: handler for processing uploaded image messages
+// - dl: recorder of messages failing after all retries; nil drops them
+// - d: decoder of the message format, used to record dead letters as JSON
+// - p: pauser shared by the consumers
+func NewConsumer(
+	db *sql.DB,
+	cfg *config.PGQueue,
+	s *reload.Value[retry.Strategy],
+	uh uploadedHandler,
+	dl deadLetterRecorder,
+	d decoder,
+	p *queue.Pauser,
+) *Consumer {
+	c := &Consumer{
+		db:              db,
+		uploadedHandler: uh,
+		deadLetters:     dl,
+		decoder:         d,
+		pauser:          p,
+		strategy:        s,
+		cfg:             cfg,
+		pollInterval:    cfg.PollInterval,
+	}
+	if c.pollInterval <= 0 {
+		c.pollInterval = defaultPollInterval
+	}
+
+	return c
+}
+
+// Consume continuously claims due tasks and processes them using the handler, polling while
+// there are none. It stops claiming tasks while paused and stops gracefully on context cancellation.
+func (c *Consumer) Consume(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	zlog.Logger.Info().Str("queue", Topic).Msg("starting consumer")
+
+	for {
+		// Don't claim new tasks while consumption is paused.
+		if !c.pauser.Wait(ctx) {
+			zlog.Logger.Info().Str("queue", Topic).Msg("shutdown signal received, stopping consumer")
+			return
+		}
+
+		claimed, err := c.next(ctx)
+		if err != nil && ctx.Err() == nil {
+			zlog.Logger.Err(err).Str("queue", Topic).Msg("failed to claim task")
+		}
+		if claimed {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			zlog.Logger.Info().Str("queue", Topic).Msg("shutdown signal received, stopping consumer")
+			return
+		case <-time.After(c.pollInterval):
+		}
+	}
+}
+
+// Close releases the consumer. The connection to the database is closed by the Queue.
+func (c *Consumer) Close() error {
+	return nil
+}
+
+// next claims the next due task and processes it. Reports whether a task was claimed.
+func (c *Consumer) next(ctx context.Context) (bool, error) {
+	// The task is finished and committed on shutdown, up to the drain timeout.
+	drainCtx, cancelDrain := queue.Drain(ctx, c.cfg.DrainTimeout)
+	defer cancelDrain()
+
+	tx, err := c.db.BeginTx(drainCtx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	// Rolling back releases the task if it wasn't handled; it is a no-op once committed.
+	defer func() { _ = tx.Rollback() }()
+
+	query := `
+		SELECT id, message_id, payload, headers, deliveries
+		FROM jobs
+		WHERE run_at <= now()
+		ORDER BY run_at, id
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED
+    `
+
+	var (
+		j       job
+		headers []byte
+	)
+
+	err = tx.QueryRowContext(ctx, query).Scan(&j.id, &j.msg.ID, &j.msg.Value, &headers, &j.deliveries)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("failed to select task: %w", err)
+	}
+
+	j.msg.Topic = Topic
+	if err := json.Unmarshal(headers, &j.msg.Headers); err != nil {
+		return true, fmt.Errorf("failed to unmarshal headers: %w", err)
+	}
+
+	if err := c.handle(ctx, drainCtx, tx, j); err != nil {
+		return true, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return true, fmt.Errorf("failed to commit task: %w", err)
+	}
+
+	return true, nil
+}
+
+// handle processes the claimed task and, in its transaction, deletes, postpones or dead-letters it
+// depending on the outcome.
+func (c *Consumer) handle(ctx, drainCtx context.Context, tx *sql.Tx, j job) error {
+	defer c.pauser.Begin()()
+
+	msgCtx := trace.Extract(drainCtx, func(key string) string { return j.msg.Headers[key] })
+	tc := trace.FromContext(msgCtx)
+
+	err := retry.Do(func() error {
+		return c.uploadedHandler.Handle(msgCtx, j.msg)
+	}, c.strategy.Load())
+	if err == nil {
+		if err := c.delete(drainCtx, tx, j); err != nil {
+			return err
+		}
+
+		zlog.Logger.Info().
+			Str("queue", Topic).
+			Str("request_id", tc.RequestID).
+			Str("trace_id", trace.TraceID(tc.TraceParent)).
+			Str("message_id", j.msg.ID).
+			Msg("message handled successfully")
+		return nil
+	}
+
+	zlog.Logger.Err(err).
+		Str("queue", Topic).
+		Str("request_id", tc.RequestID).
+		Str("trace_id", trace.TraceID(tc.TraceParent)).
+		Int("delivery", j.deliveries+1).
+		Msg("failed to process image")
+
+	// On shutdown the task is rolled back and claimed again by the next worker.
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	return c.handleFailure(drainCtx, tx, j, err)
+}
+
+// handleFailure postpones the task by the retry delay of its delivery or, after the last one,
+// records it as a dead letter and deletes it from the table.
+func (c *Consumer) handleFailure(ctx context.Context, tx *sql.Tx, j job, err error) error {
+	if tier := j.deliveries; tier < len(c.cfg.RetryDelays) {
+		query := `
+			UPDATE jobs
+			SET deliveries = deliveries + 1, run_at = now() + make_interval(secs => $1::float8 / 1000)
+			WHERE id = $2
+        `
+
+		if _, err := tx.ExecContext(ctx, query, c.cfg.RetryDelays[tier].Milliseconds(), j.id); err != nil {
+			return fmt.Errorf("failed to schedule task for retry: %w", err)
+		}
+
+		zlog.Logger.Warn().
+			Str("message_id", j.msg.ID).
+			Dur("delay", c.cfg.RetryDelays[tier]).
+			Msg("message scheduled for retry")
+		return nil
+	}
+
+	if c.deadLetters == nil {
+		return c.delete(ctx, tx, j)
+	}
+
+	dl := model.DeadLetter{
+		Topic:    Topic,
+		Offset:   j.id,
+		Payload:  queue.Payload(c.decoder, j.msg.Value),
+		Error:    err.Error(),
+		Attempts: c.strategy.Load().Attempts * (j.deliveries + 1),
+		FailedAt: time.Now(),
+	}
+	if dlErr := c.deadLetters.RecordDeadLetter(ctx, dl); dlErr != nil {
+		return fmt.Errorf("failed to record dead letter: %w", dlErr)
+	}
+
+	if err := c.delete(ctx, tx, j); err != nil {
+		return err
+	}
+
+	zlog.Logger.Warn().Str("message_id", j.msg.ID).Msg("message moved to dead-letter queue")
+
+	return nil
+}
+
+// delete removes the handled task from the table.
+func (c *Consumer) delete(ctx context.Context, tx *sql.Tx, j job) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM jobs WHERE id = $1`, j.id); err != nil {
+		return fmt.Errorf("failed to delete task: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,138 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/wb-go/wbf/zlog"
+)
+
+// StatusChannel is the channel the images table notifies the ID of images on when their
+// status or stage changes.
+const StatusChannel = "image_status"
+
+// listenerPingInterval is how often the connection of an idle listener is checked, so that
+// a lost connection is noticed and reestablished.
+const listenerPingInterval = 90 * time.Second
+
+// StatusListener listens for status changes of images on a dedicated connection and
+// fans them out to the subscribers of each image.
+type StatusListener struct {
+	listener *pq.Listener
+
+	mu   sync.Mutex
+	subs map[uuid.UUID]map[chan struct{}]struct{}
+}
+
+// NewStatusListener connects to the PostgreSQL database at dsn and listens on StatusChannel.
+// The connection is reestablished with backoff when lost.
+func NewStatusListener(dsn string) (*StatusListener, error) {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			zlog.Logger.Err(err).Int("event", int(ev)).Msg("status listener connection event")
+		}
+	})
+
+	if err := listener.Listen(StatusChannel); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("failed to listen on %s: %w", StatusChannel, err)
+	}
+
+	return &StatusListener{
+		listener: listener,
+		subs:     make(map[uuid.UUID]map[chan struct{}]struct{}),
+	}, nil
+}
+
+// Run dispatches notifications to the subscribers until ctx is canceled.
+func (l *StatusListener) Run(ctx context.Context) {
+	ticker := time.NewTicker(listenerPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-l.listener.Notify:
+			// A nil notification follows a reconnect, after which changes may have been missed.
+			if n == nil {
+				l.broadcast()
+				continue
+			}
+
+			id, err := uuid.Parse(n.Extra)
+			if err != nil {
+				zlog.Logger.Err(err).Str("payload", n.Extra).Msg("invalid status notification")
+				continue
+			}
+
+			l.notify(id)
+		case <-ticker.C:
+			if err := l.listener.Ping(); err != nil {
+				zlog.Logger.Err(err).Msg("failed to ping status listener connection")
+			}
+		}
+	}
+}
+
+// Subscribe returns a channel signaled when the status of the image changes, until cancel is called.
+// Changes made while the previous signal is pending are coalesced into it.
+func (l *StatusListener) Subscribe(id uuid.UUID) (changes <-chan struct{}, cancel func()) {
+	ch := make(chan struct{}, 1)
+
+	l.mu.Lock()
+	if l.subs[id] == nil {
+		l.subs[id] = make(map[chan struct{}]struct{})
+	}
+	l.subs[id][ch] = struct{}{}
+	l.mu.Unlock()
+
+	return ch, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		delete(l.subs[id], ch)
+		if len(l.subs[id]) == 0 {
+			delete(l.subs, id)
+		}
+	}
+}
+
+// Close closes the connection of the listener.
+func (l *StatusListener) Close() error {
+	return l.listener.Close()
+}
+
+// notify signals the subscribers of the image.
+func (l *StatusListener) notify(id uuid.UUID) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for ch := range l.subs[id] {
+		signal(ch)
+	}
+}
+
+// broadcast signals all subscribers.
+func (l *StatusListener) broadcast() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, subs := range l.subs {
+		for ch := range subs {
+			signal(ch)
+		}
+	}
+}
+
+// signal signals ch unless a signal is already pending.
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
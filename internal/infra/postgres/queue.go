@@ -0,0 +1,75 @@
+// Package postgres implements a queue of processing tasks in the jobs table of the PostgreSQL
+// database, so that small deployments can run the upload and processing flow without a broker.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/aliskhannn/image-processor/internal/infra/queue/codec"
+	"github.com/aliskhannn/image-processor/internal/model"
+	"github.com/aliskhannn/image-processor/internal/trace"
+)
+
+// Topic is the name the queue reports for its messages: the table holding them.
+const Topic = "jobs"
+
+// Queue publishes processing tasks by inserting them into the jobs table.
+type Queue struct {
+	db    *sql.DB
+	codec *codec.Codec
+}
+
+// NewQueue creates a new Queue.
+// - db: connection to the PostgreSQL database holding the jobs table, closed by Close
+// - c: codec encoding the tasks in the configured message format
+func NewQueue(db *sql.DB, c *codec.Codec) *Queue {
+	return &Queue{db: db, codec: c}
+}
+
+// Produce serializes the Task in the configured format and inserts it into the jobs table,
+// due immediately.
+func (q *Queue) Produce(ctx context.Context, img model.Image) error {
+	data, err := q.codec.Encode(ctx, Topic, model.NewTask(img))
+	if err != nil {
+		return fmt.Errorf("failed to marshal task: %v", err)
+	}
+
+	headers := map[string]string{model.MessageIDHeader: uuid.NewString()}
+	trace.Inject(ctx, func(key, value string) { headers[key] = value })
+
+	headersJSON, err := json.Marshal(headers)
+	if err != nil {
+		return fmt.Errorf("failed to marshal headers: %v", err)
+	}
+
+	query := `
+		INSERT INTO jobs (message_id, payload, headers)
+		VALUES ($1, $2, $3)
+    `
+
+	if _, err := q.db.ExecContext(ctx, query, headers[model.MessageIDHeader], data, headersJSON); err != nil {
+		return fmt.Errorf("failed to send task: %w", err)
+	}
+
+	return nil
+}
+
+// DB returns the connection to the database, shared with the consumers of the queue.
+func (q *Queue) DB() *sql.DB {
+	return q.db
+}
+
+// Ping checks that the database is reachable.
+func (q *Queue) Ping(ctx context.Context) error {
+	return q.db.PingContext(ctx)
+}
+
+// Close closes the connection to the database, shared with the consumers.
+func (q *Queue) Close() error {
+	return q.db.Close()
+}
@@ -0,0 +1,107 @@
+package codec
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/hamba/avro/v2"
+
+	"github.com/aliskhannn/image-processor/internal/model"
+)
+
+// avroSchema is the parsed Avro schema of processing tasks.
+var avroSchema = func() avro.Schema {
+	data, err := schemas.ReadFile("schema/image_task.avsc")
+	if err != nil {
+		panic(err)
+	}
+
+	return avro.MustParse(string(data))
+}()
+
+// avroTask is the Avro representation of a processing task.
+// Fields of the writer schema missing here, e.g. the image fields of earlier versions, are skipped.
+type avroTask struct {
+	ID          string     `avro:"id"`
+	Action      avroAction `avro:"action"`
+	CallbackURL string     `avro:"callback_url"`
+}
+
+// avroAction is the Avro representation of a task action.
+type avroAction struct {
+	Name   string            `avro:"name"`
+	Params map[string]string `avro:"params"`
+}
+
+// avroWriterSchemas caches the parsed writer schemas of received messages by schema ID.
+type avroWriterSchemas struct {
+	mu      sync.Mutex
+	schemas map[int]avro.Schema
+}
+
+// encodeAvro appends the Avro encoding of the task to dst.
+func encodeAvro(dst []byte, task model.Task) ([]byte, error) {
+	record := avroTask{
+		ID:          task.ID.String(),
+		Action:      avroAction{Name: task.Action.Name, Params: task.Action.Params},
+		CallbackURL: task.CallbackURL,
+	}
+	if record.Action.Params == nil {
+		record.Action.Params = map[string]string{}
+	}
+
+	data, err := avro.Marshal(avroSchema, record)
+	if err != nil {
+		return nil, fmt.Errorf("encode task: failed to encode avro: %w", err)
+	}
+
+	return append(dst, data...), nil
+}
+
+// decodeAvro decodes an Avro-encoded task written with the given schema.
+func decodeAvro(schema avro.Schema, data []byte) (model.Task, error) {
+	var record avroTask
+	if err := avro.Unmarshal(schema, data, &record); err != nil {
+		return model.Task{}, fmt.Errorf("%w: %w", ErrInvalidMessage, err)
+	}
+
+	id, err := uuid.Parse(record.ID)
+	if err != nil {
+		return model.Task{}, fmt.Errorf("%w: invalid id: %w", ErrInvalidMessage, err)
+	}
+
+	return model.Task{
+		ID:          id,
+		Action:      model.Action{Name: record.Action.Name, Params: record.Action.Params},
+		CallbackURL: record.CallbackURL,
+	}, nil
+}
+
+// get returns the parsed writer schema with the given ID, looking it up in the registry once.
+func (s *avroWriterSchemas) get(r *Registry, id int) (avro.Schema, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if schema, ok := s.schemas[id]; ok {
+		return schema, nil
+	}
+
+	text, err := r.Schema(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// A separate cache keeps the named types of earlier versions apart from the current ones.
+	schema, err := avro.ParseWithCache(text, "", &avro.SchemaCache{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse writer schema %d: %w", id, err)
+	}
+
+	if s.schemas == nil {
+		s.schemas = make(map[int]avro.Schema)
+	}
+	s.schemas[id] = schema
+
+	return schema, nil
+}
@@ -0,0 +1,156 @@
+// Package codec encodes processing tasks into queue message values and decodes them back.
+//
+// JSON is the default format. Avro and Protobuf messages use the Confluent wire format:
+// a zero magic byte and the big-endian ID of the schema registered under "<topic>-value"
+// precede the payload, so that other teams can consume the topics with schema guarantees.
+package codec
+
+import (
+	"context"
+	"embed"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/aliskhannn/image-processor/internal/config"
+	"github.com/aliskhannn/image-processor/internal/model"
+)
+
+// Supported message formats.
+const (
+	FormatJSON     = "json"
+	FormatAvro     = "avro"
+	FormatProtobuf = "protobuf"
+)
+
+// magicByte starts every message in the Confluent wire format.
+const magicByte = 0
+
+// headerSize is the size of the magic byte and the schema ID.
+const headerSize = 5
+
+var (
+	// ErrUnknownFormat is returned for a message format other than the supported ones.
+	ErrUnknownFormat = errors.New("unknown message format")
+	// ErrInvalidMessage is returned when a message value can't be decoded.
+	ErrInvalidMessage = errors.New("invalid message")
+)
+
+//go:embed schema
+var schemas embed.FS
+
+// Codec encodes and decodes processing tasks in the configured format.
+type Codec struct {
+	format   string
+	registry *Registry // nil for JSON
+	writers  avroWriterSchemas
+}
+
+// New creates a new Codec for the format configured in cfg, defaulting to JSON.
+// Avro and Protobuf require the URL of a schema registry.
+func New(cfg *config.Queue) (*Codec, error) {
+	c := &Codec{format: cfg.Format}
+
+	switch cfg.Format {
+	case "", FormatJSON:
+		c.format = FormatJSON
+		return c, nil
+	case FormatAvro, FormatProtobuf:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, cfg.Format)
+	}
+
+	if cfg.SchemaRegistry.URL == "" {
+		return nil, fmt.Errorf("%s messages require queue.schema_registry.url", cfg.Format)
+	}
+	c.registry = NewRegistry(cfg.SchemaRegistry.URL, cfg.SchemaRegistry.Timeout)
+
+	return c, nil
+}
+
+// Format returns the format messages are encoded in.
+func (c *Codec) Format() string {
+	return c.format
+}
+
+// Encode encodes the task as the value of a message for the topic.
+func (c *Codec) Encode(ctx context.Context, topic string, task model.Task) ([]byte, error) {
+	if c.format == FormatJSON {
+		data, err := json.Marshal(task)
+		if err != nil {
+			return nil, fmt.Errorf("encode task: %w", err)
+		}
+
+		return data, nil
+	}
+
+	schemaType, schema, err := c.schema()
+	if err != nil {
+		return nil, fmt.Errorf("encode task: %w", err)
+	}
+
+	id, err := c.registry.Register(ctx, topic+"-value", schemaType, schema)
+	if err != nil {
+		return nil, fmt.Errorf("encode task: %w", err)
+	}
+
+	data := make([]byte, headerSize)
+	data[0] = magicByte
+	binary.BigEndian.PutUint32(data[1:], uint32(id))
+
+	if c.format == FormatAvro {
+		return encodeAvro(data, task)
+	}
+
+	return encodeProtobuf(data, task), nil
+}
+
+// Decode decodes a task from a message value. JSON messages are decoded regardless of the
+// configured format, so that messages enqueued before switching formats are still processed.
+// Avro payloads are decoded with the writer schema looked up in the registry by the schema ID
+// of the message, so that messages written by earlier versions of the schema are still read.
+// Protobuf payloads are decoded by field number, skipping unknown fields.
+func (c *Codec) Decode(data []byte) (model.Task, error) {
+	if len(data) == 0 || data[0] != magicByte {
+		var task model.Task
+		if err := json.Unmarshal(data, &task); err != nil {
+			return model.Task{}, fmt.Errorf("%w: %w", ErrInvalidMessage, err)
+		}
+
+		return task, nil
+	}
+
+	if len(data) < headerSize {
+		return model.Task{}, fmt.Errorf("%w: message is shorter than its header", ErrInvalidMessage)
+	}
+
+	switch c.format {
+	case FormatAvro:
+		schema, err := c.writers.get(c.registry, int(binary.BigEndian.Uint32(data[1:headerSize])))
+		if err != nil {
+			return model.Task{}, fmt.Errorf("decode task: %w", err)
+		}
+
+		return decodeAvro(schema, data[headerSize:])
+	case FormatProtobuf:
+		return decodeProtobuf(data[headerSize:])
+	default:
+		return model.Task{}, fmt.Errorf("%w: schema-encoded message in %s mode", ErrInvalidMessage, c.format)
+	}
+}
+
+// schema returns the registry schema type and the embedded schema of the format.
+func (c *Codec) schema() (string, string, error) {
+	schemaType, file := "AVRO", "schema/image_task.avsc"
+	if c.format == FormatProtobuf {
+		schemaType, file = "PROTOBUF", "schema/image_task.proto"
+	}
+
+	data, err := schemas.ReadFile(file)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read schema: %w", err)
+	}
+
+	return schemaType, string(data), nil
+}
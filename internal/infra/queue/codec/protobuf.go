@@ -0,0 +1,188 @@
+package codec
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/aliskhannn/image-processor/internal/model"
+)
+
+// Field numbers of the ImageTask message in schema/image_task.proto. Fields of the image
+// copied into tasks by earlier versions are reserved and skipped when decoding.
+const (
+	fieldID          protowire.Number = 1
+	fieldAction      protowire.Number = 5
+	fieldCallbackURL protowire.Number = 7
+)
+
+// Field numbers of the ImageTask.Action message and of its params map entries.
+const (
+	fieldActionName   protowire.Number = 1
+	fieldActionParams protowire.Number = 2
+	fieldEntryKey     protowire.Number = 1
+	fieldEntryValue   protowire.Number = 2
+)
+
+// encodeProtobuf appends the Protobuf encoding of the task to dst, preceded by the message
+// indexes of the Confluent wire format. ImageTask is the first message of the schema, whose
+// index list [0] is encoded as a single zero byte.
+func encodeProtobuf(dst []byte, task model.Task) []byte {
+	dst = append(dst, 0)
+
+	dst = appendString(dst, fieldID, task.ID.String())
+
+	var action []byte
+	action = appendString(action, fieldActionName, task.Action.Name)
+	for key, value := range task.Action.Params {
+		var entry []byte
+		entry = appendString(entry, fieldEntryKey, key)
+		entry = appendString(entry, fieldEntryValue, value)
+
+		action = protowire.AppendTag(action, fieldActionParams, protowire.BytesType)
+		action = protowire.AppendBytes(action, entry)
+	}
+	dst = protowire.AppendTag(dst, fieldAction, protowire.BytesType)
+	dst = protowire.AppendBytes(dst, action)
+
+	dst = appendString(dst, fieldCallbackURL, task.CallbackURL)
+
+	return dst
+}
+
+// decodeProtobuf decodes a Protobuf-encoded task preceded by its message indexes.
+// Unknown fields are skipped, so that fields added to the schema later don't break older workers.
+func decodeProtobuf(data []byte) (model.Task, error) {
+	data, err := skipMessageIndexes(data)
+	if err != nil {
+		return model.Task{}, err
+	}
+
+	var task model.Task
+	err = consumeFields(data, func(num protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+		switch {
+		case num == fieldID && typ == protowire.BytesType:
+			id, err := uuid.ParseBytes(value)
+			if err != nil {
+				return fmt.Errorf("invalid id: %w", err)
+			}
+			task.ID = id
+		case num == fieldAction && typ == protowire.BytesType:
+			action, err := decodeAction(value)
+			if err != nil {
+				return err
+			}
+			task.Action = action
+		case num == fieldCallbackURL && typ == protowire.BytesType:
+			task.CallbackURL = string(value)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return model.Task{}, err
+	}
+
+	return task, nil
+}
+
+// decodeAction decodes an ImageTask.Action message.
+func decodeAction(data []byte) (model.Action, error) {
+	action := model.Action{Params: map[string]string{}}
+
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+		switch {
+		case num == fieldActionName && typ == protowire.BytesType:
+			action.Name = string(value)
+		case num == fieldActionParams && typ == protowire.BytesType:
+			var key, val string
+			err := consumeFields(value, func(num protowire.Number, typ protowire.Type, v []byte, _ uint64) error {
+				if typ == protowire.BytesType && num == fieldEntryKey {
+					key = string(v)
+				} else if typ == protowire.BytesType && num == fieldEntryValue {
+					val = string(v)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			action.Params[key] = val
+		}
+
+		return nil
+	})
+
+	return action, err
+}
+
+// consumeFields calls fn with each field of the encoded message: length-delimited fields
+// get their bytes, varint fields their value. Fields of other types are skipped.
+func consumeFields(
+	data []byte,
+	fn func(num protowire.Number, typ protowire.Type, value []byte, n uint64) error,
+) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return fmt.Errorf("%w: %w", ErrInvalidMessage, protowire.ParseError(n))
+		}
+		data = data[n:]
+
+		var (
+			value  []byte
+			varint uint64
+		)
+		switch typ {
+		case protowire.BytesType:
+			value, n = protowire.ConsumeBytes(data)
+		case protowire.VarintType:
+			varint, n = protowire.ConsumeVarint(data)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return fmt.Errorf("%w: %w", ErrInvalidMessage, protowire.ParseError(n))
+		}
+		data = data[n:]
+
+		if typ != protowire.BytesType && typ != protowire.VarintType {
+			continue
+		}
+		if err := fn(num, typ, value, varint); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidMessage, err)
+		}
+	}
+
+	return nil
+}
+
+// skipMessageIndexes skips the message indexes preceding the payload in the Confluent wire format:
+// a zigzag varint count followed by as many zigzag varint indexes, or a single zero byte for [0].
+func skipMessageIndexes(data []byte) ([]byte, error) {
+	count, n := protowire.ConsumeVarint(data)
+	if n < 0 {
+		return nil, fmt.Errorf("%w: invalid message indexes", ErrInvalidMessage)
+	}
+	data = data[n:]
+
+	for i := int64(0); i < protowire.DecodeZigZag(count); i++ {
+		if _, n = protowire.ConsumeVarint(data); n < 0 {
+			return nil, fmt.Errorf("%w: invalid message indexes", ErrInvalidMessage)
+		}
+		data = data[n:]
+	}
+
+	return data, nil
+}
+
+// appendString appends a string field unless it is empty, as proto3 does.
+func appendString(dst []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return dst
+	}
+
+	dst = protowire.AppendTag(dst, num, protowire.BytesType)
+	return protowire.AppendString(dst, s)
+}
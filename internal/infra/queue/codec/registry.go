@@ -0,0 +1,109 @@
+package codec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Registry is a client of a Confluent-compatible schema registry.
+// Schema IDs are cached per subject, so each schema is registered once per process.
+type Registry struct {
+	url    string
+	client *http.Client
+
+	mu  sync.Mutex
+	ids map[string]int // schema IDs by subject
+}
+
+// NewRegistry creates a new Registry client for the registry at baseURL.
+func NewRegistry(baseURL string, timeout time.Duration) *Registry {
+	return &Registry{
+		url:    strings.TrimRight(baseURL, "/"),
+		client: &http.Client{Timeout: timeout},
+		ids:    make(map[string]int),
+	}
+}
+
+// Register registers the schema under the subject, unless it already is, and returns its ID.
+// The schema type is "AVRO" or "PROTOBUF".
+func (r *Registry) Register(ctx context.Context, subject, schemaType, schema string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if id, ok := r.ids[subject]; ok {
+		return id, nil
+	}
+
+	body, err := json.Marshal(map[string]string{"schema": schema, "schemaType": schemaType})
+	if err != nil {
+		return 0, fmt.Errorf("register schema: failed to marshal request: %w", err)
+	}
+
+	endpoint := r.url + "/subjects/" + url.PathEscape(subject) + "/versions"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("register schema: failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("register schema: failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&apiErr)
+
+		return 0, fmt.Errorf("register schema: registry returned %s: %s", resp.Status, apiErr.Message)
+	}
+
+	var res struct {
+		ID int `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return 0, fmt.Errorf("register schema: failed to decode response: %w", err)
+	}
+
+	r.ids[subject] = res.ID
+
+	return res.ID, nil
+}
+
+// Schema returns the schema with the given ID, e.g. the writer schema of a received message.
+func (r *Registry) Schema(id int) (string, error) {
+	endpoint := fmt.Sprintf("%s/schemas/ids/%d", r.url, id)
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", fmt.Errorf("get schema: failed to create request: %w", err)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("get schema: failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("get schema: registry returned %s for schema %d", resp.Status, id)
+	}
+
+	var res struct {
+		Schema string `json:"schema"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return "", fmt.Errorf("get schema: failed to decode response: %w", err)
+	}
+
+	return res.Schema, nil
+}
@@ -0,0 +1,36 @@
+package queue
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultDrainTimeout bounds finishing in-flight messages on shutdown when no timeout is configured.
+const DefaultDrainTimeout = 30 * time.Second
+
+// Drain returns the context in-flight messages are processed and acknowledged with, so that a
+// shutdown doesn't abandon a half-processed message: it keeps the values of ctx but is only
+// canceled timeout after ctx, or once cancel is called. A zero timeout uses DefaultDrainTimeout.
+func Drain(ctx context.Context, timeout time.Duration) (drainCtx context.Context, cancel context.CancelFunc) {
+	if timeout <= 0 {
+		timeout = DefaultDrainTimeout
+	}
+
+	drainCtx, cancelDrain := context.WithCancel(context.WithoutCancel(ctx))
+
+	stop := context.AfterFunc(ctx, func() {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			cancelDrain()
+		case <-drainCtx.Done():
+		}
+	})
+
+	return drainCtx, func() {
+		stop()
+		cancelDrain()
+	}
+}
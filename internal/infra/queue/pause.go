@@ -0,0 +1,86 @@
+// Package queue holds the parts of message consumption shared by the queue backends.
+package queue
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aliskhannn/image-processor/internal/metrics"
+	"github.com/aliskhannn/image-processor/internal/model"
+)
+
+// Pauser pauses and resumes all consumers sharing it. Paused consumers stop fetching
+// but finish the messages already in flight, so that the system can be drained.
+type Pauser struct {
+	mu       sync.Mutex
+	paused   bool
+	resumed  chan struct{} // closed on resume
+	inFlight atomic.Int64
+}
+
+// Begin marks a message as in flight until the returned function is called.
+// Messages in flight are also reported on the jobs in flight gauge.
+func (p *Pauser) Begin() (done func()) {
+	p.inFlight.Add(1)
+	finished := metrics.JobStarted()
+
+	return func() {
+		p.inFlight.Add(-1)
+		finished()
+	}
+}
+
+// NewPauser creates a new Pauser in the running state.
+func NewPauser() *Pauser {
+	return &Pauser{resumed: make(chan struct{})}
+}
+
+// Pause stops consumers from fetching new messages.
+func (p *Pauser) Pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.paused = true
+}
+
+// Resume lets paused consumers continue fetching messages.
+func (p *Pauser) Resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.paused {
+		return
+	}
+
+	p.paused = false
+	close(p.resumed)
+	p.resumed = make(chan struct{})
+}
+
+// State returns whether consumption is paused and the number of messages in flight.
+func (p *Pauser) State() model.ConsumerState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return model.ConsumerState{Paused: p.paused, InFlight: p.inFlight.Load()}
+}
+
+// Wait blocks while consumption is paused. It returns false if the context was canceled first.
+func (p *Pauser) Wait(ctx context.Context) bool {
+	for {
+		p.mu.Lock()
+		paused, resumed := p.paused, p.resumed
+		p.mu.Unlock()
+
+		if !paused {
+			return true
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-resumed:
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package queue
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/aliskhannn/image-processor/internal/model"
+)
+
+// decoder defines the interface for decoding tasks from message values.
+type decoder interface {
+	Decode(data []byte) (model.Task, error)
+}
+
+// Payload returns the message value as JSON, so that dead letters can be inspected and replayed
+// whatever the message format. Values that can't be decoded are kept base64-encoded.
+func Payload(d decoder, value []byte) string {
+	if json.Valid(value) {
+		return string(value)
+	}
+
+	if task, err := d.Decode(value); err == nil {
+		if data, err := json.Marshal(task); err == nil {
+			return string(data)
+		}
+	}
+
+	return base64.StdEncoding.EncodeToString(value)
+}
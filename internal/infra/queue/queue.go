@@ -0,0 +1,28 @@
+package queue
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aliskhannn/image-processor/internal/model"
+)
+
+//go:generate go run go.uber.org/mock/mockgen@v0.6.0 -destination=mocks/queue.go -package=mocks . Publisher,Worker
+
+// Publisher is implemented by the producers of all queue backends, enqueueing processing tasks.
+type Publisher interface {
+	// Produce enqueues the processing task of the image.
+	Produce(ctx context.Context, img model.Image) error
+	// Ping checks that the broker is reachable.
+	Ping(ctx context.Context) error
+	// Close releases the connection to the broker.
+	Close() error
+}
+
+// Worker is implemented by the consumers of all queue backends, processing tasks until ctx is canceled.
+type Worker interface {
+	// Consume processes tasks until ctx is canceled, then calls wg.Done.
+	Consume(ctx context.Context, wg *sync.WaitGroup)
+	// Close releases the connection to the broker.
+	Close() error
+}
@@ -0,0 +1,329 @@
+package consumer
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/wb-go/wbf/retry"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/image-processor/internal/config"
+	"github.com/aliskhannn/image-processor/internal/infra/queue"
+	"github.com/aliskhannn/image-processor/internal/infra/sqs/producer"
+	"github.com/aliskhannn/image-processor/internal/model"
+	"github.com/aliskhannn/image-processor/internal/reload"
+	"github.com/aliskhannn/image-processor/internal/trace"
+)
+
+// Defaults of the receive settings.
+const (
+	defaultVisibilityTimeout = 30 * time.Second
+	defaultWaitTime          = 20 * time.Second
+)
+
+// uploadedHandler defines the interface for handling uploaded image messages.
+type uploadedHandler interface {
+	Handle(ctx context.Context, msg model.QueueMessage) error
+}
+
+// deadLetterRecorder defines the interface for recording messages that failed processing after retries.
+type deadLetterRecorder interface {
+	RecordDeadLetter(ctx context.Context, dl model.DeadLetter) error
+}
+
+// decoder defines the interface for decoding tasks from message values.
+type decoder interface {
+	Decode(data []byte) (model.Task, error)
+}
+
+// Consumer consumes processing tasks from an SQS queue. The visibility timeout of a task is
+// extended while it is processed; failed tasks become visible again after the configured
+// retry delays and are then recorded as dead letters.
+type Consumer struct {
+	Client            *sqs.Client
+	uploadedHandler   uploadedHandler
+	deadLetters       deadLetterRecorder
+	decoder           decoder
+	pauser            *queue.Pauser
+	strategy          *reload.Value[retry.Strategy]
+	cfg               *config.SQS
+	visibilityTimeout time.Duration
+	waitTime          time.Duration
+}
+
+// New creates a new Consumer.
+// - cfg: SQS configuration struct
+// - s: retry strategy
+// - uh: handler for processing uploaded image messages
+// - dl: recorder of messages failing after all retries; nil leaves them in the queue
+// - d: decoder of the message format, used to record dead letters as JSON
+// - p: pauser shared by the consumers
+func New(
+	ctx context.Context,
+	cfg *config.SQS,
+	s *reload.Value[retry.Strategy],
+	uh uploadedHandler,
+	dl deadLetterRecorder,
+	d decoder,
+	p *queue.Pauser,
+) (*Consumer, error) {
+	client, err := producer.NewClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Consumer{
+		Client:            client,
+		uploadedHandler:   uh,
+		deadLetters:       dl,
+		decoder:           d,
+		pauser:            p,
+		strategy:          s,
+		cfg:               cfg,
+		visibilityTimeout: cfg.VisibilityTimeout,
+		waitTime:          cfg.WaitTime,
+	}
+	if c.visibilityTimeout <= 0 {
+		c.visibilityTimeout = defaultVisibilityTimeout
+	}
+	if c.waitTime <= 0 || c.waitTime > defaultWaitTime {
+		c.waitTime = defaultWaitTime
+	}
+
+	return c, nil
+}
+
+// Consume continuously receives tasks, processes them using the handler and deletes them
+// after successful processing. It stops receiving while paused. On context cancellation it stops
+// receiving and finishes and deletes the task in flight within the drain timeout; undeleted tasks
+// become visible again after their visibility timeout.
+func (c *Consumer) Consume(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	// The task in flight is processed and deleted with a context outliving ctx.
+	drainCtx, cancelDrain := queue.Drain(ctx, c.cfg.DrainTimeout)
+	defer cancelDrain()
+
+	zlog.Logger.Info().
+		Str("queue", c.cfg.QueueURL).
+		Msg("starting consumer")
+
+	for {
+		// Exit if context is canceled (graceful shutdown).
+		if ctx.Err() != nil {
+			zlog.Logger.Info().Str("queue", c.cfg.QueueURL).Msg("shutdown signal received, stopping consumer")
+			return
+		}
+
+		// Don't receive new messages while consumption is paused.
+		if !c.pauser.Wait(ctx) {
+			continue
+		}
+
+		out, err := c.Client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:                    aws.String(c.cfg.QueueURL),
+			MaxNumberOfMessages:         1,
+			WaitTimeSeconds:             int32(c.waitTime / time.Second),
+			VisibilityTimeout:           int32(c.visibilityTimeout / time.Second),
+			MessageAttributeNames:       []string{"All"},
+			MessageSystemAttributeNames: []types.MessageSystemAttributeName{types.MessageSystemAttributeNameApproximateReceiveCount},
+		})
+		if err != nil {
+			if ctx.Err() == nil {
+				zlog.Logger.Err(err).Msg("failed to receive message")
+				time.Sleep(500 * time.Millisecond)
+			}
+			continue
+		}
+
+		for _, msg := range out.Messages {
+			c.handle(ctx, drainCtx, msg)
+		}
+	}
+}
+
+// Close releases the consumer. The SQS client holds no connections that need closing.
+func (c *Consumer) Close() error {
+	return nil
+}
+
+// handle processes the message, extending its visibility timeout while the handler runs,
+// and deletes, delays or dead-letters it depending on the outcome. The message is processed and
+// deleted with drainCtx, so that it is finished on shutdown.
+func (c *Consumer) handle(ctx, drainCtx context.Context, msg types.Message) {
+	defer c.pauser.Begin()()
+
+	qm, err := c.queueMessage(msg)
+	if err != nil {
+		zlog.Logger.Err(err).Str("sqs_message_id", aws.ToString(msg.MessageId)).Msg("failed to read message")
+		return
+	}
+
+	msgCtx := trace.Extract(drainCtx, func(key string) string { return qm.Headers[key] })
+	tc := trace.FromContext(msgCtx)
+	receives, _ := strconv.Atoi(msg.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)])
+
+	stop := c.keepAlive(drainCtx, msg)
+	err = retry.Do(func() error {
+		return c.uploadedHandler.Handle(msgCtx, qm)
+	}, c.strategy.Load())
+	stop()
+
+	if err == nil {
+		if delErr := c.delete(drainCtx, msg); delErr != nil {
+			zlog.Logger.Err(delErr).Str("message_id", qm.ID).Msg("failed to delete message")
+			return
+		}
+
+		zlog.Logger.Info().
+			Str("queue", qm.Topic).
+			Str("request_id", tc.RequestID).
+			Str("trace_id", trace.TraceID(tc.TraceParent)).
+			Str("message_id", qm.ID).
+			Msg("message handled successfully")
+		return
+	}
+
+	zlog.Logger.Err(err).
+		Str("queue", qm.Topic).
+		Str("request_id", tc.RequestID).
+		Str("trace_id", trace.TraceID(tc.TraceParent)).
+		Int("receive", receives).
+		Msg("failed to process image")
+
+	// On shutdown the message becomes visible again after its visibility timeout.
+	if ctx.Err() != nil {
+		return
+	}
+
+	c.handleFailure(drainCtx, msg, qm, receives, err)
+}
+
+// handleFailure makes the message visible again after the retry delay of its receive or,
+// after the last one, records it as a dead letter and deletes it from the queue.
+func (c *Consumer) handleFailure(ctx context.Context, msg types.Message, qm model.QueueMessage, receives int, err error) {
+	if tier := receives - 1; tier >= 0 && tier < len(c.cfg.RetryDelays) {
+		if visErr := c.setVisibility(ctx, msg, c.cfg.RetryDelays[tier]); visErr != nil {
+			zlog.Logger.Err(visErr).Msg("failed to schedule message for retry")
+			return
+		}
+
+		zlog.Logger.Warn().
+			Str("message_id", qm.ID).
+			Dur("delay", c.cfg.RetryDelays[tier]).
+			Msg("message scheduled for retry")
+		return
+	}
+
+	if c.deadLetters == nil {
+		return
+	}
+
+	dl := model.DeadLetter{
+		Topic:    qm.Topic,
+		Payload:  queue.Payload(c.decoder, qm.Value),
+		Error:    err.Error(),
+		Attempts: c.strategy.Load().Attempts * receives,
+		FailedAt: time.Now(),
+	}
+	if dlErr := c.deadLetters.RecordDeadLetter(ctx, dl); dlErr != nil {
+		zlog.Logger.Err(dlErr).Str("message_id", qm.ID).Msg("failed to record dead letter")
+		return
+	}
+
+	if delErr := c.delete(ctx, msg); delErr != nil {
+		zlog.Logger.Err(delErr).Msg("failed to delete dead-lettered message")
+		return
+	}
+
+	zlog.Logger.Warn().Str("message_id", qm.ID).Msg("message moved to dead-letter queue")
+}
+
+// keepAlive extends the visibility timeout of the message every half timeout until the
+// returned function is called, so that long processing doesn't make it visible to other workers.
+func (c *Consumer) keepAlive(ctx context.Context, msg types.Message) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(c.visibilityTimeout / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := c.setVisibility(ctx, msg, c.visibilityTimeout); err != nil {
+					zlog.Logger.Err(err).Msg("failed to extend message visibility timeout")
+				}
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// setVisibility hides the message from other workers for the duration from now.
+func (c *Consumer) setVisibility(ctx context.Context, msg types.Message, d time.Duration) error {
+	_, err := c.Client.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(c.cfg.QueueURL),
+		ReceiptHandle:     msg.ReceiptHandle,
+		VisibilityTimeout: int32(d / time.Second),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to change message visibility: %w", err)
+	}
+
+	return nil
+}
+
+// delete removes the handled message from the queue.
+func (c *Consumer) delete(ctx context.Context, msg types.Message) error {
+	_, err := c.Client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(c.cfg.QueueURL),
+		ReceiptHandle: msg.ReceiptHandle,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete message: %w", err)
+	}
+
+	return nil
+}
+
+// queueMessage converts the SQS message for the handler, decoding base64 bodies.
+// Messages sent without a message ID attribute are identified by their SQS message ID.
+func (c *Consumer) queueMessage(msg types.Message) (model.QueueMessage, error) {
+	headers := make(map[string]string, len(msg.MessageAttributes))
+	for key, attr := range msg.MessageAttributes {
+		headers[key] = aws.ToString(attr.StringValue)
+	}
+
+	value := []byte(aws.ToString(msg.Body))
+	if headers[producer.ContentEncodingAttribute] == "base64" {
+		var err error
+		if value, err = base64.StdEncoding.DecodeString(aws.ToString(msg.Body)); err != nil {
+			return model.QueueMessage{}, fmt.Errorf("failed to decode message body: %w", err)
+		}
+	}
+
+	id := headers[model.MessageIDHeader]
+	if id == "" {
+		id = aws.ToString(msg.MessageId)
+	}
+
+	return model.QueueMessage{
+		ID:      id,
+		Topic:   path.Base(c.cfg.QueueURL),
+		Value:   value,
+		Headers: headers,
+	}, nil
+}
@@ -0,0 +1,119 @@
+package producer
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/retry"
+
+	"github.com/aliskhannn/image-processor/internal/config"
+	"github.com/aliskhannn/image-processor/internal/infra/queue/codec"
+	"github.com/aliskhannn/image-processor/internal/model"
+	"github.com/aliskhannn/image-processor/internal/reload"
+	"github.com/aliskhannn/image-processor/internal/trace"
+)
+
+// ContentEncodingAttribute marks message bodies that are base64-encoded. SQS bodies must be
+// text, so Avro and Protobuf tasks are sent base64-encoded.
+const ContentEncodingAttribute = "content-encoding"
+
+// Producer sends processing tasks to an SQS queue.
+type Producer struct {
+	Client   *sqs.Client
+	codec    *codec.Codec
+	strategy *reload.Value[retry.Strategy]
+	cfg      *config.SQS
+}
+
+// New creates a new Producer.
+// - cfg: SQS configuration struct
+// - s: retry strategy
+// - c: codec encoding the tasks in the configured message format
+func New(ctx context.Context, cfg *config.SQS, s *reload.Value[retry.Strategy], c *codec.Codec) (*Producer, error) {
+	client, err := NewClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Producer{
+		Client:   client,
+		codec:    c,
+		strategy: s,
+		cfg:      cfg,
+	}, nil
+}
+
+// NewClient creates an SQS client for the configured region and endpoint,
+// with credentials from the default AWS chain.
+func NewClient(ctx context.Context, cfg *config.SQS) (*sqs.Client, error) {
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(cfg.Region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load aws config: %w", err)
+	}
+
+	return sqs.NewFromConfig(awsCfg, func(o *sqs.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+	}), nil
+}
+
+// Produce serializes the Task in the configured format and sends it to the queue,
+// along with its message ID and the request ID and trace context of ctx as message attributes.
+func (p *Producer) Produce(ctx context.Context, img model.Image) error {
+	data, err := p.codec.Encode(ctx, p.cfg.QueueURL, model.NewTask(img))
+	if err != nil {
+		return fmt.Errorf("failed to marshal task: %v", err)
+	}
+
+	attrs := map[string]types.MessageAttributeValue{}
+	set := func(key, value string) {
+		attrs[key] = types.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(value)}
+	}
+	set(model.MessageIDHeader, uuid.NewString())
+	trace.Inject(ctx, set)
+
+	body := string(data)
+	if p.codec.Format() != codec.FormatJSON {
+		body = base64.StdEncoding.EncodeToString(data)
+		set(ContentEncodingAttribute, "base64")
+	}
+
+	err = retry.Do(func() error {
+		_, sendErr := p.Client.SendMessage(ctx, &sqs.SendMessageInput{
+			QueueUrl:          aws.String(p.cfg.QueueURL),
+			MessageBody:       aws.String(body),
+			MessageAttributes: attrs,
+		})
+		return sendErr
+	}, p.strategy.Load())
+	if err != nil {
+		return fmt.Errorf("failed to send task: %v", err)
+	}
+
+	return nil
+}
+
+// Ping checks that the queue is reachable.
+func (p *Producer) Ping(ctx context.Context) error {
+	_, err := p.Client.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       aws.String(p.cfg.QueueURL),
+		AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameApproximateNumberOfMessages},
+	})
+	if err != nil {
+		return fmt.Errorf("sqs queue is not reachable: %w", err)
+	}
+
+	return nil
+}
+
+// Close releases the producer. The SQS client holds no connections that need closing.
+func (p *Producer) Close() error {
+	return nil
+}
@@ -2,44 +2,105 @@ package image
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 
 	"github.com/google/uuid"
-	"github.com/segmentio/kafka-go"
-	"github.com/wb-go/wbf/zlog"
 
+	"github.com/aliskhannn/image-processor/internal/errreport"
+	"github.com/aliskhannn/image-processor/internal/logging"
 	"github.com/aliskhannn/image-processor/internal/model"
 	"github.com/aliskhannn/image-processor/internal/repository/image"
 )
 
 // service defines the interface for processing uploaded images.
 type service interface {
-	ProcessImage(ctx context.Context, img model.Image) (uuid.UUID, error)
+	ProcessImage(ctx context.Context, task model.Task) (uuid.UUID, error)
+	BeginMessageAttempt(ctx context.Context, messageID string) (model.MessageAttempt, error)
+	EndMessageAttempt(ctx context.Context, messageID string, procErr error) error
+	GiveUp(ctx context.Context, id uuid.UUID, messageID, reason string) error
 }
 
-// UploadedHandler handles Kafka messages for newly uploaded images.
+// decoder defines the interface for decoding tasks from message values.
+type decoder interface {
+	Decode(data []byte) (model.Task, error)
+}
+
+// UploadedHandler handles queue messages for newly uploaded images.
 // It relies on a service that implements image processing logic.
 type UploadedHandler struct {
-	service service
+	service     service
+	decoder     decoder
+	maxAttempts int // processing attempts per message before giving up on it; zero never gives up
+}
+
+// NewUploadedHandler creates a new handler with the given service and message decoder.
+// Messages attempted more than maxAttempts times are given up on; zero disables the cap.
+func NewUploadedHandler(s service, d decoder, maxAttempts int) *UploadedHandler {
+	return &UploadedHandler{service: s, decoder: d, maxAttempts: maxAttempts}
 }
 
-// NewUploadedHandler creates a new handler with the given service.
-func NewUploadedHandler(s service) *UploadedHandler {
-	return &UploadedHandler{service: s}
+// Handle processes a queue message containing the task of an uploaded image.
+// It decodes the task, calls the service to process the image,
+// and logs the result. Failures are returned to be retried; images are only marked failed, and
+// failures reported, once retries are over: when the last attempt allowed by the attempt cap
+// fails, when the message is dead-lettered by the consumer, or when it is given up on here.
+// Attempts are counted per message: once a message exceeds the attempt cap, e.g. because its
+// payload is corrupt or crashes the worker, its image is marked failed with the last error and
+// the message is acknowledged, so that it stops being redelivered.
+func (h *UploadedHandler) Handle(ctx context.Context, msg model.QueueMessage) error {
+	attempt, err := h.service.BeginMessageAttempt(ctx, msg.ID)
+	if err != nil {
+		return fmt.Errorf("handle message: %w", err)
+	}
+
+	task, decodeErr := h.decoder.Decode(msg.Value)
+	ctx = logging.With(ctx, logging.Fields{ImageID: task.ID, Action: task.Action.Name})
+
+	if h.maxAttempts > 0 && attempt.Attempts > h.maxAttempts {
+		return h.giveUp(ctx, msg, task, attempt)
+	}
+
+	task.LastAttempt = h.maxAttempts > 0 && attempt.Attempts >= h.maxAttempts
+	err = h.handle(ctx, msg, task, decodeErr)
+
+	if endErr := h.service.EndMessageAttempt(ctx, msg.ID, err); endErr != nil {
+		logging.FromContext(ctx).Err(endErr).Str("message_id", msg.ID).Msg("failed to record message attempt")
+	}
+
+	return err
+}
+
+// giveUp marks the image of a message that exceeded the attempt cap failed, if the message
+// could be decoded, and reports the message as handled.
+func (h *UploadedHandler) giveUp(ctx context.Context, msg model.QueueMessage, task model.Task, attempt model.MessageAttempt) error {
+	reason := fmt.Sprintf("gave up after %d attempts", attempt.Attempts-1)
+	if attempt.LastError != "" {
+		reason += ": " + attempt.LastError
+	}
+
+	if err := h.service.GiveUp(ctx, task.ID, msg.ID, reason); err != nil {
+		return fmt.Errorf("give up message: %w", err)
+	}
+	errreport.Error(ctx, fmt.Errorf("give up message %s: %s", msg.ID, reason))
+
+	logging.FromContext(ctx).Warn().
+		Str("message_id", msg.ID).
+		Int("attempts", attempt.Attempts-1).
+		Str("error", attempt.LastError).
+		Msg("message exceeded the attempt cap, giving up")
+
+	return nil
 }
 
-// Handle processes a Kafka message containing an uploaded image.
-// It unmarshals the message, calls the service to process the image,
-// and logs the result.
-func (h *UploadedHandler) Handle(ctx context.Context, msg kafka.Message) error {
-	var img model.Image
-	if err := json.Unmarshal(msg.Value, &img); err != nil {
-		return fmt.Errorf("unmarshal task: %w", err)
+// handle processes the decoded task of the message.
+func (h *UploadedHandler) handle(ctx context.Context, msg model.QueueMessage, task model.Task, decodeErr error) error {
+	if decodeErr != nil {
+		return fmt.Errorf("unmarshal task: %w", decodeErr)
 	}
+	task.MessageID = msg.ID
 
-	id, err := h.service.ProcessImage(ctx, img)
+	id, err := h.service.ProcessImage(ctx, task)
 	if err != nil {
 		if errors.Is(err, image.ErrImageNotFound) {
 			return fmt.Errorf("process task: %w", image.ErrImageNotFound)
@@ -48,7 +109,10 @@ func (h *UploadedHandler) Handle(ctx context.Context, msg kafka.Message) error {
 		return fmt.Errorf("process task: %w", err)
 	}
 
-	zlog.Logger.Printf("image processed: %s", id)
+	logging.FromContext(ctx).Info().
+		Str("message_id", msg.ID).
+		Str("derived_id", id.String()).
+		Msg("image processed")
 
 	return nil
 }
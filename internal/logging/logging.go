@@ -0,0 +1,81 @@
+// Package logging derives loggers from contexts, so that the log lines of a request or a job
+// carry its request ID, trace ID and the image, action and tenant it is about.
+package logging
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/image-processor/internal/trace"
+)
+
+// Fields are the job fields attached to the loggers of a context. Zero fields are left out.
+type Fields struct {
+	ImageID uuid.UUID
+	Action  string
+	Tenant  string // owner of the image
+}
+
+// fieldsKey is the key the fields are stored under in a context.Context.
+type fieldsKey struct{}
+
+// With returns a copy of ctx carrying the given fields on top of the ones it already carries;
+// non-zero fields replace the carried ones.
+func With(ctx context.Context, f Fields) context.Context {
+	cur, _ := ctx.Value(fieldsKey{}).(Fields)
+
+	if f.ImageID != uuid.Nil {
+		cur.ImageID = f.ImageID
+	}
+	if f.Action != "" {
+		cur.Action = f.Action
+	}
+	if f.Tenant != "" {
+		cur.Tenant = f.Tenant
+	}
+
+	return context.WithValue(ctx, fieldsKey{}, cur)
+}
+
+// FieldsFromContext returns the fields carried by ctx, if any.
+func FieldsFromContext(ctx context.Context) Fields {
+	f, _ := ctx.Value(fieldsKey{}).(Fields)
+	return f
+}
+
+// FromContext returns the global logger with the request and trace IDs of the trace context
+// of ctx and the fields it carries, if any.
+func FromContext(ctx context.Context) *zerolog.Logger {
+	lc := zlog.Logger.With()
+
+	tc := trace.FromContext(ctx)
+	if tc.RequestID != "" {
+		lc = lc.Str("request_id", tc.RequestID)
+	}
+	if id := trace.TraceID(tc.TraceParent); id != "" {
+		lc = lc.Str("trace_id", id)
+	}
+
+	f := FieldsFromContext(ctx)
+	if f.ImageID != uuid.Nil {
+		lc = lc.Str("image_id", f.ImageID.String())
+	}
+	if f.Action != "" {
+		lc = lc.Str("action", f.Action)
+	}
+	if f.Tenant != "" {
+		lc = lc.Str("tenant", f.Tenant)
+	}
+
+	l := lc.Logger()
+	return &l
+}
+
+// ForImage returns the logger of ctx about the image with the given ID, e.g. a derived image
+// of the job of ctx.
+func ForImage(ctx context.Context, id uuid.UUID) *zerolog.Logger {
+	return FromContext(With(ctx, Fields{ImageID: id}))
+}
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// sizeBuckets are the buckets of request and response sizes in bytes, from 100B to 100MB.
+var sizeBuckets = prometheus.ExponentialBuckets(100, 10, 7)
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests served, by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Time taken to serve HTTP requests, by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	httpRequestSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_size_bytes",
+		Help:      "Size of the bodies of HTTP requests read, e.g. uploads, by method and route.",
+		Buckets:   sizeBuckets,
+	}, []string{"method", "route"})
+
+	httpResponseSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "response_size_bytes",
+		Help:      "Size of the bodies of HTTP responses written, by method and route.",
+		Buckets:   sizeBuckets,
+	}, []string{"method", "route"})
+)
+
+func init() {
+	prometheus.MustRegister(httpRequests, httpDuration, httpRequestSize, httpResponseSize)
+}
+
+// ObserveRequest records an HTTP request served by the route, the path template it matched.
+func ObserveRequest(method, route string, status int, bytesIn, bytesOut int64, d time.Duration) {
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	httpDuration.WithLabelValues(method, route).Observe(d.Seconds())
+	httpRequestSize.WithLabelValues(method, route).Observe(float64(bytesIn))
+	httpResponseSize.WithLabelValues(method, route).Observe(float64(bytesOut))
+}
@@ -0,0 +1,241 @@
+package metrics
+
+import (
+	"maps"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/aliskhannn/image-processor/internal/config"
+	"github.com/aliskhannn/image-processor/internal/model"
+)
+
+// Defaults of the latency tracking.
+const (
+	defaultSLOWindow        = time.Hour
+	defaultSLOSamples       = 10000
+	defaultSLOThreshold     = 10 * time.Second
+	defaultSLOTarget        = 0.99
+	defaultWarnBurnRate     = 2
+	defaultCriticalBurnRate = 10
+	minimumSLOBudget        = 1e-6 // error budget of targets of 1, so that burn rates stay finite
+)
+
+// latencyQuantiles are the quantiles of the processing time reported per action.
+var latencyQuantiles = []float64{0.5, 0.95, 0.99}
+
+// latencySample is the processing time of a job finished at a moment.
+type latencySample struct {
+	at time.Time
+	d  time.Duration
+}
+
+// LatencyTracker tracks the processing time of the jobs of each action over a rolling window,
+// in memory, and reports their percentiles and the burn rate of their latency objective.
+// It is a prometheus.Collector exporting them as gauges.
+type LatencyTracker struct {
+	cfg config.SLO
+
+	mu      sync.Mutex
+	samples map[string][]latencySample // by action, oldest first
+
+	latency  *prometheus.Desc
+	burnRate *prometheus.Desc
+}
+
+// NewLatencyTracker creates a tracker of the latency objectives of the configuration.
+func NewLatencyTracker(cfg config.SLO) *LatencyTracker {
+	if cfg.Window <= 0 {
+		cfg.Window = defaultSLOWindow
+	}
+	if cfg.MaxSamples <= 0 {
+		cfg.MaxSamples = defaultSLOSamples
+	}
+	if cfg.WarnBurnRate <= 0 {
+		cfg.WarnBurnRate = defaultWarnBurnRate
+	}
+	if cfg.CriticalBurnRate <= 0 {
+		cfg.CriticalBurnRate = defaultCriticalBurnRate
+	}
+
+	return &LatencyTracker{
+		cfg:     cfg,
+		samples: make(map[string][]latencySample),
+		latency: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "slo", "latency_seconds"),
+			"Processing time of the jobs of the action finished within the SLO window, by quantile.",
+			[]string{"action", "quantile"}, nil,
+		),
+		burnRate: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "slo", "burn_rate"),
+			"Share of the jobs of the action over the latency threshold within the SLO window, relative to the error budget.",
+			[]string{"action"}, nil,
+		),
+	}
+}
+
+// ObserveLatency records the processing time of a job of the action finished now.
+func (t *LatencyTracker) ObserveLatency(action string, d time.Duration) {
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := append(t.samples[action], latencySample{at: now, d: d})
+	if len(s) > t.cfg.MaxSamples {
+		s = s[len(s)-t.cfg.MaxSamples:]
+	}
+	t.samples[action] = s
+}
+
+// LatencyReport returns the percentiles and objective state of each action with jobs tracked
+// within the window or an objective of its own, sorted by action. Actions in the warning or critical state are also
+// listed as alerts, the most burning first.
+func (t *LatencyTracker) LatencyReport() model.SLOReport {
+	now := time.Now()
+	report := model.SLOReport{
+		Window:      t.cfg.Window.String(),
+		GeneratedAt: now,
+		Actions:     []model.LatencySLO{},
+		Alerts:      []model.LatencySLO{},
+	}
+
+	windows := t.windows(now)
+	for _, action := range slices.Sorted(maps.Keys(windows)) {
+		slo := t.evaluate(action, windows[action])
+		report.Actions = append(report.Actions, slo)
+
+		if slo.State == model.SLOStateWarning || slo.State == model.SLOStateCritical {
+			report.Alerts = append(report.Alerts, slo)
+		}
+	}
+
+	sort.SliceStable(report.Alerts, func(i, j int) bool {
+		return report.Alerts[i].BurnRate > report.Alerts[j].BurnRate
+	})
+
+	return report
+}
+
+// Describe implements prometheus.Collector.
+func (t *LatencyTracker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- t.latency
+	ch <- t.burnRate
+}
+
+// Collect implements prometheus.Collector. Actions without jobs within the window are left out.
+func (t *LatencyTracker) Collect(ch chan<- prometheus.Metric) {
+	for _, slo := range t.LatencyReport().Actions {
+		if slo.State == model.SLOStateNoData {
+			continue
+		}
+
+		for i, ms := range []float64{slo.P50Ms, slo.P95Ms, slo.P99Ms} {
+			ch <- prometheus.MustNewConstMetric(t.latency, prometheus.GaugeValue, ms/1000,
+				slo.Action, strconv.FormatFloat(latencyQuantiles[i], 'f', -1, 64))
+		}
+		ch <- prometheus.MustNewConstMetric(t.burnRate, prometheus.GaugeValue, slo.BurnRate, slo.Action)
+	}
+}
+
+// windows drops the samples older than the window and returns the sorted processing times of the
+// actions with samples left, and of the actions with an objective of their own.
+func (t *LatencyTracker) windows(now time.Time) map[string][]time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	since := now.Add(-t.cfg.Window)
+
+	windows := make(map[string][]time.Duration, len(t.samples)+len(t.cfg.Actions))
+	for action := range t.cfg.Actions {
+		windows[action] = nil
+	}
+
+	for action, s := range t.samples {
+		i, _ := slices.BinarySearchFunc(s, since, func(smp latencySample, at time.Time) int {
+			return smp.at.Compare(at)
+		})
+		if i == len(s) {
+			delete(t.samples, action)
+			continue
+		}
+		t.samples[action] = s[i:]
+
+		durations := make([]time.Duration, 0, len(s)-i)
+		for _, smp := range s[i:] {
+			durations = append(durations, smp.d)
+		}
+		slices.Sort(durations)
+		windows[action] = durations
+	}
+
+	return windows
+}
+
+// evaluate describes the processing times of the action, sorted, against its latency objective.
+func (t *LatencyTracker) evaluate(action string, durations []time.Duration) model.LatencySLO {
+	obj := t.objective(action)
+
+	slo := model.LatencySLO{
+		Action:      action,
+		Count:       len(durations),
+		ThresholdMs: float64(obj.Threshold.Milliseconds()),
+		Target:      obj.Target,
+		State:       model.SLOStateNoData,
+	}
+	if len(durations) == 0 {
+		return slo
+	}
+
+	slo.P50Ms = percentileMs(durations, latencyQuantiles[0])
+	slo.P95Ms = percentileMs(durations, latencyQuantiles[1])
+	slo.P99Ms = percentileMs(durations, latencyQuantiles[2])
+
+	// Durations are sorted, so the slow jobs are the ones after the last within the threshold.
+	within, _ := slices.BinarySearch(durations, obj.Threshold+1)
+	slo.Slow = len(durations) - within
+
+	budget := math.Max(1-obj.Target, minimumSLOBudget)
+	slo.BurnRate = float64(slo.Slow) / float64(slo.Count) / budget
+
+	switch {
+	case slo.BurnRate >= t.cfg.CriticalBurnRate:
+		slo.State = model.SLOStateCritical
+	case slo.BurnRate >= t.cfg.WarnBurnRate:
+		slo.State = model.SLOStateWarning
+	default:
+		slo.State = model.SLOStateOK
+	}
+
+	return slo
+}
+
+// objective returns the latency objective of the action, the default one if it has none.
+func (t *LatencyTracker) objective(action string) config.LatencyObjective {
+	obj, ok := t.cfg.Actions[action]
+	if !ok {
+		obj = t.cfg.Default
+	}
+
+	if obj.Threshold <= 0 {
+		obj.Threshold = defaultSLOThreshold
+	}
+	if obj.Target <= 0 || obj.Target > 1 {
+		obj.Target = defaultSLOTarget
+	}
+
+	return obj
+}
+
+// percentileMs returns the q quantile of the sorted durations in milliseconds, by nearest rank.
+func percentileMs(sorted []time.Duration, q float64) float64 {
+	rank := int(math.Ceil(q*float64(len(sorted)))) - 1
+	rank = max(0, min(rank, len(sorted)-1))
+
+	return float64(sorted[rank].Microseconds()) / 1000
+}
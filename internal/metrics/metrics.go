@@ -0,0 +1,18 @@
+// Package metrics exposes the Prometheus metrics of the service.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/wb-go/wbf/ginext"
+)
+
+// namespace prefixes the names of all metrics of the service.
+const namespace = "image_processor"
+
+// handler serves the metrics of the default registry.
+var handler = promhttp.Handler()
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler(c *ginext.Context) {
+	handler.ServeHTTP(c.Writer, c.Request)
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Phases of processing a job, timed per action.
+const (
+	PhaseDecode  = "decode"  // loading the original from storage and decoding it
+	PhaseProcess = "process" // applying the action
+	PhaseEncode  = "encode"  // encoding the result, streamed to storage while saving
+	PhaseSave    = "save"    // saving the result to storage, encoding included
+)
+
+var (
+	phaseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "processing",
+		Name:      "phase_duration_seconds",
+		Help:      "Time spent in each phase of processing a job, per action.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"action", "phase"})
+
+	jobsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "queue",
+		Name:      "jobs_in_flight",
+		Help:      "Jobs being processed by the consumers of this instance.",
+	})
+
+	fetchBacklog = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "queue",
+		Name:      "fetch_backlog",
+		Help:      "Messages of the topic waiting to be fetched by the consumers, as of their last fetch.",
+	}, []string{"topic"})
+)
+
+func init() {
+	prometheus.MustRegister(phaseDuration, jobsInFlight, fetchBacklog)
+}
+
+// ObservePhase records the time spent in the phase of processing a job of the action since start.
+func ObservePhase(action, phase string, start time.Time) {
+	phaseDuration.WithLabelValues(action, phase).Observe(time.Since(start).Seconds())
+}
+
+// JobStarted counts a job as in flight until the returned function is called.
+func JobStarted() (done func()) {
+	jobsInFlight.Inc()
+	return jobsInFlight.Dec
+}
+
+// SetFetchBacklog records the number of messages of the topic waiting to be fetched.
+func SetFetchBacklog(topic string, n int64) {
+	fetchBacklog.WithLabelValues(topic).Set(float64(n))
+}
@@ -0,0 +1,40 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Kinds of divergence between the primary and the replica storage.
+const (
+	DivergenceMissing      = "missing"       // in the primary storage only
+	DivergenceExtra        = "extra"         // in the replica only
+	DivergenceSizeMismatch = "size_mismatch" // in both with different sizes
+)
+
+var (
+	replicaDivergence = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "replica_divergent_objects",
+		Help:      "Objects differing between the primary and the replica storage at the last reconciliation.",
+	}, []string{"kind"})
+
+	replicationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "replication_failures_total",
+		Help:      "Writes and deletes that failed to be replicated or were dropped with a full queue.",
+	}, []string{"op"})
+)
+
+func init() {
+	prometheus.MustRegister(replicaDivergence, replicationFailures)
+}
+
+// SetReplicaDivergence records the number of divergent objects of the kind found by the last reconciliation.
+func SetReplicaDivergence(kind string, n int) {
+	replicaDivergence.WithLabelValues(kind).Set(float64(n))
+}
+
+// ReplicationFailed counts a replication of the operation ("save", "delete") that failed or was dropped.
+func ReplicationFailed(op string) {
+	replicationFailures.WithLabelValues(op).Inc()
+}
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/image-processor/internal/model"
+)
+
+// usageTimeout bounds the usage query of a single scrape.
+const usageTimeout = 5 * time.Second
+
+// usageSource defines the interface for loading the storage usage accounted in the database.
+type usageSource interface {
+	StorageUsage(ctx context.Context, f model.UsageFilter) (model.UsageReport, error)
+}
+
+// UsageCollector reports the storage usage per owner and top-level storage directory as gauges,
+// loaded from the database on every scrape, since bucket metrics don't map to owners.
+type UsageCollector struct {
+	source  usageSource
+	bytes   *prometheus.Desc
+	objects *prometheus.Desc
+	errors  prometheus.Counter
+}
+
+// NewUsageCollector creates a collector of the storage usage reported by the source.
+func NewUsageCollector(src usageSource) *UsageCollector {
+	labels := []string{"owner", "subdir"}
+
+	return &UsageCollector{
+		source: src,
+		bytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "storage", "bytes"),
+			"Bytes stored per owner and top-level storage directory.",
+			labels, nil,
+		),
+		objects: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "storage", "objects"),
+			"Objects stored per owner and top-level storage directory.",
+			labels, nil,
+		),
+		errors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "storage",
+			Name:      "usage_errors_total",
+			Help:      "Scrapes that failed to load the storage usage.",
+		}),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *UsageCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.bytes
+	ch <- c.objects
+	c.errors.Describe(ch)
+}
+
+// Collect implements prometheus.Collector. Failures to load the usage are logged and counted,
+// and the gauges are left out of the scrape.
+func (c *UsageCollector) Collect(ch chan<- prometheus.Metric) {
+	defer c.errors.Collect(ch)
+
+	ctx, cancel := context.WithTimeout(context.Background(), usageTimeout)
+	defer cancel()
+
+	report, err := c.source.StorageUsage(ctx, model.UsageFilter{})
+	if err != nil {
+		zlog.Logger.Err(err).Msg("failed to collect storage usage")
+		c.errors.Inc()
+		return
+	}
+
+	for _, u := range report.Usage {
+		ch <- prometheus.MustNewConstMetric(c.bytes, prometheus.GaugeValue, float64(u.Bytes), u.Owner, u.Subdir)
+		ch <- prometheus.MustNewConstMetric(c.objects, prometheus.GaugeValue, float64(u.Objects), u.Owner, u.Subdir)
+	}
+}
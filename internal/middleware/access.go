@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/wb-go/wbf/ginext"
+
+	"github.com/aliskhannn/image-processor/internal/audit"
+	"github.com/aliskhannn/image-processor/internal/logging"
+	"github.com/aliskhannn/image-processor/internal/metrics"
+)
+
+// unmatchedRoute is the route label of requests matching no route, so that arbitrary paths
+// don't grow the number of series.
+const unmatchedRoute = "unmatched"
+
+// AccessLogMiddleware returns a Gin middleware that logs every request with its method, path,
+// status, bytes read and written, duration and the caller it was made by (the X-User-ID user or
+// the admin), along with its request and trace IDs, and records it in the HTTP metrics.
+//
+// Bytes in are counted as the handler reads the body, so they are also known for chunked uploads.
+// Requests failing with a 5xx status are logged as errors, with a 4xx status as warnings.
+func AccessLogMiddleware() ginext.HandlerFunc {
+	return func(c *ginext.Context) {
+		start := time.Now()
+
+		body := &countingBody{ReadCloser: c.Request.Body}
+		if c.Request.Body != nil && c.Request.Body != http.NoBody {
+			c.Request.Body = body
+		}
+
+		c.Next()
+
+		d := time.Since(start)
+		status := c.Writer.Status()
+		bytesOut := int64(max(c.Writer.Size(), 0))
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		metrics.ObserveRequest(c.Request.Method, route, status, body.n, bytesOut, d)
+
+		// The request of the context carries what the handlers attached to it, e.g. the admin actor.
+		ctx := c.Request.Context()
+		l := logging.FromContext(ctx)
+
+		var e *zerolog.Event
+		switch {
+		case status >= http.StatusInternalServerError:
+			e = l.Error()
+		case status >= http.StatusBadRequest:
+			e = l.Warn()
+		default:
+			e = l.Info()
+		}
+
+		if len(c.Errors) > 0 {
+			e = e.Str("errors", c.Errors.String())
+		}
+
+		e.Str("method", c.Request.Method).
+			Str("path", c.Request.URL.Path).
+			Str("route", route).
+			Int("status", status).
+			Int64("bytes_in", body.n).
+			Int64("bytes_out", bytesOut).
+			Dur("duration", d).
+			Str("actor", audit.Actor(ctx)).
+			Str("client_ip", c.ClientIP()).
+			Msg("request served")
+	}
+}
+
+// countingBody counts the bytes read from a request body.
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+// Read implements io.Reader.
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
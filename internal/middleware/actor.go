@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/wb-go/wbf/ginext"
+
+	"github.com/aliskhannn/image-processor/internal/audit"
+	"github.com/aliskhannn/image-processor/internal/model"
+)
+
+// UserHeader identifies the user making the request.
+const UserHeader = "X-User-ID"
+
+// ActorMiddleware returns a Gin middleware that attaches the user making the request,
+// taken from the X-User-ID header or the default owner, to the request context
+// as the actor of the audited events the request causes.
+func ActorMiddleware() ginext.HandlerFunc {
+	return func(c *ginext.Context) {
+		actor := strings.TrimSpace(c.GetHeader(UserHeader))
+		if actor == "" {
+			actor = model.DefaultOwner
+		}
+
+		c.Request = c.Request.WithContext(audit.WithActor(c.Request.Context(), actor))
+
+		c.Next()
+	}
+}
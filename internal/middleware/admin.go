@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/wb-go/wbf/ginext"
+
+	"github.com/aliskhannn/image-processor/internal/api/respond"
+	"github.com/aliskhannn/image-processor/internal/audit"
+)
+
+// AdminAuthMiddleware returns a Gin middleware that only lets through requests carrying
+// the admin token as "Authorization: Bearer <token>".
+//
+// An empty token disables the protected routes entirely. Authorized requests act as audit.ActorAdmin.
+func AdminAuthMiddleware(token string) ginext.HandlerFunc {
+	return func(c *ginext.Context) {
+		if token == "" {
+			respond.Fail(c, http.StatusForbidden, fmt.Errorf("admin api is disabled"))
+			c.Abort()
+			return
+		}
+
+		got, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			respond.Fail(c, http.StatusUnauthorized, fmt.Errorf("invalid admin token"))
+			c.Abort()
+			return
+		}
+
+		c.Request = c.Request.WithContext(audit.WithActor(c.Request.Context(), audit.ActorAdmin))
+
+		c.Next()
+	}
+}
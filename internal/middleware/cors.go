@@ -2,22 +2,76 @@ package middleware
 
 import (
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/wb-go/wbf/ginext"
+
+	"github.com/aliskhannn/image-processor/internal/config"
 )
 
-// CORSMiddleware returns a Gin middleware that handles Cross-Origin Resource Sharing (CORS) settings.
+var (
+	// defaultCORSMethods are allowed when the policy doesn't list any methods.
+	defaultCORSMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	// defaultCORSHeaders are allowed when the policy doesn't list any headers.
+	defaultCORSHeaders = []string{
+		"Content-Type", "Authorization", "X-User-ID", "X-Request-ID", "traceparent", "tracestate",
+	}
+)
+
+// CORSMiddleware returns a Gin middleware that handles Cross-Origin Resource Sharing (CORS)
+// according to the configured policy.
 //
-// It sets the necessary headers to allow requests from the frontend (http://localhost:3000)
-// and supports credentials, custom headers, and standard HTTP methods.
-func CORSMiddleware() ginext.HandlerFunc {
+// Only origins listed in the policy get CORS headers; "*" allows any origin, without credentials:
+// the wildcard is sent as is and never combined with Access-Control-Allow-Credentials, which the
+// config validation rejects. With no origins configured, cross-origin requests are not allowed at
+// all, which is the production default. Preflight requests from disallowed origins are rejected with 403.
+func CORSMiddleware(cfg config.CORS) ginext.HandlerFunc {
+	methods := strings.Join(valuesOr(cfg.AllowedMethods, defaultCORSMethods), ", ")
+	headers := strings.Join(valuesOr(cfg.AllowedHeaders, defaultCORSHeaders), ", ")
+	anyOrigin := slices.Contains(cfg.AllowedOrigins, "*")
+
 	return func(c *ginext.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		c.Writer.Header().Add("Vary", "Origin")
+
+		allowed := anyOrigin || slices.Contains(cfg.AllowedOrigins, origin)
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		if !allowed {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+
+			c.Next()
+			return
+		}
+
+		// Echoing any origin with credentials would let every site make credentialed requests,
+		// so the wildcard never comes with them.
+		if anyOrigin {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			if cfg.AllowCredentials {
+				c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+		}
+
+		if preflight {
+			c.Writer.Header().Set("Access-Control-Allow-Methods", methods)
+			c.Writer.Header().Set("Access-Control-Allow-Headers", headers)
+			if cfg.MaxAge > 0 {
+				c.Writer.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge.Seconds())))
+			}
 
-		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusNoContent)
 			return
 		}
@@ -25,3 +79,12 @@ func CORSMiddleware() ginext.HandlerFunc {
 		c.Next()
 	}
 }
+
+// valuesOr returns values, or def if values is empty.
+func valuesOr(values, def []string) []string {
+	if len(values) == 0 {
+		return def
+	}
+
+	return values
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"github.com/wb-go/wbf/ginext"
+
+	"github.com/aliskhannn/image-processor/internal/errreport"
+)
+
+// ReportPanicMiddleware returns a Gin middleware that reports panics of the handlers to the error
+// reporting service, with the request and trace IDs of the request, and panics again so that the
+// recovery middleware registered before it logs the panic and responds with 500 Internal Server Error.
+func ReportPanicMiddleware() ginext.HandlerFunc {
+	return func(c *ginext.Context) {
+		defer func() {
+			if v := recover(); v != nil {
+				errreport.Panic(c.Request.Context(), v)
+				panic(v)
+			}
+		}()
+
+		c.Next()
+	}
+}
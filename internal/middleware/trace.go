@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"github.com/wb-go/wbf/ginext"
+
+	"github.com/aliskhannn/image-processor/internal/trace"
+)
+
+// TraceMiddleware returns a Gin middleware that attaches the request ID and trace context
+// to the request context, so that they are propagated to the jobs the request enqueues.
+//
+// The incoming X-Request-ID and traceparent headers are continued when present; otherwise
+// a new request ID and trace are started. The request ID is echoed in the response.
+func TraceMiddleware() ginext.HandlerFunc {
+	return func(c *ginext.Context) {
+		ctx := trace.Extract(c.Request.Context(), c.GetHeader)
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Header(trace.RequestIDHeader, trace.FromContext(ctx).RequestID)
+
+		c.Next()
+	}
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+
+	"github.com/wb-go/wbf/ginext"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/image-processor/internal/config"
+)
+
+// UserMiddleware returns a Gin middleware that removes the X-User-ID header of requests not made
+// by one of the trusted proxies, so that only the authenticating proxy in front of the API names
+// the user requests are accounted and audited to; the others count against the default owner.
+//
+// Proxies are matched against the peer address of the connection, never against forwarded
+// headers. With no trusted proxies the header is always removed. It returns an error if a trusted
+// proxy is neither an IP address nor a CIDR network.
+func UserMiddleware(cfg config.Users) (ginext.HandlerFunc, error) {
+	proxies, err := cfg.Proxies()
+	if err != nil {
+		return nil, fmt.Errorf("user middleware: %w", err)
+	}
+
+	return func(c *ginext.Context) {
+		if c.GetHeader(UserHeader) == "" || trustedPeer(c.Request.RemoteAddr, proxies) {
+			c.Next()
+			return
+		}
+
+		zlog.Logger.Debug().
+			Str("remote_addr", c.Request.RemoteAddr).
+			Msg("removed X-User-ID header of an untrusted peer")
+		c.Request.Header.Del(UserHeader)
+
+		c.Next()
+	}, nil
+}
+
+// trustedPeer reports whether the peer address remoteAddr ("ip:port") is in one of the networks of proxies.
+func trustedPeer(remoteAddr string, proxies []netip.Prefix) bool {
+	addrPort, err := netip.ParseAddrPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	addr := addrPort.Addr().Unmap()
+
+	return slices.ContainsFunc(proxies, func(p netip.Prefix) bool { return p.Contains(addr) })
+}
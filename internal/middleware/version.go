@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/wb-go/wbf/ginext"
+)
+
+// versionHeader reports the API version that served a request.
+const versionHeader = "API-Version"
+
+// APIVersionMiddleware returns a Gin middleware that reports the API version serving
+// the request in the API-Version response header.
+func APIVersionMiddleware(version string) ginext.HandlerFunc {
+	return func(c *ginext.Context) {
+		c.Header(versionHeader, version)
+		c.Next()
+	}
+}
+
+// DeprecatedAliasMiddleware returns a Gin middleware for routes kept under the old prefix
+// for existing integrations. Responses are marked deprecated (RFC 9745) and link to the
+// same route under the successor prefix.
+func DeprecatedAliasMiddleware(prefix, successor string) ginext.HandlerFunc {
+	return func(c *ginext.Context) {
+		path := successor + strings.TrimPrefix(c.Request.URL.Path, prefix)
+
+		c.Header("Deprecation", "true")
+		c.Header("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", path))
+		c.Next()
+	}
+}
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Audited image lifecycle events.
+const (
+	AuditUpload    = "upload"    // an image was uploaded
+	AuditProcess   = "process"   // the action of an image was processed
+	AuditDownload  = "download"  // an image or its bundle was downloaded
+	AuditDelete    = "delete"    // an image was deleted
+	AuditReprocess = "reprocess" // an image was enqueued for processing again
+)
+
+// AuditEvent is an entry of the append-only audit log of image lifecycle events.
+type AuditEvent struct {
+	ID        int64     `json:"id"`
+	Event     string    `json:"event"` // one of the Audit* constants
+	ImageID   uuid.UUID `json:"image_id"`
+	Actor     string    `json:"actor"`                // user, admin or system acting on the image
+	RequestID string    `json:"request_id,omitempty"` // correlation ID of the request that caused the event
+	Details   string    `json:"details,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AuditFilter defines the criteria for listing audit events.
+// Zero values mean "no restriction" for the corresponding field.
+type AuditFilter struct {
+	ImageID uuid.UUID
+	Event   string
+	Actor   string
+	From    time.Time // recorded at or after
+	To      time.Time // recorded before
+	Limit   int
+	Offset  int
+}
@@ -0,0 +1,16 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Change represents a single entry of the image change feed.
+type Change struct {
+	Seq       int64     `json:"seq"`       // monotonically increasing cursor
+	ImageID   uuid.UUID `json:"image_id"`  // affected image
+	Operation string    `json:"operation"` // created / updated / deleted
+	Path      string    `json:"object_key"`
+	ChangedAt time.Time `json:"changed_at"`
+}
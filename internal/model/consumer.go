@@ -0,0 +1,7 @@
+package model
+
+// ConsumerState describes whether queue consumption is paused and how many messages are still being processed.
+type ConsumerState struct {
+	Paused   bool  `json:"paused"`    // consumers don't fetch new messages while paused
+	InFlight int64 `json:"in_flight"` // messages being processed; 0 once a pause has drained
+}
@@ -0,0 +1,22 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeadLetter is a processing message that kept failing after retries,
+// recorded along with the error for inspection and replay.
+type DeadLetter struct {
+	ID         uuid.UUID  `json:"id"`
+	Topic      string     `json:"topic"`     // topic the message was consumed from
+	Partition  int        `json:"partition"` // partition the message was consumed from
+	Offset     int64      `json:"offset"`    // offset of the message in its partition
+	Key        string     `json:"key"`
+	Payload    string     `json:"payload"` // original message value
+	Error      string     `json:"error"`   // error of the last processing attempt
+	Attempts   int        `json:"attempts"`
+	FailedAt   time.Time  `json:"failed_at"`
+	ReplayedAt *time.Time `json:"replayed_at,omitempty"` // set once the message was re-enqueued
+}
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Webhook event types.
+const (
+	EventImageProcessed = "image.processed"
+	EventImageFailed    = "image.failed"
+)
+
+// WebhookEvent is the JSON payload POSTed to client callback URLs.
+type WebhookEvent struct {
+	Event      string     `json:"event"`
+	ImageID    uuid.UUID  `json:"image_id"`
+	DerivedID  *uuid.UUID `json:"derived_id,omitempty"`
+	Action     string     `json:"action"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	OccurredAt time.Time  `json:"occurred_at"`
+}
+
+// ProcessedEvent is published to the events topic after an image was processed,
+// so that downstream systems (search indexers, CDNs) can react without polling the database.
+type ProcessedEvent struct {
+	Event      string             `json:"event"` // always EventImageProcessed
+	ImageID    uuid.UUID          `json:"image_id"`
+	Action     string             `json:"action"`
+	Variants   []ProcessedVariant `json:"variants"`    // images derived by the job
+	DurationMs int64              `json:"duration_ms"` // processing time of the job
+	OccurredAt time.Time          `json:"occurred_at"`
+}
+
+// ProcessedVariant is an image derived by a processing job.
+type ProcessedVariant struct {
+	ID     uuid.UUID `json:"id"`
+	Path   string    `json:"path"` // path of the file in storage
+	Format string    `json:"format,omitempty"`
+	Size   int64     `json:"size_bytes"`
+}
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Image kinds selectable in filters.
+const (
+	KindOriginal = "original" // uploaded originals
+	KindDerived  = "derived"  // results derived from an original
+)
+
+// ImageFilter defines the criteria for listing images.
+// Zero values mean "no restriction" for the corresponding field.
+type ImageFilter struct {
+	Query          string    // full-text query over filename, tags and OCR text
+	Status         string    // exact status match
+	Action         string    // exact action name match
+	Owner          string    // exact owner match
+	Filename       string    // case-insensitive filename substring
+	Title          string    // case-insensitive title substring
+	Tags           []string  // images carrying all of these tags
+	AnyTags        []string  // images carrying at least one of these tags
+	ExcludedTags   []string  // images carrying none of these tags
+	Kind           string    // KindOriginal or KindDerived
+	Formats        []string  // stored format is one of these, e.g. "jpeg", "png"
+	MinSize        int64     // file size in bytes at least
+	MaxSize        int64     // file size in bytes at most
+	From           time.Time // created at or after
+	To             time.Time // created before
+	IncludeDeleted bool      // also soft-deleted images, for admins
+	After          *Cursor   // continue after this position instead of skipping Offset rows
+	Limit          int
+	Offset         int
+}
+
+// Cursor marks the position of an image in the newest-first listing order,
+// used for keyset pagination that stays stable while images are added.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
@@ -8,12 +8,31 @@ import (
 
 // Image represents an image processing job that will be sent to the queue.
 type Image struct {
-	ID        uuid.UUID `json:"id"`
-	Filename  string    `json:"filename"`
-	Path      string    `json:"file_path"`
-	Action    Action    `json:"actions"` // action to perform
-	Status    string    `json:"status"`  // pending / processed / failed
-	CreatedAt time.Time `json:"created_at"`
+	ID          uuid.UUID  `json:"id"`
+	OriginalID  *uuid.UUID `json:"original_id,omitempty"` // set for images derived from an original
+	Filename    string     `json:"filename"`
+	Path        string     `json:"file_path"`
+	Action      Action     `json:"actions"`                // action to perform
+	Status      string     `json:"status"`                 // one of the Status* constants
+	OCRText     string     `json:"ocr_text,omitempty"`     // text extracted by the optional OCR step
+	CallbackURL string     `json:"callback_url,omitempty"` // webhook notified when processing finishes
+	Owner       string     `json:"owner"`                  // user the image counts against for quotas
+	Priority    string     `json:"priority"`               // processing priority, one of the Priority* constants
+	Size        int64      `json:"size_bytes"`             // size of the stored file
+	Format      string     `json:"format,omitempty"`       // stored image format, e.g. "jpeg", "png"
+	Width       int        `json:"width,omitempty"`        // width of the stored image in pixels
+	Height      int        `json:"height,omitempty"`       // height of the stored image in pixels
+	ColorSpace  string     `json:"color_space,omitempty"`  // "rgb", "gray" or "cmyk"
+	ContentHash string     `json:"content_hash,omitempty"` // hex SHA-256 of uploaded originals
+	Title       string     `json:"title"`                  // user-supplied title
+	Description string     `json:"description"`            // user-supplied description
+	Tags        []string   `json:"tags"`                   // user-supplied tags, normalized to lower case
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`   // deleted by the expiry sweeper after this time
+	Error       string     `json:"error,omitempty"`        // error of the last attempt while the status is failed
+	FailedAt    *time.Time `json:"failed_at,omitempty"`    // time processing failed while the status is failed
+	MessageID   string     `json:"-"`                      // queue message the image is processed for, or derived from
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`   // time the image was soft-deleted, hidden from reads since
+	CreatedAt   time.Time  `json:"created_at"`
 }
 
 // Action defines a single action and its optional parameters.
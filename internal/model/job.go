@@ -0,0 +1,75 @@
+package model
+
+import "time"
+
+// Job states selectable in the admin job listing.
+const (
+	JobStateFailed = "failed" // last processing attempt failed
+	JobStateStuck  = "stuck"  // not finished and not updated for a while
+)
+
+// Job describes the processing job of an uploaded original.
+type Job struct {
+	ProcessingStatus
+	Filename string `json:"filename"`
+	Action   string `json:"action"`
+	Redrives int    `json:"redrives"` // times the job was re-enqueued after getting stuck
+}
+
+// JobFilter defines the criteria for listing jobs.
+type JobFilter struct {
+	State      string    // one of the JobState* constants
+	StuckSince time.Time // unfinished jobs last updated before this moment are stuck
+	Limit      int
+	Offset     int
+	// NewestFirst lists the most recently updated jobs first instead of the oldest.
+	NewestFirst bool
+}
+
+// QueueDepth describes the unfinished jobs of originals, whenever submitted.
+type QueueDepth struct {
+	Queued     int `json:"queued"`     // waiting for a worker
+	Processing int `json:"processing"` // picked up by a worker
+	Stuck      int `json:"stuck"`      // queued or processing, but not updated for a while
+}
+
+// ActionStats describes the processing throughput of a single action.
+type ActionStats struct {
+	Action        string  `json:"action"`
+	Total         int     `json:"total"`           // jobs submitted in the window
+	Processed     int     `json:"processed"`       // jobs finished successfully
+	Failed        int     `json:"failed"`          // jobs whose last attempt failed
+	Pending       int     `json:"pending"`         // jobs queued or in progress
+	PerHour       float64 `json:"per_hour"`        // processed jobs per hour over the window
+	AvgDurationMs float64 `json:"avg_duration_ms"` // mean duration of the successful attempts
+	FailureRate   float64 `json:"failure_rate"`    // share of finished jobs that failed, from 0 to 1
+}
+
+// StatusCount is the number of jobs in a status.
+type StatusCount struct {
+	Status string `json:"status"`
+	Count  int    `json:"count"`
+}
+
+// HourlyStats describes the jobs finished within an hour.
+type HourlyStats struct {
+	Hour          time.Time `json:"hour"` // start of the hour
+	Processed     int       `json:"processed"`
+	Failed        int       `json:"failed"`
+	AvgDurationMs float64   `json:"avg_duration_ms"` // mean duration of the successful attempts
+}
+
+// Stats aggregates the processing of the jobs submitted or finished within a window.
+type Stats struct {
+	Window        string        `json:"window"`
+	Processed     int           `json:"processed"`
+	Failed        int           `json:"failed"`
+	AvgDurationMs float64       `json:"avg_duration_ms"` // mean duration of the successful attempts
+	FailureRate   float64       `json:"failure_rate"`    // share of finished jobs that failed, from 0 to 1
+	Statuses      []StatusCount `json:"statuses"`        // jobs submitted in the window by status
+	Actions       []ActionStats `json:"actions"`         // jobs submitted in the window by action
+	Hourly        []HourlyStats `json:"hourly"`          // jobs finished in the window by hour, oldest first
+	Queue         QueueDepth    `json:"queue"`           // unfinished jobs, whenever submitted
+	Storage       UsageReport   `json:"storage"`         // storage usage by top-level directory
+	RecentErrors  []Job         `json:"recent_errors"`   // failed jobs, the most recently failed first
+}
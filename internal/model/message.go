@@ -0,0 +1,21 @@
+package model
+
+// MessageIDHeader is the message header carrying the unique ID of a processing task message.
+// It is kept when the message is forwarded to retry topics, so that redeliveries are recognized.
+const MessageIDHeader = "message-id"
+
+// QueueMessage is a processing task message received from the queue, independent of the broker.
+type QueueMessage struct {
+	ID      string            // unique ID of the message, the same for all its deliveries
+	Topic   string            // topic, subject or queue the message was received from
+	Key     []byte            // partitioning key, the image ID
+	Value   []byte            // encoded processing task
+	Headers map[string]string // headers such as the request ID and trace context
+}
+
+// MessageAttempt counts the processing attempts of a queue message that hasn't been processed yet.
+type MessageAttempt struct {
+	MessageID string
+	Attempts  int    // attempts begun so far, including the current one
+	LastError string // error of the last failed attempt, empty if none failed yet
+}
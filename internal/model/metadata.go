@@ -0,0 +1,9 @@
+package model
+
+// MetadataUpdate holds user-supplied metadata changes of an image.
+// Nil fields are left unchanged; Tags replaces the whole tag set.
+type MetadataUpdate struct {
+	Title       *string   `json:"title"`
+	Description *string   `json:"description"`
+	Tags        *[]string `json:"tags"`
+}
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// OutboxMessage is a processing task stored in the transaction that recorded its image,
+// waiting to be published to the queue.
+type OutboxMessage struct {
+	ID        int64
+	Image     Image             // image the task is produced for
+	Headers   map[string]string // trace context of the request that enqueued the task
+	CreatedAt time.Time
+}
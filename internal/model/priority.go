@@ -0,0 +1,9 @@
+package model
+
+// Processing priorities selectable on upload. High and low priority jobs are routed
+// to their own topics, so that interactive uploads aren't queued behind bulk backfills.
+const (
+	PriorityHigh   = "high"
+	PriorityNormal = "normal"
+	PriorityLow    = "low"
+)
@@ -0,0 +1,20 @@
+package model
+
+// DefaultOwner is the owner assigned to images uploaded without a user ID.
+const DefaultOwner = "anonymous"
+
+// QuotaLimits holds the per-user resource limits. A zero value disables the limit.
+type QuotaLimits struct {
+	MaxBytes     int64 `json:"max_bytes"`      // total bytes stored, originals and derivatives
+	ImagesPerDay int   `json:"images_per_day"` // uploaded images in the last 24 hours
+	JobsPerHour  int   `json:"jobs_per_hour"`  // processing jobs enqueued in the last hour
+}
+
+// QuotaUsage describes the current resource usage of a user against their limits.
+type QuotaUsage struct {
+	Owner         string      `json:"owner"`
+	BytesStored   int64       `json:"bytes_stored"`
+	ImagesLastDay int         `json:"images_last_day"`
+	JobsLastHour  int         `json:"jobs_last_hour"`
+	Limits        QuotaLimits `json:"limits"`
+}
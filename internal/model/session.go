@@ -0,0 +1,18 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session represents an interactive editing session opened on an image.
+// Actions are applied incrementally to a preview and committed as a single derivative.
+type Session struct {
+	ID          uuid.UUID `json:"id"`
+	ImageID     uuid.UUID `json:"image_id"`
+	Actions     []Action  `json:"actions"`      // pipeline applied so far, in order
+	PreviewPath string    `json:"preview_path"` // path to the latest preview in the workspace
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
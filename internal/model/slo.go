@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// States of a latency objective, by the rate its error budget burns at.
+const (
+	SLOStateOK       = "ok"       // burning slower than the warning rate
+	SLOStateWarning  = "warning"  // burning at the warning rate or faster
+	SLOStateCritical = "critical" // burning at the critical rate or faster
+	SLOStateNoData   = "no_data"  // no job finished within the window
+)
+
+// LatencySLO describes the processing latency of an action over a rolling window
+// against its latency objective.
+type LatencySLO struct {
+	Action      string  `json:"action"`
+	Count       int     `json:"count"`        // jobs finished within the window
+	P50Ms       float64 `json:"p50_ms"`       // median processing time
+	P95Ms       float64 `json:"p95_ms"`       // 95th percentile processing time
+	P99Ms       float64 `json:"p99_ms"`       // 99th percentile processing time
+	ThresholdMs float64 `json:"threshold_ms"` // processing time jobs should finish within
+	Target      float64 `json:"target"`       // share of jobs that should finish within the threshold
+	Slow        int     `json:"slow"`         // jobs over the threshold
+	BurnRate    float64 `json:"burn_rate"`    // share of slow jobs relative to the error budget, 1 spends it exactly
+	State       string  `json:"state"`        // one of the SLOState* constants
+}
+
+// SLOReport describes the latency objectives of all actions processed within a rolling window.
+type SLOReport struct {
+	Window      string       `json:"window"`
+	GeneratedAt time.Time    `json:"generated_at"`
+	Actions     []LatencySLO `json:"actions"`
+	Alerts      []LatencySLO `json:"alerts"` // actions in the warning or critical state
+}
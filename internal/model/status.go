@@ -0,0 +1,42 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Processing stages reported while an image goes through the worker.
+const (
+	StageQueued     = "queued"
+	StageDecoding   = "decoding"
+	StageProcessing = "processing"
+	StageUploading  = "uploading"
+	StageDone       = "done"
+	StageFailed     = "failed"
+)
+
+// Statuses of images. Originals move from pending to processing when a worker picks up their job,
+// and to processed or failed once it finishes; derived images are saved processed.
+const (
+	StatusPending    = "pending"
+	StatusProcessing = "processing"
+	StatusProcessed  = "processed"
+	StatusFailed     = "failed"
+)
+
+// ProcessingStatus describes the detailed processing state of an image.
+type ProcessingStatus struct {
+	ID        uuid.UUID `json:"id"`
+	Status    string    `json:"status"`               // one of the Status* constants
+	Stage     string    `json:"stage"`                // one of the Stage* constants
+	Attempts  int       `json:"attempts"`             // number of processing attempts so far
+	LastError string    `json:"last_error,omitempty"` // error of the last failed attempt
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Timing of the last attempt; unset before the first one and while it runs.
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	DurationMs *int64     `json:"duration_ms,omitempty"`
+}
@@ -0,0 +1,18 @@
+package model
+
+import "github.com/google/uuid"
+
+// Task is the processing job carried by a queue message. It references the image by ID only,
+// so that workers load its current state from the database instead of a copy taken at enqueue time.
+type Task struct {
+	ID          uuid.UUID `json:"id"`
+	Action      Action    `json:"actions"`                // action to perform, which may differ from the stored one on reprocessing
+	CallbackURL string    `json:"callback_url,omitempty"` // webhook of this job, overriding the stored one
+	MessageID   string    `json:"-"`                      // queue message the task was received in
+	LastAttempt bool      `json:"-"`                      // last attempt of the message before it is given up on
+}
+
+// NewTask returns the task processing the image with its action and callback.
+func NewTask(img Image) Task {
+	return Task{ID: img.ID, Action: img.Action, CallbackURL: img.CallbackURL}
+}
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UploadOptions holds optional per-upload settings supplied by the client.
+type UploadOptions struct {
+	CallbackURL string     // webhook notified when processing succeeds or fails
+	Owner       string     // user the upload counts against for quotas
+	Reprocess   bool       // enqueue the action even if the upload duplicates an existing original
+	ExpiresAt   *time.Time // delete the image and its derivatives after this time; nil keeps it
+	Priority    string     // one of the Priority* constants, normal if empty
+}
+
+// SavedUpload describes the image an upload was stored as.
+type SavedUpload struct {
+	ID        uuid.UUID
+	Path      string
+	Duplicate bool // identical to an existing original, which was returned instead of storing a copy
+}
@@ -0,0 +1,30 @@
+package model
+
+// Groupings of the storage usage report.
+const (
+	UsageByOwner  = "owner"  // bytes per owner across directories
+	UsageBySubdir = "subdir" // bytes per top-level storage directory across owners
+)
+
+// StorageUsage describes the bytes and objects stored by an owner under a top-level storage
+// directory ("original", "processed", ...). Owner or Subdir is empty when usage is grouped by the other.
+type StorageUsage struct {
+	Owner   string `json:"owner,omitempty"`
+	Subdir  string `json:"subdir,omitempty"`
+	Bytes   int64  `json:"bytes"`
+	Objects int64  `json:"objects"`
+}
+
+// UsageFilter selects the storage usage to report. Empty fields match everything.
+type UsageFilter struct {
+	Owner   string
+	Subdir  string
+	GroupBy string // UsageByOwner, UsageBySubdir, or empty for both
+}
+
+// UsageReport describes the storage usage matching a filter along with its totals.
+type UsageReport struct {
+	Bytes   int64          `json:"bytes"`
+	Objects int64          `json:"objects"`
+	Usage   []StorageUsage `json:"usage"`
+}
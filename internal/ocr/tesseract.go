@@ -0,0 +1,49 @@
+package ocr
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Tesseract extracts text from images by running the tesseract CLI.
+type Tesseract struct {
+	binary    string
+	languages string
+	timeout   time.Duration
+}
+
+// NewTesseract creates a new Tesseract extractor.
+// - binary: path to the tesseract executable
+// - languages: tesseract language spec, e.g. "eng" or "eng+rus"
+// - timeout: max time a single extraction may take
+func NewTesseract(binary, languages string, timeout time.Duration) *Tesseract {
+	return &Tesseract{
+		binary:    binary,
+		languages: languages,
+		timeout:   timeout,
+	}
+}
+
+// Extract runs OCR on the image read from src and returns the recognized text.
+func (t *Tesseract) Extract(ctx context.Context, src io.Reader) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, t.binary, "stdin", "stdout", "-l", t.languages)
+	cmd.Stdin = src
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("tesseract failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,106 @@
+package processor
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	defaultKeyColor  = "#00ff00"
+	defaultTolerance = 80.0
+	defaultSoftness  = 30.0
+)
+
+// chromaKey removes the key color (green screen by default) from the image,
+// making matching pixels transparent. The result is saved as PNG.
+//
+// Supported params:
+//   - color: key color as "#rrggbb" (default "#00ff00").
+//   - tolerance: max RGB distance from the key color removed completely (default 80).
+//   - softness: width of the partially transparent edge beyond the tolerance (default 30).
+func chromaKey(src image.Image, params map[string]string) (image.Image, error) {
+	key, err := parseHexColor(valueOr(params["color"], defaultKeyColor))
+	if err != nil {
+		return nil, err
+	}
+
+	tolerance, err := parseNonNegative(params, "tolerance", defaultTolerance)
+	if err != nil {
+		return nil, err
+	}
+	softness, err := parseNonNegative(params, "softness", defaultSoftness)
+	if err != nil {
+		return nil, err
+	}
+
+	img := imaging.Clone(src)
+	for i := 0; i+3 < len(img.Pix); i += 4 {
+		d := math.Sqrt(
+			sq(float64(img.Pix[i])-float64(key[0])) +
+				sq(float64(img.Pix[i+1])-float64(key[1])) +
+				sq(float64(img.Pix[i+2])-float64(key[2])),
+		)
+
+		switch {
+		case d <= tolerance:
+			img.Pix[i+3] = 0
+		case d < tolerance+softness:
+			img.Pix[i+3] = clamp8(float64(img.Pix[i+3]) * (d - tolerance) / softness)
+		}
+	}
+
+	return img, nil
+}
+
+// parseHexColor parses a "#rrggbb" color into its RGB components.
+func parseHexColor(s string) ([3]uint8, error) {
+	var rgb [3]uint8
+
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) != 6 {
+		return rgb, fmt.Errorf("invalid color: %q", s)
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return rgb, fmt.Errorf("invalid color: %q", s)
+	}
+
+	rgb[0], rgb[1], rgb[2] = uint8(v>>16), uint8(v>>8), uint8(v)
+
+	return rgb, nil
+}
+
+// parseNonNegative parses an optional non-negative float param, falling back to def.
+func parseNonNegative(params map[string]string, name string, def float64) (float64, error) {
+	v := params[name]
+	if v == "" {
+		return def, nil
+	}
+
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("invalid %s: %q", name, v)
+	}
+
+	return f, nil
+}
+
+// valueOr returns v, or def if v is empty.
+func valueOr(v, def string) string {
+	if v == "" {
+		return def
+	}
+
+	return v
+}
+
+// sq returns the square of v.
+func sq(v float64) float64 {
+	return v * v
+}
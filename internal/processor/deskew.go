@@ -0,0 +1,136 @@
+package processor
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	defaultDeskewMaxAngle = 15.0 // degrees searched in each direction
+	deskewAngleStep       = 0.2  // search resolution in degrees
+	deskewSampleSize      = 600  // max side of the image used for angle detection
+)
+
+// deskew detects the rotation angle of a scanned page and straightens it,
+// cropping the empty borders produced by the rotation.
+//
+// Supported params:
+//   - max_angle: max absolute rotation to detect in degrees (default 15).
+//   - crop: "false" keeps the expanded canvas instead of cropping borders.
+func deskew(src image.Image, params map[string]string) (image.Image, error) {
+	maxAngle := defaultDeskewMaxAngle
+	if v := params["max_angle"]; v != "" {
+		var err error
+		maxAngle, err = strconv.ParseFloat(v, 64)
+		if err != nil || maxAngle <= 0 || maxAngle > 45 {
+			return nil, fmt.Errorf("invalid max_angle: %q", v)
+		}
+	}
+
+	angle := detectSkew(src, maxAngle)
+	if math.Abs(angle) < deskewAngleStep/2 {
+		return src, nil
+	}
+
+	rotated := imaging.Rotate(src, angle, color.White)
+	if params["crop"] == "false" {
+		return rotated, nil
+	}
+
+	b := src.Bounds()
+	w, h := innerRect(float64(b.Dx()), float64(b.Dy()), angle*math.Pi/180)
+
+	return imaging.CropCenter(rotated, int(w), int(h)), nil
+}
+
+// detectSkew estimates the counter-clockwise rotation in degrees that makes the
+// text lines of the image horizontal, using the projection profile method:
+// the angle that maximizes the variance between adjacent row sums of dark pixels wins.
+func detectSkew(src image.Image, maxAngle float64) float64 {
+	sample := imaging.Grayscale(imaging.Fit(src, deskewSampleSize, deskewSampleSize, imaging.Box))
+	b := sample.Bounds()
+	cx, cy := float64(b.Dx())/2, float64(b.Dy())/2
+
+	// Use the mean intensity as the binarization threshold.
+	var sum int
+	for i := 0; i < len(sample.Pix); i += 4 {
+		sum += int(sample.Pix[i])
+	}
+	threshold := sum / (len(sample.Pix)/4 + 1)
+
+	var xs, ys []float64
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			if int(sample.Pix[y*sample.Stride+x*4]) < threshold {
+				xs = append(xs, float64(x)-cx)
+				ys = append(ys, float64(y)-cy)
+			}
+		}
+	}
+	if len(xs) == 0 {
+		return 0
+	}
+
+	diag := int(math.Hypot(float64(b.Dx()), float64(b.Dy()))) + 1
+	rows := make([]int, diag)
+
+	bestAngle, bestScore := 0.0, -1.0
+	for angle := -maxAngle; angle <= maxAngle; angle += deskewAngleStep {
+		rad := angle * math.Pi / 180
+		sin, cos := math.Sin(rad), math.Cos(rad)
+
+		for i := range rows {
+			rows[i] = 0
+		}
+		for i := range xs {
+			row := int(-xs[i]*sin+ys[i]*cos) + diag/2
+			if row >= 0 && row < diag {
+				rows[row]++
+			}
+		}
+
+		var score float64
+		for i := 1; i < diag; i++ {
+			d := float64(rows[i] - rows[i-1])
+			score += d * d
+		}
+
+		if score > bestScore {
+			bestScore, bestAngle = score, angle
+		}
+	}
+
+	return bestAngle
+}
+
+// innerRect returns the size of the largest axis-aligned rectangle that fits
+// inside a w×h rectangle rotated by the given angle in radians.
+func innerRect(w, h, angle float64) (float64, float64) {
+	if w <= 0 || h <= 0 {
+		return 0, 0
+	}
+
+	long, short := w, h
+	if h > w {
+		long, short = h, w
+	}
+
+	sin, cos := math.Abs(math.Sin(angle)), math.Abs(math.Cos(angle))
+
+	if short <= 2*sin*cos*long || math.Abs(sin-cos) < 1e-10 {
+		x := short / 2
+		if w >= h {
+			return x / sin, x / cos
+		}
+		return x / cos, x / sin
+	}
+
+	cos2 := cos*cos - sin*sin
+
+	return (w*cos - h*sin) / cos2, (h*cos - w*sin) / cos2
+}
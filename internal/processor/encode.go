@@ -0,0 +1,134 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"strconv"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/disintegration/imaging"
+	_ "golang.org/x/image/webp" // register WebP decoding for uploaded originals
+)
+
+// maxTransformSide bounds the width and height accepted by on-the-fly transforms.
+const maxTransformSide = 4096
+
+// Formats supported by Encode with their MIME types and file extensions.
+var formats = map[string]struct {
+	contentType string
+	ext         string
+}{
+	"jpeg": {contentType: "image/jpeg", ext: ".jpg"},
+	"png":  {contentType: "image/png", ext: ".png"},
+	"gif":  {contentType: "image/gif", ext: ".gif"},
+	"webp": {contentType: "image/webp", ext: ".webp"},
+}
+
+// ContentType returns the MIME type of an image format detected by Sniff or produced by Encode,
+// or "application/octet-stream" for unknown formats.
+func ContentType(format string) string {
+	if f, ok := formats[format]; ok {
+		return f.contentType
+	}
+
+	switch format {
+	case "bmp", "tiff":
+		return "image/" + format
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// TransformOptions describes a synchronous resize/crop requested via the transform URL API.
+type TransformOptions struct {
+	Width  int    // target width, 0 keeps the aspect ratio (resize mode only)
+	Height int    // target height, 0 keeps the aspect ratio (resize mode only)
+	Mode   string // fit (default), fill or resize
+	Format string // jpeg (default), png, gif or webp
+}
+
+// ParseTransformOptions validates raw transform query values and fills in defaults.
+func ParseTransformOptions(w, h, mode, format string) (TransformOptions, error) {
+	opts := TransformOptions{Mode: valueOr(mode, "fit"), Format: valueOr(format, "jpeg")}
+
+	var err error
+	if w != "" {
+		if opts.Width, err = strconv.Atoi(w); err != nil || opts.Width < 0 || opts.Width > maxTransformSide {
+			return opts, fmt.Errorf("invalid w: %q", w)
+		}
+	}
+	if h != "" {
+		if opts.Height, err = strconv.Atoi(h); err != nil || opts.Height < 0 || opts.Height > maxTransformSide {
+			return opts, fmt.Errorf("invalid h: %q", h)
+		}
+	}
+
+	switch opts.Mode {
+	case "fit", "fill":
+		if opts.Width == 0 || opts.Height == 0 {
+			return opts, fmt.Errorf("mode %s requires both w and h", opts.Mode)
+		}
+	case "resize":
+		if opts.Width == 0 && opts.Height == 0 {
+			return opts, fmt.Errorf("mode resize requires w or h")
+		}
+	default:
+		return opts, fmt.Errorf("invalid mode: %q", opts.Mode)
+	}
+
+	if _, ok := formats[opts.Format]; !ok {
+		return opts, fmt.Errorf("invalid format: %q", opts.Format)
+	}
+
+	return opts, nil
+}
+
+// Key returns a stable identifier of the transform, suitable for cache object names.
+func (o TransformOptions) Key() string {
+	return fmt.Sprintf("%dx%d_%s%s", o.Width, o.Height, o.Mode, formats[o.Format].ext)
+}
+
+// ContentType returns the MIME type of the transform output.
+func (o TransformOptions) ContentType() string {
+	return formats[o.Format].contentType
+}
+
+// Transform resizes the image according to the options.
+func Transform(src image.Image, o TransformOptions) image.Image {
+	switch o.Mode {
+	case "fill":
+		return imaging.Fill(src, o.Width, o.Height, imaging.Center, imaging.Lanczos)
+	case "resize":
+		return imaging.Resize(src, o.Width, o.Height, imaging.Lanczos)
+	default:
+		return imaging.Fit(src, o.Width, o.Height, imaging.Lanczos)
+	}
+}
+
+// SaveTransform transforms the image according to the options and streams its encoding in the
+// requested format to storage under dst, as saveEncoded does for processing results.
+func (p *Processor) SaveTransform(ctx context.Context, dst string, src image.Image, o TransformOptions) error {
+	_, err := p.saveStreamed(ctx, dst, o.ContentType(), func(w io.Writer) error {
+		return Encode(w, Transform(src, o), o.Format)
+	})
+
+	return err
+}
+
+// Encode writes the image to w in the given format (jpeg, png, gif or webp).
+func Encode(w io.Writer, img image.Image, format string) error {
+	switch format {
+	case "jpeg":
+		return imaging.Encode(w, img, imaging.JPEG)
+	case "png":
+		return imaging.Encode(w, img, imaging.PNG)
+	case "gif":
+		return imaging.Encode(w, img, imaging.GIF)
+	case "webp":
+		return nativewebp.Encode(w, img, nil)
+	default:
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+}
@@ -0,0 +1,135 @@
+package processor
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+
+	"github.com/disintegration/imaging"
+)
+
+// defaultLevelsClip is the share of darkest and brightest pixels ignored
+// when searching for the black and white points.
+const defaultLevelsClip = 0.005
+
+// autoLevels stretches the contrast of the image automatically.
+//
+// Supported params:
+//   - mode: "stretch" (default) maps the clipped luminance range to 0..255,
+//     "equalize" performs histogram equalization of the luminance.
+//   - clip: share of pixels clipped on each side in stretch mode (default 0.005).
+func autoLevels(src image.Image, params map[string]string) (image.Image, error) {
+	clip := defaultLevelsClip
+	if v := params["clip"]; v != "" {
+		var err error
+		clip, err = strconv.ParseFloat(v, 64)
+		if err != nil || clip < 0 || clip >= 0.5 {
+			return nil, fmt.Errorf("invalid clip: %q", v)
+		}
+	}
+
+	img := imaging.Clone(src)
+	hist := luminanceHistogram(img)
+	total := img.Bounds().Dx() * img.Bounds().Dy()
+	if total == 0 {
+		return img, nil
+	}
+
+	var lut [256]uint8
+
+	switch mode := params["mode"]; mode {
+	case "", "stretch":
+		low, high := histogramBounds(hist, total, clip)
+		if high <= low {
+			return img, nil
+		}
+		scale := 255.0 / float64(high-low)
+		for i := range lut {
+			lut[i] = clamp8((float64(i) - float64(low)) * scale)
+		}
+	case "equalize":
+		var cdf, cdfMin int
+		for i, n := range hist {
+			cdf += n
+			if cdfMin == 0 && cdf > 0 {
+				cdfMin = cdf
+			}
+			if total == cdfMin {
+				lut[i] = uint8(i)
+				continue
+			}
+			lut[i] = clamp8(float64(cdf-cdfMin) / float64(total-cdfMin) * 255)
+		}
+	default:
+		return nil, fmt.Errorf("invalid mode: %q", mode)
+	}
+
+	applyLuminanceLUT(img, lut)
+
+	return img, nil
+}
+
+// luminanceHistogram builds a 256-bucket histogram of the Rec. 601 luma of the image.
+func luminanceHistogram(img *image.NRGBA) [256]int {
+	var hist [256]int
+	for i := 0; i+3 < len(img.Pix); i += 4 {
+		hist[luma(img.Pix[i], img.Pix[i+1], img.Pix[i+2])]++
+	}
+
+	return hist
+}
+
+// histogramBounds returns the luminance values below and above which
+// the given share of pixels lies.
+func histogramBounds(hist [256]int, total int, clip float64) (int, int) {
+	threshold := int(float64(total) * clip)
+
+	low, acc := 0, 0
+	for ; low < 255; low++ {
+		acc += hist[low]
+		if acc > threshold {
+			break
+		}
+	}
+
+	high := 255
+	acc = 0
+	for ; high > 0; high-- {
+		acc += hist[high]
+		if acc > threshold {
+			break
+		}
+	}
+
+	return low, high
+}
+
+// applyLuminanceLUT remaps the luminance of every pixel through the LUT
+// while preserving the hue by scaling all channels by the same ratio.
+func applyLuminanceLUT(img *image.NRGBA, lut [256]uint8) {
+	for i := 0; i+3 < len(img.Pix); i += 4 {
+		r, g, b := img.Pix[i], img.Pix[i+1], img.Pix[i+2]
+		y := luma(r, g, b)
+		if y == 0 {
+			v := lut[0]
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2] = v, v, v
+			continue
+		}
+
+		ratio := float64(lut[y]) / float64(y)
+		img.Pix[i] = clamp8(float64(r) * ratio)
+		img.Pix[i+1] = clamp8(float64(g) * ratio)
+		img.Pix[i+2] = clamp8(float64(b) * ratio)
+	}
+}
+
+// luma returns the Rec. 601 luma of an RGB triple.
+func luma(r, g, b uint8) uint8 {
+	return uint8((299*int(r) + 587*int(g) + 114*int(b)) / 1000)
+}
+
+// clamp8 rounds and clamps a value to the 0..255 range.
+func clamp8(v float64) uint8 {
+	return uint8(math.Max(0, math.Min(255, math.Round(v))))
+}
@@ -0,0 +1,96 @@
+package processor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+
+	"github.com/disintegration/imaging"
+)
+
+// ErrImageTooLarge is returned when an image exceeds the configured dimension limits.
+var ErrImageTooLarge = errors.New("image dimensions exceed limits")
+
+// Limits bounds the dimensions of images accepted for decoding. Zero disables a limit.
+type Limits struct {
+	MaxWidth  int
+	MaxHeight int
+	MaxPixels int64
+}
+
+// check returns ErrImageTooLarge if the image described by cfg exceeds the limits.
+func (l Limits) check(cfg image.Config) error {
+	switch {
+	case l.MaxWidth > 0 && cfg.Width > l.MaxWidth,
+		l.MaxHeight > 0 && cfg.Height > l.MaxHeight,
+		l.MaxPixels > 0 && int64(cfg.Width)*int64(cfg.Height) > l.MaxPixels:
+		return fmt.Errorf("%w: %dx%d", ErrImageTooLarge, cfg.Width, cfg.Height)
+	default:
+		return nil
+	}
+}
+
+// Info describes the technical properties of an image.
+type Info struct {
+	Width      int
+	Height     int
+	ColorSpace string // "rgb", "gray" or "cmyk"
+}
+
+// ColorSpace returns the color space of images in the color model. YCbCr and paletted
+// images are RGB images in another encoding.
+func ColorSpace(m color.Model) string {
+	switch m {
+	case color.GrayModel, color.Gray16Model:
+		return "gray"
+	case color.CMYKModel:
+		return "cmyk"
+	default:
+		return "rgb"
+	}
+}
+
+// infoOf returns the properties of a decoded image.
+func infoOf(img image.Image) Info {
+	b := img.Bounds()
+	return Info{Width: b.Dx(), Height: b.Dy(), ColorSpace: ColorSpace(img.ColorModel())}
+}
+
+// CheckLimits reads the image header from r and verifies its dimensions against the limits
+// without decoding the pixels. It returns the dimensions and color space read from the header
+// and a reader that replays the consumed header followed by the rest of r.
+func (p *Processor) CheckLimits(r io.Reader) (Info, io.Reader, error) {
+	head := bytes.NewBuffer(nil)
+
+	cfg, _, err := image.DecodeConfig(io.TeeReader(r, head))
+	if err != nil {
+		return Info{}, nil, fmt.Errorf("%w: failed to decode image header: %v", ErrUnsupportedFormat, err)
+	}
+
+	if err := p.limits.check(cfg); err != nil {
+		return Info{}, nil, err
+	}
+
+	info := Info{Width: cfg.Width, Height: cfg.Height, ColorSpace: ColorSpace(cfg.ColorModel)}
+
+	return info, io.MultiReader(head, r), nil
+}
+
+// Decode decodes an image after checking its header against the limits,
+// so oversized images are rejected before their pixels are allocated.
+func (p *Processor) Decode(r io.Reader) (image.Image, error) {
+	_, r, err := p.CheckLimits(r)
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := imaging.Decode(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	return src, nil
+}
@@ -0,0 +1,223 @@
+package processor
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/google/uuid"
+)
+
+// LUTSubdir is the storage subdirectory where uploaded .cube files are kept.
+const LUTSubdir = "luts"
+
+// maxLUTSize bounds the LUT_3D_SIZE accepted from uploaded files.
+const maxLUTSize = 128
+
+// LUT is a parsed 3D color lookup table.
+type LUT struct {
+	size      int
+	domainMin [3]float64
+	domainMax [3]float64
+	table     [][3]float64 // size^3 entries, red changing fastest
+}
+
+// ParseCube parses a LUT in the Adobe/Resolve .cube format.
+func ParseCube(r io.Reader) (*LUT, error) {
+	lut := &LUT{domainMax: [3]float64{1, 1, 1}}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		switch fields[0] {
+		case "TITLE", "LUT_1D_INPUT_RANGE", "LUT_3D_INPUT_RANGE":
+			continue
+		case "LUT_1D_SIZE":
+			return nil, errors.New("1D LUTs are not supported")
+		case "LUT_3D_SIZE":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("invalid LUT_3D_SIZE line: %q", line)
+			}
+			size, err := strconv.Atoi(fields[1])
+			if err != nil || size < 2 || size > maxLUTSize {
+				return nil, fmt.Errorf("invalid LUT_3D_SIZE: %q", fields[1])
+			}
+			lut.size = size
+			lut.table = make([][3]float64, 0, size*size*size)
+		case "DOMAIN_MIN", "DOMAIN_MAX":
+			v, err := parseTriple(fields[1:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", fields[0], err)
+			}
+			if fields[0] == "DOMAIN_MIN" {
+				lut.domainMin = v
+			} else {
+				lut.domainMax = v
+			}
+		default:
+			if lut.size == 0 {
+				return nil, errors.New("LUT_3D_SIZE must precede table data")
+			}
+			v, err := parseTriple(fields)
+			if err != nil {
+				return nil, fmt.Errorf("invalid table entry %q: %w", line, err)
+			}
+			if len(lut.table) == cap(lut.table) {
+				return nil, errors.New("too many table entries")
+			}
+			lut.table = append(lut.table, v)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read LUT: %w", err)
+	}
+
+	if lut.size == 0 || len(lut.table) != lut.size*lut.size*lut.size {
+		return nil, fmt.Errorf("incomplete LUT: expected %d entries, got %d", lut.size*lut.size*lut.size, len(lut.table))
+	}
+
+	return lut, nil
+}
+
+// parseTriple parses three float fields.
+func parseTriple(fields []string) ([3]float64, error) {
+	var v [3]float64
+	if len(fields) != 3 {
+		return v, fmt.Errorf("expected 3 values, got %d", len(fields))
+	}
+
+	for i, f := range fields {
+		n, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return v, err
+		}
+		v[i] = n
+	}
+
+	return v, nil
+}
+
+// lookup returns the graded color for normalized input using trilinear interpolation.
+func (l *LUT) lookup(in [3]float64) [3]float64 {
+	var idx [3]int
+	var frac [3]float64
+
+	for c := 0; c < 3; c++ {
+		span := l.domainMax[c] - l.domainMin[c]
+		v := 0.0
+		if span > 0 {
+			v = (in[c] - l.domainMin[c]) / span
+		}
+		v = math.Max(0, math.Min(1, v)) * float64(l.size-1)
+
+		idx[c] = int(v)
+		if idx[c] >= l.size-1 {
+			idx[c] = l.size - 2
+		}
+		frac[c] = v - float64(idx[c])
+	}
+
+	at := func(r, g, b int) [3]float64 {
+		return l.table[r+g*l.size+b*l.size*l.size]
+	}
+
+	var out [3]float64
+	for c := 0; c < 3; c++ {
+		c00 := lerp(at(idx[0], idx[1], idx[2])[c], at(idx[0]+1, idx[1], idx[2])[c], frac[0])
+		c10 := lerp(at(idx[0], idx[1]+1, idx[2])[c], at(idx[0]+1, idx[1]+1, idx[2])[c], frac[0])
+		c01 := lerp(at(idx[0], idx[1], idx[2]+1)[c], at(idx[0]+1, idx[1], idx[2]+1)[c], frac[0])
+		c11 := lerp(at(idx[0], idx[1]+1, idx[2]+1)[c], at(idx[0]+1, idx[1]+1, idx[2]+1)[c], frac[0])
+		out[c] = lerp(lerp(c00, c10, frac[1]), lerp(c01, c11, frac[1]), frac[2])
+	}
+
+	return out
+}
+
+// lerp linearly interpolates between a and b.
+func lerp(a, b, t float64) float64 {
+	return a + (b-a)*t
+}
+
+// applyLUT grades the image with a previously uploaded 3D LUT.
+//
+// Supported params:
+//   - lut: ID of the LUT asset returned by the assets endpoint (required).
+//   - intensity: blend between the original (0) and graded (1) image (default 1).
+func (p *Processor) applyLUT(ctx context.Context, src image.Image, params map[string]string) (image.Image, error) {
+	id, err := uuid.Parse(params["lut"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid lut: %q", params["lut"])
+	}
+
+	intensity := 1.0
+	if v := params["intensity"]; v != "" {
+		intensity, err = strconv.ParseFloat(v, 64)
+		if err != nil || intensity < 0 || intensity > 1 {
+			return nil, fmt.Errorf("invalid intensity: %q", v)
+		}
+	}
+
+	lut, err := p.loadLUT(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	img := imaging.Clone(src)
+	for i := 0; i+3 < len(img.Pix); i += 4 {
+		in := [3]float64{
+			float64(img.Pix[i]) / 255,
+			float64(img.Pix[i+1]) / 255,
+			float64(img.Pix[i+2]) / 255,
+		}
+		out := lut.lookup(in)
+
+		for c := 0; c < 3; c++ {
+			img.Pix[i+c] = clamp8(lerp(in[c], out[c], intensity) * 255)
+		}
+	}
+
+	return img, nil
+}
+
+// loadLUT returns the LUT with the given ID, loading and caching it on first use.
+func (p *Processor) loadLUT(ctx context.Context, id uuid.UUID) (*LUT, error) {
+	p.lutsMu.Lock()
+	defer p.lutsMu.Unlock()
+
+	if lut, ok := p.luts[id]; ok {
+		return lut, nil
+	}
+
+	reader, err := p.fileStorage.Load(ctx, LUTPath(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load lut: %w", err)
+	}
+	defer reader.Close()
+
+	lut, err := ParseCube(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse lut: %w", err)
+	}
+
+	p.luts[id] = lut
+
+	return lut, nil
+}
+
+// LUTPath returns the storage path of the LUT asset with the given ID.
+func LUTPath(id uuid.UUID) string {
+	return LUTSubdir + "/" + id.String() + ".cube"
+}
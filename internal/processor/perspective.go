@@ -0,0 +1,182 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// point is a 2D point in image coordinates.
+type point struct {
+	x, y float64
+}
+
+// perspective warps the quadrilateral defined by four corners to a rectangle,
+// e.g. to flatten a photo of a whiteboard or a document.
+//
+// Supported params:
+//   - corners: "x1,y1,x2,y2,x3,y3,x4,y4" in the order top-left, top-right,
+//     bottom-right, bottom-left (required).
+//   - width, height: output size; derived from the quadrilateral edges if omitted.
+//
+// The output size is bounded by limits, like decoded images.
+func perspective(src image.Image, params map[string]string, limits Limits) (image.Image, error) {
+	quad, err := parseCorners(params["corners"])
+	if err != nil {
+		return nil, err
+	}
+
+	edgeWidth := math.Round(math.Max(dist(quad[0], quad[1]), dist(quad[3], quad[2])))
+	edgeHeight := math.Round(math.Max(dist(quad[0], quad[3]), dist(quad[1], quad[2])))
+	if edgeWidth > math.MaxInt32 || edgeHeight > math.MaxInt32 {
+		return nil, fmt.Errorf("%w: corners span %.0fx%.0f", ErrImageTooLarge, edgeWidth, edgeHeight)
+	}
+	width, height := int(edgeWidth), int(edgeHeight)
+
+	if v := params["width"]; v != "" {
+		if width, err = strconv.Atoi(v); err != nil || width <= 0 {
+			return nil, fmt.Errorf("invalid width: %q", v)
+		}
+	}
+	if v := params["height"]; v != "" {
+		if height, err = strconv.Atoi(v); err != nil || height <= 0 {
+			return nil, fmt.Errorf("invalid height: %q", v)
+		}
+	}
+	if width <= 0 || height <= 0 {
+		return nil, errors.New("degenerate corners")
+	}
+	if err := limits.check(image.Config{Width: width, Height: height}); err != nil {
+		return nil, err
+	}
+
+	// Map destination rectangle corners back to the source quadrilateral.
+	rect := [4]point{{0, 0}, {float64(width), 0}, {float64(width), float64(height)}, {0, float64(height)}}
+	h, err := homography(rect, quad)
+	if err != nil {
+		return nil, err
+	}
+
+	in := imaging.Clone(src)
+	out := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			fx, fy := float64(x)+0.5, float64(y)+0.5
+			w := h[6]*fx + h[7]*fy + 1
+			sx := (h[0]*fx + h[1]*fy + h[2]) / w
+			sy := (h[3]*fx + h[4]*fy + h[5]) / w
+
+			copy(out.Pix[y*out.Stride+x*4:], bilinear(in, sx-0.5, sy-0.5))
+		}
+	}
+
+	return out, nil
+}
+
+// parseCorners parses eight comma-separated coordinates into four points.
+func parseCorners(s string) ([4]point, error) {
+	var quad [4]point
+
+	parts := strings.Split(s, ",")
+	if len(parts) != 8 {
+		return quad, fmt.Errorf("invalid corners: expected 8 coordinates, got %d", len(parts))
+	}
+
+	var coords [8]float64
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return quad, fmt.Errorf("invalid corners: %v", err)
+		}
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return quad, fmt.Errorf("invalid corners: %q is not finite", p)
+		}
+		coords[i] = v
+	}
+
+	for i := range quad {
+		quad[i] = point{coords[i*2], coords[i*2+1]}
+	}
+
+	return quad, nil
+}
+
+// homography computes the 3x3 projective transform (with h[8] = 1) mapping
+// each of the from points to the corresponding to point.
+func homography(from, to [4]point) ([8]float64, error) {
+	var a [8][9]float64
+
+	for i := 0; i < 4; i++ {
+		x, y, u, v := from[i].x, from[i].y, to[i].x, to[i].y
+		a[i*2] = [9]float64{x, y, 1, 0, 0, 0, -u * x, -u * y, u}
+		a[i*2+1] = [9]float64{0, 0, 0, x, y, 1, -v * x, -v * y, v}
+	}
+
+	// Gaussian elimination with partial pivoting.
+	for col := 0; col < 8; col++ {
+		pivot := col
+		for row := col + 1; row < 8; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return [8]float64{}, errors.New("degenerate corners")
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+
+		for row := 0; row < 8; row++ {
+			if row == col {
+				continue
+			}
+			f := a[row][col] / a[col][col]
+			for k := col; k < 9; k++ {
+				a[row][k] -= f * a[col][k]
+			}
+		}
+	}
+
+	var h [8]float64
+	for i := range h {
+		h[i] = a[i][8] / a[i][i]
+	}
+
+	return h, nil
+}
+
+// bilinear samples the image at a fractional position, clamping to the edges.
+func bilinear(img *image.NRGBA, x, y float64) []uint8 {
+	b := img.Bounds()
+	maxX, maxY := b.Dx()-1, b.Dy()-1
+
+	x = math.Max(0, math.Min(float64(maxX), x))
+	y = math.Max(0, math.Min(float64(maxY), y))
+
+	x0, y0 := int(x), int(y)
+	x1, y1 := min(x0+1, maxX), min(y0+1, maxY)
+	dx, dy := x-float64(x0), y-float64(y0)
+
+	px := func(x, y, c int) float64 {
+		return float64(img.Pix[y*img.Stride+x*4+c])
+	}
+
+	res := make([]uint8, 4)
+	for c := 0; c < 4; c++ {
+		top := px(x0, y0, c)*(1-dx) + px(x1, y0, c)*dx
+		bottom := px(x0, y1, c)*(1-dx) + px(x1, y1, c)*dx
+		res[c] = clamp8(top*(1-dy) + bottom*dy)
+	}
+
+	return res
+}
+
+// dist returns the euclidean distance between two points.
+func dist(a, b point) float64 {
+	return math.Hypot(a.x-b.x, a.y-b.y)
+}
@@ -0,0 +1,77 @@
+package processor
+
+import (
+	"errors"
+	"image"
+	"strings"
+	"testing"
+)
+
+func TestPerspectiveRejectsNonFiniteCorners(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+
+	for _, corners := range []string{
+		"NaN,0,10,0,10,10,0,10",
+		"0,0,Inf,0,10,10,0,10",
+		"0,0,10,0,10,-Inf,0,10",
+		"0,0,10,0,10,10,0,1e400",
+	} {
+		_, err := perspective(src, map[string]string{"corners": corners}, Limits{})
+		if err == nil || !strings.Contains(err.Error(), "invalid corners") {
+			t.Errorf("corners %q: got error %v, want invalid corners", corners, err)
+		}
+	}
+}
+
+func TestPerspectiveBoundsOutputSize(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	limits := Limits{MaxWidth: 100, MaxHeight: 100, MaxPixels: 5000}
+
+	tests := []struct {
+		name   string
+		params map[string]string
+		limits Limits
+		want   error
+	}{
+		{
+			name:   "within limits",
+			params: map[string]string{"corners": "0,0,10,0,10,10,0,10", "width": "50", "height": "50"},
+			limits: limits,
+		},
+		{
+			name:   "width over limit",
+			params: map[string]string{"corners": "0,0,10,0,10,10,0,10", "width": "101", "height": "10"},
+			limits: limits,
+			want:   ErrImageTooLarge,
+		},
+		{
+			name:   "pixels over limit",
+			params: map[string]string{"corners": "0,0,10,0,10,10,0,10", "width": "100", "height": "100"},
+			limits: limits,
+			want:   ErrImageTooLarge,
+		},
+		{
+			name:   "corners spanning over limit",
+			params: map[string]string{"corners": "0,0,1000,0,1000,1000,0,1000"},
+			limits: limits,
+			want:   ErrImageTooLarge,
+		},
+		{
+			name:   "corners spanning over int range without limits",
+			params: map[string]string{"corners": "0,0,1e300,0,1e300,1e300,0,1e300"},
+			want:   ErrImageTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := perspective(src, tt.params, tt.limits)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got error %v, want %v", err, tt.want)
+			}
+			if tt.want == nil && out.Bounds().Dx() != 50 {
+				t.Errorf("got width %d, want 50", out.Bounds().Dx())
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+
+	"github.com/aliskhannn/image-processor/internal/model"
+)
+
+var (
+	// ErrActionNotAllowed is returned for actions unknown or not enabled by the ActionPolicy.
+	ErrActionNotAllowed = errors.New("action not allowed")
+	// ErrParamOutOfBounds is returned for parameters that are not integers or fall outside the bounds of the ActionPolicy.
+	ErrParamOutOfBounds = errors.New("parameter out of bounds")
+)
+
+// Bounds limits the values of an integer parameter. Zero disables a bound; values below 1 are
+// refused regardless, as widths, heights and qualities are all positive.
+type Bounds struct {
+	Min int
+	Max int
+}
+
+// check returns ErrParamOutOfBounds if the parameter is set and not a positive integer within the bounds.
+func (b Bounds) check(name, v string) error {
+	if v == "" {
+		return nil
+	}
+
+	n, err := strconv.Atoi(v)
+	switch {
+	case err != nil:
+		return fmt.Errorf("%w: %s must be an integer, got %q", ErrParamOutOfBounds, name, v)
+	case n < max(b.Min, 1):
+		return fmt.Errorf("%w: %s must be at least %d, got %d", ErrParamOutOfBounds, name, max(b.Min, 1), n)
+	case b.Max > 0 && n > b.Max:
+		return fmt.Errorf("%w: %s must be at most %d, got %d", ErrParamOutOfBounds, name, b.Max, n)
+	default:
+		return nil
+	}
+}
+
+// fontChecker defines the interface for checking the fonts named by actions.
+type fontChecker interface {
+	Check(name string) error
+}
+
+// ActionPolicy restricts the actions accepted for processing, the values of their width, height
+// and quality parameters and the fonts they name, so that requests are refused before anything
+// is stored.
+type ActionPolicy struct {
+	enabled map[string]bool
+	width   Bounds
+	height  Bounds
+	quality Bounds
+	fonts   fontChecker
+}
+
+// NewActionPolicy creates a policy enabling the named actions, or all of them if none is named,
+// with the bounds of the width, height and quality parameters and the fonts text is drawn with.
+// It returns an error if an action is unknown.
+func NewActionPolicy(enabled []string, width, height, quality Bounds, fonts fontChecker) (*ActionPolicy, error) {
+	if len(enabled) == 0 {
+		enabled = slices.Collect(maps.Keys(outputs))
+	}
+
+	p := &ActionPolicy{
+		enabled: make(map[string]bool, len(enabled)),
+		width:   width,
+		height:  height,
+		quality: quality,
+		fonts:   fonts,
+	}
+	for _, name := range enabled {
+		if _, ok := outputs[name]; !ok {
+			return nil, fmt.Errorf("unknown action %q", name)
+		}
+		p.enabled[name] = true
+	}
+
+	return p, nil
+}
+
+// Check returns ErrActionNotAllowed if the action is not enabled, ErrParamOutOfBounds if
+// one of its width, height or quality parameters is out of bounds, or the error of the font
+// it names, e.g. fonts.ErrUnknownFont.
+func (p *ActionPolicy) Check(action model.Action) error {
+	if !p.enabled[action.Name] {
+		return fmt.Errorf("%w: %q", ErrActionNotAllowed, action.Name)
+	}
+
+	if err := p.width.check("width", action.Params["width"]); err != nil {
+		return err
+	}
+	if err := p.height.check("height", action.Params["height"]); err != nil {
+		return err
+	}
+
+	if name := action.Params["font"]; name != "" {
+		if err := p.fonts.Check(name); err != nil {
+			return fmt.Errorf("invalid font: %w", err)
+		}
+	}
+
+	if _, err := jpegQuality(action.Params); err != nil {
+		return fmt.Errorf("%w: %v", ErrParamOutOfBounds, err)
+	}
+
+	return p.quality.check("quality", action.Params["quality"])
+}
@@ -1,181 +1,366 @@
 package processor
 
 import (
-	"bytes"
+	"cmp"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"image"
 	"image/color"
 	"io"
+	"path"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/disintegration/imaging"
 	"github.com/fogleman/gg"
+	"github.com/google/uuid"
+	"golang.org/x/image/font"
 
+	"github.com/aliskhannn/image-processor/internal/errreport"
+	"github.com/aliskhannn/image-processor/internal/logging"
+	"github.com/aliskhannn/image-processor/internal/metrics"
 	"github.com/aliskhannn/image-processor/internal/model"
+	"github.com/aliskhannn/image-processor/internal/reload"
+	"github.com/aliskhannn/image-processor/internal/storage"
 )
 
-const defaultFontPath = "internal/assets/fonts/DejaVuSans.ttf"
+// Defaults of the watermark action, used when Watermark leaves them unset.
+const (
+	defaultWatermarkText  = "Watermark"
+	defaultWatermarkScale = 0.05 // 5% of the image width
+)
+
+// defaultJPEGQuality is the quality of the JPEG results of actions without a quality parameter.
+const defaultJPEGQuality = 95
+
+// Watermark holds the defaults of the watermark action for jobs not setting a text or font.
+// Zero values fall back to the built-in defaults.
+type Watermark struct {
+	Text  string
+	Font  string  // name of the font of the registry
+	Scale float64 // font size as a share of the image width
+}
+
+// output describes where and in which format the result of an action is saved.
+type output struct {
+	subdir string
+	format imaging.Format
+}
+
+// outputs maps each supported action to the storage subdirectory and
+// encoding format of its results.
+var outputs = map[string]output{
+	"resize":             {subdir: "resized", format: imaging.JPEG},
+	"thumbnail":          {subdir: "thumbnails", format: imaging.JPEG},
+	"watermark":          {subdir: "watermarked", format: imaging.JPEG},
+	"auto_levels":        {subdir: "leveled", format: imaging.JPEG},
+	"deskew":             {subdir: "deskewed", format: imaging.JPEG},
+	"perspective":        {subdir: "perspective", format: imaging.JPEG},
+	"chroma_key":         {subdir: "keyed", format: imaging.PNG},
+	"lut":                {subdir: "graded", format: imaging.JPEG},
+	"forensic_watermark": {subdir: "traced", format: imaging.JPEG},
+	"qr_overlay":         {subdir: "qr", format: imaging.JPEG},
+}
+
+// extensions maps encoding formats to the file extensions of saved results.
+var extensions = map[imaging.Format]string{
+	imaging.JPEG: ".jpg",
+	imaging.PNG:  ".png",
+}
+
+// fontRegistry defines the interface for loading the font faces text is drawn with.
+type fontRegistry interface {
+	Face(name string, size float64) (font.Face, error)
+}
 
-// fileStorage defines the interface for file storage.
-// It allows saving and loading files from a backend (e.g., local FS, S3, MinIO).
-type fileStorage interface {
-	Save(ctx context.Context, subdir, filename string, src io.Reader) (string, error)
-	Load(ctx context.Context, path string) (io.ReadCloser, error)
+// stageTracker defines the interface for reporting processing stages of an image.
+type stageTracker interface {
+	UpdateStage(ctx context.Context, id uuid.UUID, stage string) error
 }
 
 // Processor is responsible for executing image processing tasks
 // such as resize, thumbnail generation, and watermarking.
 type Processor struct {
-	fileStorage fileStorage
+	fileStorage storage.Storage
+	stages      stageTracker
+	limits      Limits
+	watermarks  *reload.Value[Watermark]
+	fonts       fontRegistry
+
+	lutsMu sync.Mutex
+	luts   map[uuid.UUID]*LUT // parsed LUT assets by ID
 }
 
-// New creates a new Processor with the given file storage backend.
-func New(fs fileStorage) *Processor {
-	return &Processor{fileStorage: fs}
+// New creates a new Processor with the given file storage backend, tracker the processing
+// stages are reported to, decoding limits, watermark defaults and registry of the fonts text is drawn with.
+func New(fs storage.Storage, st stageTracker, limits Limits, wm *reload.Value[Watermark], fonts fontRegistry) *Processor {
+	return &Processor{
+		fileStorage: fs,
+		stages:      st,
+		limits:      limits,
+		watermarks:  wm,
+		fonts:       fonts,
+		luts:        make(map[uuid.UUID]*LUT),
+	}
 }
 
-// Process iterates over all actions defined in the Task and
-// calls the appropriate processing method.
-func (p *Processor) Process(ctx context.Context, img model.Image) (model.Image, error) {
-	switch img.Action.Name {
-	case "resize":
-		return p.resize(ctx, img)
-	case "thumbnail":
-		return p.thumbnail(ctx, img)
-	case "watermark":
-		return p.watermark(ctx, img)
-	default:
+// Process loads the original image, applies the action defined in the image
+// and saves the result to the subdirectory of that action. Panics of the decoders and actions,
+// e.g. on malformed images, are reported to the error reporting service and returned as errors.
+func (p *Processor) Process(ctx context.Context, img model.Image) (_ model.Image, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			errreport.Panic(ctx, v)
+			err = fmt.Errorf("panic while processing %s image: %v", img.Action.Name, v)
+		}
+	}()
+
+	out, ok := outputs[img.Action.Name]
+	if !ok {
 		return model.Image{}, fmt.Errorf("unknown task action: %s", img.Action.Name)
 	}
-}
-
-// resize resizes the image to the specified width and height.
-func (p *Processor) resize(ctx context.Context, img model.Image) (model.Image, error) {
-	params := img.Action.Params
-
-	width, err := strconv.Atoi(params["width"])
+	quality, err := jpegQuality(img.Action.Params)
 	if err != nil {
-		return model.Image{}, fmt.Errorf("invalid width: %v", err)
-	}
-	height, err := strconv.Atoi(params["height"])
-	if err != nil {
-		return model.Image{}, fmt.Errorf("invalid height: %v", err)
+		return model.Image{}, err
 	}
 
 	// Load the original image from storage.
+	start := time.Now()
 	srcReader, err := p.fileStorage.Load(ctx, img.Path)
 	if err != nil {
 		return model.Image{}, fmt.Errorf("failed to load original image: %w", err)
 	}
 	defer srcReader.Close()
 
-	// Decode into an image object.
-	image, err := imaging.Decode(srcReader)
+	// Decode into an image object, refusing images over the dimension limits.
+	src, err := p.Decode(srcReader)
 	if err != nil {
-		return model.Image{}, fmt.Errorf("failed to decode image: %w", err)
+		return model.Image{}, err
 	}
+	metrics.ObservePhase(img.Action.Name, metrics.PhaseDecode, start)
 
-	// Perform resizing.
-	resized := imaging.Resize(image, width, height, imaging.Lanczos)
+	p.reportStage(ctx, img.ID, model.StageProcessing)
 
-	// Encode resized image into buffer for storage.
-	buf := bytes.NewBuffer(nil)
-	if err := imaging.Encode(buf, resized, imaging.JPEG); err != nil {
-		return model.Image{}, fmt.Errorf("failed to encode resized image: %w", err)
+	start = time.Now()
+	result, err := p.Apply(ctx, src, img.Action)
+	if err != nil {
+		return model.Image{}, err
 	}
+	metrics.ObservePhase(img.Action.Name, metrics.PhaseProcess, start)
+
+	p.reportStage(ctx, img.ID, model.StageUploading)
 
-	// Save resized version.
-	dst, err := p.fileStorage.Save(ctx, "resized", img.Filename, buf)
+	// Save processed version, streaming its encoding to storage.
+	dst := path.Join(out.subdir, ResultName(img.ID, img.Action, out.format))
+	size, err := p.saveEncoded(ctx, dst, result, out.format, quality, img.Action.Name)
 	if err != nil {
-		return model.Image{}, fmt.Errorf("failed to save resized image: %w", err)
+		return model.Image{}, fmt.Errorf("failed to save %s image: %w", img.Action.Name, err)
 	}
 
 	img.Path = dst
-	img.Status = "processed"
+	img.Size = size
+	img.Format = strings.ToLower(out.format.String())
+	info := infoOf(result)
+	img.Width, img.Height, img.ColorSpace = info.Width, info.Height, info.ColorSpace
+	img.Status = model.StatusProcessed
 
 	return img, nil
 }
 
-// thumbnail generates a small thumbnail of the image.
-func (p *Processor) thumbnail(ctx context.Context, img model.Image) (model.Image, error) {
-	params := img.Action.Params
-
-	width, err := strconv.Atoi(params["width"])
+// saveEncoded encodes the image in the format, with the quality for JPEG, and streams the encoding
+// to storage under dst, so that large results are never held in memory as a whole. It returns the stored size.
+// The encode and save phases are timed for the action; as they overlap, the encode phase
+// includes waiting for storage to take the encoding.
+func (p *Processor) saveEncoded(
+	ctx context.Context, dst string, img image.Image, format imaging.Format, quality int, action string,
+) (int64, error) {
+	start := time.Now()
+
+	n, err := p.saveStreamed(ctx, dst, ContentType(strings.ToLower(format.String())), func(w io.Writer) error {
+		if err := imaging.Encode(w, img, format, imaging.JPEGQuality(quality)); err != nil {
+			return err
+		}
+		metrics.ObservePhase(action, metrics.PhaseEncode, start)
+		return nil
+	})
 	if err != nil {
-		return model.Image{}, fmt.Errorf("invalid width: %v", err)
+		return 0, err
 	}
-	height, err := strconv.Atoi(params["height"])
+	metrics.ObservePhase(action, metrics.PhaseSave, start)
+
+	return n, nil
+}
+
+// saveStreamed runs encode in the background and streams what it writes to storage under dst, with
+// the content type. It returns the stored size.
+func (p *Processor) saveStreamed(
+	ctx context.Context, dst, contentType string, encode func(w io.Writer) error,
+) (int64, error) {
+	pr, pw := io.Pipe()
+	cw := &countingWriter{w: pw}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := encode(cw); err != nil {
+			pw.CloseWithError(fmt.Errorf("failed to encode image: %w", err))
+			return
+		}
+		pw.Close()
+	}()
+
+	err := p.fileStorage.Save(ctx, dst, pr, contentType)
+	// Unblock the encoder if storage stopped reading early.
+	pr.CloseWithError(io.ErrClosedPipe)
+	<-done
+
 	if err != nil {
-		return model.Image{}, fmt.Errorf("invalid height: %v", err)
+		return 0, err
 	}
 
-	// Load the original image.
-	srcReader, err := p.fileStorage.Load(ctx, img.Path)
-	if err != nil {
-		return model.Image{}, fmt.Errorf("failed to load original image: %w", err)
+	return cw.n, nil
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+// Write writes p to the underlying writer and counts the written bytes.
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// reportStage reports the processing stage of an image.
+// Stage reporting is informational, so failures are only logged.
+func (p *Processor) reportStage(ctx context.Context, id uuid.UUID, stage string) {
+	if err := p.stages.UpdateStage(ctx, id, stage); err != nil {
+		logging.ForImage(ctx, id).Err(err).Str("stage", stage).Msg("failed to report stage")
 	}
-	defer srcReader.Close()
+}
 
-	// Decode into an image object.
-	image, err := imaging.Decode(srcReader)
-	if err != nil {
-		return model.Image{}, fmt.Errorf("failed to decode image: %w", err)
+// Apply performs a single action on an already decoded image and returns the result.
+// It does not save anything to storage, so it can be used for in-memory pipelines and previews.
+func (p *Processor) Apply(ctx context.Context, src image.Image, action model.Action) (image.Image, error) {
+	switch action.Name {
+	case "resize":
+		return resize(src, action.Params)
+	case "thumbnail":
+		return thumbnail(src, action.Params)
+	case "watermark":
+		return p.watermark(src, action.Params)
+	case "auto_levels":
+		return autoLevels(src, action.Params)
+	case "deskew":
+		return deskew(src, action.Params)
+	case "perspective":
+		return perspective(src, action.Params, p.limits)
+	case "chroma_key":
+		return chromaKey(src, action.Params)
+	case "lut":
+		return p.applyLUT(ctx, src, action.Params)
+	case "forensic_watermark":
+		return forensicWatermark(src, action.Params)
+	case "qr_overlay":
+		return qrOverlay(src, action.Params)
+	default:
+		return nil, fmt.Errorf("unknown task action: %s", action.Name)
 	}
+}
+
+// ResultName returns the filename of a processed image: the ID of the original followed by a
+// fingerprint of the action and its parameters, so that variants of an image made by different
+// jobs never overwrite each other, while redeliveries of a job overwrite their own result.
+func ResultName(id uuid.UUID, action model.Action, format imaging.Format) string {
+	// Params are marshaled with sorted keys, so equal actions get equal fingerprints.
+	data, _ := json.Marshal(action)
+	sum := sha256.Sum256(data)
 
-	// Generate thumbnail.
-	thumb := imaging.Thumbnail(image, width, height, imaging.Lanczos)
+	return id.String() + "-" + action.Name + "-" + hex.EncodeToString(sum[:6]) + extensions[format]
+}
+
+// jpegQuality parses the optional quality parameter of an action, from 1 to 100,
+// applied to its JPEG results.
+func jpegQuality(params map[string]string) (int, error) {
+	v := params["quality"]
+	if v == "" {
+		return defaultJPEGQuality, nil
+	}
 
-	// Encode resized image into buffer for storage.
-	buf := bytes.NewBuffer(nil)
-	if err := imaging.Encode(buf, thumb, imaging.JPEG); err != nil {
-		return model.Image{}, fmt.Errorf("failed to encode thumbnail: %w", err)
+	quality, err := strconv.Atoi(v)
+	if err != nil || quality < 1 || quality > 100 {
+		return 0, fmt.Errorf("invalid quality: %q, expected 1 to 100", v)
 	}
 
-	// Save thumbnail.
-	dst, err := p.fileStorage.Save(ctx, "thumbnails", img.Filename, buf)
+	return quality, nil
+}
+
+// dimensions parses the width and height parameters of an action, both at least 1.
+func dimensions(params map[string]string) (int, int, error) {
+	width, err := strconv.Atoi(params["width"])
 	if err != nil {
-		return model.Image{}, fmt.Errorf("failed to save thumbnail: %w", err)
+		return 0, 0, fmt.Errorf("invalid width: %v", err)
+	}
+	height, err := strconv.Atoi(params["height"])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid height: %v", err)
+	}
+	if width < 1 || height < 1 {
+		return 0, 0, fmt.Errorf("invalid dimensions %dx%d: width and height must be at least 1", width, height)
 	}
 
-	img.Path = dst
-	img.Status = "processed"
+	return width, height, nil
+}
 
-	return img, nil
+// resize resizes the image to the specified width and height.
+func resize(src image.Image, params map[string]string) (image.Image, error) {
+	width, height, err := dimensions(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return imaging.Resize(src, width, height, imaging.Lanczos), nil
+}
+
+// thumbnail generates a small thumbnail of the image.
+func thumbnail(src image.Image, params map[string]string) (image.Image, error) {
+	width, height, err := dimensions(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return imaging.Thumbnail(src, width, height, imaging.Lanczos), nil
 }
 
 // watermark adds a watermark text to the image.
 // For simplicity, the watermark will be placed in the bottom-right corner.
-func (p *Processor) watermark(ctx context.Context, img model.Image) (model.Image, error) {
-	params := img.Action.Params
+func (p *Processor) watermark(src image.Image, params map[string]string) (image.Image, error) {
+	defaults := p.watermarks.Load()
 
 	text := params["text"]
 	if text == "" {
-		text = "Watermark"
-	}
-
-	// Load the original image.
-	srcReader, err := p.fileStorage.Load(ctx, img.Path)
-	if err != nil {
-		return model.Image{}, fmt.Errorf("failed to load original image: %w", err)
-	}
-	defer srcReader.Close()
-
-	// Decode into an image object.
-	image, err := imaging.Decode(srcReader)
-	if err != nil {
-		return model.Image{}, fmt.Errorf("failed to decode image: %w", err)
+		text = cmp.Or(defaults.Text, defaultWatermarkText)
 	}
 
 	// Draw watermark text on top of the image.
-	dc := gg.NewContextForImage(image)
+	dc := gg.NewContextForImage(src)
 	dc.SetColor(color.White)
 
-	fontSize := float64(dc.Width()) * 0.05 // 5% of the image width
+	fontSize := float64(dc.Width()) * cmp.Or(defaults.Scale, defaultWatermarkScale)
 
-	err = dc.LoadFontFace(defaultFontPath, fontSize)
+	face, err := p.fonts.Face(cmp.Or(params["font"], defaults.Font), fontSize)
 	if err != nil {
-		return model.Image{}, fmt.Errorf("failed to load font: %w", err)
+		return nil, fmt.Errorf("failed to load font: %w", err)
 	}
+	dc.SetFontFace(face)
 
 	tw, th := dc.MeasureString(text) // calculate font size
 
@@ -186,20 +371,5 @@ func (p *Processor) watermark(ctx context.Context, img model.Image) (model.Image
 	dc.DrawStringAnchored(text, x, y, 1, 1) // bottom-right corner
 	dc.Fill()
 
-	// Encode modified image.
-	buf := new(bytes.Buffer)
-	if err := imaging.Encode(buf, dc.Image(), imaging.JPEG); err != nil {
-		return model.Image{}, fmt.Errorf("failed to encode watermarked image: %w", err)
-	}
-
-	// Save watermarked version.
-	dst, err := p.fileStorage.Save(ctx, "watermarked", img.Filename, buf)
-	if err != nil {
-		return model.Image{}, fmt.Errorf("failed to save watermarked image: %w", err)
-	}
-
-	img.Path = dst
-	img.Status = "processed"
-
-	return img, nil
+	return dc.Image(), nil
 }
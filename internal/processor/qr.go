@@ -0,0 +1,73 @@
+package processor
+
+import (
+	"fmt"
+	"image"
+	"net/url"
+	"strconv"
+
+	"github.com/disintegration/imaging"
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	defaultQRCorner = "bottom-right"
+	defaultQRScale  = 0.2 // share of the shorter image side used by the code
+	defaultQRMargin = 10
+)
+
+// qrOverlay generates a QR code from a URL and composites it in a corner of the image.
+//
+// Supported params:
+//   - url: absolute http(s) URL to encode (required).
+//   - corner: top-left, top-right, bottom-left or bottom-right (default).
+//   - size: side of the code in pixels (default 20% of the shorter image side).
+//   - margin: distance from the image edges in pixels (default 10).
+func qrOverlay(src image.Image, params map[string]string) (image.Image, error) {
+	u, err := url.Parse(params["url"])
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid url: %q", params["url"])
+	}
+
+	b := src.Bounds()
+
+	size := int(float64(min(b.Dx(), b.Dy())) * defaultQRScale)
+	if v := params["size"]; v != "" {
+		if size, err = strconv.Atoi(v); err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid size: %q", v)
+		}
+	}
+
+	margin := defaultQRMargin
+	if v := params["margin"]; v != "" {
+		if margin, err = strconv.Atoi(v); err != nil || margin < 0 {
+			return nil, fmt.Errorf("invalid margin: %q", v)
+		}
+	}
+
+	if size+2*margin > b.Dx() || size+2*margin > b.Dy() {
+		return nil, fmt.Errorf("qr code of size %d does not fit the image", size)
+	}
+
+	qr, err := qrcode.New(u.String(), qrcode.Medium)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate qr code: %w", err)
+	}
+	code := qr.Image(size)
+
+	var pos image.Point
+	switch corner := valueOr(params["corner"], defaultQRCorner); corner {
+	case "top-left":
+		pos = image.Pt(margin, margin)
+	case "top-right":
+		pos = image.Pt(b.Dx()-size-margin, margin)
+	case "bottom-left":
+		pos = image.Pt(margin, b.Dy()-size-margin)
+	case "bottom-right":
+		pos = image.Pt(b.Dx()-size-margin, b.Dy()-size-margin)
+	default:
+		return nil, fmt.Errorf("invalid corner: %q", corner)
+	}
+
+	return imaging.Overlay(src, code, pos, 1), nil
+}
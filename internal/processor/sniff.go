@@ -0,0 +1,64 @@
+package processor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrUnsupportedFormat is returned when an uploaded file is not an image in a supported format.
+var ErrUnsupportedFormat = errors.New("unsupported image format")
+
+// sniffLen is the number of leading bytes inspected by Sniff.
+const sniffLen = 12
+
+// signatures maps magic byte prefixes to the image formats accepted for upload.
+// WebP is checked separately since its magic is split around the RIFF chunk size.
+var signatures = []struct {
+	format string
+	magic  []byte
+}{
+	{format: "jpeg", magic: []byte{0xFF, 0xD8, 0xFF}},
+	{format: "png", magic: []byte("\x89PNG\r\n\x1a\n")},
+	{format: "gif", magic: []byte("GIF87a")},
+	{format: "gif", magic: []byte("GIF89a")},
+	{format: "bmp", magic: []byte("BM")},
+	{format: "tiff", magic: []byte("II*\x00")},
+	{format: "tiff", magic: []byte("MM\x00*")},
+}
+
+// Sniff detects the image format of r from its magic bytes, ignoring any client-supplied
+// content type. It returns the format name and a reader that replays the inspected bytes
+// followed by the rest of r. Non-image payloads are rejected with ErrUnsupportedFormat.
+func Sniff(r io.Reader) (string, io.Reader, error) {
+	head := make([]byte, sniffLen)
+
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", nil, fmt.Errorf("failed to read file header: %w", err)
+	}
+	head = head[:n]
+
+	format := sniffFormat(head)
+	if format == "" {
+		return "", nil, ErrUnsupportedFormat
+	}
+
+	return format, io.MultiReader(bytes.NewReader(head), r), nil
+}
+
+// sniffFormat returns the image format matching the leading bytes, or "" if none does.
+func sniffFormat(head []byte) string {
+	if len(head) >= 12 && bytes.Equal(head[:4], []byte("RIFF")) && bytes.Equal(head[8:12], []byte("WEBP")) {
+		return "webp"
+	}
+
+	for _, sig := range signatures {
+		if bytes.HasPrefix(head, sig.magic) {
+			return sig.format
+		}
+	}
+
+	return ""
+}
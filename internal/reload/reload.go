@@ -0,0 +1,28 @@
+// Package reload holds the settings that can be replaced while the app runs, e.g. when the
+// configuration is reloaded, without recreating the components using them.
+package reload
+
+import "sync/atomic"
+
+// Value holds a setting read by concurrent users and replaced as a whole.
+type Value[T any] struct {
+	p atomic.Pointer[T]
+}
+
+// NewValue creates a holder of the setting v.
+func NewValue[T any](v T) *Value[T] {
+	var rv Value[T]
+	rv.Store(v)
+
+	return &rv
+}
+
+// Load returns the current setting.
+func (v *Value[T]) Load() T {
+	return *v.p.Load()
+}
+
+// Store replaces the setting, for the next Load.
+func (v *Value[T]) Store(x T) {
+	v.p.Store(&x)
+}
@@ -0,0 +1,74 @@
+//go:build integration
+
+package image
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/aliskhannn/image-processor/internal/model"
+)
+
+// TestListChangesCommitOrder records a change in a transaction left open while another one is
+// recorded, and checks that a reader following the cursor sees both, numbered in commit order.
+func TestListChangesCommitOrder(t *testing.T) {
+	r := newTestRepository(t)
+	ctx := testContext(t)
+
+	slowCtx, slow := beginTx(t, r)
+	slowID, err := r.SaveImage(slowCtx, testImage("slow"))
+	if err != nil {
+		t.Fatalf("save slow image: %v", err)
+	}
+
+	// The fast change waits for the slow transaction holding the counter row.
+	type saved struct {
+		id  uuid.UUID
+		err error
+	}
+	fast := make(chan saved, 1)
+	go func() {
+		id, err := r.SaveImage(ctx, testImage("fast"))
+		fast <- saved{id, err}
+	}()
+
+	// Reading writes nothing, so it neither waits for the writers nor sees uncommitted changes.
+	changes, err := r.ListChanges(ctx, 0, 100)
+	if err != nil {
+		t.Fatalf("list changes: %v", err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("first read: got changes of %v, want none", changedImages(changes))
+	}
+
+	if err := slow.Commit(); err != nil {
+		t.Fatalf("commit slow transaction: %v", err)
+	}
+	res := <-fast
+	if res.err != nil {
+		t.Fatalf("save fast image: %v", res.err)
+	}
+
+	changes, err = r.ListChanges(ctx, 0, 100)
+	if err != nil {
+		t.Fatalf("list changes: %v", err)
+	}
+	if got := changedImages(changes); !slices.Equal(got, []uuid.UUID{slowID, res.id}) {
+		t.Fatalf("second read: got changes of %v, want %v then %v", got, slowID, res.id)
+	}
+	if changes[0].Seq >= changes[1].Seq {
+		t.Fatalf("second read: got seqs %d and %d, want increasing", changes[0].Seq, changes[1].Seq)
+	}
+}
+
+// changedImages returns the IDs of the images of changes, in order.
+func changedImages(changes []model.Change) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(changes))
+	for _, ch := range changes {
+		ids = append(ids, ch.ImageID)
+	}
+
+	return ids
+}
@@ -0,0 +1,83 @@
+//go:build integration
+
+package image
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/aliskhannn/image-processor/internal/model"
+)
+
+// TestSoftDeleteImage checks on every backend that soft-deleting an original hides it and its
+// derived images from reads, and lists them for purging, derived images first, once deleted.
+func TestSoftDeleteImage(t *testing.T) {
+	for name, newStore := range testStores() {
+		t.Run(name, func(t *testing.T) {
+			r := newStore(t)
+			ctx := testContext(t)
+
+			original := testImage("deleted")
+			originalID, err := r.SaveImage(ctx, original)
+			if err != nil {
+				t.Fatalf("save original: %v", err)
+			}
+			derivedIDs, err := r.SaveDerivedImages(ctx, []model.Image{testDerived(original, originalID, "resize")})
+			if err != nil {
+				t.Fatalf("save derived image: %v", err)
+			}
+			keptID, err := r.SaveImage(ctx, testImage("kept"))
+			if err != nil {
+				t.Fatalf("save kept image: %v", err)
+			}
+
+			if err := r.SoftDeleteImage(ctx, originalID); err != nil {
+				t.Fatalf("SoftDeleteImage() error = %v", err)
+			}
+			if err := r.SoftDeleteImage(ctx, originalID); !errors.Is(err, ErrImageNotFound) {
+				t.Errorf("SoftDeleteImage() again error = %v, want ErrImageNotFound", err)
+			}
+
+			for _, id := range []uuid.UUID{originalID, derivedIDs[0]} {
+				if _, err := r.GetImage(ctx, id); !errors.Is(err, ErrImageNotFound) {
+					t.Errorf("GetImage(%v) of deleted image error = %v, want ErrImageNotFound", id, err)
+				}
+			}
+			if _, err := r.GetImage(ctx, keptID); err != nil {
+				t.Errorf("GetImage() of kept image error = %v", err)
+			}
+
+			if deleted, err := r.ListDeleted(ctx, time.Now().Add(-time.Hour), 10); err != nil || len(deleted) != 0 {
+				t.Fatalf("ListDeleted() before the deletion = %d images, %v; want none", len(deleted), err)
+			}
+
+			deleted, err := r.ListDeleted(ctx, time.Now().Add(time.Hour), 10)
+			if err != nil {
+				t.Fatalf("ListDeleted() error = %v", err)
+			}
+			var listed []uuid.UUID
+			for _, img := range deleted {
+				listed = append(listed, img.ID)
+				if img.DeletedAt == nil {
+					t.Errorf("listed image %v has no deletion time", img.ID)
+				}
+			}
+			if want := []uuid.UUID{derivedIDs[0], originalID}; len(listed) != 2 || listed[0] != want[0] || listed[1] != want[1] {
+				t.Fatalf("ListDeleted() = %v, want %v", listed, want)
+			}
+
+			// Purging deletes the records for good.
+			for _, id := range listed {
+				if err := r.DeleteImage(ctx, id); err != nil {
+					t.Fatalf("DeleteImage(%v) error = %v", id, err)
+				}
+			}
+			if deleted, err := r.ListDeleted(ctx, time.Now().Add(time.Hour), 10); err != nil || len(deleted) != 0 {
+				t.Errorf("ListDeleted() after purging = %d images, %v; want none", len(deleted), err)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+//go:build integration
+
+package image
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+
+	"github.com/aliskhannn/image-processor/internal/model"
+)
+
+// testMySQLDSNEnv names the environment variable holding the DSN of the MySQL server the
+// integration tests create their databases on, with the options NewMySQLRepository requires, e.g.
+//
+//	TEST_MYSQL_DSN="root:root@tcp(localhost:3306)/?parseTime=true&clientFoundRows=true&multiStatements=true&loc=UTC&time_zone=%27%2B00%3A00%27"
+const testMySQLDSNEnv = "TEST_MYSQL_DSN"
+
+// testStores returns constructors of a store on a database of its own for every backend. The
+// PostgreSQL and MySQL stores are skipped without their DSNs; the SQLite one always runs.
+func testStores() map[string]func(t *testing.T) Store {
+	return map[string]func(t *testing.T) Store{
+		"postgres": func(t *testing.T) Store { return newTestRepository(t) },
+		"mysql":    newTestMySQLRepository,
+		"sqlite":   newTestSQLiteRepository,
+	}
+}
+
+// newTestSQLiteRepository returns a repository on a SQLite database in a temporary directory.
+func newTestSQLiteRepository(t *testing.T) Store {
+	t.Helper()
+
+	r, err := NewSQLiteRepository(testContext(t), filepath.Join(t.TempDir(), "images.db"))
+	if err != nil {
+		t.Fatalf("open sqlite repository: %v", err)
+	}
+	t.Cleanup(func() { _ = r.Close() })
+
+	return r
+}
+
+// newTestMySQLRepository returns a repository on a MySQL database of its own, dropped once the
+// test finishes. The test is skipped without TEST_MYSQL_DSN.
+func newTestMySQLRepository(t *testing.T) Store {
+	t.Helper()
+
+	dsn := os.Getenv(testMySQLDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s not set", testMySQLDSNEnv)
+	}
+
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("parse %s: %v", testMySQLDSNEnv, err)
+	}
+
+	admin, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatalf("open admin connection: %v", err)
+	}
+	t.Cleanup(func() { _ = admin.Close() })
+
+	cfg.DBName = "image_processor_test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	if _, err := admin.Exec("CREATE DATABASE `" + cfg.DBName + "`"); err != nil {
+		t.Fatalf("create database: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP DATABASE IF EXISTS `" + cfg.DBName + "`"); err != nil {
+			t.Errorf("drop database: %v", err)
+		}
+	})
+
+	r, err := NewMySQLRepository(testContext(t), cfg.FormatDSN(), nil)
+	if err != nil {
+		t.Fatalf("open mysql repository: %v", err)
+	}
+	t.Cleanup(func() { _ = r.Close() })
+
+	return r
+}
+
+// testDerived returns an image derived from the original with the given ID by action.
+func testDerived(original model.Image, originalID uuid.UUID, action string) model.Image {
+	img := testImage(original.Owner)
+	img.Filename = action + "_" + original.Filename
+	img.Path = "processed/" + action + "-" + uuid.NewString() + ".jpg"
+	img.Action = model.Action{Name: action}
+	img.Status = model.StatusProcessed
+	img.OriginalID = &originalID
+
+	return img
+}
+
+// TestListDerived checks on every backend that the images derived from an original are linked to
+// it by their original ID and listed with it, apart from those of other originals.
+func TestListDerived(t *testing.T) {
+	for name, newStore := range testStores() {
+		t.Run(name, func(t *testing.T) {
+			r := newStore(t)
+			ctx := testContext(t)
+
+			original := testImage("linked")
+			originalID, err := r.SaveImage(ctx, original)
+			if err != nil {
+				t.Fatalf("save original: %v", err)
+			}
+
+			other := testImage("other")
+			otherID, err := r.SaveImage(ctx, other)
+			if err != nil {
+				t.Fatalf("save other original: %v", err)
+			}
+
+			derivedIDs, err := r.SaveDerivedImages(ctx, []model.Image{
+				testDerived(original, originalID, "resize"),
+				testDerived(original, originalID, "grayscale"),
+			})
+			if err != nil {
+				t.Fatalf("save derived images: %v", err)
+			}
+			if _, err := r.SaveDerivedImages(ctx, []model.Image{testDerived(other, otherID, "resize")}); err != nil {
+				t.Fatalf("save derived image of other original: %v", err)
+			}
+
+			derived, err := r.ListDerived(ctx, originalID)
+			if err != nil {
+				t.Fatalf("ListDerived() error = %v", err)
+			}
+
+			var listed []uuid.UUID
+			for _, img := range derived {
+				listed = append(listed, img.ID)
+				if img.OriginalID == nil || *img.OriginalID != originalID {
+					t.Errorf("derived image %v has original %v, want %v", img.ID, img.OriginalID, originalID)
+				}
+			}
+			if !sameIDs(listed, derivedIDs) {
+				t.Errorf("ListDerived() = %v, want %v", listed, derivedIDs)
+			}
+
+			// The link reads back on the derived images, and originals have none.
+			got, err := r.GetImage(ctx, derivedIDs[0])
+			if err != nil {
+				t.Fatalf("get derived image: %v", err)
+			}
+			if got.OriginalID == nil || *got.OriginalID != originalID {
+				t.Errorf("GetImage() of derived has original %v, want %v", got.OriginalID, originalID)
+			}
+
+			got, err = r.GetImage(ctx, originalID)
+			if err != nil {
+				t.Fatalf("get original: %v", err)
+			}
+			if got.OriginalID != nil {
+				t.Errorf("GetImage() of original has original %v, want none", *got.OriginalID)
+			}
+
+			// Derived images have no derived images of their own.
+			derived, err = r.ListDerived(ctx, derivedIDs[0])
+			if err != nil {
+				t.Fatalf("ListDerived() of derived error = %v", err)
+			}
+			if len(derived) != 0 {
+				t.Errorf("ListDerived() of derived returned %d images, want none", len(derived))
+			}
+		})
+	}
+}
+
+// sameIDs reports whether a and b hold the same IDs, in any order.
+func sameIDs(a, b []uuid.UUID) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	cmp := func(x, y uuid.UUID) int { return strings.Compare(x.String(), y.String()) }
+	slices.SortFunc(a, cmp)
+	slices.SortFunc(b, cmp)
+
+	return slices.Equal(a, b)
+}
@@ -0,0 +1,220 @@
+package image
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Queries of all backends are written with the numbered $N placeholders of PostgreSQL.
+// Backends whose driver only accepts positional ? placeholders run them through rebind.
+
+// rebind rewrites the $N placeholders of query to ? placeholders, returning the arguments
+// in the order of the placeholders, repeated for placeholders used more than once.
+// Dollar signs in string literals, e.g. JSON paths, are left alone.
+func rebind(query string, args []interface{}) (string, []interface{}) {
+	var (
+		b       strings.Builder
+		out     = make([]interface{}, 0, len(args))
+		inQuote bool
+	)
+
+	b.Grow(len(query))
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		if c == '\'' {
+			inQuote = !inQuote
+		}
+
+		if c != '$' || inQuote {
+			b.WriteByte(c)
+			continue
+		}
+
+		j := i + 1
+		for j < len(query) && query[j] >= '0' && query[j] <= '9' {
+			j++
+		}
+
+		n, err := strconv.Atoi(query[i+1 : j])
+		if err != nil || n < 1 || n > len(args) {
+			b.WriteByte(c)
+			continue
+		}
+
+		b.WriteByte('?')
+		out = append(out, args[n-1])
+		i = j - 1
+	}
+
+	return b.String(), out
+}
+
+// valuesList repeats row, a VALUES row written with the placeholders $1 to $cols, for n rows of
+// a multi-row insert, numbering the placeholders of each row after those of the previous one.
+func valuesList(row string, cols, n int) string {
+	rows := make([]string, 0, n)
+	for i := range n {
+		rows = append(rows, placeholder.ReplaceAllStringFunc(row, func(p string) string {
+			num, _ := strconv.Atoi(p[1:])
+			return "$" + strconv.Itoa(num+i*cols)
+		}))
+	}
+
+	return strings.Join(rows, ", ")
+}
+
+// placeholder matches the $N placeholders of queries.
+var placeholder = regexp.MustCompile(`\$[0-9]+`)
+
+// positionalConn runs queries written with $N placeholders on a connection accepting only ? placeholders.
+type positionalConn struct {
+	conn execer
+}
+
+// QueryContext runs a query returning rows.
+func (c positionalConn) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	query, args = rebind(query, args)
+	return c.conn.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext runs a query returning at most one row.
+func (c positionalConn) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	query, args = rebind(query, args)
+	return c.conn.QueryRowContext(ctx, query, args...)
+}
+
+// ExecContext runs a query without returning rows.
+func (c positionalConn) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	query, args = rebind(query, args)
+	return c.conn.ExecContext(ctx, query, args...)
+}
+
+// migrator is implemented by the backends applying their embedded migrations on startup.
+type migrator interface {
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+	conn(ctx context.Context) execer
+}
+
+// migrate applies the migrations in dir of fsys that are missing from the schema_migrations table,
+// in file name order and each in its own transaction. Migrations are versioned by the timestamp
+// prefixing their file name, as the goose migrations of PostgreSQL. createTable creates
+// the schema_migrations table if it doesn't exist.
+func migrate(ctx context.Context, m migrator, fsys fs.FS, dir, createTable string) error {
+	if _, err := m.conn(ctx).ExecContext(ctx, createTable); err != nil {
+		return fmt.Errorf("migrate: failed to create migrations table: %w", err)
+	}
+
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return fmt.Errorf("migrate: failed to list migrations: %w", err)
+	}
+
+	for _, e := range entries {
+		version, _, _ := strings.Cut(e.Name(), "_")
+
+		err := m.WithTx(ctx, func(ctx context.Context) error {
+			var applied bool
+			err := m.conn(ctx).QueryRowContext(
+				ctx, `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, version,
+			).Scan(&applied)
+			if err != nil || applied {
+				return err
+			}
+
+			script, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+			if err != nil {
+				return err
+			}
+
+			if _, err := m.conn(ctx).ExecContext(ctx, string(script)); err != nil {
+				return err
+			}
+
+			_, err = m.conn(ctx).ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("migrate: failed to apply %s: %w", e.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+// searchQuery is a full-text query in the syntax of websearch_to_tsquery in PostgreSQL, parsed for
+// the backends translating it to their own full-text syntax: words and "quoted phrases" must all match
+// unless joined by "or", and those prefixed with "-" must not.
+type searchQuery struct {
+	required [][]string // alternatives of each required clause
+	excluded []string
+}
+
+// parseSearch parses a web search query. Terms without any letter or digit are dropped.
+func parseSearch(q string) searchQuery {
+	var (
+		sq searchQuery
+		or bool
+	)
+
+	for q = strings.TrimSpace(q); q != ""; q = strings.TrimSpace(q) {
+		var term string
+
+		exclude := strings.HasPrefix(q, "-")
+		if exclude {
+			q = q[1:]
+		}
+
+		if strings.HasPrefix(q, `"`) {
+			end := strings.Index(q[1:], `"`)
+			if end < 0 {
+				term, q = q[1:], ""
+			} else {
+				term, q = q[1:end+1], q[end+2:]
+			}
+		} else {
+			end := strings.IndexFunc(q, unicode.IsSpace)
+			if end < 0 {
+				end = len(q)
+			}
+			term, q = q[:end], q[end:]
+
+			if !exclude && strings.EqualFold(term, "or") {
+				or = len(sq.required) > 0
+				continue
+			}
+		}
+
+		if strings.IndexFunc(term, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) < 0 {
+			continue
+		}
+
+		switch {
+		case exclude:
+			sq.excluded = append(sq.excluded, term)
+		case or:
+			sq.required[len(sq.required)-1] = append(sq.required[len(sq.required)-1], term)
+		default:
+			sq.required = append(sq.required, []string{term})
+		}
+		or = false
+	}
+
+	return sq
+}
+
+// empty reports whether the query has no terms, matching no images.
+func (sq searchQuery) empty() bool {
+	return len(sq.required) == 0 && len(sq.excluded) == 0
+}
+
+// onlyExcluded reports whether the query has only excluded terms, matching the images none of them match.
+func (sq searchQuery) onlyExcluded() bool {
+	return len(sq.required) == 0 && len(sq.excluded) > 0
+}
@@ -0,0 +1,126 @@
+//go:build integration
+
+package image
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/wb-go/wbf/dbpg"
+
+	"github.com/aliskhannn/image-processor/internal/model"
+)
+
+// testDSNEnv names the environment variable holding the DSN of the PostgreSQL server the
+// integration tests create their databases on, e.g. the db service of docker-compose:
+//
+//	TEST_POSTGRES_DSN="host=localhost port=5432 user=postgres password=postgres dbname=postgres sslmode=disable" \
+//		go test -tags integration ./internal/repository/image/
+const testDSNEnv = "TEST_POSTGRES_DSN"
+
+// newTestRepository returns a repository on a database of its own, migrated to the latest schema
+// and dropped once the test finishes. The test is skipped without TEST_POSTGRES_DSN.
+func newTestRepository(t *testing.T) *Repository {
+	t.Helper()
+
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s not set", testDSNEnv)
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("open admin connection: %v", err)
+	}
+	t.Cleanup(func() { _ = admin.Close() })
+
+	name := "image_processor_test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	if _, err := admin.Exec(`CREATE DATABASE ` + pq.QuoteIdentifier(name)); err != nil {
+		t.Fatalf("create database: %v", err)
+	}
+
+	db, err := dbpg.New(dsn+" dbname="+name, nil, nil)
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = db.Master.Close()
+		if _, err := admin.Exec(`DROP DATABASE IF EXISTS ` + pq.QuoteIdentifier(name) + ` WITH (FORCE)`); err != nil {
+			t.Errorf("drop database: %v", err)
+		}
+	})
+
+	migrateUp(t, db.Master)
+
+	return NewRepository(db, false)
+}
+
+// migrateUp applies the Up sections of the goose migrations in version order.
+func migrateUp(t *testing.T, db *sql.DB) {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join(migrationsDir, "*.sql"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("list migrations: %v (%d files)", err, len(files))
+	}
+	slices.Sort(files)
+
+	for _, file := range files {
+		script, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("read migration: %v", err)
+		}
+
+		up, _, _ := strings.Cut(string(script), "-- +goose Down")
+		if _, err := db.Exec(up); err != nil {
+			t.Fatalf("apply %s: %v", filepath.Base(file), err)
+		}
+	}
+}
+
+// testImage returns an original image to save, named and owned after name. Owners differ between
+// names, as the storage usage of an owner is a row locked by the transactions saving its images.
+func testImage(name string) model.Image {
+	return model.Image{
+		Filename: name + ".jpg",
+		Path:     fmt.Sprintf("original/%s-%s.jpg", name, uuid.NewString()),
+		Action:   model.Action{Name: "resize", Params: map[string]string{"width": "100", "height": "100"}},
+		Status:   model.StatusPending,
+		Owner:    name,
+		Priority: model.PriorityNormal,
+		Size:     1024,
+	}
+}
+
+// beginTx starts a transaction on the master and returns a context running the repository
+// queries in it, rolled back when the test finishes unless committed.
+func beginTx(t *testing.T, r *Repository) (context.Context, *sql.Tx) {
+	t.Helper()
+
+	tx, err := r.db.Master.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("begin transaction: %v", err)
+	}
+	t.Cleanup(func() { _ = tx.Rollback() })
+
+	return context.WithValue(context.Background(), txKey{}, tx), tx
+}
+
+// testContext returns a context bounded by a timeout generous for a local database.
+func testContext(t *testing.T) context.Context {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	t.Cleanup(cancel)
+
+	return ctx
+}
@@ -0,0 +1,55 @@
+package image
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// migrationsDir is the directory of the goose migrations, relative to this package.
+const migrationsDir = "../../../migrations"
+
+// lastUncheckedVersion is the last migration version applied before versions were checked. Some
+// of the versions up to it have hours past 23; they are kept as applied, since renumbering them
+// would make databases apply them again.
+const lastUncheckedVersion = 20261018400000
+
+// migrationName matches the file names of migrations: the version, a timestamp, and a name.
+var migrationName = regexp.MustCompile(`^([0-9]{14})_[a-z0-9_]+\.sql$`)
+
+// TestMigrationVersions checks that the migrations of every backend are named after distinct,
+// increasing versions and that versions added since lastUncheckedVersion are valid timestamps.
+func TestMigrationVersions(t *testing.T) {
+	for _, dir := range []string{migrationsDir, "mysql", "sqlite"} {
+		t.Run(dir, func(t *testing.T) {
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatalf("list migrations: %v", err)
+			}
+
+			var prev int64
+			for _, e := range entries {
+				m := migrationName.FindStringSubmatch(e.Name())
+				if m == nil {
+					t.Errorf("%s: not named <YYYYMMDDhhmmss>_<name>.sql", e.Name())
+					continue
+				}
+
+				version, _ := strconv.ParseInt(m[1], 10, 64)
+				if version <= prev {
+					t.Errorf("%s: version not above the previous %d", e.Name(), prev)
+				}
+				prev = version
+
+				if version <= lastUncheckedVersion {
+					continue
+				}
+				if _, err := time.Parse("20060102150405", m[1]); err != nil {
+					t.Errorf("%s: version is not a valid timestamp: %v", e.Name(), err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,757 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/aliskhannn/image-processor/internal/repository/image (interfaces: Store)
+//
+// Generated by this command:
+//
+//	mockgen -destination=mocks/store.go -package=mocks . Store
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	model "github.com/aliskhannn/image-processor/internal/model"
+	uuid "github.com/google/uuid"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockStore is a mock of Store interface.
+type MockStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockStoreMockRecorder
+	isgomock struct{}
+}
+
+// MockStoreMockRecorder is the mock recorder for MockStore.
+type MockStoreMockRecorder struct {
+	mock *MockStore
+}
+
+// NewMockStore creates a new mock instance.
+func NewMockStore(ctrl *gomock.Controller) *MockStore {
+	mock := &MockStore{ctrl: ctrl}
+	mock.recorder = &MockStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStore) EXPECT() *MockStoreMockRecorder {
+	return m.recorder
+}
+
+// ActionStats mocks base method.
+func (m *MockStore) ActionStats(ctx context.Context, since time.Time) ([]model.ActionStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ActionStats", ctx, since)
+	ret0, _ := ret[0].([]model.ActionStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ActionStats indicates an expected call of ActionStats.
+func (mr *MockStoreMockRecorder) ActionStats(ctx, since any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ActionStats", reflect.TypeOf((*MockStore)(nil).ActionStats), ctx, since)
+}
+
+// BeginAttempt mocks base method.
+func (m *MockStore) BeginAttempt(ctx context.Context, id uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BeginAttempt", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BeginAttempt indicates an expected call of BeginAttempt.
+func (mr *MockStoreMockRecorder) BeginAttempt(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BeginAttempt", reflect.TypeOf((*MockStore)(nil).BeginAttempt), ctx, id)
+}
+
+// BeginMessageAttempt mocks base method.
+func (m *MockStore) BeginMessageAttempt(ctx context.Context, messageID string) (model.MessageAttempt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BeginMessageAttempt", ctx, messageID)
+	ret0, _ := ret[0].(model.MessageAttempt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BeginMessageAttempt indicates an expected call of BeginMessageAttempt.
+func (mr *MockStoreMockRecorder) BeginMessageAttempt(ctx, messageID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BeginMessageAttempt", reflect.TypeOf((*MockStore)(nil).BeginMessageAttempt), ctx, messageID)
+}
+
+// Close mocks base method.
+func (m *MockStore) Close() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockStoreMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockStore)(nil).Close))
+}
+
+// CountImages mocks base method.
+func (m *MockStore) CountImages(ctx context.Context, f model.ImageFilter) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountImages", ctx, f)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountImages indicates an expected call of CountImages.
+func (mr *MockStoreMockRecorder) CountImages(ctx, f any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountImages", reflect.TypeOf((*MockStore)(nil).CountImages), ctx, f)
+}
+
+// DeleteDerived mocks base method.
+func (m *MockStore) DeleteDerived(ctx context.Context, originalID uuid.UUID) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteDerived", ctx, originalID)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteDerived indicates an expected call of DeleteDerived.
+func (mr *MockStoreMockRecorder) DeleteDerived(ctx, originalID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteDerived", reflect.TypeOf((*MockStore)(nil).DeleteDerived), ctx, originalID)
+}
+
+// DeleteImage mocks base method.
+func (m *MockStore) DeleteImage(ctx context.Context, id uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteImage", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteImage indicates an expected call of DeleteImage.
+func (mr *MockStoreMockRecorder) DeleteImage(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteImage", reflect.TypeOf((*MockStore)(nil).DeleteImage), ctx, id)
+}
+
+// DeleteMessageAttempts mocks base method.
+func (m *MockStore) DeleteMessageAttempts(ctx context.Context, messageID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteMessageAttempts", ctx, messageID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteMessageAttempts indicates an expected call of DeleteMessageAttempts.
+func (mr *MockStoreMockRecorder) DeleteMessageAttempts(ctx, messageID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteMessageAttempts", reflect.TypeOf((*MockStore)(nil).DeleteMessageAttempts), ctx, messageID)
+}
+
+// DeleteOutbox mocks base method.
+func (m *MockStore) DeleteOutbox(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteOutbox", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteOutbox indicates an expected call of DeleteOutbox.
+func (mr *MockStoreMockRecorder) DeleteOutbox(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOutbox", reflect.TypeOf((*MockStore)(nil).DeleteOutbox), ctx, id)
+}
+
+// EnqueueOutbox mocks base method.
+func (m *MockStore) EnqueueOutbox(ctx context.Context, msg model.OutboxMessage) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnqueueOutbox", ctx, msg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EnqueueOutbox indicates an expected call of EnqueueOutbox.
+func (mr *MockStoreMockRecorder) EnqueueOutbox(ctx, msg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnqueueOutbox", reflect.TypeOf((*MockStore)(nil).EnqueueOutbox), ctx, msg)
+}
+
+// FailAttempt mocks base method.
+func (m *MockStore) FailAttempt(ctx context.Context, id uuid.UUID, errMsg string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FailAttempt", ctx, id, errMsg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FailAttempt indicates an expected call of FailAttempt.
+func (mr *MockStoreMockRecorder) FailAttempt(ctx, id, errMsg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FailAttempt", reflect.TypeOf((*MockStore)(nil).FailAttempt), ctx, id, errMsg)
+}
+
+// FailMessageAttempt mocks base method.
+func (m *MockStore) FailMessageAttempt(ctx context.Context, messageID, errMsg string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FailMessageAttempt", ctx, messageID, errMsg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FailMessageAttempt indicates an expected call of FailMessageAttempt.
+func (mr *MockStoreMockRecorder) FailMessageAttempt(ctx, messageID, errMsg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FailMessageAttempt", reflect.TypeOf((*MockStore)(nil).FailMessageAttempt), ctx, messageID, errMsg)
+}
+
+// FailStuckImage mocks base method.
+func (m *MockStore) FailStuckImage(ctx context.Context, id uuid.UUID, stuckSince time.Time, errMsg string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FailStuckImage", ctx, id, stuckSince, errMsg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FailStuckImage indicates an expected call of FailStuckImage.
+func (mr *MockStoreMockRecorder) FailStuckImage(ctx, id, stuckSince, errMsg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FailStuckImage", reflect.TypeOf((*MockStore)(nil).FailStuckImage), ctx, id, stuckSince, errMsg)
+}
+
+// FindOriginalByHash mocks base method.
+func (m *MockStore) FindOriginalByHash(ctx context.Context, owner, hash string) (model.Image, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindOriginalByHash", ctx, owner, hash)
+	ret0, _ := ret[0].(model.Image)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindOriginalByHash indicates an expected call of FindOriginalByHash.
+func (mr *MockStoreMockRecorder) FindOriginalByHash(ctx, owner, hash any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOriginalByHash", reflect.TypeOf((*MockStore)(nil).FindOriginalByHash), ctx, owner, hash)
+}
+
+// FinishAttempt mocks base method.
+func (m *MockStore) FinishAttempt(ctx context.Context, id uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FinishAttempt", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FinishAttempt indicates an expected call of FinishAttempt.
+func (mr *MockStoreMockRecorder) FinishAttempt(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FinishAttempt", reflect.TypeOf((*MockStore)(nil).FinishAttempt), ctx, id)
+}
+
+// GetDeadLetter mocks base method.
+func (m *MockStore) GetDeadLetter(ctx context.Context, id uuid.UUID) (model.DeadLetter, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeadLetter", ctx, id)
+	ret0, _ := ret[0].(model.DeadLetter)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeadLetter indicates an expected call of GetDeadLetter.
+func (mr *MockStoreMockRecorder) GetDeadLetter(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeadLetter", reflect.TypeOf((*MockStore)(nil).GetDeadLetter), ctx, id)
+}
+
+// GetImage mocks base method.
+func (m *MockStore) GetImage(ctx context.Context, id uuid.UUID) (model.Image, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetImage", ctx, id)
+	ret0, _ := ret[0].(model.Image)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetImage indicates an expected call of GetImage.
+func (mr *MockStoreMockRecorder) GetImage(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetImage", reflect.TypeOf((*MockStore)(nil).GetImage), ctx, id)
+}
+
+// GetProcessedMessage mocks base method.
+func (m *MockStore) GetProcessedMessage(ctx context.Context, messageID string) (uuid.UUID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetProcessedMessage", ctx, messageID)
+	ret0, _ := ret[0].(uuid.UUID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetProcessedMessage indicates an expected call of GetProcessedMessage.
+func (mr *MockStoreMockRecorder) GetProcessedMessage(ctx, messageID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProcessedMessage", reflect.TypeOf((*MockStore)(nil).GetProcessedMessage), ctx, messageID)
+}
+
+// GetStatus mocks base method.
+func (m *MockStore) GetStatus(ctx context.Context, id uuid.UUID) (model.ProcessingStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStatus", ctx, id)
+	ret0, _ := ret[0].(model.ProcessingStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStatus indicates an expected call of GetStatus.
+func (mr *MockStoreMockRecorder) GetStatus(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStatus", reflect.TypeOf((*MockStore)(nil).GetStatus), ctx, id)
+}
+
+// GetUsage mocks base method.
+func (m *MockStore) GetUsage(ctx context.Context, owner string) (model.QuotaUsage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUsage", ctx, owner)
+	ret0, _ := ret[0].(model.QuotaUsage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUsage indicates an expected call of GetUsage.
+func (mr *MockStoreMockRecorder) GetUsage(ctx, owner any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsage", reflect.TypeOf((*MockStore)(nil).GetUsage), ctx, owner)
+}
+
+// HourlyStats mocks base method.
+func (m *MockStore) HourlyStats(ctx context.Context, since time.Time) ([]model.HourlyStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HourlyStats", ctx, since)
+	ret0, _ := ret[0].([]model.HourlyStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HourlyStats indicates an expected call of HourlyStats.
+func (mr *MockStoreMockRecorder) HourlyStats(ctx, since any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HourlyStats", reflect.TypeOf((*MockStore)(nil).HourlyStats), ctx, since)
+}
+
+// ListAuditEvents mocks base method.
+func (m *MockStore) ListAuditEvents(ctx context.Context, f model.AuditFilter) ([]model.AuditEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAuditEvents", ctx, f)
+	ret0, _ := ret[0].([]model.AuditEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAuditEvents indicates an expected call of ListAuditEvents.
+func (mr *MockStoreMockRecorder) ListAuditEvents(ctx, f any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAuditEvents", reflect.TypeOf((*MockStore)(nil).ListAuditEvents), ctx, f)
+}
+
+// ListChanges mocks base method.
+func (m *MockStore) ListChanges(ctx context.Context, since int64, limit int) ([]model.Change, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListChanges", ctx, since, limit)
+	ret0, _ := ret[0].([]model.Change)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListChanges indicates an expected call of ListChanges.
+func (mr *MockStoreMockRecorder) ListChanges(ctx, since, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListChanges", reflect.TypeOf((*MockStore)(nil).ListChanges), ctx, since, limit)
+}
+
+// ListDeadLetters mocks base method.
+func (m *MockStore) ListDeadLetters(ctx context.Context, includeReplayed bool, limit, offset int) ([]model.DeadLetter, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDeadLetters", ctx, includeReplayed, limit, offset)
+	ret0, _ := ret[0].([]model.DeadLetter)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDeadLetters indicates an expected call of ListDeadLetters.
+func (mr *MockStoreMockRecorder) ListDeadLetters(ctx, includeReplayed, limit, offset any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDeadLetters", reflect.TypeOf((*MockStore)(nil).ListDeadLetters), ctx, includeReplayed, limit, offset)
+}
+
+// ListDeleted mocks base method.
+func (m *MockStore) ListDeleted(ctx context.Context, before time.Time, limit int) ([]model.Image, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDeleted", ctx, before, limit)
+	ret0, _ := ret[0].([]model.Image)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDeleted indicates an expected call of ListDeleted.
+func (mr *MockStoreMockRecorder) ListDeleted(ctx, before, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDeleted", reflect.TypeOf((*MockStore)(nil).ListDeleted), ctx, before, limit)
+}
+
+// ListDerived mocks base method.
+func (m *MockStore) ListDerived(ctx context.Context, originalID uuid.UUID) ([]model.Image, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDerived", ctx, originalID)
+	ret0, _ := ret[0].([]model.Image)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDerived indicates an expected call of ListDerived.
+func (mr *MockStoreMockRecorder) ListDerived(ctx, originalID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDerived", reflect.TypeOf((*MockStore)(nil).ListDerived), ctx, originalID)
+}
+
+// ListExpired mocks base method.
+func (m *MockStore) ListExpired(ctx context.Context, limit int) ([]model.Image, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListExpired", ctx, limit)
+	ret0, _ := ret[0].([]model.Image)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListExpired indicates an expected call of ListExpired.
+func (mr *MockStoreMockRecorder) ListExpired(ctx, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListExpired", reflect.TypeOf((*MockStore)(nil).ListExpired), ctx, limit)
+}
+
+// ListImages mocks base method.
+func (m *MockStore) ListImages(ctx context.Context, f model.ImageFilter) ([]model.Image, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListImages", ctx, f)
+	ret0, _ := ret[0].([]model.Image)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListImages indicates an expected call of ListImages.
+func (mr *MockStoreMockRecorder) ListImages(ctx, f any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListImages", reflect.TypeOf((*MockStore)(nil).ListImages), ctx, f)
+}
+
+// ListJobs mocks base method.
+func (m *MockStore) ListJobs(ctx context.Context, f model.JobFilter) ([]model.Job, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListJobs", ctx, f)
+	ret0, _ := ret[0].([]model.Job)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListJobs indicates an expected call of ListJobs.
+func (mr *MockStoreMockRecorder) ListJobs(ctx, f any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListJobs", reflect.TypeOf((*MockStore)(nil).ListJobs), ctx, f)
+}
+
+// MarkDeadLetterReplayed mocks base method.
+func (m *MockStore) MarkDeadLetterReplayed(ctx context.Context, id uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkDeadLetterReplayed", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkDeadLetterReplayed indicates an expected call of MarkDeadLetterReplayed.
+func (mr *MockStoreMockRecorder) MarkDeadLetterReplayed(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkDeadLetterReplayed", reflect.TypeOf((*MockStore)(nil).MarkDeadLetterReplayed), ctx, id)
+}
+
+// MarkMessageProcessed mocks base method.
+func (m *MockStore) MarkMessageProcessed(ctx context.Context, messageID string, imageID, derivedID uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkMessageProcessed", ctx, messageID, imageID, derivedID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkMessageProcessed indicates an expected call of MarkMessageProcessed.
+func (mr *MockStoreMockRecorder) MarkMessageProcessed(ctx, messageID, imageID, derivedID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkMessageProcessed", reflect.TypeOf((*MockStore)(nil).MarkMessageProcessed), ctx, messageID, imageID, derivedID)
+}
+
+// PathInUse mocks base method.
+func (m *MockStore) PathInUse(ctx context.Context, path string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PathInUse", ctx, path)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PathInUse indicates an expected call of PathInUse.
+func (mr *MockStoreMockRecorder) PathInUse(ctx, path any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PathInUse", reflect.TypeOf((*MockStore)(nil).PathInUse), ctx, path)
+}
+
+// PendingOutbox mocks base method.
+func (m *MockStore) PendingOutbox(ctx context.Context, limit int) ([]model.OutboxMessage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PendingOutbox", ctx, limit)
+	ret0, _ := ret[0].([]model.OutboxMessage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PendingOutbox indicates an expected call of PendingOutbox.
+func (mr *MockStoreMockRecorder) PendingOutbox(ctx, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PendingOutbox", reflect.TypeOf((*MockStore)(nil).PendingOutbox), ctx, limit)
+}
+
+// Ping mocks base method.
+func (m *MockStore) Ping(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockStoreMockRecorder) Ping(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockStore)(nil).Ping), ctx)
+}
+
+// QueueDepth mocks base method.
+func (m *MockStore) QueueDepth(ctx context.Context, stuckSince time.Time) (model.QueueDepth, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QueueDepth", ctx, stuckSince)
+	ret0, _ := ret[0].(model.QueueDepth)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// QueueDepth indicates an expected call of QueueDepth.
+func (mr *MockStoreMockRecorder) QueueDepth(ctx, stuckSince any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueueDepth", reflect.TypeOf((*MockStore)(nil).QueueDepth), ctx, stuckSince)
+}
+
+// RedriveImage mocks base method.
+func (m *MockStore) RedriveImage(ctx context.Context, id uuid.UUID, stuckSince time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RedriveImage", ctx, id, stuckSince)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RedriveImage indicates an expected call of RedriveImage.
+func (mr *MockStoreMockRecorder) RedriveImage(ctx, id, stuckSince any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RedriveImage", reflect.TypeOf((*MockStore)(nil).RedriveImage), ctx, id, stuckSince)
+}
+
+// RequeueImage mocks base method.
+func (m *MockStore) RequeueImage(ctx context.Context, id uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequeueImage", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RequeueImage indicates an expected call of RequeueImage.
+func (mr *MockStoreMockRecorder) RequeueImage(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequeueImage", reflect.TypeOf((*MockStore)(nil).RequeueImage), ctx, id)
+}
+
+// SaveAuditEvent mocks base method.
+func (m *MockStore) SaveAuditEvent(ctx context.Context, ev model.AuditEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveAuditEvent", ctx, ev)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveAuditEvent indicates an expected call of SaveAuditEvent.
+func (mr *MockStoreMockRecorder) SaveAuditEvent(ctx, ev any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveAuditEvent", reflect.TypeOf((*MockStore)(nil).SaveAuditEvent), ctx, ev)
+}
+
+// SaveDeadLetter mocks base method.
+func (m *MockStore) SaveDeadLetter(ctx context.Context, dl model.DeadLetter) (uuid.UUID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveDeadLetter", ctx, dl)
+	ret0, _ := ret[0].(uuid.UUID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SaveDeadLetter indicates an expected call of SaveDeadLetter.
+func (mr *MockStoreMockRecorder) SaveDeadLetter(ctx, dl any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveDeadLetter", reflect.TypeOf((*MockStore)(nil).SaveDeadLetter), ctx, dl)
+}
+
+// SaveDerivedImages mocks base method.
+func (m *MockStore) SaveDerivedImages(ctx context.Context, imgs []model.Image) ([]uuid.UUID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveDerivedImages", ctx, imgs)
+	ret0, _ := ret[0].([]uuid.UUID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SaveDerivedImages indicates an expected call of SaveDerivedImages.
+func (mr *MockStoreMockRecorder) SaveDerivedImages(ctx, imgs any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveDerivedImages", reflect.TypeOf((*MockStore)(nil).SaveDerivedImages), ctx, imgs)
+}
+
+// SaveImage mocks base method.
+func (m *MockStore) SaveImage(ctx context.Context, img model.Image) (uuid.UUID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveImage", ctx, img)
+	ret0, _ := ret[0].(uuid.UUID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SaveImage indicates an expected call of SaveImage.
+func (mr *MockStoreMockRecorder) SaveImage(ctx, img any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveImage", reflect.TypeOf((*MockStore)(nil).SaveImage), ctx, img)
+}
+
+// SoftDeleteImage mocks base method.
+func (m *MockStore) SoftDeleteImage(ctx context.Context, id uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SoftDeleteImage", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SoftDeleteImage indicates an expected call of SoftDeleteImage.
+func (mr *MockStoreMockRecorder) SoftDeleteImage(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SoftDeleteImage", reflect.TypeOf((*MockStore)(nil).SoftDeleteImage), ctx, id)
+}
+
+// StatusCounts mocks base method.
+func (m *MockStore) StatusCounts(ctx context.Context, since time.Time) ([]model.StatusCount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StatusCounts", ctx, since)
+	ret0, _ := ret[0].([]model.StatusCount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// StatusCounts indicates an expected call of StatusCounts.
+func (mr *MockStoreMockRecorder) StatusCounts(ctx, since any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StatusCounts", reflect.TypeOf((*MockStore)(nil).StatusCounts), ctx, since)
+}
+
+// StorageUsage mocks base method.
+func (m *MockStore) StorageUsage(ctx context.Context, f model.UsageFilter) ([]model.StorageUsage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StorageUsage", ctx, f)
+	ret0, _ := ret[0].([]model.StorageUsage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// StorageUsage indicates an expected call of StorageUsage.
+func (mr *MockStoreMockRecorder) StorageUsage(ctx, f any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StorageUsage", reflect.TypeOf((*MockStore)(nil).StorageUsage), ctx, f)
+}
+
+// UpdateImage mocks base method.
+func (m *MockStore) UpdateImage(ctx context.Context, id uuid.UUID, path, status string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateImage", ctx, id, path, status)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateImage indicates an expected call of UpdateImage.
+func (mr *MockStoreMockRecorder) UpdateImage(ctx, id, path, status any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateImage", reflect.TypeOf((*MockStore)(nil).UpdateImage), ctx, id, path, status)
+}
+
+// UpdateMetadata mocks base method.
+func (m *MockStore) UpdateMetadata(ctx context.Context, id uuid.UUID, upd model.MetadataUpdate) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateMetadata", ctx, id, upd)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateMetadata indicates an expected call of UpdateMetadata.
+func (mr *MockStoreMockRecorder) UpdateMetadata(ctx, id, upd any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateMetadata", reflect.TypeOf((*MockStore)(nil).UpdateMetadata), ctx, id, upd)
+}
+
+// UpdateOCRText mocks base method.
+func (m *MockStore) UpdateOCRText(ctx context.Context, id uuid.UUID, text string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateOCRText", ctx, id, text)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateOCRText indicates an expected call of UpdateOCRText.
+func (mr *MockStoreMockRecorder) UpdateOCRText(ctx, id, text any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOCRText", reflect.TypeOf((*MockStore)(nil).UpdateOCRText), ctx, id, text)
+}
+
+// UpdateStage mocks base method.
+func (m *MockStore) UpdateStage(ctx context.Context, id uuid.UUID, stage string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateStage", ctx, id, stage)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateStage indicates an expected call of UpdateStage.
+func (mr *MockStoreMockRecorder) UpdateStage(ctx, id, stage any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateStage", reflect.TypeOf((*MockStore)(nil).UpdateStage), ctx, id, stage)
+}
+
+// WithTx mocks base method.
+func (m *MockStore) WithTx(ctx context.Context, fn func(context.Context) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithTx", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WithTx indicates an expected call of WithTx.
+func (mr *MockStoreMockRecorder) WithTx(ctx, fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithTx", reflect.TypeOf((*MockStore)(nil).WithTx), ctx, fn)
+}
@@ -0,0 +1,1461 @@
+package image
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql" // registers the "mysql" driver
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/dbpg"
+
+	"github.com/aliskhannn/image-processor/internal/model"
+)
+
+// mysqlMigrations holds the schema of the MySQL backend, applied in file name order on connect.
+//
+//go:embed mysql/*.sql
+var mysqlMigrations embed.FS
+
+// mysqlMigrationsTable is the table recording the applied migrations.
+const mysqlMigrationsTable = `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    VARCHAR(32) NOT NULL PRIMARY KEY,
+			applied_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6)
+		)
+    `
+
+// mysqlNotExpired excludes images past their expiry time that the sweeper has not deleted yet.
+const mysqlNotExpired = `(expires_at IS NULL OR expires_at > NOW(6))`
+
+// mysqlFinishAttempt is the SET clause recording the end and duration of the current processing attempt.
+const mysqlFinishAttempt = `finished_at = NOW(6), duration_ms = TIMESTAMPDIFF(MICROSECOND, started_at, NOW(6)) DIV 1000`
+
+// mysqlDeadLetterColumns is the column list selected for model.DeadLetter, in the order expected by scanDeadLetter.
+const mysqlDeadLetterColumns = "id, topic, `partition`, `offset`, `key`, payload, error, attempts, failed_at, replayed_at"
+
+// MySQLRepository provides the operations of Repository on MySQL 8 or MariaDB 10.6 and later.
+// Queries are written with $N placeholders like those of Repository and rebound for the driver.
+// Without RETURNING, upserts read back the affected row in the same transaction.
+// Full-text search uses a FULLTEXT index in boolean mode, subject to the minimum word
+// length and stopwords of the server.
+type MySQLRepository struct {
+	db *sql.DB
+}
+
+// NewMySQLRepository connects to the MySQL database at dsn with the given pool options,
+// and applies the embedded migrations not applied yet. The DSN must enable parseTime,
+// clientFoundRows and multiStatements, and use UTC for both loc and time_zone.
+func NewMySQLRepository(ctx context.Context, dsn string, opts *dbpg.Options) (*MySQLRepository, error) {
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("mysql: failed to open database: %w", err)
+	}
+
+	if opts != nil {
+		db.SetMaxOpenConns(opts.MaxOpenConns)
+		db.SetMaxIdleConns(opts.MaxIdleConns)
+		db.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	}
+
+	r := &MySQLRepository{db: db}
+	if err := migrate(ctx, r, mysqlMigrations, "mysql", mysqlMigrationsTable); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("mysql: %w", err)
+	}
+
+	return r, nil
+}
+
+// Ping verifies that the database is reachable.
+func (r *MySQLRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+// Close closes the database connections.
+func (r *MySQLRepository) Close() error {
+	return r.db.Close()
+}
+
+// WithTx runs fn with a context whose queries run in a single transaction,
+// committed if fn returns nil and rolled back otherwise. Nested calls join the outer transaction.
+func (r *MySQLRepository) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("with tx: failed to begin transaction: %w", err)
+	}
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return errors.Join(err, fmt.Errorf("with tx: failed to roll back: %w", rbErr))
+		}
+
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("with tx: failed to commit: %w", err)
+	}
+
+	return nil
+}
+
+// conn returns the transaction of ctx if any, the database otherwise, rebinding the placeholders of queries.
+func (r *MySQLRepository) conn(ctx context.Context) execer {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return positionalConn{conn: tx}
+	}
+
+	return positionalConn{conn: r.db}
+}
+
+// SaveImage inserts a new image record into the database and returns its UUID.
+// Saving is idempotent per message ID: an image saved again for the same message
+// replaces the file and size of the existing record and returns its ID.
+func (r *MySQLRepository) SaveImage(ctx context.Context, img model.Image) (uuid.UUID, error) {
+	query := `
+		INSERT INTO images (
+			id, filename, path, action, params, status, original_id, stage, callback_url, owner, size_bytes,
+			content_hash, format, expires_at, priority, message_id, width, height, color_space
+		)
+		VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, $11, NULLIF($12, ''), NULLIF($13, ''), $14,
+			COALESCE(NULLIF($15, ''), 'normal'), NULLIF($16, ''), NULLIF($17, 0), NULLIF($18, 0), NULLIF($19, '')
+		)
+		ON DUPLICATE KEY UPDATE path = VALUES(path), size_bytes = VALUES(size_bytes), format = VALUES(format),
+		                        width = VALUES(width), height = VALUES(height), color_space = VALUES(color_space)
+    `
+
+	// Images saved already processed (e.g. derived ones) skip the queue.
+	stage := model.StageQueued
+	if img.Status == model.StatusProcessed {
+		stage = model.StageDone
+	}
+
+	paramsJSON, err := json.Marshal(img.Action.Params)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to marshal action params: %w", err)
+	}
+
+	id := uuid.New()
+
+	err = r.WithTx(ctx, func(ctx context.Context) error {
+		_, err := r.conn(ctx).ExecContext(
+			ctx, query, id, img.Filename, img.Path, img.Action.Name, string(paramsJSON), img.Status, img.OriginalID,
+			stage, img.CallbackURL, img.Owner, img.Size, img.ContentHash, img.Format, img.ExpiresAt, img.Priority,
+			img.MessageID, img.Width, img.Height, img.ColorSpace,
+		)
+		if err != nil || img.MessageID == "" {
+			return err
+		}
+
+		// The image saved earlier for the message keeps its ID.
+		return r.conn(ctx).QueryRowContext(ctx, `SELECT id FROM images WHERE message_id = $1`, img.MessageID).Scan(&id)
+	})
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("save: failed to save image: %w", err)
+	}
+
+	return id, nil
+}
+
+// SaveDerivedImages inserts the records of several images derived by a job in a single statement,
+// e.g. the outputs of a variant set, and returns their UUIDs in order. As with SaveImage, saving
+// is idempotent per message ID, so the images must each carry a distinct message ID, if any;
+// images saved again keep their ID, read back in the same transaction.
+func (r *MySQLRepository) SaveDerivedImages(ctx context.Context, imgs []model.Image) ([]uuid.UUID, error) {
+	if len(imgs) == 0 {
+		return nil, nil
+	}
+
+	row := `(
+			$1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, $11, NULLIF($12, ''), NULLIF($13, ''), $14,
+			COALESCE(NULLIF($15, ''), 'normal'), NULLIF($16, ''), NULLIF($17, 0), NULLIF($18, 0), NULLIF($19, '')
+		)`
+
+	query := `
+		INSERT INTO images (
+			id, filename, path, action, params, status, original_id, stage, callback_url, owner, size_bytes,
+			content_hash, format, expires_at, priority, message_id, width, height, color_space
+		)
+		VALUES ` + valuesList(row, 19, len(imgs)) + `
+		ON DUPLICATE KEY UPDATE path = VALUES(path), size_bytes = VALUES(size_bytes), format = VALUES(format),
+		                        width = VALUES(width), height = VALUES(height), color_space = VALUES(color_space)
+    `
+
+	var (
+		ids        = make([]uuid.UUID, 0, len(imgs))
+		args       = make([]interface{}, 0, 19*len(imgs))
+		messageIDs []string
+	)
+	for _, img := range imgs {
+		paramsJSON, err := json.Marshal(img.Action.Params)
+		if err != nil {
+			return nil, fmt.Errorf("save derived: failed to marshal action params: %w", err)
+		}
+
+		id := uuid.New()
+		ids = append(ids, id)
+		args = append(args,
+			id, img.Filename, img.Path, img.Action.Name, string(paramsJSON), img.Status, img.OriginalID,
+			savedStage(img), img.CallbackURL, img.Owner, img.Size, img.ContentHash, img.Format, img.ExpiresAt,
+			img.Priority, img.MessageID, img.Width, img.Height, img.ColorSpace,
+		)
+
+		if img.MessageID != "" {
+			messageIDs = append(messageIDs, img.MessageID)
+		}
+	}
+
+	err := r.WithTx(ctx, func(ctx context.Context) error {
+		if _, err := r.conn(ctx).ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+
+		if len(messageIDs) == 0 {
+			return nil
+		}
+
+		// The images saved earlier for their message keep their ID.
+		placeholders := make([]string, 0, len(messageIDs))
+		idArgs := make([]interface{}, 0, len(messageIDs))
+		for _, messageID := range messageIDs {
+			idArgs = append(idArgs, messageID)
+			placeholders = append(placeholders, fmt.Sprintf("$%d", len(idArgs)))
+		}
+
+		rows, err := r.conn(ctx).QueryContext(ctx,
+			`SELECT id, message_id FROM images WHERE message_id IN (`+strings.Join(placeholders, ", ")+`)`, idArgs...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		saved, err := scanSavedIDs(rows)
+		if err != nil {
+			return err
+		}
+
+		ids = keepSavedIDs(ids, imgs, saved)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("save derived: failed to save images: %w", err)
+	}
+
+	return ids, nil
+}
+
+// GetImage retrieves an image record by ID from the database.
+// Expired images are reported as not found even before they are swept.
+func (r *MySQLRepository) GetImage(ctx context.Context, id uuid.UUID) (model.Image, error) {
+	query := `
+		SELECT ` + imageColumns + `
+		FROM images
+		WHERE id = $1 AND ` + mysqlNotExpired + ` AND ` + notDeleted
+
+	img, err := scanImage(r.conn(ctx).QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.Image{}, ErrImageNotFound
+		}
+
+		return model.Image{}, fmt.Errorf("get: %w", err)
+	}
+
+	return img, nil
+}
+
+// FindOriginalByHash returns the oldest original uploaded by the owner with the given content hash.
+// Expiring originals are skipped so that a permanent upload never resolves to one about to be deleted.
+// Returns ErrImageNotFound if there is none.
+func (r *MySQLRepository) FindOriginalByHash(ctx context.Context, owner, hash string) (model.Image, error) {
+	query := `
+		SELECT ` + imageColumns + `
+		FROM images
+		WHERE owner = $1 AND content_hash = $2 AND original_id IS NULL AND expires_at IS NULL AND deleted_at IS NULL
+		ORDER BY created_at
+		LIMIT 1
+    `
+
+	img, err := scanImage(r.conn(ctx).QueryRowContext(ctx, query, owner, hash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.Image{}, ErrImageNotFound
+		}
+
+		return model.Image{}, fmt.Errorf("find by hash: %w", err)
+	}
+
+	return img, nil
+}
+
+// UpdateImage updates the path and status of an existing image by ID.
+// Moving it to the failed status records the time of the failure; any other status clears it.
+func (r *MySQLRepository) UpdateImage(ctx context.Context, id uuid.UUID, path, status string) error {
+	query := `
+		UPDATE images
+		SET path = $1, status = $2, failed_at = CASE WHEN $2 = 'failed' THEN NOW(6) END
+		WHERE id = $3
+    `
+
+	return r.execUpdate(ctx, "update", query, path, status, id)
+}
+
+// UpdateMetadata applies the user-supplied metadata changes to an image by ID.
+// Fields left nil in the update keep their current value.
+func (r *MySQLRepository) UpdateMetadata(ctx context.Context, id uuid.UUID, upd model.MetadataUpdate) error {
+	query := `
+		UPDATE images
+		SET title = COALESCE($1, title),
+		    description = COALESCE($2, description),
+		    tags = COALESCE($3, tags)
+		WHERE id = $4
+    `
+
+	// A nil interface is sent as NULL, keeping the current tags.
+	var tags interface{}
+	if upd.Tags != nil {
+		tags = tagsJSON(*upd.Tags)
+	}
+
+	return r.execUpdate(ctx, "update metadata", query, upd.Title, upd.Description, tags, id)
+}
+
+// UpdateOCRText stores the text extracted from an image by ID.
+func (r *MySQLRepository) UpdateOCRText(ctx context.Context, id uuid.UUID, text string) error {
+	query := `
+		UPDATE images
+		SET ocr_text = $1
+		WHERE id = $2
+    `
+
+	return r.execUpdate(ctx, "update ocr text", query, text, id)
+}
+
+// DeleteImage deletes an image record by ID from the database, along with its derived images.
+// They are deleted explicitly, as the foreign key cascade wouldn't fire the triggers on images.
+func (r *MySQLRepository) DeleteImage(ctx context.Context, id uuid.UUID) error {
+	var n int64
+
+	err := r.WithTx(ctx, func(ctx context.Context) error {
+		if _, err := r.conn(ctx).ExecContext(ctx, `DELETE FROM images WHERE original_id = $1`, id); err != nil {
+			return fmt.Errorf("delete: failed to delete derived images: %w", err)
+		}
+
+		res, err := r.conn(ctx).ExecContext(ctx, `DELETE FROM images WHERE id = $1`, id)
+		if err != nil {
+			return fmt.Errorf("delete: failed to delete image: %w", err)
+		}
+
+		if n, err = res.RowsAffected(); err != nil {
+			return fmt.Errorf("delete: failed to get number of rows affected: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrImageNotFound
+	}
+
+	return nil
+}
+
+// SoftDeleteImage marks the image with the given ID and the images derived from it deleted, hiding
+// them from reads. Their records and files are kept until purged by the sweeper with DeleteImage.
+func (r *MySQLRepository) SoftDeleteImage(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE images
+		SET deleted_at = NOW(6)
+		WHERE (id = $1 OR original_id = $1)
+		  AND deleted_at IS NULL
+    `
+
+	res, err := r.conn(ctx).ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("soft delete: failed to mark image deleted: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("soft delete: failed to get number of rows affected: %w", err)
+	}
+
+	if n == 0 {
+		return ErrImageNotFound
+	}
+
+	return nil
+}
+
+// ListDeleted returns up to limit images soft-deleted before the given moment, in the order they
+// were deleted. Derived images come before their original, deleted along with them, so that purging
+// the images in order deletes their files before the record of the original cascades to theirs.
+func (r *MySQLRepository) ListDeleted(ctx context.Context, before time.Time, limit int) ([]model.Image, error) {
+	query := `
+		SELECT ` + imageColumns + `
+		FROM images
+		WHERE deleted_at < $1
+		ORDER BY deleted_at, original_id IS NULL
+		LIMIT $2
+    `
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list deleted: failed to query images: %w", err)
+	}
+	defer rows.Close()
+
+	images, err := scanImages(rows, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list deleted: %w", err)
+	}
+
+	return images, nil
+}
+
+// ListChanges returns up to limit change feed entries with a sequence number greater than since,
+// ordered by sequence number. Changes are numbered in commit order as they are recorded, under the
+// lock of the counter row, as in the PostgreSQL repository.
+func (r *MySQLRepository) ListChanges(ctx context.Context, since int64, limit int) ([]model.Change, error) {
+	query := `
+		SELECT seq, image_id, operation, path, changed_at
+		FROM image_changes
+		WHERE seq > $1
+		ORDER BY seq
+		LIMIT $2
+    `
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list changes: failed to query changes: %w", err)
+	}
+	defer rows.Close()
+
+	changes := make([]model.Change, 0, limit)
+	for rows.Next() {
+		var ch model.Change
+		if err := rows.Scan(&ch.Seq, &ch.ImageID, &ch.Operation, &ch.Path, &ch.ChangedAt); err != nil {
+			return nil, fmt.Errorf("list changes: failed to scan change: %w", err)
+		}
+
+		changes = append(changes, ch)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list changes: failed to iterate changes: %w", err)
+	}
+
+	return changes, nil
+}
+
+// ListImages returns images matching the filter, newest first
+// or by relevance when the filter has a full-text query.
+func (r *MySQLRepository) ListImages(ctx context.Context, f model.ImageFilter) ([]model.Image, error) {
+	where, args := mysqlFilterConditions(f)
+
+	// Keyset pagination continues strictly after the last image of the previous page.
+	if f.After != nil {
+		args = append(args, f.After.CreatedAt, f.After.ID)
+		where += fmt.Sprintf(" AND (created_at, id) < ($%d, $%d)", len(args)-1, len(args))
+	}
+
+	query := `
+		SELECT ` + imageColumns + `
+		FROM images
+    ` + where
+
+	// Full-text matches are ranked by relevance; ties and plain listings are newest first.
+	if match, negated := booleanQuery(f.Query); match != "" && !negated {
+		args = append(args, match)
+		query += fmt.Sprintf(" ORDER BY MATCH (search_text) AGAINST ($%d IN BOOLEAN MODE) DESC,", len(args))
+	} else {
+		query += " ORDER BY"
+	}
+
+	args = append(args, f.Limit, f.Offset)
+	query += fmt.Sprintf(" created_at DESC, id DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list: failed to query images: %w", err)
+	}
+	defer rows.Close()
+
+	images, err := scanImages(rows, f.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("list: %w", err)
+	}
+
+	return images, nil
+}
+
+// CountImages returns the number of images matching the filter, ignoring its pagination.
+func (r *MySQLRepository) CountImages(ctx context.Context, f model.ImageFilter) (int, error) {
+	where, args := mysqlFilterConditions(f)
+
+	query := `
+		SELECT COUNT(*)
+		FROM images
+    ` + where
+
+	var n int
+	if err := r.conn(ctx).QueryRowContext(ctx, query, args...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count: failed to count images: %w", err)
+	}
+
+	return n, nil
+}
+
+// mysqlFilterConditions builds the WHERE clause for the filter along with its arguments.
+// Expired images are always excluded, soft-deleted ones unless the filter includes them.
+func mysqlFilterConditions(f model.ImageFilter) (string, []interface{}) {
+	var (
+		conds = []string{mysqlNotExpired}
+		args  []interface{}
+	)
+
+	if !f.IncludeDeleted {
+		conds = append(conds, notDeleted)
+	}
+
+	// arg adds a query argument and returns its placeholder.
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	addCond := func(cond string, v interface{}) {
+		conds = append(conds, fmt.Sprintf(cond, arg(v)))
+	}
+
+	// anyTag builds a condition matching images carrying at least one of the tags.
+	anyTag := func(tags []string) string {
+		checks := make([]string, 0, len(tags))
+		for _, t := range tags {
+			checks = append(checks, "JSON_CONTAINS(tags, "+arg(tagsJSON([]string{t}))+")")
+		}
+
+		return "(" + strings.Join(checks, " OR ") + ")"
+	}
+
+	if f.Query != "" {
+		switch match, negated := booleanQuery(f.Query); {
+		case match == "":
+			conds = append(conds, "FALSE")
+		case negated:
+			addCond("NOT MATCH (search_text) AGAINST (%s IN BOOLEAN MODE)", match)
+		default:
+			addCond("MATCH (search_text) AGAINST (%s IN BOOLEAN MODE)", match)
+		}
+	}
+	if f.Status != "" {
+		addCond("status = %s", f.Status)
+	}
+	if f.Action != "" {
+		addCond("action = %s", f.Action)
+	}
+	if f.Owner != "" {
+		addCond("owner = %s", f.Owner)
+	}
+	if f.Filename != "" {
+		addCond("filename LIKE %s", "%"+escapeLike(f.Filename)+"%")
+	}
+	if f.Title != "" {
+		addCond("title LIKE %s", "%"+escapeLike(f.Title)+"%")
+	}
+	if len(f.Tags) > 0 {
+		addCond("JSON_CONTAINS(tags, %s)", tagsJSON(f.Tags))
+	}
+	if len(f.AnyTags) > 0 {
+		conds = append(conds, anyTag(f.AnyTags))
+	}
+	if len(f.ExcludedTags) > 0 {
+		conds = append(conds, "NOT "+anyTag(f.ExcludedTags))
+	}
+	switch f.Kind {
+	case model.KindOriginal:
+		conds = append(conds, "original_id IS NULL")
+	case model.KindDerived:
+		conds = append(conds, "original_id IS NOT NULL")
+	}
+	if len(f.Formats) > 0 {
+		placeholders := make([]string, 0, len(f.Formats))
+		for _, format := range f.Formats {
+			placeholders = append(placeholders, arg(format))
+		}
+		conds = append(conds, "format IN ("+strings.Join(placeholders, ", ")+")")
+	}
+	if f.MinSize > 0 {
+		addCond("size_bytes >= %s", f.MinSize)
+	}
+	if f.MaxSize > 0 {
+		addCond("size_bytes <= %s", f.MaxSize)
+	}
+	if !f.From.IsZero() {
+		addCond("created_at >= %s", f.From)
+	}
+	if !f.To.IsZero() {
+		addCond("created_at < %s", f.To)
+	}
+
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// booleanQuery converts a web search query to a full-text query in boolean mode. A query made only
+// of excluded terms is returned as the query of the excluded terms with negated set, to be matched
+// by the images it doesn't match. Returns an empty match if the query has no terms.
+func booleanQuery(q string) (match string, negated bool) {
+	sq := parseSearch(q)
+
+	// phrases quotes the terms, which can't contain quotes in boolean mode, separated by spaces.
+	phrases := func(terms []string) string {
+		quoted := make([]string, 0, len(terms))
+		for _, t := range terms {
+			quoted = append(quoted, `"`+strings.ReplaceAll(t, `"`, " ")+`"`)
+		}
+
+		return strings.Join(quoted, " ")
+	}
+
+	switch {
+	case sq.empty():
+		return "", false
+	case sq.onlyExcluded():
+		return phrases(sq.excluded), true
+	}
+
+	clauses := make([]string, 0, len(sq.required)+len(sq.excluded))
+	for _, alts := range sq.required {
+		clauses = append(clauses, "+("+phrases(alts)+")")
+	}
+	for _, t := range sq.excluded {
+		clauses = append(clauses, "-"+phrases([]string{t}))
+	}
+
+	return strings.Join(clauses, " "), false
+}
+
+// PathInUse reports whether any image record references the stored file at path.
+func (r *MySQLRepository) PathInUse(ctx context.Context, path string) (bool, error) {
+	query := `
+		SELECT EXISTS (SELECT 1 FROM images WHERE path = $1)
+    `
+
+	var inUse bool
+	if err := r.conn(ctx).QueryRowContext(ctx, query, path).Scan(&inUse); err != nil {
+		return false, fmt.Errorf("path in use: %w", err)
+	}
+
+	return inUse, nil
+}
+
+// ListDerived returns all images derived from the original with the given ID, oldest first.
+func (r *MySQLRepository) ListDerived(ctx context.Context, originalID uuid.UUID) ([]model.Image, error) {
+	query := `
+		SELECT ` + imageColumns + `
+		FROM images
+		WHERE original_id = $1 AND deleted_at IS NULL
+		ORDER BY created_at, id
+    `
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, originalID)
+	if err != nil {
+		return nil, fmt.Errorf("list derived: failed to query images: %w", err)
+	}
+	defer rows.Close()
+
+	images, err := scanImages(rows, 0)
+	if err != nil {
+		return nil, fmt.Errorf("list derived: %w", err)
+	}
+
+	return images, nil
+}
+
+// ListExpired returns up to limit originals whose expiry time has passed, oldest expiry first.
+// Their derived images share the expiry and are deleted along with them.
+func (r *MySQLRepository) ListExpired(ctx context.Context, limit int) ([]model.Image, error) {
+	query := `
+		SELECT ` + imageColumns + `
+		FROM images
+		WHERE expires_at <= NOW(6) AND original_id IS NULL
+		ORDER BY expires_at
+		LIMIT $1
+    `
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list expired: failed to query images: %w", err)
+	}
+	defer rows.Close()
+
+	images, err := scanImages(rows, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list expired: %w", err)
+	}
+
+	return images, nil
+}
+
+// GetStatus retrieves the detailed processing status of an image by ID.
+func (r *MySQLRepository) GetStatus(ctx context.Context, id uuid.UUID) (model.ProcessingStatus, error) {
+	query := `
+		SELECT status, stage, attempts, COALESCE(last_error, ''), started_at, finished_at, duration_ms,
+		       created_at, updated_at
+		FROM images
+		WHERE id = $1 AND deleted_at IS NULL
+    `
+
+	st := model.ProcessingStatus{ID: id}
+
+	err := r.conn(ctx).QueryRowContext(ctx, query, id).Scan(
+		&st.Status, &st.Stage, &st.Attempts, &st.LastError, &st.StartedAt, &st.FinishedAt, &st.DurationMs,
+		&st.CreatedAt, &st.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.ProcessingStatus{}, ErrImageNotFound
+		}
+
+		return model.ProcessingStatus{}, fmt.Errorf("get status: failed to get image status: %w", err)
+	}
+
+	return st, nil
+}
+
+// BeginAttempt increments the attempt counter of an image, records the start of the attempt
+// and moves it to the decoding stage.
+func (r *MySQLRepository) BeginAttempt(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE images
+		SET attempts = attempts + 1, stage = $1, started_at = NOW(6), finished_at = NULL, duration_ms = NULL,
+		    updated_at = NOW(6)
+		WHERE id = $2
+    `
+
+	return r.execUpdate(ctx, "begin attempt", query, model.StageDecoding, id)
+}
+
+// UpdateStage moves an image to the given processing stage.
+func (r *MySQLRepository) UpdateStage(ctx context.Context, id uuid.UUID, stage string) error {
+	query := `
+		UPDATE images
+		SET stage = $1, updated_at = NOW(6)
+		WHERE id = $2
+    `
+
+	return r.execUpdate(ctx, "update stage", query, stage, id)
+}
+
+// FinishAttempt moves an image to the done stage and records the end and duration of the attempt.
+func (r *MySQLRepository) FinishAttempt(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE images
+		SET stage = $1, ` + mysqlFinishAttempt + `, updated_at = NOW(6)
+		WHERE id = $2
+    `
+
+	return r.execUpdate(ctx, "finish attempt", query, model.StageDone, id)
+}
+
+// FailAttempt moves an image to the failed stage and records the error, end and duration of the attempt.
+func (r *MySQLRepository) FailAttempt(ctx context.Context, id uuid.UUID, errMsg string) error {
+	query := `
+		UPDATE images
+		SET stage = $1, last_error = $2, ` + mysqlFinishAttempt + `, updated_at = NOW(6)
+		WHERE id = $3
+    `
+
+	return r.execUpdate(ctx, "fail attempt", query, model.StageFailed, errMsg, id)
+}
+
+// execUpdate executes an image update query and reports ErrImageNotFound if no row was affected.
+// Rows matched but left unchanged count as affected, as the DSN enables clientFoundRows.
+func (r *MySQLRepository) execUpdate(ctx context.Context, op, query string, args ...interface{}) error {
+	res, err := r.conn(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: failed to update image: %w", op, err)
+	}
+
+	rows, _ := res.RowsAffected()
+
+	if rows == 0 {
+		return ErrImageNotFound
+	}
+
+	return nil
+}
+
+// GetUsage returns the storage and rate usage of the given owner, counting stored objects once
+// per owner as Repository does.
+func (r *MySQLRepository) GetUsage(ctx context.Context, owner string) (model.QuotaUsage, error) {
+	query := `
+		SELECT (SELECT COALESCE(SUM(size_bytes), 0)
+		        FROM (SELECT MAX(size_bytes) AS size_bytes FROM images WHERE owner = $1 GROUP BY path) stored),
+		       COALESCE(SUM(original_id IS NULL AND created_at >= NOW(6) - INTERVAL 1 DAY), 0),
+		       COALESCE(SUM(original_id IS NULL AND created_at >= NOW(6) - INTERVAL 1 HOUR), 0)
+		FROM images
+		WHERE owner = $1
+    `
+
+	var u model.QuotaUsage
+
+	err := r.conn(ctx).QueryRowContext(ctx, query, owner).Scan(&u.BytesStored, &u.ImagesLastDay, &u.JobsLastHour)
+	if err != nil {
+		return model.QuotaUsage{}, fmt.Errorf("get usage: failed to get usage: %w", err)
+	}
+
+	return u, nil
+}
+
+// StorageUsage returns the bytes and objects stored per owner and top-level storage directory,
+// grouped and filtered as requested, largest first. The usage is kept up to date by triggers on images.
+func (r *MySQLRepository) StorageUsage(ctx context.Context, f model.UsageFilter) ([]model.StorageUsage, error) {
+	owner, subdir := "owner", "subdir"
+	switch f.GroupBy {
+	case model.UsageByOwner:
+		subdir = "''"
+	case model.UsageBySubdir:
+		owner = "''"
+	case "":
+	default:
+		return nil, fmt.Errorf("storage usage: unknown grouping: %s", f.GroupBy)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %[1]s, %[2]s, COALESCE(SUM(bytes), 0), COALESCE(SUM(objects), 0)
+		FROM storage_usage
+		WHERE ($1 = '' OR owner = $1) AND ($2 = '' OR subdir = $2) AND objects > 0
+		GROUP BY %[1]s, %[2]s
+		ORDER BY 3 DESC, 1, 2
+    `, owner, subdir)
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, f.Owner, f.Subdir)
+	if err != nil {
+		return nil, fmt.Errorf("storage usage: failed to query usage: %w", err)
+	}
+	defer rows.Close()
+
+	var usage []model.StorageUsage
+	for rows.Next() {
+		var u model.StorageUsage
+		if err := rows.Scan(&u.Owner, &u.Subdir, &u.Bytes, &u.Objects); err != nil {
+			return nil, fmt.Errorf("storage usage: failed to scan usage: %w", err)
+		}
+
+		usage = append(usage, u)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("storage usage: failed to iterate usage: %w", err)
+	}
+
+	return usage, nil
+}
+
+// ListJobs returns the processing jobs of uploaded originals in the requested state, oldest update first
+// unless the filter asks for the newest first.
+func (r *MySQLRepository) ListJobs(ctx context.Context, f model.JobFilter) ([]model.Job, error) {
+	query := `
+		SELECT id, filename, action, status, stage, attempts, COALESCE(last_error, ''), started_at, finished_at,
+		       duration_ms, created_at, updated_at, redrives
+		FROM images
+		WHERE original_id IS NULL AND deleted_at IS NULL
+    `
+
+	args := []interface{}{model.StageFailed}
+
+	switch f.State {
+	case model.JobStateFailed:
+		query += " AND stage = $1"
+	case model.JobStateStuck:
+		args = append(args, model.StageDone, f.StuckSince)
+		query += " AND stage NOT IN ($1, $2) AND updated_at < $3"
+	default:
+		return nil, fmt.Errorf("list jobs: unknown job state: %s", f.State)
+	}
+
+	order := "updated_at, id"
+	if f.NewestFirst {
+		order = "updated_at DESC, id DESC"
+	}
+
+	args = append(args, f.Limit, f.Offset)
+	query += fmt.Sprintf(" ORDER BY %s LIMIT $%d OFFSET $%d", order, len(args)-1, len(args))
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list jobs: failed to query jobs: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := make([]model.Job, 0, f.Limit)
+	for rows.Next() {
+		var j model.Job
+		err := rows.Scan(
+			&j.ID, &j.Filename, &j.Action, &j.Status, &j.Stage, &j.Attempts, &j.LastError, &j.StartedAt, &j.FinishedAt,
+			&j.DurationMs, &j.CreatedAt, &j.UpdatedAt, &j.Redrives,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("list jobs: failed to scan job: %w", err)
+		}
+
+		jobs = append(jobs, j)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list jobs: failed to iterate jobs: %w", err)
+	}
+
+	return jobs, nil
+}
+
+// RequeueImage moves an image back to the queued stage and clears the error of its last attempt.
+func (r *MySQLRepository) RequeueImage(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE images
+		SET status = 'pending', stage = $1, last_error = NULL, failed_at = NULL,
+		    started_at = NULL, finished_at = NULL, duration_ms = NULL, updated_at = NOW(6)
+		WHERE id = $2
+    `
+
+	return r.execUpdate(ctx, "requeue image", query, model.StageQueued, id)
+}
+
+// RedriveImage moves a stuck image back to the queued stage and counts the redrive.
+// Images finished or updated since the given moment are left alone and reported as ErrImageNotFound,
+// so that a worker picking the job up concurrently isn't overridden.
+func (r *MySQLRepository) RedriveImage(ctx context.Context, id uuid.UUID, stuckSince time.Time) error {
+	query := `
+		UPDATE images
+		SET stage = $1, redrives = redrives + 1, updated_at = NOW(6)
+		WHERE id = $2 AND stage NOT IN ($3, $4) AND updated_at < $5
+    `
+
+	return r.execUpdate(ctx, "redrive image", query,
+		model.StageQueued, id, model.StageDone, model.StageFailed, stuckSince)
+}
+
+// FailStuckImage moves a stuck image to the failed status with the given error.
+// Like RedriveImage, images finished or updated since the given moment are reported as ErrImageNotFound.
+func (r *MySQLRepository) FailStuckImage(ctx context.Context, id uuid.UUID, stuckSince time.Time, errMsg string) error {
+	query := `
+		UPDATE images
+		SET status = 'failed', stage = $1, last_error = $2, failed_at = NOW(6), ` + mysqlFinishAttempt + `,
+		    updated_at = NOW(6)
+		WHERE id = $3 AND stage NOT IN ($4, $1) AND updated_at < $5
+    `
+
+	return r.execUpdate(ctx, "fail stuck image", query,
+		model.StageFailed, errMsg, id, model.StageDone, stuckSince)
+}
+
+// DeleteDerived deletes all images derived from the original with the given ID.
+// Returns the number of deleted records.
+func (r *MySQLRepository) DeleteDerived(ctx context.Context, originalID uuid.UUID) (int64, error) {
+	query := `
+		DELETE FROM images WHERE original_id = $1
+    `
+
+	res, err := r.conn(ctx).ExecContext(ctx, query, originalID)
+	if err != nil {
+		return 0, fmt.Errorf("delete derived: failed to delete images: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("delete derived: failed to get number of rows affected: %w", err)
+	}
+
+	return n, nil
+}
+
+// ActionStats returns per-action job counts for originals uploaded since the given moment.
+func (r *MySQLRepository) ActionStats(ctx context.Context, since time.Time) ([]model.ActionStats, error) {
+	query := `
+		SELECT action,
+		       COUNT(*),
+		       SUM(stage = $1),
+		       SUM(stage = $2),
+		       SUM(stage NOT IN ($1, $2)),
+		       COALESCE(AVG(CASE WHEN stage = $1 THEN duration_ms END), 0)
+		FROM images
+		WHERE original_id IS NULL AND created_at >= $3
+		GROUP BY action
+		ORDER BY action
+    `
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, model.StageDone, model.StageFailed, since)
+	if err != nil {
+		return nil, fmt.Errorf("action stats: failed to query stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []model.ActionStats
+	for rows.Next() {
+		var st model.ActionStats
+		if err := rows.Scan(&st.Action, &st.Total, &st.Processed, &st.Failed, &st.Pending, &st.AvgDurationMs); err != nil {
+			return nil, fmt.Errorf("action stats: failed to scan stats: %w", err)
+		}
+
+		stats = append(stats, st)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("action stats: failed to iterate stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// StatusCounts returns the number of originals uploaded since the given moment per status.
+func (r *MySQLRepository) StatusCounts(ctx context.Context, since time.Time) ([]model.StatusCount, error) {
+	query := `
+		SELECT status, COUNT(*)
+		FROM images
+		WHERE original_id IS NULL AND created_at >= $1
+		GROUP BY status
+		ORDER BY status
+    `
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, since)
+	if err != nil {
+		return nil, fmt.Errorf("status counts: failed to query counts: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []model.StatusCount
+	for rows.Next() {
+		var sc model.StatusCount
+		if err := rows.Scan(&sc.Status, &sc.Count); err != nil {
+			return nil, fmt.Errorf("status counts: failed to scan count: %w", err)
+		}
+
+		counts = append(counts, sc)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("status counts: failed to iterate counts: %w", err)
+	}
+
+	return counts, nil
+}
+
+// QueueDepth returns the number of unfinished jobs of originals, whenever uploaded: queued, being
+// processed, and stuck among them, i.e. not updated since stuckSince.
+func (r *MySQLRepository) QueueDepth(ctx context.Context, stuckSince time.Time) (model.QueueDepth, error) {
+	query := `
+		SELECT COALESCE(SUM(CASE WHEN stage = $1 THEN 1 ELSE 0 END), 0),
+		       COALESCE(SUM(CASE WHEN stage <> $1 THEN 1 ELSE 0 END), 0),
+		       COALESCE(SUM(CASE WHEN updated_at < $2 THEN 1 ELSE 0 END), 0)
+		FROM images
+		WHERE original_id IS NULL AND deleted_at IS NULL AND stage NOT IN ($3, $4)
+    `
+
+	var d model.QueueDepth
+	err := r.conn(ctx).QueryRowContext(ctx, query, model.StageQueued, stuckSince, model.StageDone, model.StageFailed).
+		Scan(&d.Queued, &d.Processing, &d.Stuck)
+	if err != nil {
+		return model.QueueDepth{}, fmt.Errorf("queue depth: failed to count jobs: %w", err)
+	}
+
+	return d, nil
+}
+
+// HourlyStats returns the processing jobs of originals finished since the given moment per hour, oldest first.
+// Hours without finished jobs are omitted.
+func (r *MySQLRepository) HourlyStats(ctx context.Context, since time.Time) ([]model.HourlyStats, error) {
+	query := `
+		SELECT CAST(DATE_FORMAT(finished_at, '%Y-%m-%d %H:00:00') AS DATETIME),
+		       SUM(stage = $1),
+		       SUM(stage = $2),
+		       COALESCE(AVG(CASE WHEN stage = $1 THEN duration_ms END), 0)
+		FROM images
+		WHERE original_id IS NULL AND finished_at >= $3
+		GROUP BY 1
+		ORDER BY 1
+    `
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, model.StageDone, model.StageFailed, since)
+	if err != nil {
+		return nil, fmt.Errorf("hourly stats: failed to query stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []model.HourlyStats
+	for rows.Next() {
+		var st model.HourlyStats
+		if err := rows.Scan(&st.Hour, &st.Processed, &st.Failed, &st.AvgDurationMs); err != nil {
+			return nil, fmt.Errorf("hourly stats: failed to scan stats: %w", err)
+		}
+
+		stats = append(stats, st)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("hourly stats: failed to iterate stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// SaveAuditEvent appends an event to the audit log.
+func (r *MySQLRepository) SaveAuditEvent(ctx context.Context, ev model.AuditEvent) error {
+	query := `
+		INSERT INTO audit_events (event, image_id, actor, request_id, details)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''))
+    `
+
+	if _, err := r.conn(ctx).ExecContext(ctx, query, ev.Event, ev.ImageID, ev.Actor, ev.RequestID, ev.Details); err != nil {
+		return fmt.Errorf("save audit event: failed to save event: %w", err)
+	}
+
+	return nil
+}
+
+// ListAuditEvents returns the audit events matching the filter, newest first.
+func (r *MySQLRepository) ListAuditEvents(ctx context.Context, f model.AuditFilter) ([]model.AuditEvent, error) {
+	var (
+		conds []string
+		args  []interface{}
+	)
+
+	addCond := func(cond string, v interface{}) {
+		args = append(args, v)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.ImageID != uuid.Nil {
+		addCond("image_id = $%d", f.ImageID)
+	}
+	if f.Event != "" {
+		addCond("event = $%d", f.Event)
+	}
+	if f.Actor != "" {
+		addCond("actor = $%d", f.Actor)
+	}
+	if !f.From.IsZero() {
+		addCond("created_at >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		addCond("created_at < $%d", f.To)
+	}
+
+	query := `
+		SELECT id, event, image_id, actor, COALESCE(request_id, ''), COALESCE(details, ''), created_at
+		FROM audit_events
+    `
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	args = append(args, f.Limit, f.Offset)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list audit events: failed to query events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]model.AuditEvent, 0, f.Limit)
+	for rows.Next() {
+		var ev model.AuditEvent
+		if err := rows.Scan(
+			&ev.ID, &ev.Event, &ev.ImageID, &ev.Actor, &ev.RequestID, &ev.Details, &ev.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("list audit events: failed to scan event: %w", err)
+		}
+
+		events = append(events, ev)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list audit events: failed to iterate events: %w", err)
+	}
+
+	return events, nil
+}
+
+// EnqueueOutbox stores the processing task of an image in the outbox, to be published by the relay.
+// Called in the transaction saving the image, the task is stored if and only if the image is.
+func (r *MySQLRepository) EnqueueOutbox(ctx context.Context, msg model.OutboxMessage) error {
+	query := `
+		INSERT INTO outbox (image_id, payload, headers)
+		VALUES ($1, $2, $3)
+    `
+
+	payload, err := json.Marshal(msg.Image)
+	if err != nil {
+		return fmt.Errorf("enqueue outbox: failed to marshal image: %w", err)
+	}
+
+	headers, err := json.Marshal(msg.Headers)
+	if err != nil {
+		return fmt.Errorf("enqueue outbox: failed to marshal headers: %w", err)
+	}
+
+	if _, err := r.conn(ctx).ExecContext(ctx, query, msg.Image.ID, string(payload), string(headers)); err != nil {
+		return fmt.Errorf("enqueue outbox: failed to insert message: %w", err)
+	}
+
+	return nil
+}
+
+// PendingOutbox locks and returns up to limit outbox messages, oldest first. Messages locked by
+// another relay are skipped, so it must be called in WithTx, which holds the locks until it ends.
+func (r *MySQLRepository) PendingOutbox(ctx context.Context, limit int) ([]model.OutboxMessage, error) {
+	query := `
+		SELECT id, payload, headers, created_at
+		FROM outbox
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+    `
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("pending outbox: failed to query messages: %w", err)
+	}
+	defer rows.Close()
+
+	var msgs []model.OutboxMessage
+	for rows.Next() {
+		var (
+			msg              model.OutboxMessage
+			payload, headers []byte
+		)
+		if err := rows.Scan(&msg.ID, &payload, &headers, &msg.CreatedAt); err != nil {
+			return nil, fmt.Errorf("pending outbox: failed to scan message: %w", err)
+		}
+
+		if err := json.Unmarshal(payload, &msg.Image); err != nil {
+			return nil, fmt.Errorf("pending outbox: failed to unmarshal image: %w", err)
+		}
+		if err := json.Unmarshal(headers, &msg.Headers); err != nil {
+			return nil, fmt.Errorf("pending outbox: failed to unmarshal headers: %w", err)
+		}
+
+		msgs = append(msgs, msg)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("pending outbox: failed to iterate messages: %w", err)
+	}
+
+	return msgs, nil
+}
+
+// DeleteOutbox removes a published message from the outbox.
+func (r *MySQLRepository) DeleteOutbox(ctx context.Context, id int64) error {
+	query := `
+		DELETE FROM outbox
+		WHERE id = $1
+    `
+
+	if _, err := r.conn(ctx).ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("delete outbox: failed to delete message: %w", err)
+	}
+
+	return nil
+}
+
+// SaveDeadLetter records a message that failed processing and returns its ID.
+func (r *MySQLRepository) SaveDeadLetter(ctx context.Context, dl model.DeadLetter) (uuid.UUID, error) {
+	query := "INSERT INTO dead_letters (id, topic, `partition`, `offset`, `key`, payload, error, attempts, failed_at)" + `
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+    `
+
+	id := uuid.New()
+
+	_, err := r.conn(ctx).ExecContext(
+		ctx, query, id, dl.Topic, dl.Partition, dl.Offset, dl.Key, []byte(dl.Payload), dl.Error, dl.Attempts, dl.FailedAt,
+	)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("save dead letter: failed to save dead letter: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetDeadLetter retrieves a dead letter by ID.
+func (r *MySQLRepository) GetDeadLetter(ctx context.Context, id uuid.UUID) (model.DeadLetter, error) {
+	query := `
+		SELECT ` + mysqlDeadLetterColumns + `
+		FROM dead_letters
+		WHERE id = $1
+    `
+
+	dl, err := scanDeadLetter(r.conn(ctx).QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.DeadLetter{}, ErrDeadLetterNotFound
+		}
+
+		return model.DeadLetter{}, fmt.Errorf("get dead letter: %w", err)
+	}
+
+	return dl, nil
+}
+
+// ListDeadLetters returns dead letters, most recent failures first.
+// Already replayed ones are skipped unless includeReplayed is set.
+func (r *MySQLRepository) ListDeadLetters(ctx context.Context, includeReplayed bool, limit, offset int) ([]model.DeadLetter, error) {
+	query := `
+		SELECT ` + mysqlDeadLetterColumns + `
+		FROM dead_letters
+		WHERE $1 OR replayed_at IS NULL
+		ORDER BY failed_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+    `
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, includeReplayed, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("list dead letters: failed to query dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	letters := make([]model.DeadLetter, 0, limit)
+	for rows.Next() {
+		dl, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, fmt.Errorf("list dead letters: %w", err)
+		}
+
+		letters = append(letters, dl)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list dead letters: failed to iterate dead letters: %w", err)
+	}
+
+	return letters, nil
+}
+
+// MarkDeadLetterReplayed records that the dead letter was re-enqueued.
+func (r *MySQLRepository) MarkDeadLetterReplayed(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE dead_letters
+		SET replayed_at = NOW(6)
+		WHERE id = $1
+    `
+
+	res, err := r.conn(ctx).ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("mark dead letter replayed: failed to update dead letter: %w", err)
+	}
+
+	rows, _ := res.RowsAffected()
+
+	if rows == 0 {
+		return ErrDeadLetterNotFound
+	}
+
+	return nil
+}
+
+// GetProcessedMessage returns the ID of the derived image produced for the message.
+// Returns ErrMessageNotProcessed if the message has not been processed yet.
+func (r *MySQLRepository) GetProcessedMessage(ctx context.Context, messageID string) (uuid.UUID, error) {
+	query := `
+		SELECT derived_id
+		FROM processed_messages
+		WHERE message_id = $1
+    `
+
+	var derivedID uuid.UUID
+	if err := r.conn(ctx).QueryRowContext(ctx, query, messageID).Scan(&derivedID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return uuid.Nil, ErrMessageNotProcessed
+		}
+
+		return uuid.Nil, fmt.Errorf("get processed message: %w", err)
+	}
+
+	return derivedID, nil
+}
+
+// MarkMessageProcessed records that the message produced the derived image of the original.
+// Recording the same message again keeps the first record.
+func (r *MySQLRepository) MarkMessageProcessed(ctx context.Context, messageID string, imageID, derivedID uuid.UUID) error {
+	query := `
+		INSERT INTO processed_messages (message_id, image_id, derived_id)
+		VALUES ($1, $2, $3)
+		ON DUPLICATE KEY UPDATE message_id = message_id
+    `
+
+	if _, err := r.conn(ctx).ExecContext(ctx, query, messageID, imageID, derivedID); err != nil {
+		return fmt.Errorf("mark message processed: failed to insert message: %w", err)
+	}
+
+	return nil
+}
+
+// BeginMessageAttempt counts a new processing attempt of the message and returns its attempts so far.
+func (r *MySQLRepository) BeginMessageAttempt(ctx context.Context, messageID string) (model.MessageAttempt, error) {
+	query := `
+		INSERT INTO message_attempts (message_id, attempts)
+		VALUES ($1, 1)
+		ON DUPLICATE KEY UPDATE attempts = attempts + 1, updated_at = NOW(6)
+    `
+
+	a := model.MessageAttempt{MessageID: messageID}
+
+	err := r.WithTx(ctx, func(ctx context.Context) error {
+		if _, err := r.conn(ctx).ExecContext(ctx, query, messageID); err != nil {
+			return err
+		}
+
+		return r.conn(ctx).QueryRowContext(
+			ctx, `SELECT attempts, COALESCE(last_error, '') FROM message_attempts WHERE message_id = $1`, messageID,
+		).Scan(&a.Attempts, &a.LastError)
+	})
+	if err != nil {
+		return model.MessageAttempt{}, fmt.Errorf("begin message attempt: %w", err)
+	}
+
+	return a, nil
+}
+
+// FailMessageAttempt records the error of the current processing attempt of the message.
+func (r *MySQLRepository) FailMessageAttempt(ctx context.Context, messageID, errMsg string) error {
+	query := `
+		UPDATE message_attempts
+		SET last_error = $1, updated_at = NOW(6)
+		WHERE message_id = $2
+    `
+
+	if _, err := r.conn(ctx).ExecContext(ctx, query, errMsg, messageID); err != nil {
+		return fmt.Errorf("fail message attempt: failed to update attempts: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteMessageAttempts forgets the attempts of a message that was processed or given up on.
+func (r *MySQLRepository) DeleteMessageAttempts(ctx context.Context, messageID string) error {
+	query := `
+		DELETE FROM message_attempts WHERE message_id = $1
+    `
+
+	if _, err := r.conn(ctx).ExecContext(ctx, query, messageID); err != nil {
+		return fmt.Errorf("delete message attempts: %w", err)
+	}
+
+	return nil
+}
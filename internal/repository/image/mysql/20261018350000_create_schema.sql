@@ -0,0 +1,209 @@
+-- Schema of the MySQL/MariaDB backend, matching the PostgreSQL migrations up to 20261018330000_create_outbox.
+-- Timestamps are stored as UTC DATETIME(6) values (the connection time zone is UTC) and UUIDs as text.
+-- Foreign key cascades don't fire triggers, so the repository deletes derived images itself.
+CREATE TABLE IF NOT EXISTS images
+(
+    id           CHAR(36)     NOT NULL PRIMARY KEY,
+    original_id  CHAR(36),
+    filename     VARCHAR(512) NOT NULL,
+    path         VARCHAR(512) NOT NULL,
+    action       VARCHAR(64)  NOT NULL,
+    params       JSON,
+    status       VARCHAR(32)  NOT NULL DEFAULT 'pending',
+    stage        VARCHAR(32)  NOT NULL DEFAULT 'queued',
+    attempts     INT          NOT NULL DEFAULT 0,
+    last_error   TEXT,
+    ocr_text     MEDIUMTEXT,
+    callback_url TEXT,
+    owner        VARCHAR(255) NOT NULL DEFAULT 'anonymous',
+    size_bytes   BIGINT       NOT NULL DEFAULT 0,
+    content_hash CHAR(64),
+    title        TEXT,
+    description  TEXT,
+    tags         JSON         NOT NULL DEFAULT ('[]'),
+    format       VARCHAR(16),
+    expires_at   DATETIME(6),
+    failed_at    DATETIME(6),
+    priority     VARCHAR(16)  NOT NULL DEFAULT 'normal',
+    message_id   VARCHAR(255),
+    redrives     INT          NOT NULL DEFAULT 0,
+    started_at   DATETIME(6),
+    finished_at  DATETIME(6),
+    duration_ms  BIGINT,
+    width        INT,
+    height       INT,
+    color_space  VARCHAR(16),
+    -- Words of the filename, tags and OCR text, maintained by triggers for the full-text index.
+    search_text  MEDIUMTEXT,
+    created_at   DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at   DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    CONSTRAINT fk_images_original_id FOREIGN KEY (original_id) REFERENCES images (id) ON DELETE CASCADE,
+    UNIQUE KEY idx_images_message_id (message_id),
+    KEY idx_images_owner_created_at (owner, created_at),
+    KEY idx_images_owner_content_hash (owner, content_hash),
+    KEY idx_images_format (format),
+    KEY idx_images_size_bytes (size_bytes),
+    KEY idx_images_created_at (created_at, id),
+    KEY idx_images_expires_at (expires_at),
+    KEY idx_images_path (path),
+    KEY idx_images_finished_at (finished_at),
+    FULLTEXT KEY idx_images_search_text (search_text)
+) DEFAULT CHARSET = utf8mb4;
+
+-- Words in filenames are usually joined by punctuation ("summer_trip-01.jpg"), which the full-text
+-- parser keeps in words, so it is replaced with spaces; so are the brackets and quotes of the tags.
+CREATE TRIGGER images_search_insert
+    BEFORE INSERT
+    ON images
+    FOR EACH ROW
+    SET NEW.search_text = CONCAT_WS(' ',
+        REGEXP_REPLACE(NEW.filename, '[._-]+', ' '),
+        REGEXP_REPLACE(CAST(NEW.tags AS CHAR), '[][",]+', ' '),
+        NEW.ocr_text);
+
+CREATE TRIGGER images_search_update
+    BEFORE UPDATE
+    ON images
+    FOR EACH ROW
+    SET NEW.search_text = CONCAT_WS(' ',
+        REGEXP_REPLACE(NEW.filename, '[._-]+', ' '),
+        REGEXP_REPLACE(CAST(NEW.tags AS CHAR), '[][",]+', ' '),
+        NEW.ocr_text);
+
+CREATE TABLE IF NOT EXISTS image_changes
+(
+    seq        BIGINT       NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    image_id   CHAR(36)     NOT NULL,
+    operation  VARCHAR(16)  NOT NULL,
+    path       VARCHAR(512) NOT NULL,
+    changed_at DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6)
+) DEFAULT CHARSET = utf8mb4;
+
+-- Bytes and objects stored per owner and top-level storage directory, maintained by the triggers below.
+CREATE TABLE IF NOT EXISTS storage_usage
+(
+    owner      VARCHAR(255) NOT NULL,
+    subdir     VARCHAR(255) NOT NULL,
+    bytes      BIGINT       NOT NULL DEFAULT 0,
+    objects    BIGINT       NOT NULL DEFAULT 0,
+    updated_at DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (owner, subdir)
+) DEFAULT CHARSET = utf8mb4;
+
+CREATE TRIGGER images_after_insert
+    AFTER INSERT
+    ON images
+    FOR EACH ROW
+BEGIN
+    INSERT INTO image_changes (image_id, operation, path) VALUES (NEW.id, 'created', NEW.path);
+
+    INSERT INTO storage_usage (owner, subdir, bytes, objects)
+    VALUES (NEW.owner, SUBSTRING_INDEX(NEW.path, '/', 1), NEW.size_bytes, 1)
+    ON DUPLICATE KEY UPDATE bytes      = bytes + VALUES(bytes),
+                            objects    = objects + 1,
+                            updated_at = CURRENT_TIMESTAMP(6);
+END;
+
+CREATE TRIGGER images_after_update
+    AFTER UPDATE
+    ON images
+    FOR EACH ROW
+BEGIN
+    INSERT INTO image_changes (image_id, operation, path) VALUES (NEW.id, 'updated', NEW.path);
+
+    IF NOT (OLD.owner <=> NEW.owner AND OLD.path <=> NEW.path AND OLD.size_bytes <=> NEW.size_bytes) THEN
+        UPDATE storage_usage
+        SET bytes      = bytes - OLD.size_bytes,
+            objects    = objects - 1,
+            updated_at = CURRENT_TIMESTAMP(6)
+        WHERE owner = OLD.owner
+          AND subdir = SUBSTRING_INDEX(OLD.path, '/', 1);
+
+        INSERT INTO storage_usage (owner, subdir, bytes, objects)
+        VALUES (NEW.owner, SUBSTRING_INDEX(NEW.path, '/', 1), NEW.size_bytes, 1)
+        ON DUPLICATE KEY UPDATE bytes      = bytes + VALUES(bytes),
+                                objects    = objects + 1,
+                                updated_at = CURRENT_TIMESTAMP(6);
+    END IF;
+END;
+
+CREATE TRIGGER images_after_delete
+    AFTER DELETE
+    ON images
+    FOR EACH ROW
+BEGIN
+    INSERT INTO image_changes (image_id, operation, path) VALUES (OLD.id, 'deleted', OLD.path);
+
+    UPDATE storage_usage
+    SET bytes      = bytes - OLD.size_bytes,
+        objects    = objects - 1,
+        updated_at = CURRENT_TIMESTAMP(6)
+    WHERE owner = OLD.owner
+      AND subdir = SUBSTRING_INDEX(OLD.path, '/', 1);
+END;
+
+CREATE TABLE IF NOT EXISTS dead_letters
+(
+    id          CHAR(36)     NOT NULL PRIMARY KEY,
+    topic       VARCHAR(255) NOT NULL,
+    `partition` INT          NOT NULL,
+    `offset`    BIGINT       NOT NULL,
+    `key`       TEXT         NOT NULL,
+    payload     LONGBLOB     NOT NULL,
+    error       TEXT         NOT NULL,
+    attempts    INT          NOT NULL,
+    failed_at   DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    replayed_at DATETIME(6),
+    KEY idx_dead_letters_failed_at (failed_at)
+) DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS processed_messages
+(
+    message_id   VARCHAR(255) NOT NULL PRIMARY KEY,
+    image_id     CHAR(36)     NOT NULL,
+    derived_id   CHAR(36)     NOT NULL,
+    processed_at DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6)
+) DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS message_attempts
+(
+    message_id VARCHAR(255) NOT NULL PRIMARY KEY,
+    attempts   INT          NOT NULL DEFAULT 0,
+    last_error TEXT,
+    updated_at DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6)
+) DEFAULT CHARSET = utf8mb4;
+
+-- Append-only audit log of image lifecycle events; image_id has no foreign key as events outlive images.
+CREATE TABLE IF NOT EXISTS audit_events
+(
+    id         BIGINT       NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    event      VARCHAR(32)  NOT NULL,
+    image_id   CHAR(36)     NOT NULL,
+    actor      VARCHAR(255) NOT NULL,
+    request_id VARCHAR(255),
+    details    TEXT,
+    created_at DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    KEY idx_audit_events_image_id (image_id, id),
+    KEY idx_audit_events_created_at (created_at)
+) DEFAULT CHARSET = utf8mb4;
+
+CREATE TRIGGER audit_events_no_update
+    BEFORE UPDATE
+    ON audit_events
+    FOR EACH ROW
+    SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_events is append-only';
+
+CREATE TRIGGER audit_events_no_delete
+    BEFORE DELETE
+    ON audit_events
+    FOR EACH ROW
+    SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_events is append-only';
+
+CREATE TABLE IF NOT EXISTS outbox
+(
+    id         BIGINT      NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    image_id   CHAR(36)    NOT NULL,
+    payload    JSON        NOT NULL,
+    headers    JSON        NOT NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6)
+) DEFAULT CHARSET = utf8mb4;
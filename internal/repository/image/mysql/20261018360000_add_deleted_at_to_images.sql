@@ -0,0 +1,4 @@
+-- Time an image was soft-deleted, as in the PostgreSQL migration 20261018360000_add_deleted_at_to_images.
+ALTER TABLE images
+    ADD COLUMN deleted_at DATETIME(6),
+    ADD KEY idx_images_deleted_at (deleted_at);
@@ -0,0 +1,21 @@
+-- Changes recorded unnumbered and numbered once committed, as in the PostgreSQL migration
+-- 20261019170000_number_image_changes_on_read. The counter row holds the last number handed out
+-- and is locked by the reader numbering changes.
+ALTER TABLE image_changes
+    MODIFY seq BIGINT NOT NULL,
+    DROP PRIMARY KEY,
+    ADD COLUMN id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY FIRST;
+
+ALTER TABLE image_changes
+    MODIFY seq BIGINT NULL,
+    ADD UNIQUE KEY idx_image_changes_seq (seq);
+
+CREATE TABLE IF NOT EXISTS image_change_counter
+(
+    id  TINYINT NOT NULL PRIMARY KEY,
+    seq BIGINT  NOT NULL
+);
+
+INSERT INTO image_change_counter (id, seq)
+SELECT 1, COALESCE(MAX(seq), 0)
+FROM image_changes;
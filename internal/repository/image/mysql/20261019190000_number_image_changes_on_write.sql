@@ -0,0 +1,82 @@
+-- Changes numbered as they are recorded, under the lock of the counter row, as in the PostgreSQL
+-- migration 20261019190000_number_image_changes_on_write.
+UPDATE image_changes c
+    JOIN (SELECT id, ROW_NUMBER() OVER (ORDER BY id) AS n FROM image_changes WHERE seq IS NULL) p
+    ON c.id = p.id
+    JOIN image_change_counter k ON k.id = 1
+SET c.seq = k.seq + p.n;
+
+UPDATE image_change_counter
+SET seq = (SELECT COALESCE(MAX(seq), 0) FROM image_changes)
+WHERE id = 1;
+
+ALTER TABLE image_changes
+    MODIFY seq BIGINT NOT NULL;
+
+DROP TRIGGER IF EXISTS images_after_insert;
+DROP TRIGGER IF EXISTS images_after_update;
+DROP TRIGGER IF EXISTS images_after_delete;
+
+CREATE TRIGGER images_after_insert
+    AFTER INSERT
+    ON images
+    FOR EACH ROW
+BEGIN
+    DECLARE next_seq BIGINT;
+
+    SELECT seq + 1 INTO next_seq FROM image_change_counter WHERE id = 1 FOR UPDATE;
+    UPDATE image_change_counter SET seq = next_seq WHERE id = 1;
+    INSERT INTO image_changes (seq, image_id, operation, path) VALUES (next_seq, NEW.id, 'created', NEW.path);
+
+    INSERT INTO storage_usage (owner, subdir, bytes, objects)
+    VALUES (NEW.owner, SUBSTRING_INDEX(NEW.path, '/', 1), NEW.size_bytes, 1)
+    ON DUPLICATE KEY UPDATE bytes      = bytes + VALUES(bytes),
+                            objects    = objects + 1,
+                            updated_at = CURRENT_TIMESTAMP(6);
+END;
+
+CREATE TRIGGER images_after_update
+    AFTER UPDATE
+    ON images
+    FOR EACH ROW
+BEGIN
+    DECLARE next_seq BIGINT;
+
+    SELECT seq + 1 INTO next_seq FROM image_change_counter WHERE id = 1 FOR UPDATE;
+    UPDATE image_change_counter SET seq = next_seq WHERE id = 1;
+    INSERT INTO image_changes (seq, image_id, operation, path) VALUES (next_seq, NEW.id, 'updated', NEW.path);
+
+    IF NOT (OLD.owner <=> NEW.owner AND OLD.path <=> NEW.path AND OLD.size_bytes <=> NEW.size_bytes) THEN
+        UPDATE storage_usage
+        SET bytes      = bytes - OLD.size_bytes,
+            objects    = objects - 1,
+            updated_at = CURRENT_TIMESTAMP(6)
+        WHERE owner = OLD.owner
+          AND subdir = SUBSTRING_INDEX(OLD.path, '/', 1);
+
+        INSERT INTO storage_usage (owner, subdir, bytes, objects)
+        VALUES (NEW.owner, SUBSTRING_INDEX(NEW.path, '/', 1), NEW.size_bytes, 1)
+        ON DUPLICATE KEY UPDATE bytes      = bytes + VALUES(bytes),
+                                objects    = objects + 1,
+                                updated_at = CURRENT_TIMESTAMP(6);
+    END IF;
+END;
+
+CREATE TRIGGER images_after_delete
+    AFTER DELETE
+    ON images
+    FOR EACH ROW
+BEGIN
+    DECLARE next_seq BIGINT;
+
+    SELECT seq + 1 INTO next_seq FROM image_change_counter WHERE id = 1 FOR UPDATE;
+    UPDATE image_change_counter SET seq = next_seq WHERE id = 1;
+    INSERT INTO image_changes (seq, image_id, operation, path) VALUES (next_seq, OLD.id, 'deleted', OLD.path);
+
+    UPDATE storage_usage
+    SET bytes      = bytes - OLD.size_bytes,
+        objects    = objects - 1,
+        updated_at = CURRENT_TIMESTAMP(6)
+    WHERE owner = OLD.owner
+      AND subdir = SUBSTRING_INDEX(OLD.path, '/', 1);
+END;
@@ -0,0 +1,124 @@
+-- Stored objects accounted once per owner, as in the PostgreSQL migration
+-- 20261019200000_count_shared_objects_once. The other records of a path are looked for with a
+-- locking read, after locking the usage row, so that they are read as last committed.
+DROP TRIGGER IF EXISTS images_after_insert;
+DROP TRIGGER IF EXISTS images_after_update;
+DROP TRIGGER IF EXISTS images_after_delete;
+
+CREATE TRIGGER images_after_insert
+    AFTER INSERT
+    ON images
+    FOR EACH ROW
+BEGIN
+    DECLARE next_seq BIGINT;
+    DECLARE shared INT;
+
+    SELECT seq + 1 INTO next_seq FROM image_change_counter WHERE id = 1 FOR UPDATE;
+    UPDATE image_change_counter SET seq = next_seq WHERE id = 1;
+    INSERT INTO image_changes (seq, image_id, operation, path) VALUES (next_seq, NEW.id, 'created', NEW.path);
+
+    INSERT INTO storage_usage (owner, subdir)
+    VALUES (NEW.owner, SUBSTRING_INDEX(NEW.path, '/', 1))
+    ON DUPLICATE KEY UPDATE updated_at = CURRENT_TIMESTAMP(6);
+
+    SELECT COUNT(*) INTO shared
+    FROM images
+    WHERE owner = NEW.owner AND path = NEW.path AND id <> NEW.id
+    LOCK IN SHARE MODE;
+
+    IF shared = 0 THEN
+        UPDATE storage_usage
+        SET bytes   = bytes + NEW.size_bytes,
+            objects = objects + 1
+        WHERE owner = NEW.owner
+          AND subdir = SUBSTRING_INDEX(NEW.path, '/', 1);
+    END IF;
+END;
+
+CREATE TRIGGER images_after_update
+    AFTER UPDATE
+    ON images
+    FOR EACH ROW
+BEGIN
+    DECLARE next_seq BIGINT;
+    DECLARE shared INT;
+
+    SELECT seq + 1 INTO next_seq FROM image_change_counter WHERE id = 1 FOR UPDATE;
+    UPDATE image_change_counter SET seq = next_seq WHERE id = 1;
+    INSERT INTO image_changes (seq, image_id, operation, path) VALUES (next_seq, NEW.id, 'updated', NEW.path);
+
+    IF NOT (OLD.owner <=> NEW.owner AND OLD.path <=> NEW.path AND OLD.size_bytes <=> NEW.size_bytes) THEN
+        UPDATE storage_usage
+        SET updated_at = CURRENT_TIMESTAMP(6)
+        WHERE owner = OLD.owner
+          AND subdir = SUBSTRING_INDEX(OLD.path, '/', 1);
+
+        SELECT COUNT(*) INTO shared
+        FROM images
+        WHERE owner = OLD.owner AND path = OLD.path AND id <> OLD.id
+        LOCK IN SHARE MODE;
+
+        IF shared = 0 THEN
+            UPDATE storage_usage
+            SET bytes   = bytes - OLD.size_bytes,
+                objects = objects - 1
+            WHERE owner = OLD.owner
+              AND subdir = SUBSTRING_INDEX(OLD.path, '/', 1);
+        END IF;
+
+        INSERT INTO storage_usage (owner, subdir)
+        VALUES (NEW.owner, SUBSTRING_INDEX(NEW.path, '/', 1))
+        ON DUPLICATE KEY UPDATE updated_at = CURRENT_TIMESTAMP(6);
+
+        SELECT COUNT(*) INTO shared
+        FROM images
+        WHERE owner = NEW.owner AND path = NEW.path AND id <> NEW.id
+        LOCK IN SHARE MODE;
+
+        IF shared = 0 THEN
+            UPDATE storage_usage
+            SET bytes   = bytes + NEW.size_bytes,
+                objects = objects + 1
+            WHERE owner = NEW.owner
+              AND subdir = SUBSTRING_INDEX(NEW.path, '/', 1);
+        END IF;
+    END IF;
+END;
+
+CREATE TRIGGER images_after_delete
+    AFTER DELETE
+    ON images
+    FOR EACH ROW
+BEGIN
+    DECLARE next_seq BIGINT;
+    DECLARE shared INT;
+
+    SELECT seq + 1 INTO next_seq FROM image_change_counter WHERE id = 1 FOR UPDATE;
+    UPDATE image_change_counter SET seq = next_seq WHERE id = 1;
+    INSERT INTO image_changes (seq, image_id, operation, path) VALUES (next_seq, OLD.id, 'deleted', OLD.path);
+
+    UPDATE storage_usage
+    SET updated_at = CURRENT_TIMESTAMP(6)
+    WHERE owner = OLD.owner
+      AND subdir = SUBSTRING_INDEX(OLD.path, '/', 1);
+
+    SELECT COUNT(*) INTO shared
+    FROM images
+    WHERE owner = OLD.owner AND path = OLD.path
+    LOCK IN SHARE MODE;
+
+    IF shared = 0 THEN
+        UPDATE storage_usage
+        SET bytes   = bytes - OLD.size_bytes,
+            objects = objects - 1
+        WHERE owner = OLD.owner
+          AND subdir = SUBSTRING_INDEX(OLD.path, '/', 1);
+    END IF;
+END;
+
+DELETE FROM storage_usage;
+
+INSERT INTO storage_usage (owner, subdir, bytes, objects)
+SELECT owner, SUBSTRING_INDEX(path, '/', 1), SUM(size_bytes), COUNT(*)
+FROM (SELECT owner, path, MAX(size_bytes) AS size_bytes FROM images GROUP BY owner, path) stored
+GROUP BY owner, SUBSTRING_INDEX(path, '/', 1);
@@ -6,8 +6,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/wb-go/wbf/dbpg"
 
 	"github.com/aliskhannn/image-processor/internal/model"
@@ -15,80 +18,431 @@ import (
 
 var ErrImageNotFound = errors.New("image not found")
 
+// ErrDeadLetterNotFound is returned when a dead letter does not exist.
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// ErrMessageNotProcessed is returned when a queue message has not been processed yet.
+var ErrMessageNotProcessed = errors.New("message not processed")
+
+// imageColumns is the column list selected for model.Image, in the order expected by scanImage.
+const imageColumns = `id, original_id, filename, path, action, params, status, COALESCE(ocr_text, ''),
+		COALESCE(callback_url, ''), owner, priority, size_bytes, COALESCE(content_hash, ''), COALESCE(title, ''),
+		COALESCE(description, ''), tags, COALESCE(format, ''), COALESCE(width, 0), COALESCE(height, 0),
+		COALESCE(color_space, ''), expires_at,
+		CASE WHEN status = 'failed' THEN COALESCE(last_error, '') ELSE '' END, failed_at, deleted_at, created_at`
+
+// notDeleted excludes soft-deleted images. Reads exclude them unless a filter includes them;
+// the sweeper, storage accounting, statistics and the change feed still see them.
+const notDeleted = `deleted_at IS NULL`
+
+// notExpired excludes images past their expiry time that the sweeper has not deleted yet.
+const notExpired = `(expires_at IS NULL OR expires_at > now())`
+
+// derivedOf selects the images derived from the image of $1. Image IDs hold their creation time
+// (see insertImages), so lookups by ID bound created_at with image_created_at and Postgres skips the
+// monthly partitions before the image's. Derived images are looked up from a day before their
+// original, as the hosts saving them may have clocks behind.
+const derivedOf = `original_id = $1 AND created_at >= image_created_at($1) - INTERVAL '1 day'`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// querier runs read queries, implemented by *sql.DB, *sql.Tx and *dbpg.DB.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// execer runs queries on the master, implemented by both *sql.DB and *sql.Tx.
+type execer interface {
+	querier
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// txKey is the context key of the transaction started by WithTx.
+type txKey struct{}
+
+// replicaKey is the context key marking reads that may be served by the slaves.
+type replicaKey struct{}
+
 // Repository provides CRUD operations for images in the database.
+// Queries run on the master, except for the image reads (GetImage, ListImages and CountImages)
+// given a context returned by ReplicaReads, which may be routed to the slaves.
+// Queries given the context passed by WithTx run in its transaction instead.
 type Repository struct {
-	db *dbpg.DB
+	db               *dbpg.DB
+	readFromReplicas bool
 }
 
 // NewRepository creates a new Repository with the given DB connection.
-func NewRepository(db *dbpg.DB) *Repository {
-	return &Repository{db: db}
+// With readFromReplicas, image reads served to clients are balanced across the slaves of the connection,
+// trading replication lag for a lighter master under read-heavy traffic.
+func NewRepository(db *dbpg.DB, readFromReplicas bool) *Repository {
+	return &Repository{db: db, readFromReplicas: readFromReplicas}
+}
+
+// ReplicaReads returns a context whose image reads may be served by the slaves. It marks the reads
+// served to clients, which tolerate the replication delay; jobs and reads following a write keep
+// reading from the master.
+func ReplicaReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replicaKey{}, true)
+}
+
+// Ping verifies that the master is reachable.
+func (r *Repository) Ping(ctx context.Context) error {
+	return r.db.Master.PingContext(ctx)
+}
+
+// Close closes the master and slave connections.
+func (r *Repository) Close() error {
+	var errs []error
+
+	if err := r.db.Master.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to close master: %w", err))
+	}
+	for i, s := range r.db.Slaves {
+		if err := s.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close slave %d: %w", i, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// WithTx runs fn with a context whose queries run in a single transaction on the master,
+// committed if fn returns nil and rolled back otherwise. Nested calls join the outer transaction.
+func (r *Repository) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := r.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("with tx: failed to begin transaction: %w", err)
+	}
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return errors.Join(err, fmt.Errorf("with tx: failed to roll back: %w", rbErr))
+		}
+
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("with tx: failed to commit: %w", err)
+	}
+
+	return nil
+}
+
+// EnsurePartitions creates the monthly partitions of images for the current month and the given
+// number of months ahead, if missing, and returns the number of partitions created. Images created
+// in a month without a partition are stored in the default partition.
+func (r *Repository) EnsurePartitions(ctx context.Context, monthsAhead int) (int, error) {
+	var created int
+	if err := r.db.Master.QueryRowContext(ctx, `SELECT ensure_images_partitions($1)`, monthsAhead).Scan(&created); err != nil {
+		return 0, fmt.Errorf("ensure partitions: failed to create partitions: %w", err)
+	}
+
+	return created, nil
+}
+
+// master returns the connection serving writes and consistent reads: the transaction of ctx if any,
+// the master otherwise.
+func (r *Repository) master(ctx context.Context) execer {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+
+	return r.db.Master
+}
+
+// reader returns the connection serving image reads: the transaction of ctx if any,
+// the slaves if enabled, configured and allowed by ctx, the master otherwise.
+func (r *Repository) reader(ctx context.Context) querier {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+
+	replica, _ := ctx.Value(replicaKey{}).(bool)
+
+	switch {
+	case replica && r.readFromReplicas && len(r.db.Slaves) > 0:
+		return r.db
+	default:
+		return r.db.Master
+	}
 }
 
 // SaveImage inserts a new image record into the database and returns its UUID.
+// Saving is idempotent per message ID: an image saved again for the same message
+// replaces the file and size of the existing record and returns its ID.
 func (r *Repository) SaveImage(ctx context.Context, img model.Image) (uuid.UUID, error) {
+	ids, err := r.saveImages(ctx, []model.Image{img})
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("save: %w", err)
+	}
+
+	return ids[0], nil
+}
+
+// SaveDerivedImages inserts the records of several images derived by a job in a single statement,
+// e.g. the outputs of a variant set, and returns their UUIDs in order. As with SaveImage, saving
+// is idempotent per message ID, so the images must each carry a distinct message ID, if any;
+// images saved again keep their ID.
+func (r *Repository) SaveDerivedImages(ctx context.Context, imgs []model.Image) ([]uuid.UUID, error) {
+	if len(imgs) == 0 {
+		return nil, nil
+	}
+
+	ids, err := r.saveImages(ctx, imgs)
+	if err != nil {
+		return nil, fmt.Errorf("save derived: %w", err)
+	}
+
+	return ids, nil
+}
+
+// saveImages saves imgs in a transaction and returns their UUIDs in order. The images table is
+// partitioned by creation month, so no index keeps message IDs unique across partitions: the
+// message IDs of imgs are locked for the transaction instead, the images already saved for their
+// message are updated and the others are inserted in a single statement.
+func (r *Repository) saveImages(ctx context.Context, imgs []model.Image) ([]uuid.UUID, error) {
+	var messageIDs []string
+	for _, img := range imgs {
+		if img.MessageID != "" {
+			messageIDs = append(messageIDs, img.MessageID)
+		}
+	}
+
+	ids := make([]uuid.UUID, len(imgs))
+	err := r.WithTx(ctx, func(ctx context.Context) error {
+		saved, err := r.lockMessages(ctx, messageIDs)
+		if err != nil {
+			return err
+		}
+
+		var inserted []int
+		for i, img := range imgs {
+			id, ok := saved[img.MessageID]
+			if !ok {
+				if ids[i], err = uuid.NewV7(); err != nil {
+					return fmt.Errorf("failed to generate image id: %w", err)
+				}
+				inserted = append(inserted, i)
+				continue
+			}
+
+			if err := r.updateSavedImage(ctx, id, img); err != nil {
+				return err
+			}
+			ids[i] = id
+		}
+
+		return r.insertImages(ctx, ids, imgs, inserted)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// lockMessages locks the given message IDs until the end of the transaction of ctx, serializing
+// the saves of images for the same message, and returns the IDs of the images already saved for
+// them by message ID. Locks are taken in order, so that batches sharing messages don't deadlock.
+func (r *Repository) lockMessages(ctx context.Context, messageIDs []string) (map[string]uuid.UUID, error) {
+	if len(messageIDs) == 0 {
+		return nil, nil
+	}
+
+	lock := `
+		SELECT pg_advisory_xact_lock(hashtext('images.message_id'), hashtext(m))
+		FROM unnest($1::text[]) AS m
+		ORDER BY m
+    `
+
+	if _, err := r.master(ctx).ExecContext(ctx, lock, pq.Array(messageIDs)); err != nil {
+		return nil, fmt.Errorf("failed to lock message ids: %w", err)
+	}
+
 	query := `
-		INSERT INTO images (filename, path, action, params, status)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id
-   `
+		SELECT id, message_id FROM images WHERE message_id = ANY($1)
+    `
 
-	paramsJSON, err := json.Marshal(img.Action.Params)
+	rows, err := r.master(ctx).QueryContext(ctx, query, pq.Array(messageIDs))
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("failed to marshal action params: %w", err)
+		return nil, fmt.Errorf("failed to query saved images: %w", err)
 	}
+	defer rows.Close()
 
-	var id uuid.UUID
-	err = r.db.QueryRowContext(
-		ctx, query, img.Filename, img.Path, img.Action.Name, paramsJSON, img.Status,
-	).Scan(&id)
+	return scanSavedIDs(rows)
+}
+
+// updateSavedImage replaces the file and size of the image with the given ID, saved again for its message.
+func (r *Repository) updateSavedImage(ctx context.Context, id uuid.UUID, img model.Image) error {
+	query := `
+		UPDATE images
+		SET path = $2, size_bytes = $3, format = NULLIF($4, ''), width = NULLIF($5, 0), height = NULLIF($6, 0),
+		    color_space = NULLIF($7, '')
+		WHERE id = $1 AND created_at >= image_created_at($1)
+    `
+
+	_, err := r.master(ctx).ExecContext(ctx, query, id, img.Path, img.Size, img.Format, img.Width, img.Height, img.ColorSpace)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("save: failed to save image: %w", err)
+		return fmt.Errorf("failed to update image: %w", err)
 	}
 
-	return id, nil
+	return nil
+}
+
+// insertImages inserts the images of imgs at the given indexes in a single statement, with the IDs
+// of ids at the same indexes. IDs are UUIDv7 and images are created at the time their ID holds, so
+// that lookups by ID can bound created_at.
+func (r *Repository) insertImages(ctx context.Context, ids []uuid.UUID, imgs []model.Image, indexes []int) error {
+	if len(indexes) == 0 {
+		return nil
+	}
+
+	row := `(
+			$1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, $11, NULLIF($12, ''), NULLIF($13, ''), $14,
+			COALESCE(NULLIF($15, ''), 'normal'), NULLIF($16, ''), NULLIF($17, 0), NULLIF($18, 0), NULLIF($19, ''),
+			image_created_at($1)
+		)`
+
+	query := `
+		INSERT INTO images (
+			id, filename, path, action, params, status, original_id, stage, callback_url, owner, size_bytes,
+			content_hash, format, expires_at, priority, message_id, width, height, color_space, created_at
+		)
+		VALUES ` + valuesList(row, 19, len(indexes))
+
+	args := make([]interface{}, 0, 19*len(indexes))
+	for _, i := range indexes {
+		img := imgs[i]
+
+		paramsJSON, err := json.Marshal(img.Action.Params)
+		if err != nil {
+			return fmt.Errorf("failed to marshal action params: %w", err)
+		}
+
+		args = append(args,
+			ids[i], img.Filename, img.Path, img.Action.Name, paramsJSON, img.Status, img.OriginalID, savedStage(img),
+			img.CallbackURL, img.Owner, img.Size, img.ContentHash, img.Format, img.ExpiresAt, img.Priority,
+			img.MessageID, img.Width, img.Height, img.ColorSpace,
+		)
+	}
+
+	if _, err := r.master(ctx).ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to save images: %w", err)
+	}
+
+	return nil
+}
+
+// savedStage returns the stage an image starts in: images saved already processed (e.g. derived ones)
+// skip the queue.
+func savedStage(img model.Image) string {
+	if img.Status == model.StatusProcessed {
+		return model.StageDone
+	}
+
+	return model.StageQueued
+}
+
+// scanSavedIDs reads the IDs of saved images by message ID from rows of id and message_id.
+func scanSavedIDs(rows *sql.Rows) (map[string]uuid.UUID, error) {
+	saved := make(map[string]uuid.UUID)
+	for rows.Next() {
+		var (
+			id        uuid.UUID
+			messageID sql.NullString
+		)
+		if err := rows.Scan(&id, &messageID); err != nil {
+			return nil, fmt.Errorf("failed to scan image id: %w", err)
+		}
+
+		if messageID.Valid {
+			saved[messageID.String] = id
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate image ids: %w", err)
+	}
+
+	return saved, nil
+}
+
+// keepSavedIDs replaces the IDs generated for images saved again for their message with the IDs
+// they were saved with the first time.
+func keepSavedIDs(ids []uuid.UUID, imgs []model.Image, saved map[string]uuid.UUID) []uuid.UUID {
+	for i, img := range imgs {
+		if id, ok := saved[img.MessageID]; ok && img.MessageID != "" {
+			ids[i] = id
+		}
+	}
+
+	return ids
 }
 
 // GetImage retrieves an image record by ID from the database.
+// Expired images are reported as not found even before they are swept.
 func (r *Repository) GetImage(ctx context.Context, id uuid.UUID) (model.Image, error) {
 	query := `
-		SELECT filename, path, action, params, status, created_at
+		SELECT ` + imageColumns + `
 		FROM images
-		WHERE id = $1
-    `
-
-	var img model.Image
-	var paramsBytes []byte
+		WHERE id = $1 AND created_at >= image_created_at($1) AND ` + notExpired + ` AND ` + notDeleted
 
-	err := r.db.QueryRowContext(
-		ctx, query, id,
-	).Scan(&img.Filename, &img.Path, &img.Action.Name, &paramsBytes, &img.Status, &img.CreatedAt)
+	img, err := scanImage(r.reader(ctx).QueryRowContext(ctx, query, id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return model.Image{}, ErrImageNotFound
 		}
 
-		return model.Image{}, fmt.Errorf("get: failed to get image: %w", err)
+		return model.Image{}, fmt.Errorf("get: %w", err)
 	}
 
-	if err := json.Unmarshal(paramsBytes, &img.Action.Params); err != nil {
-		return model.Image{}, fmt.Errorf("get: failed to unmarshal params: %w", err)
-	}
+	return img, nil
+}
+
+// FindOriginalByHash returns the oldest original uploaded by the owner with the given content hash.
+// Expiring originals are skipped so that a permanent upload never resolves to one about to be deleted.
+// Returns ErrImageNotFound if there is none.
+func (r *Repository) FindOriginalByHash(ctx context.Context, owner, hash string) (model.Image, error) {
+	query := `
+		SELECT ` + imageColumns + `
+		FROM images
+		WHERE owner = $1 AND content_hash = $2 AND original_id IS NULL AND expires_at IS NULL AND deleted_at IS NULL
+		ORDER BY created_at
+		LIMIT 1
+    `
+
+	img, err := scanImage(r.master(ctx).QueryRowContext(ctx, query, owner, hash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.Image{}, ErrImageNotFound
+		}
 
-	img.ID = id
+		return model.Image{}, fmt.Errorf("find by hash: %w", err)
+	}
 
 	return img, nil
 }
 
 // UpdateImage updates the path and status of an existing image by ID.
+// Moving it to the failed status records the time of the failure; any other status clears it.
 func (r *Repository) UpdateImage(ctx context.Context, id uuid.UUID, path, status string) error {
 	query := `
 		UPDATE images
-		SET path = $1, status = $2
-		WHERE id = $3
+		SET path = $1, status = $2, failed_at = CASE WHEN $2 = 'failed' THEN NOW() END
+		WHERE id = $3 AND created_at >= image_created_at($3)
     `
 
-	res, err := r.db.ExecContext(ctx, query, path, status, id)
+	res, err := r.master(ctx).ExecContext(ctx, query, path, status, id)
 	if err != nil {
 		return fmt.Errorf("update: failed to update image: %w", err)
 	}
@@ -102,20 +456,113 @@ func (r *Repository) UpdateImage(ctx context.Context, id uuid.UUID, path, status
 	return nil
 }
 
-// DeleteImage deletes an image record by ID from the database.
+// UpdateMetadata applies the user-supplied metadata changes to an image by ID.
+// Fields left nil in the update keep their current value.
+func (r *Repository) UpdateMetadata(ctx context.Context, id uuid.UUID, upd model.MetadataUpdate) error {
+	query := `
+		UPDATE images
+		SET title = COALESCE($1, title),
+		    description = COALESCE($2, description),
+		    tags = COALESCE($3::jsonb, tags)
+		WHERE id = $4 AND created_at >= image_created_at($4)
+    `
+
+	// A nil interface is sent as NULL, keeping the current tags.
+	var tags interface{}
+	if upd.Tags != nil {
+		tagsJSON, err := json.Marshal(*upd.Tags)
+		if err != nil {
+			return fmt.Errorf("update metadata: failed to marshal tags: %w", err)
+		}
+		tags = string(tagsJSON)
+	}
+
+	res, err := r.master(ctx).ExecContext(ctx, query, upd.Title, upd.Description, tags, id)
+	if err != nil {
+		return fmt.Errorf("update metadata: failed to update image: %w", err)
+	}
+
+	rows, _ := res.RowsAffected()
+
+	if rows == 0 {
+		return ErrImageNotFound
+	}
+
+	return nil
+}
+
+// UpdateOCRText stores the text extracted from an image by ID.
+func (r *Repository) UpdateOCRText(ctx context.Context, id uuid.UUID, text string) error {
+	query := `
+		UPDATE images
+		SET ocr_text = $1
+		WHERE id = $2 AND created_at >= image_created_at($2)
+    `
+
+	res, err := r.master(ctx).ExecContext(ctx, query, text, id)
+	if err != nil {
+		return fmt.Errorf("update ocr text: failed to update image: %w", err)
+	}
+
+	rows, _ := res.RowsAffected()
+
+	if rows == 0 {
+		return ErrImageNotFound
+	}
+
+	return nil
+}
+
+// DeleteImage deletes an image record by ID from the database, along with its derived images
+// in the same transaction: the partitioned images table has no foreign key cascading the delete.
 func (r *Repository) DeleteImage(ctx context.Context, id uuid.UUID) error {
+	var n int64
+
+	err := r.WithTx(ctx, func(ctx context.Context) error {
+		if _, err := r.master(ctx).ExecContext(ctx, `DELETE FROM images WHERE `+derivedOf, id); err != nil {
+			return fmt.Errorf("delete: failed to delete derived images: %w", err)
+		}
+
+		res, err := r.master(ctx).ExecContext(ctx, `DELETE FROM images WHERE id = $1 AND created_at >= image_created_at($1)`, id)
+		if err != nil {
+			return fmt.Errorf("delete: failed to delete image: %w", err)
+		}
+
+		if n, err = res.RowsAffected(); err != nil {
+			return fmt.Errorf("delete: failed to get number of rows affected: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrImageNotFound
+	}
+
+	return nil
+}
+
+// SoftDeleteImage marks the image with the given ID and the images derived from it deleted, hiding
+// them from reads. Their records and files are kept until purged by the sweeper with DeleteImage.
+func (r *Repository) SoftDeleteImage(ctx context.Context, id uuid.UUID) error {
 	query := `
-		DELETE FROM images WHERE id = $1
+		UPDATE images
+		SET deleted_at = now()
+		WHERE ((id = $1 AND created_at >= image_created_at($1)) OR (` + derivedOf + `))
+		  AND deleted_at IS NULL
     `
 
-	rows, err := r.db.ExecContext(ctx, query, id)
+	res, err := r.master(ctx).ExecContext(ctx, query, id)
 	if err != nil {
-		return fmt.Errorf("delete: failed to delete image: %w", err)
+		return fmt.Errorf("soft delete: failed to mark image deleted: %w", err)
 	}
 
-	n, err := rows.RowsAffected()
+	n, err := res.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("delete: failed to get number of rows affected: %w", err)
+		return fmt.Errorf("soft delete: failed to get number of rows affected: %w", err)
 	}
 
 	if n == 0 {
@@ -124,3 +571,1095 @@ func (r *Repository) DeleteImage(ctx context.Context, id uuid.UUID) error {
 
 	return nil
 }
+
+// ListDeleted returns up to limit images soft-deleted before the given moment, in the order they
+// were deleted. Derived images come before their original, deleted along with them, so that purging
+// the images in order deletes their files before the record of the original cascades to theirs.
+func (r *Repository) ListDeleted(ctx context.Context, before time.Time, limit int) ([]model.Image, error) {
+	query := `
+		SELECT ` + imageColumns + `
+		FROM images
+		WHERE deleted_at < $1
+		ORDER BY deleted_at, original_id IS NULL
+		LIMIT $2
+    `
+
+	rows, err := r.master(ctx).QueryContext(ctx, query, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list deleted: failed to query images: %w", err)
+	}
+	defer rows.Close()
+
+	images, err := scanImages(rows, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list deleted: %w", err)
+	}
+
+	return images, nil
+}
+
+// ListChanges returns up to limit change feed entries with a sequence number greater than since,
+// ordered by sequence number. Changes are numbered in commit order as they are recorded, so reading
+// them writes nothing and may be served by the slaves.
+func (r *Repository) ListChanges(ctx context.Context, since int64, limit int) ([]model.Change, error) {
+	query := `
+		SELECT seq, image_id, operation, path, changed_at
+		FROM image_changes
+		WHERE seq > $1
+		ORDER BY seq
+		LIMIT $2
+    `
+
+	rows, err := r.reader(ctx).QueryContext(ctx, query, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list changes: failed to query changes: %w", err)
+	}
+	defer rows.Close()
+
+	changes := make([]model.Change, 0, limit)
+	for rows.Next() {
+		var ch model.Change
+		if err := rows.Scan(&ch.Seq, &ch.ImageID, &ch.Operation, &ch.Path, &ch.ChangedAt); err != nil {
+			return nil, fmt.Errorf("list changes: failed to scan change: %w", err)
+		}
+
+		changes = append(changes, ch)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list changes: failed to iterate changes: %w", err)
+	}
+
+	return changes, nil
+}
+
+// ListImages returns images matching the filter, newest first
+// or by relevance when the filter has a full-text query.
+func (r *Repository) ListImages(ctx context.Context, f model.ImageFilter) ([]model.Image, error) {
+	where, args := filterConditions(f)
+
+	// Keyset pagination continues strictly after the last image of the previous page,
+	// walking the (created_at, id) index instead of skipping rows.
+	if f.After != nil {
+		args = append(args, f.After.CreatedAt, f.After.ID)
+		where += fmt.Sprintf(" AND (created_at, id) < ($%d, $%d)", len(args)-1, len(args))
+	}
+
+	query := `
+		SELECT ` + imageColumns + `
+		FROM images
+    ` + where
+
+	// Full-text matches are ranked by relevance, with filename and tag hits
+	// weighted above OCR text; ties and plain listings are newest first.
+	if f.Query != "" {
+		args = append(args, f.Query)
+		query += fmt.Sprintf(" ORDER BY ts_rank(search_vector, websearch_to_tsquery('simple', $%d)) DESC,", len(args))
+	} else {
+		query += " ORDER BY"
+	}
+
+	args = append(args, f.Limit, f.Offset)
+	query += fmt.Sprintf(" created_at DESC, id DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := r.reader(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list: failed to query images: %w", err)
+	}
+	defer rows.Close()
+
+	images, err := scanImages(rows, f.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("list: %w", err)
+	}
+
+	return images, nil
+}
+
+// CountImages returns the number of images matching the filter, ignoring its pagination.
+func (r *Repository) CountImages(ctx context.Context, f model.ImageFilter) (int, error) {
+	where, args := filterConditions(f)
+
+	query := `
+		SELECT COUNT(*)
+		FROM images
+    ` + where
+
+	var n int
+	if err := r.reader(ctx).QueryRowContext(ctx, query, args...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count: failed to count images: %w", err)
+	}
+
+	return n, nil
+}
+
+// filterConditions builds the WHERE clause for the filter along with its arguments.
+// Expired images are always excluded, soft-deleted ones unless the filter includes them.
+func filterConditions(f model.ImageFilter) (string, []interface{}) {
+	var (
+		conds = []string{notExpired}
+		args  []interface{}
+	)
+
+	if !f.IncludeDeleted {
+		conds = append(conds, notDeleted)
+	}
+
+	// arg adds a query argument and returns its placeholder.
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	addCond := func(cond string, v interface{}) {
+		conds = append(conds, fmt.Sprintf(cond, arg(v)))
+	}
+
+	// anyTag builds a condition matching images carrying at least one of the tags.
+	// Each tag is a separate containment check so that the GIN index on tags is used.
+	anyTag := func(tags []string) string {
+		checks := make([]string, 0, len(tags))
+		for _, t := range tags {
+			checks = append(checks, "tags @> "+arg(tagsJSON([]string{t}))+"::jsonb")
+		}
+
+		return "(" + strings.Join(checks, " OR ") + ")"
+	}
+
+	if f.Query != "" {
+		addCond("search_vector @@ websearch_to_tsquery('simple', %s)", f.Query)
+	}
+	if f.Status != "" {
+		addCond("status = %s", f.Status)
+	}
+	if f.Action != "" {
+		addCond("action = %s", f.Action)
+	}
+	if f.Owner != "" {
+		addCond("owner = %s", f.Owner)
+	}
+	if f.Filename != "" {
+		addCond("filename ILIKE %s", "%"+escapeLike(f.Filename)+"%")
+	}
+	if f.Title != "" {
+		addCond("title ILIKE %s", "%"+escapeLike(f.Title)+"%")
+	}
+	if len(f.Tags) > 0 {
+		addCond("tags @> %s::jsonb", tagsJSON(f.Tags))
+	}
+	if len(f.AnyTags) > 0 {
+		conds = append(conds, anyTag(f.AnyTags))
+	}
+	if len(f.ExcludedTags) > 0 {
+		conds = append(conds, "NOT "+anyTag(f.ExcludedTags))
+	}
+	switch f.Kind {
+	case model.KindOriginal:
+		conds = append(conds, "original_id IS NULL")
+	case model.KindDerived:
+		conds = append(conds, "original_id IS NOT NULL")
+	}
+	if len(f.Formats) > 0 {
+		placeholders := make([]string, 0, len(f.Formats))
+		for _, format := range f.Formats {
+			placeholders = append(placeholders, arg(format))
+		}
+		conds = append(conds, "format IN ("+strings.Join(placeholders, ", ")+")")
+	}
+	if f.MinSize > 0 {
+		addCond("size_bytes >= %s", f.MinSize)
+	}
+	if f.MaxSize > 0 {
+		addCond("size_bytes <= %s", f.MaxSize)
+	}
+	if !f.From.IsZero() {
+		addCond("created_at >= %s", f.From)
+	}
+	if !f.To.IsZero() {
+		addCond("created_at < %s", f.To)
+	}
+
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// tagsJSON encodes tags as a JSON array for comparison with the tags column.
+func tagsJSON(tags []string) string {
+	data, _ := json.Marshal(tags) // a []string always marshals
+	return string(data)
+}
+
+// PathInUse reports whether any image record references the stored file at path.
+func (r *Repository) PathInUse(ctx context.Context, path string) (bool, error) {
+	query := `
+		SELECT EXISTS (SELECT 1 FROM images WHERE path = $1)
+    `
+
+	var inUse bool
+	if err := r.master(ctx).QueryRowContext(ctx, query, path).Scan(&inUse); err != nil {
+		return false, fmt.Errorf("path in use: %w", err)
+	}
+
+	return inUse, nil
+}
+
+// ListDerived returns all images derived from the original with the given ID, oldest first.
+func (r *Repository) ListDerived(ctx context.Context, originalID uuid.UUID) ([]model.Image, error) {
+	query := `
+		SELECT ` + imageColumns + `
+		FROM images
+		WHERE ` + derivedOf + ` AND deleted_at IS NULL
+		ORDER BY created_at, id
+    `
+
+	rows, err := r.master(ctx).QueryContext(ctx, query, originalID)
+	if err != nil {
+		return nil, fmt.Errorf("list derived: failed to query images: %w", err)
+	}
+	defer rows.Close()
+
+	images, err := scanImages(rows, 0)
+	if err != nil {
+		return nil, fmt.Errorf("list derived: %w", err)
+	}
+
+	return images, nil
+}
+
+// ListExpired returns up to limit originals whose expiry time has passed, oldest expiry first.
+// Their derived images share the expiry and are deleted along with them.
+func (r *Repository) ListExpired(ctx context.Context, limit int) ([]model.Image, error) {
+	query := `
+		SELECT ` + imageColumns + `
+		FROM images
+		WHERE expires_at <= now() AND original_id IS NULL
+		ORDER BY expires_at
+		LIMIT $1
+    `
+
+	rows, err := r.master(ctx).QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list expired: failed to query images: %w", err)
+	}
+	defer rows.Close()
+
+	images, err := scanImages(rows, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list expired: %w", err)
+	}
+
+	return images, nil
+}
+
+// scanImages reads all image rows selected with imageColumns.
+func scanImages(rows *sql.Rows, capacity int) ([]model.Image, error) {
+	images := make([]model.Image, 0, capacity)
+	for rows.Next() {
+		img, err := scanImage(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		images = append(images, img)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate images: %w", err)
+	}
+
+	return images, nil
+}
+
+// scanImage reads a single image row selected with imageColumns.
+func scanImage(row rowScanner) (model.Image, error) {
+	var img model.Image
+	var paramsBytes, tagsBytes []byte
+
+	err := row.Scan(
+		&img.ID, &img.OriginalID, &img.Filename, &img.Path, &img.Action.Name, &paramsBytes,
+		&img.Status, &img.OCRText, &img.CallbackURL, &img.Owner, &img.Priority, &img.Size, &img.ContentHash,
+		&img.Title, &img.Description, &tagsBytes, &img.Format, &img.Width, &img.Height,
+		&img.ColorSpace, &img.ExpiresAt, &img.Error, &img.FailedAt, &img.DeletedAt, &img.CreatedAt,
+	)
+	if err != nil {
+		return model.Image{}, fmt.Errorf("failed to scan image: %w", err)
+	}
+
+	if err := json.Unmarshal(paramsBytes, &img.Action.Params); err != nil {
+		return model.Image{}, fmt.Errorf("failed to unmarshal params: %w", err)
+	}
+
+	if err := json.Unmarshal(tagsBytes, &img.Tags); err != nil {
+		return model.Image{}, fmt.Errorf("failed to unmarshal tags: %w", err)
+	}
+
+	return img, nil
+}
+
+// escapeLike escapes the LIKE wildcard characters in s.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// GetStatus retrieves the detailed processing status of an image by ID.
+func (r *Repository) GetStatus(ctx context.Context, id uuid.UUID) (model.ProcessingStatus, error) {
+	query := `
+		SELECT status, stage, attempts, COALESCE(last_error, ''), started_at, finished_at, duration_ms,
+		       created_at, updated_at
+		FROM images
+		WHERE id = $1 AND created_at >= image_created_at($1) AND deleted_at IS NULL
+    `
+
+	st := model.ProcessingStatus{ID: id}
+
+	err := r.master(ctx).QueryRowContext(ctx, query, id).Scan(
+		&st.Status, &st.Stage, &st.Attempts, &st.LastError, &st.StartedAt, &st.FinishedAt, &st.DurationMs,
+		&st.CreatedAt, &st.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.ProcessingStatus{}, ErrImageNotFound
+		}
+
+		return model.ProcessingStatus{}, fmt.Errorf("get status: failed to get image status: %w", err)
+	}
+
+	return st, nil
+}
+
+// finishAttempt is the SET clause recording the end and duration of the current processing attempt.
+const finishAttempt = `finished_at = NOW(), duration_ms = (EXTRACT(EPOCH FROM NOW() - started_at) * 1000)::BIGINT`
+
+// BeginAttempt increments the attempt counter of an image, records the start of the attempt
+// and moves it to the decoding stage.
+func (r *Repository) BeginAttempt(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE images
+		SET attempts = attempts + 1, stage = $1, started_at = NOW(), finished_at = NULL, duration_ms = NULL,
+		    updated_at = NOW()
+		WHERE id = $2 AND created_at >= image_created_at($2)
+    `
+
+	return r.execStatusUpdate(ctx, "begin attempt", query, model.StageDecoding, id)
+}
+
+// UpdateStage moves an image to the given processing stage.
+func (r *Repository) UpdateStage(ctx context.Context, id uuid.UUID, stage string) error {
+	query := `
+		UPDATE images
+		SET stage = $1, updated_at = NOW()
+		WHERE id = $2 AND created_at >= image_created_at($2)
+    `
+
+	return r.execStatusUpdate(ctx, "update stage", query, stage, id)
+}
+
+// FinishAttempt moves an image to the done stage and records the end and duration of the attempt.
+func (r *Repository) FinishAttempt(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE images
+		SET stage = $1, ` + finishAttempt + `, updated_at = NOW()
+		WHERE id = $2 AND created_at >= image_created_at($2)
+    `
+
+	return r.execStatusUpdate(ctx, "finish attempt", query, model.StageDone, id)
+}
+
+// FailAttempt moves an image to the failed stage and records the error, end and duration of the attempt.
+func (r *Repository) FailAttempt(ctx context.Context, id uuid.UUID, errMsg string) error {
+	query := `
+		UPDATE images
+		SET stage = $1, last_error = $2, ` + finishAttempt + `, updated_at = NOW()
+		WHERE id = $3 AND created_at >= image_created_at($3)
+    `
+
+	return r.execStatusUpdate(ctx, "fail attempt", query, model.StageFailed, errMsg, id)
+}
+
+// execStatusUpdate executes a status update query and reports ErrImageNotFound if no row was affected.
+func (r *Repository) execStatusUpdate(ctx context.Context, op, query string, args ...interface{}) error {
+	res, err := r.master(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: failed to update image: %w", op, err)
+	}
+
+	rows, _ := res.RowsAffected()
+
+	if rows == 0 {
+		return ErrImageNotFound
+	}
+
+	return nil
+}
+
+// GetUsage returns the storage and rate usage of the given owner. Originals are stored once per
+// content hash, so the bytes of a stored object shared by several records of the owner, e.g.
+// expiring uploads of the same content, count once.
+func (r *Repository) GetUsage(ctx context.Context, owner string) (model.QuotaUsage, error) {
+	query := `
+		SELECT (SELECT COALESCE(SUM(size_bytes), 0)
+		        FROM (SELECT MAX(size_bytes) AS size_bytes FROM images WHERE owner = $1 GROUP BY path) stored),
+		       COUNT(*) FILTER (WHERE original_id IS NULL AND created_at >= NOW() - INTERVAL '1 day'),
+		       COUNT(*) FILTER (WHERE original_id IS NULL AND created_at >= NOW() - INTERVAL '1 hour')
+		FROM images
+		WHERE owner = $1
+    `
+
+	var u model.QuotaUsage
+
+	err := r.master(ctx).QueryRowContext(ctx, query, owner).Scan(&u.BytesStored, &u.ImagesLastDay, &u.JobsLastHour)
+	if err != nil {
+		return model.QuotaUsage{}, fmt.Errorf("get usage: failed to get usage: %w", err)
+	}
+
+	return u, nil
+}
+
+// StorageUsage returns the bytes and objects stored per owner and top-level storage directory,
+// grouped and filtered as requested, largest first. The usage is kept up to date by a trigger on images.
+func (r *Repository) StorageUsage(ctx context.Context, f model.UsageFilter) ([]model.StorageUsage, error) {
+	owner, subdir := "owner", "subdir"
+	switch f.GroupBy {
+	case model.UsageByOwner:
+		subdir = "''"
+	case model.UsageBySubdir:
+		owner = "''"
+	case "":
+	default:
+		return nil, fmt.Errorf("storage usage: unknown grouping: %s", f.GroupBy)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %[1]s, %[2]s, COALESCE(SUM(bytes), 0)::BIGINT, COALESCE(SUM(objects), 0)::BIGINT
+		FROM storage_usage
+		WHERE ($1 = '' OR owner = $1) AND ($2 = '' OR subdir = $2) AND objects > 0
+		GROUP BY %[1]s, %[2]s
+		ORDER BY 3 DESC, 1, 2
+    `, owner, subdir)
+
+	rows, err := r.master(ctx).QueryContext(ctx, query, f.Owner, f.Subdir)
+	if err != nil {
+		return nil, fmt.Errorf("storage usage: failed to query usage: %w", err)
+	}
+	defer rows.Close()
+
+	var usage []model.StorageUsage
+	for rows.Next() {
+		var u model.StorageUsage
+		if err := rows.Scan(&u.Owner, &u.Subdir, &u.Bytes, &u.Objects); err != nil {
+			return nil, fmt.Errorf("storage usage: failed to scan usage: %w", err)
+		}
+
+		usage = append(usage, u)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("storage usage: failed to iterate usage: %w", err)
+	}
+
+	return usage, nil
+}
+
+// ListJobs returns the processing jobs of uploaded originals in the requested state, oldest update first
+// unless the filter asks for the newest first.
+func (r *Repository) ListJobs(ctx context.Context, f model.JobFilter) ([]model.Job, error) {
+	query := `
+		SELECT id, filename, action, status, stage, attempts, COALESCE(last_error, ''), started_at, finished_at,
+		       duration_ms, created_at, updated_at, redrives
+		FROM images
+		WHERE original_id IS NULL AND deleted_at IS NULL
+    `
+
+	args := []interface{}{model.StageFailed}
+
+	switch f.State {
+	case model.JobStateFailed:
+		query += " AND stage = $1"
+	case model.JobStateStuck:
+		args = append(args, model.StageDone, f.StuckSince)
+		query += " AND stage NOT IN ($1, $2) AND updated_at < $3"
+	default:
+		return nil, fmt.Errorf("list jobs: unknown job state: %s", f.State)
+	}
+
+	order := "updated_at, id"
+	if f.NewestFirst {
+		order = "updated_at DESC, id DESC"
+	}
+
+	args = append(args, f.Limit, f.Offset)
+	query += fmt.Sprintf(" ORDER BY %s LIMIT $%d OFFSET $%d", order, len(args)-1, len(args))
+
+	rows, err := r.master(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list jobs: failed to query jobs: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := make([]model.Job, 0, f.Limit)
+	for rows.Next() {
+		var j model.Job
+		err := rows.Scan(
+			&j.ID, &j.Filename, &j.Action, &j.Status, &j.Stage, &j.Attempts, &j.LastError, &j.StartedAt, &j.FinishedAt,
+			&j.DurationMs, &j.CreatedAt, &j.UpdatedAt, &j.Redrives,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("list jobs: failed to scan job: %w", err)
+		}
+
+		jobs = append(jobs, j)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list jobs: failed to iterate jobs: %w", err)
+	}
+
+	return jobs, nil
+}
+
+// RequeueImage moves an image back to the queued stage and clears the error of its last attempt.
+func (r *Repository) RequeueImage(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE images
+		SET status = 'pending', stage = $1, last_error = NULL, failed_at = NULL,
+		    started_at = NULL, finished_at = NULL, duration_ms = NULL, updated_at = NOW()
+		WHERE id = $2 AND created_at >= image_created_at($2)
+    `
+
+	return r.execStatusUpdate(ctx, "requeue image", query, model.StageQueued, id)
+}
+
+// RedriveImage moves a stuck image back to the queued stage and counts the redrive.
+// Images finished or updated since the given moment are left alone and reported as ErrImageNotFound,
+// so that a worker picking the job up concurrently isn't overridden.
+func (r *Repository) RedriveImage(ctx context.Context, id uuid.UUID, stuckSince time.Time) error {
+	query := `
+		UPDATE images
+		SET stage = $1, redrives = redrives + 1, updated_at = NOW()
+		WHERE id = $2 AND created_at >= image_created_at($2) AND stage NOT IN ($3, $4) AND updated_at < $5
+    `
+
+	return r.execStatusUpdate(ctx, "redrive image", query,
+		model.StageQueued, id, model.StageDone, model.StageFailed, stuckSince)
+}
+
+// FailStuckImage moves a stuck image to the failed status with the given error.
+// Like RedriveImage, images finished or updated since the given moment are reported as ErrImageNotFound.
+func (r *Repository) FailStuckImage(ctx context.Context, id uuid.UUID, stuckSince time.Time, errMsg string) error {
+	query := `
+		UPDATE images
+		SET status = 'failed', stage = $1, last_error = $2, failed_at = NOW(), ` + finishAttempt + `, updated_at = NOW()
+		WHERE id = $3 AND created_at >= image_created_at($3) AND stage NOT IN ($4, $1) AND updated_at < $5
+    `
+
+	return r.execStatusUpdate(ctx, "fail stuck image", query,
+		model.StageFailed, errMsg, id, model.StageDone, stuckSince)
+}
+
+// DeleteDerived deletes all images derived from the original with the given ID.
+// Returns the number of deleted records.
+func (r *Repository) DeleteDerived(ctx context.Context, originalID uuid.UUID) (int64, error) {
+	query := `
+		DELETE FROM images WHERE ` + derivedOf + `
+    `
+
+	res, err := r.master(ctx).ExecContext(ctx, query, originalID)
+	if err != nil {
+		return 0, fmt.Errorf("delete derived: failed to delete images: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("delete derived: failed to get number of rows affected: %w", err)
+	}
+
+	return n, nil
+}
+
+// ActionStats returns per-action job counts for originals uploaded since the given moment.
+func (r *Repository) ActionStats(ctx context.Context, since time.Time) ([]model.ActionStats, error) {
+	query := `
+		SELECT action,
+		       COUNT(*),
+		       COUNT(*) FILTER (WHERE stage = $1),
+		       COUNT(*) FILTER (WHERE stage = $2),
+		       COUNT(*) FILTER (WHERE stage NOT IN ($1, $2)),
+		       COALESCE(AVG(duration_ms) FILTER (WHERE stage = $1), 0)
+		FROM images
+		WHERE original_id IS NULL AND created_at >= $3
+		GROUP BY action
+		ORDER BY action
+    `
+
+	rows, err := r.master(ctx).QueryContext(ctx, query, model.StageDone, model.StageFailed, since)
+	if err != nil {
+		return nil, fmt.Errorf("action stats: failed to query stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []model.ActionStats
+	for rows.Next() {
+		var st model.ActionStats
+		if err := rows.Scan(&st.Action, &st.Total, &st.Processed, &st.Failed, &st.Pending, &st.AvgDurationMs); err != nil {
+			return nil, fmt.Errorf("action stats: failed to scan stats: %w", err)
+		}
+
+		stats = append(stats, st)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("action stats: failed to iterate stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// StatusCounts returns the number of originals uploaded since the given moment per status.
+func (r *Repository) StatusCounts(ctx context.Context, since time.Time) ([]model.StatusCount, error) {
+	query := `
+		SELECT status, COUNT(*)
+		FROM images
+		WHERE original_id IS NULL AND created_at >= $1
+		GROUP BY status
+		ORDER BY status
+    `
+
+	rows, err := r.master(ctx).QueryContext(ctx, query, since)
+	if err != nil {
+		return nil, fmt.Errorf("status counts: failed to query counts: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []model.StatusCount
+	for rows.Next() {
+		var sc model.StatusCount
+		if err := rows.Scan(&sc.Status, &sc.Count); err != nil {
+			return nil, fmt.Errorf("status counts: failed to scan count: %w", err)
+		}
+
+		counts = append(counts, sc)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("status counts: failed to iterate counts: %w", err)
+	}
+
+	return counts, nil
+}
+
+// QueueDepth returns the number of unfinished jobs of originals, whenever uploaded: queued, being
+// processed, and stuck among them, i.e. not updated since stuckSince.
+func (r *Repository) QueueDepth(ctx context.Context, stuckSince time.Time) (model.QueueDepth, error) {
+	query := `
+		SELECT COALESCE(SUM(CASE WHEN stage = $1 THEN 1 ELSE 0 END), 0),
+		       COALESCE(SUM(CASE WHEN stage <> $1 THEN 1 ELSE 0 END), 0),
+		       COALESCE(SUM(CASE WHEN updated_at < $2 THEN 1 ELSE 0 END), 0)
+		FROM images
+		WHERE original_id IS NULL AND deleted_at IS NULL AND stage NOT IN ($3, $4)
+    `
+
+	var d model.QueueDepth
+	err := r.master(ctx).QueryRowContext(ctx, query, model.StageQueued, stuckSince, model.StageDone, model.StageFailed).
+		Scan(&d.Queued, &d.Processing, &d.Stuck)
+	if err != nil {
+		return model.QueueDepth{}, fmt.Errorf("queue depth: failed to count jobs: %w", err)
+	}
+
+	return d, nil
+}
+
+// HourlyStats returns the processing jobs of originals finished since the given moment per hour, oldest first.
+// Hours without finished jobs are omitted.
+func (r *Repository) HourlyStats(ctx context.Context, since time.Time) ([]model.HourlyStats, error) {
+	query := `
+		SELECT date_trunc('hour', finished_at),
+		       COUNT(*) FILTER (WHERE stage = $1),
+		       COUNT(*) FILTER (WHERE stage = $2),
+		       COALESCE(AVG(duration_ms) FILTER (WHERE stage = $1), 0)
+		FROM images
+		WHERE original_id IS NULL AND finished_at >= $3
+		GROUP BY 1
+		ORDER BY 1
+    `
+
+	rows, err := r.master(ctx).QueryContext(ctx, query, model.StageDone, model.StageFailed, since)
+	if err != nil {
+		return nil, fmt.Errorf("hourly stats: failed to query stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []model.HourlyStats
+	for rows.Next() {
+		var st model.HourlyStats
+		if err := rows.Scan(&st.Hour, &st.Processed, &st.Failed, &st.AvgDurationMs); err != nil {
+			return nil, fmt.Errorf("hourly stats: failed to scan stats: %w", err)
+		}
+
+		stats = append(stats, st)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("hourly stats: failed to iterate stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// SaveAuditEvent appends an event to the audit log.
+func (r *Repository) SaveAuditEvent(ctx context.Context, ev model.AuditEvent) error {
+	query := `
+		INSERT INTO audit_events (event, image_id, actor, request_id, details)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''))
+    `
+
+	if _, err := r.master(ctx).ExecContext(ctx, query, ev.Event, ev.ImageID, ev.Actor, ev.RequestID, ev.Details); err != nil {
+		return fmt.Errorf("save audit event: failed to save event: %w", err)
+	}
+
+	return nil
+}
+
+// ListAuditEvents returns the audit events matching the filter, newest first.
+func (r *Repository) ListAuditEvents(ctx context.Context, f model.AuditFilter) ([]model.AuditEvent, error) {
+	var (
+		conds []string
+		args  []interface{}
+	)
+
+	addCond := func(cond string, v interface{}) {
+		args = append(args, v)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.ImageID != uuid.Nil {
+		addCond("image_id = $%d", f.ImageID)
+	}
+	if f.Event != "" {
+		addCond("event = $%d", f.Event)
+	}
+	if f.Actor != "" {
+		addCond("actor = $%d", f.Actor)
+	}
+	if !f.From.IsZero() {
+		addCond("created_at >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		addCond("created_at < $%d", f.To)
+	}
+
+	query := `
+		SELECT id, event, image_id, actor, COALESCE(request_id, ''), COALESCE(details, ''), created_at
+		FROM audit_events
+    `
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	args = append(args, f.Limit, f.Offset)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := r.master(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list audit events: failed to query events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]model.AuditEvent, 0, f.Limit)
+	for rows.Next() {
+		var ev model.AuditEvent
+		if err := rows.Scan(
+			&ev.ID, &ev.Event, &ev.ImageID, &ev.Actor, &ev.RequestID, &ev.Details, &ev.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("list audit events: failed to scan event: %w", err)
+		}
+
+		events = append(events, ev)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list audit events: failed to iterate events: %w", err)
+	}
+
+	return events, nil
+}
+
+// EnqueueOutbox stores the processing task of an image in the outbox, to be published by the relay.
+// Called in the transaction saving the image, the task is stored if and only if the image is.
+func (r *Repository) EnqueueOutbox(ctx context.Context, msg model.OutboxMessage) error {
+	query := `
+		INSERT INTO outbox (image_id, payload, headers)
+		VALUES ($1, $2, $3)
+    `
+
+	payload, err := json.Marshal(msg.Image)
+	if err != nil {
+		return fmt.Errorf("enqueue outbox: failed to marshal image: %w", err)
+	}
+
+	headers, err := json.Marshal(msg.Headers)
+	if err != nil {
+		return fmt.Errorf("enqueue outbox: failed to marshal headers: %w", err)
+	}
+
+	if _, err := r.master(ctx).ExecContext(ctx, query, msg.Image.ID, payload, headers); err != nil {
+		return fmt.Errorf("enqueue outbox: failed to insert message: %w", err)
+	}
+
+	return nil
+}
+
+// PendingOutbox locks and returns up to limit outbox messages, oldest first. Messages locked by
+// another relay are skipped, so it must be called in WithTx, which holds the locks until it ends.
+func (r *Repository) PendingOutbox(ctx context.Context, limit int) ([]model.OutboxMessage, error) {
+	query := `
+		SELECT id, payload, headers, created_at
+		FROM outbox
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+    `
+
+	rows, err := r.master(ctx).QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("pending outbox: failed to query messages: %w", err)
+	}
+	defer rows.Close()
+
+	var msgs []model.OutboxMessage
+	for rows.Next() {
+		var (
+			msg              model.OutboxMessage
+			payload, headers []byte
+		)
+		if err := rows.Scan(&msg.ID, &payload, &headers, &msg.CreatedAt); err != nil {
+			return nil, fmt.Errorf("pending outbox: failed to scan message: %w", err)
+		}
+
+		if err := json.Unmarshal(payload, &msg.Image); err != nil {
+			return nil, fmt.Errorf("pending outbox: failed to unmarshal image: %w", err)
+		}
+		if err := json.Unmarshal(headers, &msg.Headers); err != nil {
+			return nil, fmt.Errorf("pending outbox: failed to unmarshal headers: %w", err)
+		}
+
+		msgs = append(msgs, msg)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("pending outbox: failed to iterate messages: %w", err)
+	}
+
+	return msgs, nil
+}
+
+// DeleteOutbox removes a published message from the outbox.
+func (r *Repository) DeleteOutbox(ctx context.Context, id int64) error {
+	query := `
+		DELETE FROM outbox
+		WHERE id = $1
+    `
+
+	if _, err := r.master(ctx).ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("delete outbox: failed to delete message: %w", err)
+	}
+
+	return nil
+}
+
+// deadLetterColumns is the column list selected for model.DeadLetter, in the order expected by scanDeadLetter.
+const deadLetterColumns = `id, topic, partition, "offset", key, payload, error, attempts, failed_at, replayed_at`
+
+// SaveDeadLetter records a message that failed processing and returns its ID.
+func (r *Repository) SaveDeadLetter(ctx context.Context, dl model.DeadLetter) (uuid.UUID, error) {
+	query := `
+		INSERT INTO dead_letters (topic, partition, "offset", key, payload, error, attempts, failed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+    `
+
+	var id uuid.UUID
+	err := r.master(ctx).QueryRowContext(
+		ctx, query, dl.Topic, dl.Partition, dl.Offset, dl.Key, []byte(dl.Payload), dl.Error, dl.Attempts, dl.FailedAt,
+	).Scan(&id)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("save dead letter: failed to save dead letter: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetDeadLetter retrieves a dead letter by ID.
+func (r *Repository) GetDeadLetter(ctx context.Context, id uuid.UUID) (model.DeadLetter, error) {
+	query := `
+		SELECT ` + deadLetterColumns + `
+		FROM dead_letters
+		WHERE id = $1
+    `
+
+	dl, err := scanDeadLetter(r.master(ctx).QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.DeadLetter{}, ErrDeadLetterNotFound
+		}
+
+		return model.DeadLetter{}, fmt.Errorf("get dead letter: %w", err)
+	}
+
+	return dl, nil
+}
+
+// ListDeadLetters returns dead letters, most recent failures first.
+// Already replayed ones are skipped unless includeReplayed is set.
+func (r *Repository) ListDeadLetters(ctx context.Context, includeReplayed bool, limit, offset int) ([]model.DeadLetter, error) {
+	query := `
+		SELECT ` + deadLetterColumns + `
+		FROM dead_letters
+		WHERE $1 OR replayed_at IS NULL
+		ORDER BY failed_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+    `
+
+	rows, err := r.master(ctx).QueryContext(ctx, query, includeReplayed, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("list dead letters: failed to query dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	letters := make([]model.DeadLetter, 0, limit)
+	for rows.Next() {
+		dl, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, fmt.Errorf("list dead letters: %w", err)
+		}
+
+		letters = append(letters, dl)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list dead letters: failed to iterate dead letters: %w", err)
+	}
+
+	return letters, nil
+}
+
+// MarkDeadLetterReplayed records that the dead letter was re-enqueued.
+func (r *Repository) MarkDeadLetterReplayed(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE dead_letters
+		SET replayed_at = now()
+		WHERE id = $1
+    `
+
+	res, err := r.master(ctx).ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("mark dead letter replayed: failed to update dead letter: %w", err)
+	}
+
+	rows, _ := res.RowsAffected()
+
+	if rows == 0 {
+		return ErrDeadLetterNotFound
+	}
+
+	return nil
+}
+
+// scanDeadLetter reads a single dead letter row selected with deadLetterColumns.
+func scanDeadLetter(row rowScanner) (model.DeadLetter, error) {
+	var dl model.DeadLetter
+	var payload []byte
+
+	err := row.Scan(
+		&dl.ID, &dl.Topic, &dl.Partition, &dl.Offset, &dl.Key, &payload, &dl.Error, &dl.Attempts,
+		&dl.FailedAt, &dl.ReplayedAt,
+	)
+	if err != nil {
+		return model.DeadLetter{}, err
+	}
+
+	dl.Payload = string(payload)
+
+	return dl, nil
+}
+
+// GetProcessedMessage returns the ID of the derived image produced for the message.
+// Returns ErrMessageNotProcessed if the message has not been processed yet.
+func (r *Repository) GetProcessedMessage(ctx context.Context, messageID string) (uuid.UUID, error) {
+	query := `
+		SELECT derived_id
+		FROM processed_messages
+		WHERE message_id = $1
+    `
+
+	var derivedID uuid.UUID
+	if err := r.master(ctx).QueryRowContext(ctx, query, messageID).Scan(&derivedID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return uuid.Nil, ErrMessageNotProcessed
+		}
+
+		return uuid.Nil, fmt.Errorf("get processed message: %w", err)
+	}
+
+	return derivedID, nil
+}
+
+// MarkMessageProcessed records that the message produced the derived image of the original.
+// Recording the same message again keeps the first record.
+func (r *Repository) MarkMessageProcessed(ctx context.Context, messageID string, imageID, derivedID uuid.UUID) error {
+	query := `
+		INSERT INTO processed_messages (message_id, image_id, derived_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (message_id) DO NOTHING
+    `
+
+	if _, err := r.master(ctx).ExecContext(ctx, query, messageID, imageID, derivedID); err != nil {
+		return fmt.Errorf("mark message processed: failed to insert message: %w", err)
+	}
+
+	return nil
+}
+
+// BeginMessageAttempt counts a new processing attempt of the message and returns its attempts so far.
+func (r *Repository) BeginMessageAttempt(ctx context.Context, messageID string) (model.MessageAttempt, error) {
+	query := `
+		INSERT INTO message_attempts (message_id, attempts)
+		VALUES ($1, 1)
+		ON CONFLICT (message_id) DO UPDATE SET attempts = message_attempts.attempts + 1, updated_at = NOW()
+		RETURNING attempts, COALESCE(last_error, '')
+    `
+
+	a := model.MessageAttempt{MessageID: messageID}
+
+	if err := r.master(ctx).QueryRowContext(ctx, query, messageID).Scan(&a.Attempts, &a.LastError); err != nil {
+		return model.MessageAttempt{}, fmt.Errorf("begin message attempt: %w", err)
+	}
+
+	return a, nil
+}
+
+// FailMessageAttempt records the error of the current processing attempt of the message.
+func (r *Repository) FailMessageAttempt(ctx context.Context, messageID, errMsg string) error {
+	query := `
+		UPDATE message_attempts
+		SET last_error = $1, updated_at = NOW()
+		WHERE message_id = $2
+    `
+
+	if _, err := r.master(ctx).ExecContext(ctx, query, errMsg, messageID); err != nil {
+		return fmt.Errorf("fail message attempt: failed to update attempts: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteMessageAttempts forgets the attempts of a message that was processed or given up on.
+func (r *Repository) DeleteMessageAttempts(ctx context.Context, messageID string) error {
+	query := `
+		DELETE FROM message_attempts WHERE message_id = $1
+    `
+
+	if _, err := r.master(ctx).ExecContext(ctx, query, messageID); err != nil {
+		return fmt.Errorf("delete message attempts: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,234 @@
+-- Schema of the SQLite backend, matching the PostgreSQL migrations up to 20261018330000_create_outbox.
+-- Timestamps are stored as UTC text in the 'YYYY-MM-DD HH:MM:SS.SSS' format, which sorts chronologically,
+-- UUIDs as text and JSON documents as text.
+CREATE TABLE IF NOT EXISTS images
+(
+    id           TEXT PRIMARY KEY,
+    original_id  TEXT REFERENCES images (id) ON DELETE CASCADE,
+    filename     TEXT      NOT NULL,
+    path         TEXT      NOT NULL,
+    action       TEXT      NOT NULL,
+    params       TEXT,
+    status       TEXT      NOT NULL DEFAULT 'pending',
+    stage        TEXT      NOT NULL DEFAULT 'queued',
+    attempts     INTEGER   NOT NULL DEFAULT 0,
+    last_error   TEXT,
+    ocr_text     TEXT,
+    callback_url TEXT,
+    owner        TEXT      NOT NULL DEFAULT 'anonymous',
+    size_bytes   INTEGER   NOT NULL DEFAULT 0,
+    content_hash TEXT,
+    title        TEXT,
+    description  TEXT,
+    tags         TEXT      NOT NULL DEFAULT '[]',
+    format       TEXT,
+    expires_at   TIMESTAMP,
+    failed_at    TIMESTAMP,
+    priority     TEXT      NOT NULL DEFAULT 'normal',
+    message_id   TEXT,
+    redrives     INTEGER   NOT NULL DEFAULT 0,
+    started_at   TIMESTAMP,
+    finished_at  TIMESTAMP,
+    duration_ms  INTEGER,
+    width        INTEGER,
+    height       INTEGER,
+    color_space  TEXT,
+    created_at   TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    updated_at   TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_images_original_id ON images (original_id);
+CREATE INDEX IF NOT EXISTS idx_images_owner_created_at ON images (owner, created_at);
+CREATE INDEX IF NOT EXISTS idx_images_owner_content_hash ON images (owner, content_hash)
+    WHERE original_id IS NULL AND content_hash IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_images_format ON images (format);
+CREATE INDEX IF NOT EXISTS idx_images_size_bytes ON images (size_bytes);
+CREATE INDEX IF NOT EXISTS idx_images_created_at ON images (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_images_expires_at ON images (expires_at)
+    WHERE expires_at IS NOT NULL AND original_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_images_message_id ON images (message_id)
+    WHERE message_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_images_path ON images (path);
+CREATE INDEX IF NOT EXISTS idx_images_finished_at ON images (finished_at) WHERE original_id IS NULL;
+
+-- Full-text index over filenames, tags and OCR text. The default tokenizer splits words
+-- on punctuation, so filenames like "summer_trip-01.jpg" are searchable by their words.
+CREATE VIRTUAL TABLE IF NOT EXISTS images_search USING fts5(id UNINDEXED, filename, tags, ocr_text);
+
+CREATE TRIGGER IF NOT EXISTS images_search_insert
+    AFTER INSERT
+    ON images
+BEGIN
+    INSERT INTO images_search (id, filename, tags, ocr_text)
+    VALUES (NEW.id, NEW.filename, (SELECT group_concat(value, ' ') FROM json_each(NEW.tags)), NEW.ocr_text);
+END;
+
+CREATE TRIGGER IF NOT EXISTS images_search_update
+    AFTER UPDATE OF filename, tags, ocr_text
+    ON images
+BEGIN
+    DELETE FROM images_search WHERE id = OLD.id;
+    INSERT INTO images_search (id, filename, tags, ocr_text)
+    VALUES (NEW.id, NEW.filename, (SELECT group_concat(value, ' ') FROM json_each(NEW.tags)), NEW.ocr_text);
+END;
+
+CREATE TRIGGER IF NOT EXISTS images_search_delete
+    AFTER DELETE
+    ON images
+BEGIN
+    DELETE FROM images_search WHERE id = OLD.id;
+END;
+
+CREATE TABLE IF NOT EXISTS image_changes
+(
+    seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+    image_id   TEXT      NOT NULL,
+    operation  TEXT      NOT NULL,
+    path       TEXT      NOT NULL,
+    changed_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE TRIGGER IF NOT EXISTS images_record_insert
+    AFTER INSERT
+    ON images
+BEGIN
+    INSERT INTO image_changes (image_id, operation, path) VALUES (NEW.id, 'created', NEW.path);
+END;
+
+CREATE TRIGGER IF NOT EXISTS images_record_update
+    AFTER UPDATE
+    ON images
+BEGIN
+    INSERT INTO image_changes (image_id, operation, path) VALUES (NEW.id, 'updated', NEW.path);
+END;
+
+CREATE TRIGGER IF NOT EXISTS images_record_delete
+    AFTER DELETE
+    ON images
+BEGIN
+    INSERT INTO image_changes (image_id, operation, path) VALUES (OLD.id, 'deleted', OLD.path);
+END;
+
+-- Bytes and objects stored per owner and top-level storage directory, maintained by triggers on images.
+CREATE TABLE IF NOT EXISTS storage_usage
+(
+    owner      TEXT      NOT NULL,
+    subdir     TEXT      NOT NULL,
+    bytes      INTEGER   NOT NULL DEFAULT 0,
+    objects    INTEGER   NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    PRIMARY KEY (owner, subdir)
+);
+
+CREATE TRIGGER IF NOT EXISTS images_usage_insert
+    AFTER INSERT
+    ON images
+BEGIN
+    INSERT INTO storage_usage (owner, subdir, bytes, objects)
+    VALUES (NEW.owner, substr(NEW.path, 1, instr(NEW.path || '/', '/') - 1), NEW.size_bytes, 1)
+    ON CONFLICT (owner, subdir) DO UPDATE
+        SET bytes      = bytes + excluded.bytes,
+            objects    = objects + 1,
+            updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now');
+END;
+
+CREATE TRIGGER IF NOT EXISTS images_usage_update
+    AFTER UPDATE OF owner, path, size_bytes
+    ON images
+BEGIN
+    UPDATE storage_usage
+    SET bytes      = bytes - OLD.size_bytes,
+        objects    = objects - 1,
+        updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+    WHERE owner = OLD.owner
+      AND subdir = substr(OLD.path, 1, instr(OLD.path || '/', '/') - 1);
+
+    INSERT INTO storage_usage (owner, subdir, bytes, objects)
+    VALUES (NEW.owner, substr(NEW.path, 1, instr(NEW.path || '/', '/') - 1), NEW.size_bytes, 1)
+    ON CONFLICT (owner, subdir) DO UPDATE
+        SET bytes      = bytes + excluded.bytes,
+            objects    = objects + 1,
+            updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now');
+END;
+
+CREATE TRIGGER IF NOT EXISTS images_usage_delete
+    AFTER DELETE
+    ON images
+BEGIN
+    UPDATE storage_usage
+    SET bytes      = bytes - OLD.size_bytes,
+        objects    = objects - 1,
+        updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+    WHERE owner = OLD.owner
+      AND subdir = substr(OLD.path, 1, instr(OLD.path || '/', '/') - 1);
+END;
+
+CREATE TABLE IF NOT EXISTS dead_letters
+(
+    id          TEXT PRIMARY KEY,
+    topic       TEXT      NOT NULL,
+    partition   INTEGER   NOT NULL,
+    "offset"    INTEGER   NOT NULL,
+    key         TEXT      NOT NULL DEFAULT '',
+    payload     BLOB      NOT NULL,
+    error       TEXT      NOT NULL,
+    attempts    INTEGER   NOT NULL,
+    failed_at   TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    replayed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_dead_letters_failed_at ON dead_letters (failed_at DESC);
+
+CREATE TABLE IF NOT EXISTS processed_messages
+(
+    message_id   TEXT PRIMARY KEY,
+    image_id     TEXT      NOT NULL,
+    derived_id   TEXT      NOT NULL,
+    processed_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS message_attempts
+(
+    message_id TEXT PRIMARY KEY,
+    attempts   INTEGER   NOT NULL DEFAULT 0,
+    last_error TEXT,
+    updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+-- Append-only audit log of image lifecycle events; image_id has no foreign key as events outlive images.
+CREATE TABLE IF NOT EXISTS audit_events
+(
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    event      TEXT      NOT NULL,
+    image_id   TEXT      NOT NULL,
+    actor      TEXT      NOT NULL,
+    request_id TEXT,
+    details    TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_image_id ON audit_events (image_id, id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at);
+
+CREATE TRIGGER IF NOT EXISTS audit_events_no_update
+    BEFORE UPDATE
+    ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_events_no_delete
+    BEFORE DELETE
+    ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+
+CREATE TABLE IF NOT EXISTS outbox
+(
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    image_id   TEXT      NOT NULL,
+    payload    TEXT      NOT NULL,
+    headers    TEXT      NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
@@ -0,0 +1,59 @@
+-- Stored objects accounted once per owner, as in the PostgreSQL migration
+-- 20261019200000_count_shared_objects_once.
+DROP TRIGGER IF EXISTS images_usage_insert;
+DROP TRIGGER IF EXISTS images_usage_update;
+DROP TRIGGER IF EXISTS images_usage_delete;
+
+CREATE TRIGGER images_usage_insert
+    AFTER INSERT
+    ON images
+    WHEN NOT EXISTS (SELECT 1 FROM images WHERE owner = NEW.owner AND path = NEW.path AND id <> NEW.id)
+BEGIN
+    INSERT INTO storage_usage (owner, subdir, bytes, objects)
+    VALUES (NEW.owner, substr(NEW.path, 1, instr(NEW.path || '/', '/') - 1), NEW.size_bytes, 1)
+    ON CONFLICT (owner, subdir) DO UPDATE
+        SET bytes      = bytes + excluded.bytes,
+            objects    = objects + 1,
+            updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now');
+END;
+
+CREATE TRIGGER images_usage_update
+    AFTER UPDATE OF owner, path, size_bytes
+    ON images
+BEGIN
+    UPDATE storage_usage
+    SET bytes      = bytes - OLD.size_bytes,
+        objects    = objects - 1,
+        updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+    WHERE owner = OLD.owner
+      AND subdir = substr(OLD.path, 1, instr(OLD.path || '/', '/') - 1)
+      AND NOT EXISTS (SELECT 1 FROM images WHERE owner = OLD.owner AND path = OLD.path AND id <> OLD.id);
+
+    INSERT INTO storage_usage (owner, subdir, bytes, objects)
+    SELECT NEW.owner, substr(NEW.path, 1, instr(NEW.path || '/', '/') - 1), NEW.size_bytes, 1
+    WHERE NOT EXISTS (SELECT 1 FROM images WHERE owner = NEW.owner AND path = NEW.path AND id <> NEW.id)
+    ON CONFLICT (owner, subdir) DO UPDATE
+        SET bytes      = bytes + excluded.bytes,
+            objects    = objects + 1,
+            updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now');
+END;
+
+CREATE TRIGGER images_usage_delete
+    AFTER DELETE
+    ON images
+    WHEN NOT EXISTS (SELECT 1 FROM images WHERE owner = OLD.owner AND path = OLD.path)
+BEGIN
+    UPDATE storage_usage
+    SET bytes      = bytes - OLD.size_bytes,
+        objects    = objects - 1,
+        updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+    WHERE owner = OLD.owner
+      AND subdir = substr(OLD.path, 1, instr(OLD.path || '/', '/') - 1);
+END;
+
+DELETE FROM storage_usage;
+
+INSERT INTO storage_usage (owner, subdir, bytes, objects)
+SELECT owner, substr(path, 1, instr(path || '/', '/') - 1), SUM(size_bytes), COUNT(*)
+FROM (SELECT owner, path, MAX(size_bytes) AS size_bytes FROM images GROUP BY owner, path)
+GROUP BY owner, substr(path, 1, instr(path || '/', '/') - 1);
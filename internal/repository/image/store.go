@@ -0,0 +1,73 @@
+package image
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/aliskhannn/image-processor/internal/model"
+)
+
+//go:generate go run go.uber.org/mock/mockgen@v0.6.0 -destination=mocks/store.go -package=mocks . Store
+
+// Store is implemented by all database backends: the operations of all services, which each
+// declare the subset they use, plus the lifecycle of the connection.
+type Store interface {
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+	SaveImage(ctx context.Context, img model.Image) (uuid.UUID, error)
+	SaveDerivedImages(ctx context.Context, imgs []model.Image) ([]uuid.UUID, error)
+	GetImage(ctx context.Context, id uuid.UUID) (model.Image, error)
+	FindOriginalByHash(ctx context.Context, owner, hash string) (model.Image, error)
+	UpdateImage(ctx context.Context, id uuid.UUID, path, status string) error
+	UpdateMetadata(ctx context.Context, id uuid.UUID, upd model.MetadataUpdate) error
+	UpdateOCRText(ctx context.Context, id uuid.UUID, text string) error
+	DeleteImage(ctx context.Context, id uuid.UUID) error
+	SoftDeleteImage(ctx context.Context, id uuid.UUID) error
+	ListDeleted(ctx context.Context, before time.Time, limit int) ([]model.Image, error)
+	ListChanges(ctx context.Context, since int64, limit int) ([]model.Change, error)
+	ListImages(ctx context.Context, f model.ImageFilter) ([]model.Image, error)
+	CountImages(ctx context.Context, f model.ImageFilter) (int, error)
+	PathInUse(ctx context.Context, path string) (bool, error)
+	ListDerived(ctx context.Context, originalID uuid.UUID) ([]model.Image, error)
+	ListExpired(ctx context.Context, limit int) ([]model.Image, error)
+	GetStatus(ctx context.Context, id uuid.UUID) (model.ProcessingStatus, error)
+	BeginAttempt(ctx context.Context, id uuid.UUID) error
+	UpdateStage(ctx context.Context, id uuid.UUID, stage string) error
+	FinishAttempt(ctx context.Context, id uuid.UUID) error
+	FailAttempt(ctx context.Context, id uuid.UUID, errMsg string) error
+	GetUsage(ctx context.Context, owner string) (model.QuotaUsage, error)
+	StorageUsage(ctx context.Context, f model.UsageFilter) ([]model.StorageUsage, error)
+	ListJobs(ctx context.Context, f model.JobFilter) ([]model.Job, error)
+	RequeueImage(ctx context.Context, id uuid.UUID) error
+	RedriveImage(ctx context.Context, id uuid.UUID, stuckSince time.Time) error
+	FailStuckImage(ctx context.Context, id uuid.UUID, stuckSince time.Time, errMsg string) error
+	DeleteDerived(ctx context.Context, originalID uuid.UUID) (int64, error)
+	ActionStats(ctx context.Context, since time.Time) ([]model.ActionStats, error)
+	StatusCounts(ctx context.Context, since time.Time) ([]model.StatusCount, error)
+	HourlyStats(ctx context.Context, since time.Time) ([]model.HourlyStats, error)
+	QueueDepth(ctx context.Context, stuckSince time.Time) (model.QueueDepth, error)
+	SaveAuditEvent(ctx context.Context, ev model.AuditEvent) error
+	ListAuditEvents(ctx context.Context, f model.AuditFilter) ([]model.AuditEvent, error)
+	EnqueueOutbox(ctx context.Context, msg model.OutboxMessage) error
+	PendingOutbox(ctx context.Context, limit int) ([]model.OutboxMessage, error)
+	DeleteOutbox(ctx context.Context, id int64) error
+	SaveDeadLetter(ctx context.Context, dl model.DeadLetter) (uuid.UUID, error)
+	GetDeadLetter(ctx context.Context, id uuid.UUID) (model.DeadLetter, error)
+	ListDeadLetters(ctx context.Context, includeReplayed bool, limit, offset int) ([]model.DeadLetter, error)
+	MarkDeadLetterReplayed(ctx context.Context, id uuid.UUID) error
+	GetProcessedMessage(ctx context.Context, messageID string) (uuid.UUID, error)
+	MarkMessageProcessed(ctx context.Context, messageID string, imageID, derivedID uuid.UUID) error
+	BeginMessageAttempt(ctx context.Context, messageID string) (model.MessageAttempt, error)
+	FailMessageAttempt(ctx context.Context, messageID, errMsg string) error
+	DeleteMessageAttempts(ctx context.Context, messageID string) error
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// The database backends selectable by configuration.
+var (
+	_ Store = (*Repository)(nil)
+	_ Store = (*SQLiteRepository)(nil)
+	_ Store = (*MySQLRepository)(nil)
+)
@@ -0,0 +1,85 @@
+//go:build integration
+
+package image
+
+import (
+	"testing"
+
+	"github.com/aliskhannn/image-processor/internal/model"
+)
+
+// TestUsageSharedObjects checks on every backend that a stored object shared by several records
+// of an owner counts once against its quota and storage usage, and once for each owner.
+func TestUsageSharedObjects(t *testing.T) {
+	for name, newStore := range testStores() {
+		t.Run(name, func(t *testing.T) {
+			r := newStore(t)
+			ctx := testContext(t)
+
+			// Records of the same content share the path of its hash.
+			first := testImage("sharer")
+			first.Path = "original/sha256/shared"
+			second := first
+			other := first
+			other.Owner = "other"
+
+			firstID, err := r.SaveImage(ctx, first)
+			if err != nil {
+				t.Fatalf("save first image: %v", err)
+			}
+			secondID, err := r.SaveImage(ctx, second)
+			if err != nil {
+				t.Fatalf("save second image: %v", err)
+			}
+			if _, err := r.SaveImage(ctx, other); err != nil {
+				t.Fatalf("save image of other owner: %v", err)
+			}
+
+			checkUsage := func(step string, bytes, objects int64) {
+				t.Helper()
+
+				usage, err := r.GetUsage(ctx, "sharer")
+				if err != nil {
+					t.Fatalf("%s: GetUsage() error = %v", step, err)
+				}
+				if usage.BytesStored != bytes {
+					t.Errorf("%s: GetUsage() bytes = %d, want %d", step, usage.BytesStored, bytes)
+				}
+
+				stored, err := r.StorageUsage(ctx, model.UsageFilter{Owner: "sharer", GroupBy: model.UsageByOwner})
+				if err != nil {
+					t.Fatalf("%s: StorageUsage() error = %v", step, err)
+				}
+				var gotBytes, gotObjects int64
+				for _, u := range stored {
+					gotBytes += u.Bytes
+					gotObjects += u.Objects
+				}
+				if gotBytes != bytes || gotObjects != objects {
+					t.Errorf("%s: StorageUsage() = %d bytes in %d objects, want %d in %d",
+						step, gotBytes, gotObjects, bytes, objects)
+				}
+			}
+
+			checkUsage("shared", first.Size, 1)
+
+			if err := r.DeleteImage(ctx, firstID); err != nil {
+				t.Fatalf("delete first image: %v", err)
+			}
+			checkUsage("one deleted", first.Size, 1)
+
+			if err := r.DeleteImage(ctx, secondID); err != nil {
+				t.Fatalf("delete second image: %v", err)
+			}
+			checkUsage("both deleted", 0, 0)
+
+			usage, err := r.GetUsage(ctx, "other")
+			if err != nil {
+				t.Fatalf("GetUsage() of other owner error = %v", err)
+			}
+			if usage.BytesStored != other.Size {
+				t.Errorf("GetUsage() of other owner bytes = %d, want %d", usage.BytesStored, other.Size)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+
+	"github.com/aliskhannn/image-processor/internal/config"
+)
+
+// secretsManager reads the secrets of AWS Secrets Manager through its JSON API.
+type secretsManager struct {
+	client      *http.Client
+	endpoint    string
+	region      string
+	credentials aws.CredentialsProvider
+	signer      *v4.Signer
+}
+
+// newSecretsManager creates a reader of the secrets of the region. Credentials come from the default AWS chain.
+func newSecretsManager(ctx context.Context, client *http.Client, cfg config.SecretsAWS) (*secretsManager, error) {
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(cfg.Region))
+	if err != nil {
+		return nil, fmt.Errorf("secrets manager: failed to load aws config: %w", err)
+	}
+
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://secretsmanager." + cfg.Region + ".amazonaws.com"
+	}
+
+	return &secretsManager{
+		client:      client,
+		endpoint:    endpoint,
+		region:      cfg.Region,
+		credentials: awsCfg.Credentials,
+		signer:      v4.NewSigner(),
+	}, nil
+}
+
+// Read reads the current version of the secret with the given name or ARN. The whole secret string
+// is stored under the empty key and, when it is a JSON object, each of its fields under its own key.
+func (s *secretsManager) Read(ctx context.Context, path string) (map[string]string, error) {
+	body, err := json.Marshal(map[string]string{"SecretId": path})
+	if err != nil {
+		return nil, fmt.Errorf("secrets manager read: failed to marshal request: %w", err)
+	}
+	sum := sha256.Sum256(body)
+
+	creds, err := s.credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("secrets manager read: failed to retrieve credentials: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("secrets manager read: failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+
+	err = s.signer.SignHTTP(ctx, creds, req, hex.EncodeToString(sum[:]), "secretsmanager", s.region, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("secrets manager read: failed to sign request: %w", err)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("secrets manager read: failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("secrets manager read: unexpected status %s", resp.Status)
+	}
+
+	var out struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("secrets manager read: failed to decode response: %w", err)
+	}
+
+	values := map[string]string{"": out.SecretString}
+
+	var fields map[string]any
+	if json.Unmarshal([]byte(out.SecretString), &fields) == nil {
+		for k, v := range stringValues(fields) {
+			values[k] = v
+		}
+	}
+
+	return values, nil
+}
@@ -0,0 +1,180 @@
+// Package secrets resolves the credentials of the configuration referencing secrets of HashiCorp
+// Vault or AWS Secrets Manager, as "secret:<path>#<key>", and watches them for rotation.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/image-processor/internal/config"
+)
+
+// Providers of secrets, selected by secrets.provider.
+const (
+	ProviderVault = "vault"
+	ProviderAWS   = "aws"
+)
+
+// defaultTimeout bounds reading a secret when no timeout is configured.
+const defaultTimeout = 10 * time.Second
+
+// ErrRotated is returned by Watch once a referenced secret changed. The clients of the database,
+// storage and queue are created with their credentials, so the app is meant to shut down gracefully
+// and be restarted by its supervisor with the rotated ones.
+var ErrRotated = errors.New("secrets rotated")
+
+// provider reads the key/value pairs of a secret.
+type provider interface {
+	Read(ctx context.Context, path string) (map[string]string, error)
+}
+
+// reference is a credentials setting referencing a secret.
+type reference struct {
+	setting string // key of the setting, e.g. "database.master.pass"
+	path    string // path of the secret
+	key     string // key within the secret; empty for a secret holding a single value
+}
+
+// Resolver resolves and watches the credentials referencing secrets.
+type Resolver struct {
+	provider provider
+	refs     []reference
+	values   map[string]string // resolved values by setting
+}
+
+// New creates a resolver of the secrets of the configured provider.
+// Without a provider, credentials are used as configured.
+func New(ctx context.Context, cfg config.Secrets) (*Resolver, error) {
+	client := &http.Client{Timeout: cfg.Timeout}
+	if cfg.Timeout <= 0 {
+		client.Timeout = defaultTimeout
+	}
+
+	r := &Resolver{values: make(map[string]string)}
+
+	switch cfg.Provider {
+	case "":
+	case ProviderVault:
+		r.provider = newVault(client, cfg.Vault)
+	case ProviderAWS:
+		p, err := newSecretsManager(ctx, client, cfg.AWS)
+		if err != nil {
+			return nil, err
+		}
+		r.provider = p
+	default:
+		return nil, fmt.Errorf("unknown secrets provider %q", cfg.Provider)
+	}
+
+	return r, nil
+}
+
+// Resolve replaces the credentials of cfg referencing secrets with the values of the secrets,
+// and remembers the references for Watch.
+func (r *Resolver) Resolve(ctx context.Context, cfg *config.Config) error {
+	creds := cfg.Credentials()
+
+	refs := make([]reference, 0, len(creds))
+	for _, setting := range slices.Sorted(maps.Keys(creds)) {
+		ref, ok := strings.CutPrefix(*creds[setting], config.SecretRefPrefix)
+		if !ok {
+			continue
+		}
+
+		path, key, _ := strings.Cut(ref, "#")
+		refs = append(refs, reference{setting: setting, path: path, key: key})
+	}
+
+	if len(refs) == 0 {
+		return nil
+	}
+	if r.provider == nil {
+		return fmt.Errorf("resolve secrets: %s references a secret, but no provider is configured", refs[0].setting)
+	}
+
+	values, err := r.read(ctx, refs)
+	if err != nil {
+		return fmt.Errorf("resolve secrets: %w", err)
+	}
+
+	for _, ref := range refs {
+		*creds[ref.setting] = values[ref.setting]
+	}
+	r.refs = refs
+	r.values = values
+
+	zlog.Logger.Info().Int("count", len(refs)).Msg("resolved credentials from secrets")
+
+	return nil
+}
+
+// Watch reads the referenced secrets every interval until ctx is canceled, and returns ErrRotated,
+// naming the changed settings, once one of them changed. Failed reads are logged and retried on the
+// next tick.
+func (r *Resolver) Watch(ctx context.Context, interval time.Duration) error {
+	if len(r.refs) == 0 || interval <= 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		values, err := r.read(ctx, r.refs)
+		if err != nil {
+			zlog.Logger.Err(err).Msg("failed to refresh secrets")
+			continue
+		}
+
+		var changed []string
+		for _, ref := range r.refs {
+			if values[ref.setting] != r.values[ref.setting] {
+				changed = append(changed, ref.setting)
+			}
+		}
+
+		if len(changed) > 0 {
+			return fmt.Errorf("%w: %s", ErrRotated, strings.Join(changed, ", "))
+		}
+	}
+}
+
+// read reads the values of the references by setting, reading each secret once.
+func (r *Resolver) read(ctx context.Context, refs []reference) (map[string]string, error) {
+	secrets := make(map[string]map[string]string)
+	values := make(map[string]string, len(refs))
+
+	for _, ref := range refs {
+		secret, ok := secrets[ref.path]
+		if !ok {
+			var err error
+			secret, err = r.provider.Read(ctx, ref.path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read secret %s for %s: %w", ref.path, ref.setting, err)
+			}
+			secrets[ref.path] = secret
+		}
+
+		v, ok := secret[ref.key]
+		if !ok {
+			return nil, fmt.Errorf("secret %s has no key %q for %s", ref.path, ref.key, ref.setting)
+		}
+		values[ref.setting] = v
+	}
+
+	return values, nil
+}
@@ -0,0 +1,246 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aliskhannn/image-processor/internal/config"
+)
+
+// vaultServer serves the KV version 2 secrets of a Vault server, by path within the "secret" mount.
+type vaultServer struct {
+	mu      sync.Mutex
+	secrets map[string]map[string]any
+	reads   atomic.Int32
+}
+
+func (v *vaultServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Vault-Token") != "test-token" {
+		http.Error(w, "permission denied", http.StatusForbidden)
+		return
+	}
+
+	name, ok := strings.CutPrefix(r.URL.Path, "/v1/secret/data/")
+	v.mu.Lock()
+	data, found := v.secrets[name]
+	v.mu.Unlock()
+	if !ok || !found {
+		http.NotFound(w, r)
+		return
+	}
+	v.reads.Add(1)
+
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"data": map[string]any{
+			"data":     data,
+			"metadata": map[string]any{"version": 1},
+		},
+	})
+}
+
+// set replaces the value of a key of the secret.
+func (v *vaultServer) set(name, key string, value any) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.secrets[name][key] = value
+}
+
+// newVaultResolver returns a resolver of the secrets of a Vault server serving secrets.
+func newVaultResolver(t *testing.T, secrets map[string]map[string]any) (*Resolver, *vaultServer) {
+	t.Helper()
+
+	vs := &vaultServer{secrets: secrets}
+	srv := httptest.NewServer(vs)
+	t.Cleanup(srv.Close)
+
+	r, err := New(context.Background(), config.Secrets{
+		Provider: ProviderVault,
+		Vault:    config.SecretsVault{Address: srv.URL + "/", Token: "test-token"},
+	})
+	if err != nil {
+		t.Fatalf("new resolver: %v", err)
+	}
+
+	return r, vs
+}
+
+func TestResolveVault(t *testing.T) {
+	r, vs := newVaultResolver(t, map[string]map[string]any{
+		"image-processor/db": {"user": "app", "pass": "s3cret", "port": 5432},
+	})
+
+	var cfg config.Config
+	cfg.Database.Master.User = "secret:secret/image-processor/db#user"
+	cfg.Database.Master.Pass = "secret:secret/image-processor/db#pass"
+	cfg.Database.Slaves = []config.DatabaseNode{{User: "secret:secret/image-processor/db#port", Pass: "plain"}}
+	cfg.Storage.AccessKey = "minio"
+
+	if err := r.Resolve(context.Background(), &cfg); err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+
+	for setting, tt := range map[string]struct{ got, want string }{
+		"database.master.user":    {cfg.Database.Master.User, "app"},
+		"database.master.pass":    {cfg.Database.Master.Pass, "s3cret"},
+		"database.slaves[0].user": {cfg.Database.Slaves[0].User, "5432"},
+		"database.slaves[0].pass": {cfg.Database.Slaves[0].Pass, "plain"},
+		"storage.access_key":      {cfg.Storage.AccessKey, "minio"},
+		"storage.secret_key":      {cfg.Storage.SecretKey, ""},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", setting, tt.got, tt.want)
+		}
+	}
+
+	if n := vs.reads.Load(); n != 1 {
+		t.Errorf("secret read %d times, want once", n)
+	}
+}
+
+func TestResolveReferenceErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		ref  string
+		want string
+	}{
+		{name: "missing key", ref: "secret:secret/image-processor/db#token", want: `no key "token"`},
+		{name: "unknown secret", ref: "secret:secret/image-processor/cache#pass", want: "404"},
+		{name: "path without mount", ref: "secret:db#pass", want: "lacks the mount"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := newVaultResolver(t, map[string]map[string]any{
+				"image-processor/db": {"pass": "s3cret"},
+			})
+
+			var cfg config.Config
+			cfg.Database.Master.Pass = tt.ref
+
+			err := r.Resolve(context.Background(), &cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got error %v, want one containing %q", err, tt.want)
+			}
+			if cfg.Database.Master.Pass != tt.ref {
+				t.Errorf("setting changed to %q on error", cfg.Database.Master.Pass)
+			}
+		})
+	}
+}
+
+func TestResolveWithoutProvider(t *testing.T) {
+	r, err := New(context.Background(), config.Secrets{})
+	if err != nil {
+		t.Fatalf("new resolver: %v", err)
+	}
+
+	var cfg config.Config
+	cfg.Storage.SecretKey = "plain"
+	if err := r.Resolve(context.Background(), &cfg); err != nil {
+		t.Fatalf("resolve plain credentials: %v", err)
+	}
+
+	cfg.Storage.SecretKey = "secret:secret/storage#key"
+	if err := r.Resolve(context.Background(), &cfg); err == nil {
+		t.Fatal("resolved a secret reference without a provider")
+	}
+}
+
+func TestResolveAWSSecretString(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+
+	secretStrings := map[string]string{
+		"image-processor/storage": `{"access_key": "minio", "secret_key": "minio123", "port": 9000}`,
+		"image-processor/kafka":   "not-json-password",
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" ||
+			!strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+			http.Error(w, "unsigned request", http.StatusBadRequest)
+			return
+		}
+
+		var in struct{ SecretId string }
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s, ok := secretStrings[in.SecretId]
+		if !ok {
+			http.Error(w, "ResourceNotFoundException", http.StatusBadRequest)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]string{"Name": in.SecretId, "SecretString": s})
+	}))
+	t.Cleanup(srv.Close)
+
+	r, err := New(context.Background(), config.Secrets{
+		Provider: ProviderAWS,
+		AWS:      config.SecretsAWS{Region: "eu-west-1", Endpoint: srv.URL},
+	})
+	if err != nil {
+		t.Fatalf("new resolver: %v", err)
+	}
+
+	var cfg config.Config
+	cfg.Storage.AccessKey = "secret:image-processor/storage#access_key"
+	cfg.Storage.SecretKey = "secret:image-processor/storage#secret_key"
+	cfg.Storage.Replica.AccessKey = "secret:image-processor/storage#port"
+	cfg.Kafka.SASL.Password = "secret:image-processor/kafka"
+
+	if err := r.Resolve(context.Background(), &cfg); err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+
+	for setting, tt := range map[string]struct{ got, want string }{
+		"storage.access_key":         {cfg.Storage.AccessKey, "minio"},
+		"storage.secret_key":         {cfg.Storage.SecretKey, "minio123"},
+		"storage.replica.access_key": {cfg.Storage.Replica.AccessKey, "9000"},
+		"kafka.sasl.password":        {cfg.Kafka.SASL.Password, "not-json-password"},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", setting, tt.got, tt.want)
+		}
+	}
+}
+
+func TestWatchReportsRotation(t *testing.T) {
+	r, vs := newVaultResolver(t, map[string]map[string]any{
+		"image-processor/db": {"user": "app", "pass": "s3cret"},
+	})
+
+	var cfg config.Config
+	cfg.Database.Master.User = "secret:secret/image-processor/db#user"
+	cfg.Database.Master.Pass = "secret:secret/image-processor/db#pass"
+	if err := r.Resolve(context.Background(), &cfg); err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+
+	vs.set("image-processor/db", "pass", "rotated")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := r.Watch(ctx, 10*time.Millisecond)
+	if !errors.Is(err, ErrRotated) {
+		t.Fatalf("got error %v, want ErrRotated", err)
+	}
+	if !strings.HasSuffix(err.Error(), ": database.master.pass") {
+		t.Errorf("got error %q, want it to name only database.master.pass", err)
+	}
+}
@@ -0,0 +1,82 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aliskhannn/image-processor/internal/config"
+)
+
+// vault reads the secrets of the KV version 2 engines of a HashiCorp Vault server.
+type vault struct {
+	client    *http.Client
+	address   string
+	token     string
+	namespace string
+}
+
+// newVault creates a reader of the secrets of the Vault server.
+func newVault(client *http.Client, cfg config.SecretsVault) *vault {
+	return &vault{
+		client:    client,
+		address:   strings.TrimRight(cfg.Address, "/"),
+		token:     cfg.Token,
+		namespace: cfg.Namespace,
+	}
+}
+
+// Read reads the latest version of the secret at path, the mount of its KV engine followed by the
+// path of the secret within it, e.g. "secret/image-processor/db".
+func (v *vault) Read(ctx context.Context, path string) (map[string]string, error) {
+	mount, name, ok := strings.Cut(strings.Trim(path, "/"), "/")
+	if !ok {
+		return nil, fmt.Errorf("vault read: path %q lacks the mount of the secret", path)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.address+"/v1/"+mount+"/data/"+name, nil)
+	if err != nil {
+		return nil, fmt.Errorf("vault read: failed to create request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault read: failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault read: unexpected status %s", resp.Status)
+	}
+
+	var body struct {
+		Data struct {
+			Data map[string]any `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("vault read: failed to decode response: %w", err)
+	}
+
+	return stringValues(body.Data.Data), nil
+}
+
+// stringValues returns the values of a decoded JSON object as strings.
+func stringValues(m map[string]any) map[string]string {
+	values := make(map[string]string, len(m))
+	for k, v := range m {
+		if s, ok := v.(string); ok {
+			values[k] = s
+			continue
+		}
+		values[k] = fmt.Sprint(v)
+	}
+
+	return values
+}
@@ -0,0 +1,549 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/image-processor/internal/audit"
+	"github.com/aliskhannn/image-processor/internal/errreport"
+	"github.com/aliskhannn/image-processor/internal/model"
+	imagerepo "github.com/aliskhannn/image-processor/internal/repository/image"
+	"github.com/aliskhannn/image-processor/internal/storage"
+)
+
+var (
+	// ErrJobNotRetryable is returned when retrying a job that has already been processed.
+	ErrJobNotRetryable = errors.New("job is not retryable")
+	// ErrAlreadyReplayed is returned when replaying a dead letter that has already been re-enqueued.
+	ErrAlreadyReplayed = errors.New("dead letter already replayed")
+	// ErrInvalidPayload is returned when a dead letter does not hold a processing task.
+	ErrInvalidPayload = errors.New("dead letter payload is not a processing task")
+)
+
+// Defaults of the stuck-job re-driver.
+const (
+	defaultRedriveBatch = 100              // stuck jobs handled per query
+	defaultRedriveAfter = 15 * time.Minute // idle time after which an unfinished job is stuck
+)
+
+// recentErrors is the number of failed jobs reported by Stats.
+const recentErrors = 10
+
+// producer defines the interface for re-enqueueing processing tasks.
+type producer interface {
+	Produce(ctx context.Context, img model.Image) error
+}
+
+// deadLetterPublisher defines the interface for publishing failed messages to the dead-letter topic.
+type deadLetterPublisher interface {
+	Publish(ctx context.Context, dl model.DeadLetter) error
+}
+
+// consumerControl defines the interface for pausing and resuming queue consumption.
+type consumerControl interface {
+	Pause()
+	Resume()
+	State() model.ConsumerState
+}
+
+// latencyReporter defines the interface for reporting the processing latency objectives of the actions.
+type latencyReporter interface {
+	LatencyReport() model.SLOReport
+}
+
+// repository defines the interface for inspecting and managing processing jobs in the database.
+type repository interface {
+	GetImage(ctx context.Context, id uuid.UUID) (model.Image, error)
+	GetStatus(ctx context.Context, id uuid.UUID) (model.ProcessingStatus, error)
+	ListImages(ctx context.Context, f model.ImageFilter) ([]model.Image, error)
+	CountImages(ctx context.Context, f model.ImageFilter) (int, error)
+	ListDerived(ctx context.Context, originalID uuid.UUID) ([]model.Image, error)
+	ListJobs(ctx context.Context, f model.JobFilter) ([]model.Job, error)
+	RequeueImage(ctx context.Context, id uuid.UUID) error
+	UpdateImage(ctx context.Context, id uuid.UUID, path, status string) error
+	RedriveImage(ctx context.Context, id uuid.UUID, stuckSince time.Time) error
+	FailStuckImage(ctx context.Context, id uuid.UUID, stuckSince time.Time, errMsg string) error
+	DeleteDerived(ctx context.Context, originalID uuid.UUID) (int64, error)
+	ActionStats(ctx context.Context, since time.Time) ([]model.ActionStats, error)
+	StatusCounts(ctx context.Context, since time.Time) ([]model.StatusCount, error)
+	HourlyStats(ctx context.Context, since time.Time) ([]model.HourlyStats, error)
+	QueueDepth(ctx context.Context, stuckSince time.Time) (model.QueueDepth, error)
+	StorageUsage(ctx context.Context, f model.UsageFilter) ([]model.StorageUsage, error)
+	SaveDeadLetter(ctx context.Context, dl model.DeadLetter) (uuid.UUID, error)
+	GetDeadLetter(ctx context.Context, id uuid.UUID) (model.DeadLetter, error)
+	ListDeadLetters(ctx context.Context, includeReplayed bool, limit, offset int) ([]model.DeadLetter, error)
+	MarkDeadLetterReplayed(ctx context.Context, id uuid.UUID) error
+	SaveAuditEvent(ctx context.Context, ev model.AuditEvent) error
+	ListAuditEvents(ctx context.Context, f model.AuditFilter) ([]model.AuditEvent, error)
+}
+
+// Service provides operator tooling for processing jobs.
+type Service struct {
+	fileStorage storage.Storage
+	producer    producer
+	deadLetters deadLetterPublisher
+	consumers   consumerControl
+	repository  repository
+	latency     latencyReporter
+	stuckAfter  time.Duration
+}
+
+// NewService creates a new admin Service.
+// Unfinished jobs not updated for longer than stuckAfter are reported as stuck.
+// Dead letters are published to dl, if set, in addition to being recorded in the database.
+// queue consumption is paused and resumed through cc, and the latency objectives are reported by lr.
+func NewService(
+	fs storage.Storage,
+	p producer,
+	dl deadLetterPublisher,
+	cc consumerControl,
+	r repository,
+	lr latencyReporter,
+	stuckAfter time.Duration,
+) *Service {
+	return &Service{
+		fileStorage: fs,
+		producer:    p,
+		deadLetters: dl,
+		consumers:   cc,
+		repository:  r,
+		latency:     lr,
+		stuckAfter:  stuckAfter,
+	}
+}
+
+// ListJobs returns the jobs in the requested state (failed or stuck).
+func (s *Service) ListJobs(ctx context.Context, f model.JobFilter) ([]model.Job, error) {
+	f.StuckSince = time.Now().Add(-s.stuckAfter)
+
+	jobs, err := s.repository.ListJobs(ctx, f)
+	if err != nil {
+		return nil, fmt.Errorf("list jobs: %w", err)
+	}
+
+	return jobs, nil
+}
+
+// ListImages returns a page of images of any owner matching the filter along with
+// the total number of matches across all pages. They may be served by a replica.
+func (s *Service) ListImages(ctx context.Context, f model.ImageFilter) ([]model.Image, int, error) {
+	ctx = imagerepo.ReplicaReads(ctx)
+
+	images, err := s.repository.ListImages(ctx, f)
+	if err != nil {
+		return nil, 0, fmt.Errorf("list images: failed to list images: %w", err)
+	}
+
+	total, err := s.repository.CountImages(ctx, f)
+	if err != nil {
+		return nil, 0, fmt.Errorf("list images: failed to count images: %w", err)
+	}
+
+	return images, total, nil
+}
+
+// RetryJob re-enqueues the processing job of the original image with the given ID.
+// Jobs that have already been processed are rejected with ErrJobNotRetryable.
+func (s *Service) RetryJob(ctx context.Context, id uuid.UUID) error {
+	st, err := s.repository.GetStatus(ctx, id)
+	if err != nil {
+		return fmt.Errorf("retry job: failed to get status: %w", err)
+	}
+
+	if st.Stage == model.StageDone {
+		return ErrJobNotRetryable
+	}
+
+	img, err := s.repository.GetImage(ctx, id)
+	if err != nil {
+		return fmt.Errorf("retry job: failed to get image: %w", err)
+	}
+	img.ID = id
+
+	if img.OriginalID != nil {
+		return ErrJobNotRetryable
+	}
+
+	if err := s.repository.RequeueImage(ctx, id); err != nil {
+		return fmt.Errorf("retry job: failed to requeue image: %w", err)
+	}
+
+	if err := s.producer.Produce(ctx, img); err != nil {
+		return fmt.Errorf("retry job: failed to enqueue task: %w", err)
+	}
+
+	s.record(ctx, model.AuditReprocess, id, "retry")
+
+	return nil
+}
+
+// RedriveStuck re-enqueues up to limit jobs that have been unfinished and not updated for
+// stuckAfter, or fails those already re-driven maxRedrives times.
+// Jobs picked up by a worker in the meantime are skipped.
+// Returns the number of jobs looked at.
+func (s *Service) RedriveStuck(ctx context.Context, stuckAfter time.Duration, maxRedrives, limit int) (int, error) {
+	since := time.Now().Add(-stuckAfter)
+
+	jobs, err := s.repository.ListJobs(ctx, model.JobFilter{
+		State:      model.JobStateStuck,
+		StuckSince: since,
+		Limit:      limit,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("redrive stuck: %w", err)
+	}
+
+	for _, j := range jobs {
+		if j.Redrives >= maxRedrives {
+			errMsg := fmt.Sprintf("stuck in stage %s after %d redrives", j.Stage, j.Redrives)
+			err := s.repository.FailStuckImage(ctx, j.ID, since, errMsg)
+			if errors.Is(err, imagerepo.ErrImageNotFound) {
+				continue
+			}
+			if err != nil {
+				return 0, fmt.Errorf("redrive stuck: failed to fail job %s: %w", j.ID, err)
+			}
+
+			zlog.Logger.Warn().Str("image_id", j.ID.String()).Str("stage", j.Stage).Msg("stuck job failed")
+			continue
+		}
+
+		img, err := s.repository.GetImage(ctx, j.ID)
+		if err != nil {
+			return 0, fmt.Errorf("redrive stuck: failed to get image %s: %w", j.ID, err)
+		}
+		img.ID = j.ID
+
+		err = s.repository.RedriveImage(ctx, j.ID, since)
+		if errors.Is(err, imagerepo.ErrImageNotFound) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("redrive stuck: failed to requeue job %s: %w", j.ID, err)
+		}
+
+		if err := s.producer.Produce(ctx, img); err != nil {
+			return 0, fmt.Errorf("redrive stuck: failed to enqueue job %s: %w", j.ID, err)
+		}
+
+		s.record(ctx, model.AuditReprocess, j.ID, "redrive")
+
+		zlog.Logger.Warn().
+			Str("image_id", j.ID.String()).
+			Str("stage", j.Stage).
+			Int("redrive", j.Redrives+1).
+			Msg("stuck job re-enqueued")
+	}
+
+	return len(jobs), nil
+}
+
+// RunRedriver periodically re-drives stuck jobs until the context is canceled.
+// Each pass handles batches of at most batchSize jobs until none are left.
+func (s *Service) RunRedriver(ctx context.Context, interval, stuckAfter time.Duration, maxRedrives, batchSize int) {
+	if batchSize <= 0 {
+		batchSize = defaultRedriveBatch
+	}
+	if stuckAfter <= 0 {
+		stuckAfter = defaultRedriveAfter
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				n, err := s.RedriveStuck(ctx, stuckAfter, maxRedrives, batchSize)
+				if err != nil {
+					zlog.Logger.Err(err).Msg("failed to redrive stuck jobs")
+					break
+				}
+				if n < batchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+// PurgeDerived deletes all images derived from the original with the given ID, along with
+// their files and cached transforms. The original is kept.
+// Returns the number of purged images.
+func (s *Service) PurgeDerived(ctx context.Context, id uuid.UUID) (int64, error) {
+	if _, err := s.repository.GetImage(ctx, id); err != nil {
+		return 0, fmt.Errorf("purge derived: failed to get image: %w", err)
+	}
+
+	derived, err := s.repository.ListDerived(ctx, id)
+	if err != nil {
+		return 0, fmt.Errorf("purge derived: failed to list derived images: %w", err)
+	}
+
+	n, err := s.repository.DeleteDerived(ctx, id)
+	if err != nil {
+		return 0, fmt.Errorf("purge derived: %w", err)
+	}
+
+	for _, d := range derived {
+		if err := s.fileStorage.Delete(ctx, d.Path); err != nil {
+			return 0, fmt.Errorf("purge derived: failed to delete derived image from storage: %w", err)
+		}
+	}
+
+	if err := s.fileStorage.DeletePrefix(ctx, path.Join("transforms", id.String())+"/"); err != nil {
+		return 0, fmt.Errorf("purge derived: failed to delete cached transforms: %w", err)
+	}
+
+	s.record(ctx, model.AuditDelete, id, fmt.Sprintf("purged %d derived images", n))
+
+	return n, nil
+}
+
+// Stats aggregates the jobs of originals over the given window: counts by status and action
+// with throughput, mean processing time and failure rate, and the jobs finished per hour.
+// It also reports the current state, regardless of the window: the unfinished jobs, the storage
+// usage by top-level directory and the most recently failed jobs with their errors.
+func (s *Service) Stats(ctx context.Context, window time.Duration) (model.Stats, error) {
+	since := time.Now().Add(-window)
+
+	actions, err := s.repository.ActionStats(ctx, since)
+	if err != nil {
+		return model.Stats{}, fmt.Errorf("stats: %w", err)
+	}
+
+	statuses, err := s.repository.StatusCounts(ctx, since)
+	if err != nil {
+		return model.Stats{}, fmt.Errorf("stats: %w", err)
+	}
+
+	hourly, err := s.repository.HourlyStats(ctx, since)
+	if err != nil {
+		return model.Stats{}, fmt.Errorf("stats: %w", err)
+	}
+
+	depth, err := s.repository.QueueDepth(ctx, time.Now().Add(-s.stuckAfter))
+	if err != nil {
+		return model.Stats{}, fmt.Errorf("stats: %w", err)
+	}
+
+	usage, err := s.StorageUsage(ctx, model.UsageFilter{GroupBy: model.UsageBySubdir})
+	if err != nil {
+		return model.Stats{}, fmt.Errorf("stats: %w", err)
+	}
+
+	failed, err := s.repository.ListJobs(ctx, model.JobFilter{
+		State:       model.JobStateFailed,
+		Limit:       recentErrors,
+		NewestFirst: true,
+	})
+	if err != nil {
+		return model.Stats{}, fmt.Errorf("stats: %w", err)
+	}
+
+	stats := model.Stats{
+		Window:       window.String(),
+		Statuses:     statuses,
+		Actions:      actions,
+		Hourly:       hourly,
+		Queue:        depth,
+		Storage:      usage,
+		RecentErrors: failed,
+	}
+
+	var totalMs float64
+	for i := range actions {
+		a := &actions[i]
+		a.PerHour = float64(a.Processed) / window.Hours()
+		a.FailureRate = failureRate(a.Processed, a.Failed)
+
+		stats.Processed += a.Processed
+		stats.Failed += a.Failed
+		totalMs += a.AvgDurationMs * float64(a.Processed)
+	}
+
+	stats.FailureRate = failureRate(stats.Processed, stats.Failed)
+	if stats.Processed > 0 {
+		stats.AvgDurationMs = totalMs / float64(stats.Processed)
+	}
+
+	return stats, nil
+}
+
+// LatencySLO reports the processing time percentiles of the actions over the rolling window of the
+// jobs processed by this instance, against their latency objectives, with the burning ones as alerts.
+func (s *Service) LatencySLO() model.SLOReport {
+	return s.latency.LatencyReport()
+}
+
+// failureRate returns the share of finished jobs that failed, zero if none finished.
+func failureRate(processed, failed int) float64 {
+	if processed+failed == 0 {
+		return 0
+	}
+
+	return float64(failed) / float64(processed+failed)
+}
+
+// StorageUsage returns the bytes and objects stored per owner and top-level storage directory,
+// as accounted in the database on every save and delete, along with their totals.
+func (s *Service) StorageUsage(ctx context.Context, f model.UsageFilter) (model.UsageReport, error) {
+	usage, err := s.repository.StorageUsage(ctx, f)
+	if err != nil {
+		return model.UsageReport{}, fmt.Errorf("storage usage: %w", err)
+	}
+
+	report := model.UsageReport{Usage: usage}
+	for _, u := range usage {
+		report.Bytes += u.Bytes
+		report.Objects += u.Objects
+	}
+
+	return report, nil
+}
+
+// RecordDeadLetter stores a message that kept failing processing and publishes it to the dead-letter topic.
+// Its retries are over, so the failure is reported and the image of its task is marked failed.
+func (s *Service) RecordDeadLetter(ctx context.Context, dl model.DeadLetter) error {
+	id, err := s.repository.SaveDeadLetter(ctx, dl)
+	if err != nil {
+		return fmt.Errorf("record dead letter: %w", err)
+	}
+	dl.ID = id
+
+	if s.deadLetters != nil {
+		if err := s.deadLetters.Publish(ctx, dl); err != nil {
+			return fmt.Errorf("record dead letter: failed to publish dead letter: %w", err)
+		}
+	}
+
+	errreport.Error(ctx, fmt.Errorf("dead letter %s: %s", dl.ID, dl.Error))
+	s.failDeadLetter(ctx, dl)
+
+	return nil
+}
+
+// failDeadLetter marks the image of the task held by the dead letter failed. Errors are logged:
+// the dead letter is recorded, and the image stays replayable either way.
+func (s *Service) failDeadLetter(ctx context.Context, dl model.DeadLetter) {
+	var task model.Task
+	if err := json.Unmarshal([]byte(dl.Payload), &task); err != nil || task.ID == uuid.Nil {
+		return
+	}
+
+	img, err := s.repository.GetImage(ctx, task.ID)
+	if err == nil {
+		err = s.repository.UpdateImage(ctx, task.ID, img.Path, model.StatusFailed)
+	}
+	if err != nil && !errors.Is(err, imagerepo.ErrImageNotFound) {
+		zlog.Logger.Err(err).Str("image_id", task.ID.String()).Msg("failed to mark dead-lettered image failed")
+	}
+}
+
+// ListDeadLetters returns recorded dead letters, most recent first.
+func (s *Service) ListDeadLetters(
+	ctx context.Context,
+	includeReplayed bool,
+	limit, offset int,
+) ([]model.DeadLetter, error) {
+	letters, err := s.repository.ListDeadLetters(ctx, includeReplayed, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("list dead letters: %w", err)
+	}
+
+	return letters, nil
+}
+
+// ReplayDeadLetter re-enqueues the processing task held by the dead letter with the given ID.
+func (s *Service) ReplayDeadLetter(ctx context.Context, id uuid.UUID) error {
+	dl, err := s.repository.GetDeadLetter(ctx, id)
+	if err != nil {
+		return fmt.Errorf("replay dead letter: failed to get dead letter: %w", err)
+	}
+
+	if dl.ReplayedAt != nil {
+		return ErrAlreadyReplayed
+	}
+
+	var task model.Task
+	if err := json.Unmarshal([]byte(dl.Payload), &task); err != nil || task.ID == uuid.Nil {
+		return ErrInvalidPayload
+	}
+
+	// The task only references the image, whose current record provides the rest, e.g. its priority.
+	img, err := s.repository.GetImage(ctx, task.ID)
+	if err != nil {
+		return fmt.Errorf("replay dead letter: failed to get image: %w", err)
+	}
+	img.ID = task.ID
+	img.Action = task.Action
+	if task.CallbackURL != "" {
+		img.CallbackURL = task.CallbackURL
+	}
+
+	if err := s.repository.RequeueImage(ctx, img.ID); err != nil {
+		return fmt.Errorf("replay dead letter: failed to requeue image: %w", err)
+	}
+
+	if err := s.producer.Produce(ctx, img); err != nil {
+		return fmt.Errorf("replay dead letter: failed to enqueue task: %w", err)
+	}
+
+	if err := s.repository.MarkDeadLetterReplayed(ctx, id); err != nil {
+		return fmt.Errorf("replay dead letter: %w", err)
+	}
+
+	s.record(ctx, model.AuditReprocess, img.ID, "dead letter "+id.String())
+
+	return nil
+}
+
+// ListAuditEvents returns the audit log events matching the filter, newest first.
+func (s *Service) ListAuditEvents(ctx context.Context, f model.AuditFilter) ([]model.AuditEvent, error) {
+	events, err := s.repository.ListAuditEvents(ctx, f)
+	if err != nil {
+		return nil, fmt.Errorf("list audit events: %w", err)
+	}
+
+	return events, nil
+}
+
+// record appends an event of the image to the audit log, attributed to the actor of ctx.
+// Failures are only logged, so that auditing never fails the audited operation.
+func (s *Service) record(ctx context.Context, event string, id uuid.UUID, details string) {
+	if err := s.repository.SaveAuditEvent(ctx, audit.Event(ctx, event, id, details)); err != nil {
+		zlog.Logger.Err(err).Str("event", event).Str("image_id", id.String()).Msg("failed to record audit event")
+	}
+}
+
+// PauseConsumers stops queue consumers from fetching new messages. Messages in flight are
+// still finished, so the system is drained once the returned state reports none in flight.
+func (s *Service) PauseConsumers() model.ConsumerState {
+	s.consumers.Pause()
+	zlog.Logger.Warn().Msg("queue consumption paused")
+
+	return s.consumers.State()
+}
+
+// ResumeConsumers lets paused queue consumers continue fetching messages.
+func (s *Service) ResumeConsumers() model.ConsumerState {
+	s.consumers.Resume()
+	zlog.Logger.Info().Msg("queue consumption resumed")
+
+	return s.consumers.State()
+}
+
+// ConsumerState returns whether queue consumption is paused and the number of messages in flight.
+func (s *Service) ConsumerState() model.ConsumerState {
+	return s.consumers.State()
+}
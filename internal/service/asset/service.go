@@ -0,0 +1,48 @@
+package asset
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/google/uuid"
+
+	"github.com/aliskhannn/image-processor/internal/processor"
+	"github.com/aliskhannn/image-processor/internal/storage"
+)
+
+// ErrInvalidAsset is returned when an uploaded asset cannot be parsed.
+var ErrInvalidAsset = errors.New("invalid asset")
+
+// Service provides business logic for processing assets such as color grading LUTs.
+type Service struct {
+	fileStorage storage.Storage
+}
+
+// NewService creates a new Service with the given storage.
+func NewService(fs storage.Storage) *Service {
+	return &Service{fileStorage: fs}
+}
+
+// SaveLUT validates the uploaded .cube file and stores it as a LUT asset.
+// Returns the asset ID to be passed as the "lut" param of the lut action.
+func (s *Service) SaveLUT(ctx context.Context, file io.Reader) (uuid.UUID, error) {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("save lut: failed to read file: %w", err)
+	}
+
+	if _, err := processor.ParseCube(bytes.NewReader(data)); err != nil {
+		return uuid.Nil, fmt.Errorf("save lut: %w: %v", ErrInvalidAsset, err)
+	}
+
+	id := uuid.New()
+	if err := s.fileStorage.Save(ctx, path.Join(processor.LUTSubdir, id.String()+".cube"), bytes.NewReader(data), "text/plain"); err != nil {
+		return uuid.Nil, fmt.Errorf("save lut: failed to save file in storage: %w", err)
+	}
+
+	return id, nil
+}
@@ -2,20 +2,54 @@ package image
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"image"
 	"io"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 
+	"github.com/aliskhannn/image-processor/internal/audit"
+	"github.com/aliskhannn/image-processor/internal/errreport"
+	"github.com/aliskhannn/image-processor/internal/logging"
 	"github.com/aliskhannn/image-processor/internal/model"
+	"github.com/aliskhannn/image-processor/internal/processor"
+	"github.com/aliskhannn/image-processor/internal/reload"
+	imagerepo "github.com/aliskhannn/image-processor/internal/repository/image"
+	"github.com/aliskhannn/image-processor/internal/storage"
+	"github.com/aliskhannn/image-processor/internal/trace"
 )
 
-// fileStorage defines the interface for storing files (e.g., local filesystem or S3).
-type fileStorage interface {
-	Save(ctx context.Context, subdir, filename string, src io.Reader) (string, error)
-	Load(ctx context.Context, path string) (io.ReadCloser, error)
-	Delete(ctx context.Context, path string) error
-}
+// Defaults of the background jobs.
+const (
+	defaultSweepBatch  = 100 // expired images deleted per query
+	defaultOutboxBatch = 100 // outbox tasks published per transaction
+
+	defaultOutboxInterval = 500 * time.Millisecond // pause between outbox relay passes
+)
+
+// Metadata limits enforced by UpdateMetadata.
+const (
+	maxTitleLen       = 200
+	maxDescriptionLen = 2000
+	maxTags           = 32
+	maxTagLen         = 64
+)
+
+var (
+	// ErrInvalidMetadata is returned when user-supplied metadata fails validation.
+	ErrInvalidMetadata = errors.New("invalid metadata")
+	// ErrStorageQuotaExceeded is returned when an upload would exceed the stored bytes quota of the user.
+	ErrStorageQuotaExceeded = errors.New("storage quota exceeded")
+	// ErrRateQuotaExceeded is returned when the user exceeded the images per day or jobs per hour quota.
+	ErrRateQuotaExceeded = errors.New("rate quota exceeded")
+	// ErrWatchUnavailable is returned when status updates can't be streamed with the configured database.
+	ErrWatchUnavailable = errors.New("status updates unavailable")
+)
 
 // producer defines the interface for enqueueing tasks into a message broker (e.g., Kafka).
 type producer interface {
@@ -25,6 +59,45 @@ type producer interface {
 // imgProcessor defines the interface for processing images (resize, watermark, etc.).
 type imgProcessor interface {
 	Process(ctx context.Context, img model.Image) (model.Image, error)
+	DetectWatermark(src image.Image) (string, error)
+	Decode(r io.Reader) (image.Image, error)
+	CheckLimits(r io.Reader) (processor.Info, io.Reader, error)
+	SaveTransform(ctx context.Context, dst string, src image.Image, o processor.TransformOptions) error
+}
+
+// textExtractor defines the interface for extracting text from images (OCR).
+type textExtractor interface {
+	Extract(ctx context.Context, src io.Reader) (string, error)
+}
+
+// virusScanner defines the interface for scanning uploads for malware.
+type virusScanner interface {
+	Scan(ctx context.Context, src io.Reader) error
+}
+
+// notifier defines the interface for delivering webhook events to client callback URLs.
+type notifier interface {
+	Notify(ctx context.Context, url string, event model.WebhookEvent) error
+}
+
+// eventPublisher defines the interface for publishing completion events to downstream systems.
+type eventPublisher interface {
+	PublishProcessed(ctx context.Context, ev model.ProcessedEvent) error
+}
+
+// cdnPurger defines the interface for purging the cached responses of images from the CDN.
+type cdnPurger interface {
+	Purge(ctx context.Context, ids []uuid.UUID) error
+}
+
+// statusWatcher defines the interface for subscribing to status changes of images.
+type statusWatcher interface {
+	Subscribe(id uuid.UUID) (changes <-chan struct{}, cancel func())
+}
+
+// latencyRecorder defines the interface for tracking the processing time of jobs per action.
+type latencyRecorder interface {
+	ObserveLatency(action string, d time.Duration)
 }
 
 // repository defines the interface for image CRUD operations in the database.
@@ -32,68 +105,406 @@ type repository interface {
 	SaveImage(ctx context.Context, img model.Image) (uuid.UUID, error)
 	GetImage(ctx context.Context, id uuid.UUID) (model.Image, error)
 	UpdateImage(ctx context.Context, id uuid.UUID, path, status string) error
+	UpdateOCRText(ctx context.Context, id uuid.UUID, text string) error
 	DeleteImage(ctx context.Context, id uuid.UUID) error
+	SoftDeleteImage(ctx context.Context, id uuid.UUID) error
+	ListDeleted(ctx context.Context, before time.Time, limit int) ([]model.Image, error)
+	ListChanges(ctx context.Context, since int64, limit int) ([]model.Change, error)
+	ListImages(ctx context.Context, f model.ImageFilter) ([]model.Image, error)
+	CountImages(ctx context.Context, f model.ImageFilter) (int, error)
+	ListDerived(ctx context.Context, originalID uuid.UUID) ([]model.Image, error)
+	PathInUse(ctx context.Context, path string) (bool, error)
+	GetStatus(ctx context.Context, id uuid.UUID) (model.ProcessingStatus, error)
+	BeginAttempt(ctx context.Context, id uuid.UUID) error
+	FinishAttempt(ctx context.Context, id uuid.UUID) error
+	FailAttempt(ctx context.Context, id uuid.UUID, errMsg string) error
+	GetUsage(ctx context.Context, owner string) (model.QuotaUsage, error)
+	FindOriginalByHash(ctx context.Context, owner, hash string) (model.Image, error)
+	RequeueImage(ctx context.Context, id uuid.UUID) error
+	ListExpired(ctx context.Context, limit int) ([]model.Image, error)
+	UpdateMetadata(ctx context.Context, id uuid.UUID, upd model.MetadataUpdate) error
+	GetProcessedMessage(ctx context.Context, messageID string) (uuid.UUID, error)
+	MarkMessageProcessed(ctx context.Context, messageID string, imageID, derivedID uuid.UUID) error
+	BeginMessageAttempt(ctx context.Context, messageID string) (model.MessageAttempt, error)
+	FailMessageAttempt(ctx context.Context, messageID, errMsg string) error
+	DeleteMessageAttempts(ctx context.Context, messageID string) error
+	SaveAuditEvent(ctx context.Context, ev model.AuditEvent) error
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+	EnqueueOutbox(ctx context.Context, msg model.OutboxMessage) error
+	PendingOutbox(ctx context.Context, limit int) ([]model.OutboxMessage, error)
+	DeleteOutbox(ctx context.Context, id int64) error
 }
 
 // Service provides business logic for image operations.
 // It saves uploaded images to storage and publishes processing tasks to a queue.
 type Service struct {
-	fileStorage  fileStorage
+	fileStorage  storage.Storage
 	producer     producer
 	imgProcessor imgProcessor
 	repository   repository
+	ocr          textExtractor // optional, nil disables the OCR step
+	scanner      virusScanner  // optional, nil disables antivirus scanning
+	notifier     notifier
+	events       eventPublisher  // optional, nil disables completion events
+	cdn          cdnPurger       // optional, nil disables CDN purging
+	watcher      statusWatcher   // optional, nil disables streaming status updates
+	latency      latencyRecorder // optional, nil disables latency tracking
+	quota        *reload.Value[model.QuotaLimits]
+	outbox       bool // enqueue upload tasks through the transactional outbox
 }
 
 // NewService creates a new Service with the given storage and producer.
+// The text extractor, virus scanner, event publisher, CDN purger, status watcher and latency recorder are
+// optional; pass nil to disable the OCR step, scanning, completion events, CDN purging, status streaming
+// or latency tracking. With outbox,
+// the tasks of uploads are stored in the transaction recording their image and published by RunOutboxRelay.
 func NewService(
-	fs fileStorage,
+	fs storage.Storage,
 	p producer,
 	imgP imgProcessor,
 	r repository,
+	ocr textExtractor,
+	scanner virusScanner,
+	n notifier,
+	events eventPublisher,
+	cdn cdnPurger,
+	watcher statusWatcher,
+	latency latencyRecorder,
+	quota *reload.Value[model.QuotaLimits],
+	outbox bool,
 ) *Service {
 	return &Service{
 		fileStorage:  fs,
 		producer:     p,
 		imgProcessor: imgP,
 		repository:   r,
+		ocr:          ocr,
+		scanner:      scanner,
+		notifier:     n,
+		events:       events,
+		cdn:          cdn,
+		watcher:      watcher,
+		latency:      latency,
+		quota:        quota,
+		outbox:       outbox,
 	}
 }
 
 // SaveImage saves the uploaded file to storage, records it in the database,
 // and enqueues a background processing task for the specified action.
-// Returns the generated image ID, the path to the saved file, or an error.
-func (s *Service) SaveImage(ctx context.Context, subdir, filename string, file io.Reader, action model.Action) (uuid.UUID, string, error) {
-	// Save the original file to storage.
-	dst, err := s.fileStorage.Save(ctx, subdir, filename, file)
+// If the owner already uploaded an identical original (by SHA-256), nothing is stored and the existing
+// image is returned as a duplicate; with opts.Reprocess the requested action is still enqueued for it.
+// The upload is rejected with ErrStorageQuotaExceeded or ErrRateQuotaExceeded if it exceeds the user's quota,
+// with processor.ErrUnsupportedFormat if the file content is not a supported image,
+// with processor.ErrImageTooLarge if its dimensions exceed the decoding limits,
+// and with the scanner's error if antivirus scanning is enabled and the file is infected or can't be scanned.
+func (s *Service) SaveImage(
+	ctx context.Context,
+	subdir, filename string,
+	file io.Reader,
+	action model.Action,
+	opts model.UploadOptions,
+) (model.SavedUpload, error) {
+	if opts.Owner == "" {
+		opts.Owner = model.DefaultOwner
+	}
+	if opts.Priority == "" {
+		opts.Priority = model.PriorityNormal
+	}
+
+	// Reject non-image payloads by their content rather than the client-supplied type.
+	format, file, err := processor.Sniff(file)
 	if err != nil {
-		return uuid.Nil, "", fmt.Errorf("save image: failed to save image in storage: %w", err)
+		return model.SavedUpload{}, fmt.Errorf("save image: %w", err)
+	}
+
+	info, file, err := s.imgProcessor.CheckLimits(file)
+	if err != nil {
+		return model.SavedUpload{}, fmt.Errorf("save image: %w", err)
+	}
+
+	// The content is spooled to a temporary file so that it can be hashed and scanned before
+	// anything is stored, without holding large uploads in memory.
+	spooled, err := spool(file)
+	if err != nil {
+		return model.SavedUpload{}, fmt.Errorf("save image: %w", err)
+	}
+	defer spooled.remove()
+
+	if s.scanner != nil {
+		if err := s.scanner.Scan(ctx, spooled.reader()); err != nil {
+			return model.SavedUpload{}, fmt.Errorf("save image: antivirus: %w", err)
+		}
+	}
+
+	hash := spooled.hash
+
+	// Expiring uploads always get their own record, so that their expiry never deletes another upload.
+	if opts.ExpiresAt == nil {
+		existing, err := s.repository.FindOriginalByHash(ctx, opts.Owner, hash)
+		switch {
+		case err == nil:
+			return s.saveDuplicate(ctx, existing, action, opts)
+		case !errors.Is(err, imagerepo.ErrImageNotFound):
+			return model.SavedUpload{}, fmt.Errorf("save image: failed to look up duplicate: %w", err)
+		}
+	}
+
+	if err := s.checkQuota(ctx, opts.Owner, spooled.size); err != nil {
+		return model.SavedUpload{}, fmt.Errorf("save image: %w", err)
+	}
+
+	// Save the original file to storage under its content hash, so that uploads sharing a filename never
+	// overwrite each other; the filename is only kept in the database. Identical uploads share the file.
+	dst := path.Join(subdir, "sha256", hash)
+	if err := s.fileStorage.Save(ctx, dst, spooled.reader(), processor.ContentType(format)); err != nil {
+		return model.SavedUpload{}, fmt.Errorf("save image: failed to save image in storage: %w", err)
 	}
 
 	img := model.Image{
-		Filename: filename,
-		Path:     dst,
-		Action:   action,
-		Status:   "pending",
+		Filename:    filename,
+		Path:        dst,
+		Action:      action,
+		Status:      model.StatusPending,
+		CallbackURL: opts.CallbackURL,
+		Owner:       opts.Owner,
+		Priority:    opts.Priority,
+		Size:        spooled.size,
+		Format:      format,
+		Width:       info.Width,
+		Height:      info.Height,
+		ColorSpace:  info.ColorSpace,
+		ContentHash: hash,
+		ExpiresAt:   opts.ExpiresAt,
 	}
 
-	id, err := s.repository.SaveImage(ctx, img)
+	// Record the image and enqueue the task for asynchronous processing.
+	img, err = s.enqueue(ctx, func(ctx context.Context) (model.Image, error) {
+		id, err := s.repository.SaveImage(ctx, img)
+		if err != nil {
+			return model.Image{}, fmt.Errorf("failed to save image to db: %w", err)
+		}
+
+		img.ID = id
+		return img, nil
+	})
 	if err != nil {
-		return uuid.Nil, "", fmt.Errorf("save image: failed to save image to db: %w", err)
+		return model.SavedUpload{}, fmt.Errorf("save image: %w", err)
 	}
 
-	img.ID = id
+	s.tagObject(ctx, img)
+	s.record(ctx, model.AuditUpload, img.ID, action.Name)
 
-	// Produce the task for asynchronous processing.
-	if err := s.producer.Produce(ctx, img); err != nil {
-		return uuid.Nil, "", fmt.Errorf("save image: failed to enqueue task: %w", err)
+	return model.SavedUpload{ID: img.ID, Path: dst}, nil
+}
+
+// saveDuplicate handles an upload identical to an existing original. The existing image is returned;
+// if reprocessing was requested, the action is enqueued for it as a new processing job.
+func (s *Service) saveDuplicate(
+	ctx context.Context,
+	existing model.Image,
+	action model.Action,
+	opts model.UploadOptions,
+) (model.SavedUpload, error) {
+	saved := model.SavedUpload{ID: existing.ID, Path: existing.Path, Duplicate: true}
+
+	if !opts.Reprocess {
+		s.record(ctx, model.AuditUpload, existing.ID, "duplicate")
+		return saved, nil
 	}
 
-	return id, dst, nil
+	// Nothing new is stored, so only the rate quotas apply.
+	if err := s.checkQuota(ctx, opts.Owner, 0); err != nil {
+		return model.SavedUpload{}, fmt.Errorf("save image: %w", err)
+	}
+
+	existing.Action = action
+	existing.CallbackURL = opts.CallbackURL
+	existing.Priority = opts.Priority
+
+	_, err := s.enqueue(ctx, func(ctx context.Context) (model.Image, error) {
+		if err := s.repository.RequeueImage(ctx, existing.ID); err != nil {
+			return model.Image{}, fmt.Errorf("failed to requeue image: %w", err)
+		}
+
+		return existing, nil
+	})
+	if err != nil {
+		return model.SavedUpload{}, fmt.Errorf("save image: %w", err)
+	}
+
+	s.record(ctx, model.AuditReprocess, existing.ID, action.Name)
+
+	return saved, nil
+}
+
+// enqueue records an image with record and enqueues the processing task of the image it returns.
+// With the outbox, the task is stored in the transaction of record and published by the relay,
+// so that an image is never recorded without its task; otherwise it is produced once recorded.
+func (s *Service) enqueue(ctx context.Context, record func(ctx context.Context) (model.Image, error)) (model.Image, error) {
+	if !s.outbox {
+		img, err := record(ctx)
+		if err != nil {
+			return model.Image{}, err
+		}
+
+		if err := s.producer.Produce(ctx, img); err != nil {
+			return model.Image{}, fmt.Errorf("failed to enqueue task: %w", err)
+		}
+
+		return img, nil
+	}
+
+	var img model.Image
+	err := s.repository.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		if img, err = record(ctx); err != nil {
+			return err
+		}
+
+		msg := model.OutboxMessage{Image: img, Headers: make(map[string]string)}
+		trace.Inject(ctx, func(key, value string) { msg.Headers[key] = value })
+
+		if err := s.repository.EnqueueOutbox(ctx, msg); err != nil {
+			return fmt.Errorf("failed to enqueue task: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return model.Image{}, err
+	}
+
+	return img, nil
+}
+
+// RelayOutbox publishes up to limit tasks from the outbox to the queue in a single transaction
+// and deletes them. Tasks are published at least once: a task published by a relay failing
+// before its commit is published again. Returns the number of published tasks.
+func (s *Service) RelayOutbox(ctx context.Context, limit int) (int, error) {
+	var (
+		sent       int
+		produceErr error
+	)
+
+	err := s.repository.WithTx(ctx, func(ctx context.Context) error {
+		msgs, err := s.repository.PendingOutbox(ctx, limit)
+		if err != nil {
+			return err
+		}
+
+		for _, msg := range msgs {
+			// Tasks carry the trace context of the upload, not of the relay.
+			msgCtx := trace.Extract(ctx, func(key string) string { return msg.Headers[key] })
+			if err := s.producer.Produce(msgCtx, msg.Image); err != nil {
+				// Commit the tasks published so far; the rest is retried on the next pass.
+				produceErr = fmt.Errorf("failed to enqueue task of image %s: %w", msg.Image.ID, err)
+				break
+			}
+
+			if err := s.repository.DeleteOutbox(ctx, msg.ID); err != nil {
+				return err
+			}
+
+			sent++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("relay outbox: %w", err)
+	}
+
+	if produceErr != nil {
+		return sent, fmt.Errorf("relay outbox: %w", produceErr)
+	}
+
+	return sent, nil
+}
+
+// RunOutboxRelay periodically publishes the tasks of the outbox until the context is canceled.
+// Each pass publishes batches of at most batchSize tasks until none are left.
+func (s *Service) RunOutboxRelay(ctx context.Context, interval time.Duration, batchSize int) {
+	if batchSize <= 0 {
+		batchSize = defaultOutboxBatch
+	}
+	if interval <= 0 {
+		interval = defaultOutboxInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				n, err := s.RelayOutbox(ctx, batchSize)
+				if err != nil {
+					if ctx.Err() == nil {
+						logging.FromContext(ctx).Err(err).Msg("failed to relay outbox")
+					}
+					break
+				}
+				if n < batchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+// checkQuota returns an error if storing size more bytes for the owner would exceed the configured quotas.
+func (s *Service) checkQuota(ctx context.Context, owner string, size int64) error {
+	usage, err := s.repository.GetUsage(ctx, owner)
+	if err != nil {
+		return fmt.Errorf("failed to get quota usage: %w", err)
+	}
+
+	quota := s.quota.Load()
+	if quota.MaxBytes > 0 && usage.BytesStored+size > quota.MaxBytes {
+		return ErrStorageQuotaExceeded
+	}
+	if quota.ImagesPerDay > 0 && usage.ImagesLastDay >= quota.ImagesPerDay {
+		return ErrRateQuotaExceeded
+	}
+	if quota.JobsPerHour > 0 && usage.JobsLastHour >= quota.JobsPerHour {
+		return ErrRateQuotaExceeded
+	}
+
+	return nil
+}
+
+// GetQuotaUsage returns the current resource usage of the owner along with the configured limits.
+func (s *Service) GetQuotaUsage(ctx context.Context, owner string) (model.QuotaUsage, error) {
+	usage, err := s.repository.GetUsage(ctx, owner)
+	if err != nil {
+		return model.QuotaUsage{}, fmt.Errorf("get quota usage: %w", err)
+	}
+
+	usage.Owner = owner
+	usage.Limits = s.quota.Load()
+
+	return usage, nil
+}
+
+// GetMeta retrieves the image record without loading its file.
+// It may be served by a replica.
+func (s *Service) GetMeta(ctx context.Context, id uuid.UUID) (model.Image, error) {
+	img, err := s.repository.GetImage(imagerepo.ReplicaReads(ctx), id)
+	if err != nil {
+		return model.Image{}, fmt.Errorf("get meta: failed to get image: %w", err)
+	}
+
+	return img, nil
 }
 
 // GetImage retrieves the image metadata and file content from storage.
+// The metadata may be served by a replica.
 func (s *Service) GetImage(ctx context.Context, id uuid.UUID) (model.Image, io.ReadCloser, error) {
-	img, err := s.repository.GetImage(ctx, id)
+	img, err := s.repository.GetImage(imagerepo.ReplicaReads(ctx), id)
 	if err != nil {
 		return model.Image{}, nil, fmt.Errorf("get image: failed to get image: %w", err)
 	}
@@ -103,44 +514,742 @@ func (s *Service) GetImage(ctx context.Context, id uuid.UUID) (model.Image, io.R
 		return model.Image{}, nil, fmt.Errorf("get image: failed to load file: %w", err)
 	}
 
+	s.record(ctx, model.AuditDownload, id, "")
+
 	return img, srcReader, nil
 }
 
-// DeleteImage deletes the image record from the database and removes the file from storage.
+// DeleteImage soft-deletes the image and the images derived from it, hiding them from reads, and
+// purges them from the CDN. Their records and files are kept until SweepDeleted purges them.
 func (s *Service) DeleteImage(ctx context.Context, id uuid.UUID) error {
-	img, err := s.repository.GetImage(ctx, id)
+	derived, err := s.repository.ListDerived(ctx, id)
+	if err != nil {
+		return fmt.Errorf("delete image: failed to list derived images: %w", err)
+	}
+
+	if err := s.repository.SoftDeleteImage(ctx, id); err != nil {
+		return fmt.Errorf("delete image: failed to mark image deleted: %w", err)
+	}
+
+	ids := []uuid.UUID{id}
+	for _, d := range derived {
+		ids = append(ids, d.ID)
+	}
+	s.purge(ctx, ids...)
+
+	s.record(ctx, model.AuditDelete, id, "")
+
+	return nil
+}
+
+// deleteImage deletes a loaded image record along with its derived images not soft-deleted,
+// stored files and cached transforms, and purges them from the CDN.
+func (s *Service) deleteImage(ctx context.Context, img model.Image) error {
+	id := img.ID
+
+	derived, err := s.repository.ListDerived(ctx, id)
 	if err != nil {
-		return fmt.Errorf("get image: failed to get image: %w", err)
+		return fmt.Errorf("delete image: failed to list derived images: %w", err)
 	}
 
-	// Delete from database.
+	// Delete from database; derived records are removed by the foreign key cascade.
 	err = s.repository.DeleteImage(ctx, id)
 	if err != nil {
 		return fmt.Errorf("delete image: failed to delete image from db: %w", err)
 	}
 
-	// Delete from storage.
-	err = s.fileStorage.Delete(ctx, img.Path)
+	// Delete from storage, unless other uploads of the same content still share the file.
+	inUse, err := s.repository.PathInUse(ctx, img.Path)
+	if err != nil {
+		return fmt.Errorf("delete image: failed to check file references: %w", err)
+	}
+	if !inUse {
+		if err := s.fileStorage.Delete(ctx, img.Path); err != nil {
+			return fmt.Errorf("delete image: failed to delete image from storage: %w", err)
+		}
+	}
+
+	for _, d := range derived {
+		if err := s.fileStorage.Delete(ctx, d.Path); err != nil {
+			return fmt.Errorf("delete image: failed to delete derived image from storage: %w", err)
+		}
+	}
+
+	// Drop cached on-the-fly transforms.
+	if err := s.fileStorage.DeletePrefix(ctx, path.Join("transforms", id.String())+"/"); err != nil {
+		return fmt.Errorf("delete image: failed to delete cached transforms: %w", err)
+	}
+
+	ids := []uuid.UUID{id}
+	for _, d := range derived {
+		ids = append(ids, d.ID)
+	}
+	s.purge(ctx, ids...)
+
+	return nil
+}
+
+// SweepExpired deletes up to limit originals past their expiry time, together with their
+// derived images and stored files. It returns the number of deleted originals.
+func (s *Service) SweepExpired(ctx context.Context, limit int) (int, error) {
+	expired, err := s.repository.ListExpired(ctx, limit)
+	if err != nil {
+		return 0, fmt.Errorf("sweep expired: failed to list expired images: %w", err)
+	}
+
+	deleted := 0
+	for _, img := range expired {
+		if err := s.deleteImage(ctx, img); err != nil {
+			return deleted, fmt.Errorf("sweep expired: failed to delete image %s: %w", img.ID, err)
+		}
+
+		s.record(ctx, model.AuditDelete, img.ID, "expired")
+
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// SweepDeleted purges up to limit images soft-deleted before the given moment: their records,
+// stored files and cached transforms are deleted. It returns the number of purged images.
+func (s *Service) SweepDeleted(ctx context.Context, before time.Time, limit int) (int, error) {
+	deleted, err := s.repository.ListDeleted(ctx, before, limit)
+	if err != nil {
+		return 0, fmt.Errorf("sweep deleted: failed to list deleted images: %w", err)
+	}
+
+	// Derived images are listed before their original, so their files are deleted before the
+	// record of the original cascades to theirs.
+	purged := 0
+	for _, img := range deleted {
+		if err := s.deleteImage(ctx, img); err != nil {
+			return purged, fmt.Errorf("sweep deleted: failed to purge image %s: %w", img.ID, err)
+		}
+
+		purged++
+	}
+
+	return purged, nil
+}
+
+// RunExpirySweeper periodically deletes expired images, and purges images deleted more than
+// retention ago, until the context is canceled. Each pass deletes batches of at most batchSize
+// images until none are left.
+func (s *Service) RunExpirySweeper(ctx context.Context, interval time.Duration, batchSize int, retention time.Duration) {
+	if batchSize <= 0 {
+		batchSize = defaultSweepBatch
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sweepBatches(ctx, batchSize, "expired", func(ctx context.Context, limit int) (int, error) {
+				return s.SweepExpired(ctx, limit)
+			})
+
+			before := time.Now().Add(-retention)
+			sweepBatches(ctx, batchSize, "deleted", func(ctx context.Context, limit int) (int, error) {
+				return s.SweepDeleted(ctx, before, limit)
+			})
+		}
+	}
+}
+
+// sweepBatches runs sweep over batches of at most batchSize images until a batch comes short,
+// logging the images of kind it deleted.
+func sweepBatches(ctx context.Context, batchSize int, kind string, sweep func(ctx context.Context, limit int) (int, error)) {
+	for {
+		n, err := sweep(ctx, batchSize)
+		if err != nil {
+			logging.FromContext(ctx).Err(err).Msgf("failed to sweep %s images", kind)
+			return
+		}
+		if n > 0 {
+			logging.FromContext(ctx).Info().Int("count", n).Msgf("deleted %s images", kind)
+		}
+		if n < batchSize {
+			return
+		}
+	}
+}
+
+// ProcessImage performs the action of the task (resize, watermark, etc.) on the current state
+// of its image, records the result as a derived image linked to the original and marks the
+// original as processed. Processed images are announced to the events topic, if enabled,
+// and the cached responses of the original are purged from the CDN.
+// Messages that were already processed are skipped and redelivered messages reuse their
+// derived record, so consumer restarts don't create duplicate derived images. The image is
+// marked failed when the last attempt of the task fails, whatever the queue backend.
+// Returns the ID of the derived image.
+func (s *Service) ProcessImage(ctx context.Context, task model.Task) (uuid.UUID, error) {
+	ctx = logging.With(ctx, logging.Fields{ImageID: task.ID, Action: task.Action.Name})
+
+	if task.MessageID != "" {
+		derivedID, err := s.repository.GetProcessedMessage(ctx, task.MessageID)
+		if err == nil {
+			logging.FromContext(ctx).Info().
+				Str("message_id", task.MessageID).
+				Msg("message already processed, skipping")
+			return derivedID, nil
+		}
+		if !errors.Is(err, imagerepo.ErrMessageNotProcessed) {
+			return uuid.Nil, fmt.Errorf("process image: failed to check processed message: %w", err)
+		}
+	}
+
+	image, err := s.repository.GetImage(ctx, task.ID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("process image: failed to get image: %w", err)
+	}
+	image.ID = task.ID
+	image.Action = task.Action
+	image.MessageID = task.MessageID
+	if task.CallbackURL != "" {
+		image.CallbackURL = task.CallbackURL
+	}
+	ctx = logging.With(ctx, logging.Fields{Tenant: image.Owner})
+
+	if err := s.repository.BeginAttempt(ctx, image.ID); err != nil {
+		return uuid.Nil, fmt.Errorf("process image: failed to begin attempt: %w", err)
+	}
+
+	// Clients see the job picked up; the status moves on to processed or, once retries are over, failed.
+	if err := s.repository.UpdateImage(ctx, image.ID, image.Path, model.StatusProcessing); err != nil {
+		return uuid.Nil, fmt.Errorf("process image: failed to mark image processing: %w", err)
+	}
+
+	started := time.Now()
+
+	derived, err := s.processImage(ctx, image)
+	if err != nil {
+		if stageErr := s.repository.FailAttempt(ctx, image.ID, err.Error()); stageErr != nil {
+			logging.FromContext(ctx).Err(stageErr).Msg("failed to record failed attempt")
+		}
+
+		// Retries are over, so clients see the failure instead of an image processing forever.
+		if task.LastAttempt {
+			if failErr := s.repository.UpdateImage(ctx, image.ID, image.Path, model.StatusFailed); failErr != nil {
+				logging.FromContext(ctx).Err(failErr).Msg("failed to mark image failed")
+			}
+			errreport.Error(ctx, fmt.Errorf("process image %s: last attempt failed: %w", image.ID, err))
+		}
+
+		s.notify(ctx, image, model.WebhookEvent{
+			Event:  model.EventImageFailed,
+			Status: model.StatusFailed,
+			Error:  err.Error(),
+		})
+
+		return uuid.Nil, err
+	}
+
+	derivedID := derived.ID
+
+	if s.latency != nil {
+		s.latency.ObserveLatency(image.Action.Name, time.Since(started))
+	}
+
+	if image.MessageID != "" {
+		if err := s.repository.MarkMessageProcessed(ctx, image.MessageID, image.ID, derivedID); err != nil {
+			logging.FromContext(ctx).Err(err).Msg("failed to record processed message")
+		}
+	}
+
+	if err := s.repository.FinishAttempt(ctx, image.ID); err != nil {
+		logging.FromContext(ctx).Err(err).Msg("failed to record done stage")
+	}
+
+	s.notify(ctx, image, model.WebhookEvent{
+		Event:     model.EventImageProcessed,
+		DerivedID: &derivedID,
+		Status:    model.StatusProcessed,
+	})
+
+	s.publishProcessed(ctx, image, derived, time.Since(started))
+
+	// The status and variants served for the original changed.
+	s.purge(ctx, image.ID)
+
+	s.record(ctx, model.AuditProcess, image.ID, image.Action.Name+" derived "+derivedID.String())
+
+	return derivedID, nil
+}
+
+// MarkFailed moves the image to the failed status after its processing failed for good,
+// so that clients see the failure and its error instead of a pending image.
+// The error itself is recorded by ProcessImage with the failed attempt.
+func (s *Service) MarkFailed(ctx context.Context, id uuid.UUID) error {
+	image, err := s.repository.GetImage(ctx, id)
+	if err != nil {
+		return fmt.Errorf("mark failed: failed to get image: %w", err)
+	}
+
+	if err := s.repository.UpdateImage(ctx, id, image.Path, model.StatusFailed); err != nil {
+		return fmt.Errorf("mark failed: failed to update image: %w", err)
+	}
+
+	return nil
+}
+
+// BeginMessageAttempt counts a new processing attempt of the queue message and returns its attempts so far.
+func (s *Service) BeginMessageAttempt(ctx context.Context, messageID string) (model.MessageAttempt, error) {
+	a, err := s.repository.BeginMessageAttempt(ctx, messageID)
 	if err != nil {
-		return fmt.Errorf("delete image: failed to delete image from storage: %w", err)
+		return model.MessageAttempt{}, fmt.Errorf("begin message attempt: %w", err)
+	}
+
+	return a, nil
+}
+
+// EndMessageAttempt records the outcome of the current attempt of the queue message:
+// the error of a failed attempt is kept, while the attempts of a processed message are forgotten.
+func (s *Service) EndMessageAttempt(ctx context.Context, messageID string, procErr error) error {
+	if procErr != nil {
+		if err := s.repository.FailMessageAttempt(ctx, messageID, procErr.Error()); err != nil {
+			return fmt.Errorf("end message attempt: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := s.repository.DeleteMessageAttempts(ctx, messageID); err != nil {
+		return fmt.Errorf("end message attempt: %w", err)
 	}
 
 	return nil
 }
 
-// ProcessImage performs the specified image action (resize, watermark, etc.) and updates the database record.
-func (s *Service) ProcessImage(ctx context.Context, image model.Image) (uuid.UUID, error) {
+// GiveUp fails the processing job of the image for good after its message kept failing,
+// recording the reason as the error of the job, and forgets the attempts of the message.
+func (s *Service) GiveUp(ctx context.Context, id uuid.UUID, messageID, reason string) error {
+	if id != uuid.Nil {
+		if err := s.repository.FailAttempt(ctx, id, reason); err != nil && !errors.Is(err, imagerepo.ErrImageNotFound) {
+			return fmt.Errorf("give up: failed to record error: %w", err)
+		}
+
+		if err := s.MarkFailed(ctx, id); err != nil && !errors.Is(err, imagerepo.ErrImageNotFound) {
+			return fmt.Errorf("give up: %w", err)
+		}
+	}
+
+	if err := s.repository.DeleteMessageAttempts(ctx, messageID); err != nil {
+		return fmt.Errorf("give up: %w", err)
+	}
+
+	return nil
+}
+
+// notify delivers the webhook event to the callback URL of the image, if any.
+// Delivery runs in the background so that slow callbacks don't block the worker.
+func (s *Service) notify(ctx context.Context, image model.Image, event model.WebhookEvent) {
+	if image.CallbackURL == "" {
+		return
+	}
+
+	event.ImageID = image.ID
+	event.Action = image.Action.Name
+	event.OccurredAt = time.Now()
+
+	ctx = context.WithoutCancel(ctx)
+
+	go func() {
+		if err := s.notifier.Notify(ctx, image.CallbackURL, event); err != nil {
+			logging.ForImage(ctx, image.ID).Err(err).
+				Str("event", event.Event).
+				Msg("failed to deliver webhook")
+		}
+	}()
+}
+
+// publishProcessed announces the processed image to the events topic, if enabled.
+// The job is already done, so failures are only logged.
+func (s *Service) publishProcessed(ctx context.Context, image, derived model.Image, took time.Duration) {
+	if s.events == nil {
+		return
+	}
+
+	ev := model.ProcessedEvent{
+		Event:   model.EventImageProcessed,
+		ImageID: image.ID,
+		Action:  image.Action.Name,
+		Variants: []model.ProcessedVariant{{
+			ID:     derived.ID,
+			Path:   derived.Path,
+			Format: derived.Format,
+			Size:   derived.Size,
+		}},
+		DurationMs: took.Milliseconds(),
+		OccurredAt: time.Now(),
+	}
+
+	if err := s.events.PublishProcessed(ctx, ev); err != nil {
+		logging.ForImage(ctx, image.ID).Err(err).Msg("failed to publish processed event")
+	}
+}
+
+// purge purges the cached responses of the images from the CDN, if enabled.
+// Purging runs in the background and failures are only logged; cached responses expire on their own.
+func (s *Service) purge(ctx context.Context, ids ...uuid.UUID) {
+	if s.cdn == nil {
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+
+	go func() {
+		if err := s.cdn.Purge(ctx, ids); err != nil {
+			logging.ForImage(ctx, ids[0]).Err(err).Int("count", len(ids)).Msg("failed to purge images from cdn")
+		}
+	}()
+}
+
+// record appends an event of the image to the audit log, attributed to the actor of ctx.
+// Failures are only logged, so that auditing never fails the audited operation.
+func (s *Service) record(ctx context.Context, event string, id uuid.UUID, details string) {
+	if err := s.repository.SaveAuditEvent(ctx, audit.Event(ctx, event, id, details)); err != nil {
+		logging.ForImage(ctx, id).Err(err).Str("event", event).Msg("failed to record audit event")
+	}
+}
+
+// tagObject tags the stored file of the image with its ID and kind, so that objects can be matched
+// to their records. Tags only help reconciliation, so failures are logged without failing the caller.
+func (s *Service) tagObject(ctx context.Context, img model.Image) {
+	if err := s.fileStorage.Tag(ctx, img.Path, storage.ImageTags(img.ID, img.OriginalID)); err != nil {
+		logging.ForImage(ctx, img.ID).Err(err).Str("path", img.Path).Msg("failed to tag stored image")
+	}
+}
+
+// processImage runs a single processing attempt for the image and returns the derived image.
+func (s *Service) processImage(ctx context.Context, image model.Image) (model.Image, error) {
+	if s.ocr != nil {
+		s.extractText(ctx, image)
+	}
+
 	// Process the image (resize, watermark, etc.).
 	img, err := s.imgProcessor.Process(ctx, image)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("process image: failed to process task: %w", err)
+		return model.Image{}, fmt.Errorf("process image: failed to process task: %w", err)
+	}
+
+	derived := model.Image{
+		OriginalID: &image.ID,
+		Filename:   image.Filename,
+		Path:       img.Path,
+		Action:     image.Action,
+		Status:     img.Status,
+		Owner:      image.Owner,
+		Size:       img.Size,
+		Format:     img.Format,
+		Width:      img.Width,
+		Height:     img.Height,
+		ColorSpace: img.ColorSpace,
+		ExpiresAt:  image.ExpiresAt,
+		MessageID:  image.MessageID,
+	}
+
+	derivedID, err := s.repository.SaveImage(ctx, derived)
+	if err != nil {
+		return model.Image{}, fmt.Errorf("process image: failed to save derived image: %w", err)
+	}
+	derived.ID = derivedID
+	s.tagObject(ctx, derived)
+
+	// Update the original with its new status; its path keeps pointing to the original file.
+	err = s.repository.UpdateImage(ctx, image.ID, image.Path, img.Status)
+	if err != nil {
+		return model.Image{}, fmt.Errorf("update image: failed to update image: %w", err)
+	}
+
+	return derived, nil
+}
+
+// GetBundle returns the original image record followed by all images derived from it.
+func (s *Service) GetBundle(ctx context.Context, id uuid.UUID) ([]model.Image, error) {
+	img, err := s.repository.GetImage(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get bundle: failed to get image: %w", err)
+	}
+	img.ID = id
+
+	derived, err := s.repository.ListDerived(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get bundle: failed to list derived images: %w", err)
+	}
+
+	s.record(ctx, model.AuditDownload, id, "bundle")
+
+	return append([]model.Image{img}, derived...), nil
+}
+
+// OpenFile returns a reader for a stored image file.
+func (s *Service) OpenFile(ctx context.Context, path string) (io.ReadCloser, error) {
+	reader, err := s.fileStorage.Load(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("open file: failed to load file: %w", err)
+	}
+
+	return reader, nil
+}
+
+// Transform returns the image resized according to the options, encoded in the requested format.
+// Results are cached in storage under transforms/<id>/, so repeated requests skip decoding.
+func (s *Service) Transform(ctx context.Context, id uuid.UUID, opts processor.TransformOptions) (io.ReadCloser, error) {
+	cachePath := path.Join("transforms", id.String(), opts.Key())
+
+	cached, err := s.fileStorage.Exists(ctx, cachePath)
+	if err != nil {
+		return nil, fmt.Errorf("transform: failed to check cache: %w", err)
+	}
+
+	if !cached {
+		if err := s.renderTransform(ctx, id, opts); err != nil {
+			return nil, fmt.Errorf("transform: %w", err)
+		}
+	}
+
+	reader, err := s.fileStorage.Load(ctx, cachePath)
+	if err != nil {
+		return nil, fmt.Errorf("transform: failed to load cached result: %w", err)
+	}
+
+	return reader, nil
+}
+
+// renderTransform performs the transform on the image and stores the result in the cache.
+func (s *Service) renderTransform(ctx context.Context, id uuid.UUID, opts processor.TransformOptions) error {
+	img, err := s.repository.GetImage(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get image: %w", err)
+	}
+
+	srcReader, err := s.fileStorage.Load(ctx, img.Path)
+	if err != nil {
+		return fmt.Errorf("failed to load file: %w", err)
+	}
+	defer srcReader.Close()
+
+	src, err := s.imgProcessor.Decode(srcReader)
+	if err != nil {
+		return err
+	}
+
+	if err := s.imgProcessor.SaveTransform(ctx, path.Join("transforms", id.String(), opts.Key()), src, opts); err != nil {
+		return fmt.Errorf("failed to cache result: %w", err)
+	}
+
+	return nil
+}
+
+// GetStatus returns the detailed processing status of the image.
+func (s *Service) GetStatus(ctx context.Context, id uuid.UUID) (model.ProcessingStatus, error) {
+	st, err := s.repository.GetStatus(ctx, id)
+	if err != nil {
+		return model.ProcessingStatus{}, fmt.Errorf("get status: failed to get status: %w", err)
+	}
+
+	return st, nil
+}
+
+// WatchStatus streams the processing status of the image: the current status, then the status after
+// each change, until ctx is done. The channel is closed when the stream ends.
+// Returns ErrWatchUnavailable if status changes aren't notified by the database.
+func (s *Service) WatchStatus(ctx context.Context, id uuid.UUID) (<-chan model.ProcessingStatus, error) {
+	if s.watcher == nil {
+		return nil, ErrWatchUnavailable
+	}
+
+	// Subscribe before reading the current status, so that no change goes unnoticed in between.
+	changes, cancel := s.watcher.Subscribe(id)
+
+	st, err := s.repository.GetStatus(ctx, id)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("watch status: failed to get status: %w", err)
+	}
+
+	updates := make(chan model.ProcessingStatus, 1)
+	updates <- st
+
+	go func() {
+		defer close(updates)
+		defer cancel()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-changes:
+			}
+
+			st, err := s.repository.GetStatus(ctx, id)
+			if err != nil {
+				if ctx.Err() == nil {
+					logging.ForImage(ctx, id).Err(err).Msg("failed to get watched image status")
+				}
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case updates <- st:
+			}
+		}
+	}()
+
+	return updates, nil
+}
+
+// ListDerived returns all images derived from the original with the given ID.
+func (s *Service) ListDerived(ctx context.Context, id uuid.UUID) ([]model.Image, error) {
+	// Make sure the original exists so that unknown IDs are reported as not found.
+	if _, err := s.repository.GetImage(ctx, id); err != nil {
+		return nil, fmt.Errorf("list derived: failed to get image: %w", err)
+	}
+
+	derived, err := s.repository.ListDerived(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("list derived: failed to list derived images: %w", err)
+	}
+
+	return derived, nil
+}
+
+// UpdateMetadata validates and applies user-supplied title, description and tags to an image.
+// Tags are trimmed, lower-cased and deduplicated. Returns the updated image.
+func (s *Service) UpdateMetadata(ctx context.Context, id uuid.UUID, upd model.MetadataUpdate) (model.Image, error) {
+	if upd.Title != nil && utf8.RuneCountInString(*upd.Title) > maxTitleLen {
+		return model.Image{}, fmt.Errorf("update metadata: %w: title is longer than %d characters", ErrInvalidMetadata, maxTitleLen)
+	}
+	if upd.Description != nil && utf8.RuneCountInString(*upd.Description) > maxDescriptionLen {
+		return model.Image{}, fmt.Errorf(
+			"update metadata: %w: description is longer than %d characters", ErrInvalidMetadata, maxDescriptionLen,
+		)
+	}
+
+	if upd.Tags != nil {
+		tags, err := normalizeTags(*upd.Tags)
+		if err != nil {
+			return model.Image{}, fmt.Errorf("update metadata: %w", err)
+		}
+		upd.Tags = &tags
+	}
+
+	if err := s.repository.UpdateMetadata(ctx, id, upd); err != nil {
+		return model.Image{}, fmt.Errorf("update metadata: %w", err)
+	}
+
+	img, err := s.repository.GetImage(ctx, id)
+	if err != nil {
+		return model.Image{}, fmt.Errorf("update metadata: failed to get image: %w", err)
+	}
+
+	return img, nil
+}
+
+// normalizeTags trims, lower-cases and deduplicates tags, keeping their order.
+func normalizeTags(raw []string) ([]string, error) {
+	tags := make([]string, 0, len(raw))
+	seen := make(map[string]bool, len(raw))
+
+	for _, t := range raw {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || seen[t] {
+			continue
+		}
+		if utf8.RuneCountInString(t) > maxTagLen {
+			return nil, fmt.Errorf("%w: tag %q is longer than %d characters", ErrInvalidMetadata, t, maxTagLen)
+		}
+
+		seen[t] = true
+		tags = append(tags, t)
+	}
+
+	if len(tags) > maxTags {
+		return nil, fmt.Errorf("%w: more than %d tags", ErrInvalidMetadata, maxTags)
+	}
+
+	return tags, nil
+}
+
+// ListImages returns the images matching the filter. They may be served by a replica.
+func (s *Service) ListImages(ctx context.Context, f model.ImageFilter) ([]model.Image, error) {
+	images, err := s.repository.ListImages(imagerepo.ReplicaReads(ctx), f)
+	if err != nil {
+		return nil, fmt.Errorf("list images: failed to list images: %w", err)
+	}
+
+	return images, nil
+}
+
+// SearchImages returns a page of images matching the filter along with
+// the total number of matches across all pages. They may be served by a replica.
+func (s *Service) SearchImages(ctx context.Context, f model.ImageFilter) ([]model.Image, int, error) {
+	ctx = imagerepo.ReplicaReads(ctx)
+
+	images, err := s.repository.ListImages(ctx, f)
+	if err != nil {
+		return nil, 0, fmt.Errorf("search images: failed to list images: %w", err)
+	}
+
+	total, err := s.repository.CountImages(ctx, f)
+	if err != nil {
+		return nil, 0, fmt.Errorf("search images: failed to count images: %w", err)
+	}
+
+	return images, total, nil
+}
+
+// ListChanges returns the image change feed entries recorded after the given cursor. Mirrors follow
+// the cursor, so a change not replicated yet is only seen on a later call.
+func (s *Service) ListChanges(ctx context.Context, since int64, limit int) ([]model.Change, error) {
+	changes, err := s.repository.ListChanges(imagerepo.ReplicaReads(ctx), since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list changes: failed to list changes: %w", err)
+	}
+
+	return changes, nil
+}
+
+// extractText runs OCR on the image file and stores the recognized text.
+// OCR is an optional enrichment step, so failures are logged and do not fail processing.
+func (s *Service) extractText(ctx context.Context, img model.Image) {
+	srcReader, err := s.fileStorage.Load(ctx, img.Path)
+	if err != nil {
+		logging.ForImage(ctx, img.ID).Err(err).Msg("ocr: failed to load image")
+		return
+	}
+	defer srcReader.Close()
+
+	text, err := s.ocr.Extract(ctx, srcReader)
+	if err != nil {
+		logging.ForImage(ctx, img.ID).Err(err).Msg("ocr: failed to extract text")
+		return
+	}
+
+	if err := s.repository.UpdateOCRText(ctx, img.ID, text); err != nil {
+		logging.ForImage(ctx, img.ID).Err(err).Msg("ocr: failed to save text")
+	}
+}
+
+// DetectWatermark decodes the given file and extracts the owner ID embedded
+// by the forensic_watermark action.
+func (s *Service) DetectWatermark(ctx context.Context, file io.Reader) (string, error) {
+	src, err := s.imgProcessor.Decode(file)
+	if err != nil {
+		return "", fmt.Errorf("detect watermark: %w", err)
 	}
 
-	// Update database with new path and status.
-	err = s.repository.UpdateImage(ctx, img.ID, img.Path, img.Status)
+	owner, err := s.imgProcessor.DetectWatermark(src)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("update image: failed to update image: %w", err)
+		return "", fmt.Errorf("detect watermark: %w", err)
 	}
 
-	return img.ID, nil
+	return owner, nil
 }
@@ -0,0 +1,300 @@
+package image
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/mock/gomock"
+
+	queuemocks "github.com/aliskhannn/image-processor/internal/infra/queue/mocks"
+	"github.com/aliskhannn/image-processor/internal/model"
+	"github.com/aliskhannn/image-processor/internal/processor"
+	"github.com/aliskhannn/image-processor/internal/reload"
+	imagerepo "github.com/aliskhannn/image-processor/internal/repository/image"
+	repomocks "github.com/aliskhannn/image-processor/internal/repository/image/mocks"
+	storagemocks "github.com/aliskhannn/image-processor/internal/storage/mocks"
+)
+
+// testService is a service over mocks of the repository, the storage and the queue.
+type testService struct {
+	*Service
+	repo     *repomocks.MockStore
+	storage  *storagemocks.MockStorage
+	producer *queuemocks.MockPublisher
+}
+
+// newTestService returns a service over mocks, with the given quotas and without the optional steps.
+func newTestService(t *testing.T, quota model.QuotaLimits) testService {
+	t.Helper()
+
+	ctrl := gomock.NewController(t)
+	ts := testService{
+		repo:     repomocks.NewMockStore(ctrl),
+		storage:  storagemocks.NewMockStorage(ctrl),
+		producer: queuemocks.NewMockPublisher(ctrl),
+	}
+
+	imgP := processor.New(ts.storage, ts.repo, processor.Limits{}, reload.NewValue(processor.Watermark{}), nil)
+	ts.Service = NewService(
+		ts.storage, ts.producer, imgP, ts.repo, nil, nil, nil, nil, nil, nil, nil, reload.NewValue(quota), false,
+	)
+
+	return ts
+}
+
+// testPNG returns a small PNG image and the hex SHA-256 hash of its content.
+func testPNG(t *testing.T) ([]byte, string) {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, 4, 3))
+	img.Set(1, 1, color.RGBA{R: 255, A: 255})
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	return buf.Bytes(), hex.EncodeToString(sum[:])
+}
+
+// TestSaveImage checks that a new upload is stored under its content hash, recorded and enqueued.
+func TestSaveImage(t *testing.T) {
+	ctx := context.Background()
+	ts := newTestService(t, model.QuotaLimits{})
+	content, hash := testPNG(t)
+	id := uuid.New()
+	dst := "original/sha256/" + hash
+
+	ts.repo.EXPECT().FindOriginalByHash(gomock.Any(), "alice", hash).Return(model.Image{}, imagerepo.ErrImageNotFound)
+	ts.repo.EXPECT().GetUsage(gomock.Any(), "alice").Return(model.QuotaUsage{}, nil)
+	ts.storage.EXPECT().Save(gomock.Any(), dst, gomock.Any(), "image/png").
+		DoAndReturn(func(_ context.Context, _ string, src io.Reader, _ string) error {
+			stored, err := io.ReadAll(src)
+			if err != nil {
+				t.Fatalf("read stored content: %v", err)
+			}
+			if !bytes.Equal(stored, content) {
+				t.Error("stored content differs from the upload")
+			}
+			return nil
+		})
+	ts.repo.EXPECT().SaveImage(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, img model.Image) (uuid.UUID, error) {
+			if img.Filename != "photo.png" || img.Path != dst || img.Owner != "alice" || img.ContentHash != hash {
+				t.Errorf("saved image = %+v, want photo.png of alice at %s", img, dst)
+			}
+			if img.Width != 4 || img.Height != 3 || img.Size != int64(len(content)) {
+				t.Errorf("saved image is %dx%d of %d bytes, want 4x3 of %d", img.Width, img.Height, img.Size, len(content))
+			}
+			if img.Status != model.StatusPending || img.Priority != model.PriorityNormal {
+				t.Errorf("saved image status %q priority %q, want pending normal", img.Status, img.Priority)
+			}
+			return id, nil
+		})
+	ts.producer.EXPECT().Produce(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, img model.Image) error {
+			if img.ID != id || img.Action.Name != "resize" {
+				t.Errorf("produced task of image %v action %q, want %v resize", img.ID, img.Action.Name, id)
+			}
+			return nil
+		})
+	ts.storage.EXPECT().Tag(gomock.Any(), dst, gomock.Any()).Return(nil)
+	ts.repo.EXPECT().SaveAuditEvent(gomock.Any(), gomock.Any()).Return(nil)
+
+	saved, err := ts.SaveImage(
+		ctx, "original", "photo.png", bytes.NewReader(content),
+		model.Action{Name: "resize"}, model.UploadOptions{Owner: "alice"},
+	)
+	if err != nil {
+		t.Fatalf("SaveImage() error = %v", err)
+	}
+	if saved != (model.SavedUpload{ID: id, Path: dst}) {
+		t.Errorf("SaveImage() = %+v, want %v at %s", saved, id, dst)
+	}
+}
+
+// TestSaveImageDuplicate checks that an upload identical to an original of the owner stores and
+// enqueues nothing unless reprocessing is requested.
+func TestSaveImageDuplicate(t *testing.T) {
+	ctx := context.Background()
+	content, hash := testPNG(t)
+	existing := model.Image{ID: uuid.New(), Path: "original/sha256/" + hash, Owner: "alice"}
+
+	t.Run("kept", func(t *testing.T) {
+		ts := newTestService(t, model.QuotaLimits{})
+		ts.repo.EXPECT().FindOriginalByHash(gomock.Any(), "alice", hash).Return(existing, nil)
+		ts.repo.EXPECT().SaveAuditEvent(gomock.Any(), gomock.Any()).Return(nil)
+
+		saved, err := ts.SaveImage(
+			ctx, "original", "copy.png", bytes.NewReader(content),
+			model.Action{Name: "resize"}, model.UploadOptions{Owner: "alice"},
+		)
+		if err != nil {
+			t.Fatalf("SaveImage() error = %v", err)
+		}
+		if want := (model.SavedUpload{ID: existing.ID, Path: existing.Path, Duplicate: true}); saved != want {
+			t.Errorf("SaveImage() = %+v, want %+v", saved, want)
+		}
+	})
+
+	t.Run("reprocessed", func(t *testing.T) {
+		ts := newTestService(t, model.QuotaLimits{})
+		ts.repo.EXPECT().FindOriginalByHash(gomock.Any(), "alice", hash).Return(existing, nil)
+		ts.repo.EXPECT().GetUsage(gomock.Any(), "alice").Return(model.QuotaUsage{}, nil)
+		ts.repo.EXPECT().RequeueImage(gomock.Any(), existing.ID).Return(nil)
+		ts.producer.EXPECT().Produce(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, img model.Image) error {
+				if img.ID != existing.ID || img.Action.Name != "grayscale" {
+					t.Errorf("produced task of image %v action %q, want %v grayscale", img.ID, img.Action.Name, existing.ID)
+				}
+				return nil
+			})
+		ts.repo.EXPECT().SaveAuditEvent(gomock.Any(), gomock.Any()).Return(nil)
+
+		saved, err := ts.SaveImage(
+			ctx, "original", "copy.png", bytes.NewReader(content),
+			model.Action{Name: "grayscale"}, model.UploadOptions{Owner: "alice", Reprocess: true},
+		)
+		if err != nil {
+			t.Fatalf("SaveImage() error = %v", err)
+		}
+		if !saved.Duplicate || saved.ID != existing.ID {
+			t.Errorf("SaveImage() = %+v, want duplicate of %v", saved, existing.ID)
+		}
+	})
+}
+
+// TestSaveImageRejected checks that uploads over the quota or not images are rejected before
+// anything is stored.
+func TestSaveImageRejected(t *testing.T) {
+	ctx := context.Background()
+	content, hash := testPNG(t)
+
+	t.Run("storage quota", func(t *testing.T) {
+		ts := newTestService(t, model.QuotaLimits{MaxBytes: 100})
+		ts.repo.EXPECT().FindOriginalByHash(gomock.Any(), "alice", hash).Return(model.Image{}, imagerepo.ErrImageNotFound)
+		ts.repo.EXPECT().GetUsage(gomock.Any(), "alice").Return(model.QuotaUsage{BytesStored: 100}, nil)
+
+		_, err := ts.SaveImage(
+			ctx, "original", "photo.png", bytes.NewReader(content),
+			model.Action{Name: "resize"}, model.UploadOptions{Owner: "alice"},
+		)
+		if !errors.Is(err, ErrStorageQuotaExceeded) {
+			t.Fatalf("SaveImage() error = %v, want ErrStorageQuotaExceeded", err)
+		}
+	})
+
+	t.Run("not an image", func(t *testing.T) {
+		ts := newTestService(t, model.QuotaLimits{})
+
+		_, err := ts.SaveImage(
+			ctx, "original", "notes.png", bytes.NewReader([]byte("plain text, not a picture")),
+			model.Action{Name: "resize"}, model.UploadOptions{Owner: "alice"},
+		)
+		if !errors.Is(err, processor.ErrUnsupportedFormat) {
+			t.Fatalf("SaveImage() error = %v, want ErrUnsupportedFormat", err)
+		}
+	})
+}
+
+// TestDeleteImage checks that deleting an image only marks it deleted, keeping its files.
+func TestDeleteImage(t *testing.T) {
+	ctx := context.Background()
+	ts := newTestService(t, model.QuotaLimits{})
+	id := uuid.New()
+
+	ts.repo.EXPECT().ListDerived(gomock.Any(), id).Return([]model.Image{{ID: uuid.New(), OriginalID: &id}}, nil)
+	ts.repo.EXPECT().SoftDeleteImage(gomock.Any(), id).Return(nil)
+	ts.repo.EXPECT().SaveAuditEvent(gomock.Any(), gomock.Any()).Return(nil)
+
+	if err := ts.DeleteImage(ctx, id); err != nil {
+		t.Fatalf("DeleteImage() error = %v", err)
+	}
+
+	missing := uuid.New()
+	ts.repo.EXPECT().ListDerived(gomock.Any(), missing).Return(nil, nil)
+	ts.repo.EXPECT().SoftDeleteImage(gomock.Any(), missing).Return(imagerepo.ErrImageNotFound)
+
+	if err := ts.DeleteImage(ctx, missing); !errors.Is(err, imagerepo.ErrImageNotFound) {
+		t.Fatalf("DeleteImage() of missing image error = %v, want ErrImageNotFound", err)
+	}
+}
+
+// TestSweepDeleted checks that the sweeper purges the records and files of deleted images, keeping
+// the files still referenced by other records.
+func TestSweepDeleted(t *testing.T) {
+	ctx := context.Background()
+	ts := newTestService(t, model.QuotaLimits{})
+	before := time.Now().Add(-time.Hour)
+
+	original := model.Image{ID: uuid.New(), Path: "original/sha256/shared"}
+	derived := model.Image{ID: uuid.New(), Path: "processed/resize.jpg", OriginalID: &original.ID}
+
+	ts.repo.EXPECT().ListDeleted(gomock.Any(), before, 10).Return([]model.Image{derived, original}, nil)
+	for _, img := range []model.Image{derived, original} {
+		ts.repo.EXPECT().ListDerived(gomock.Any(), img.ID).Return(nil, nil)
+		ts.repo.EXPECT().DeleteImage(gomock.Any(), img.ID).Return(nil)
+		ts.storage.EXPECT().DeletePrefix(gomock.Any(), "transforms/"+img.ID.String()+"/").Return(nil)
+	}
+	ts.repo.EXPECT().PathInUse(gomock.Any(), derived.Path).Return(false, nil)
+	ts.storage.EXPECT().Delete(gomock.Any(), derived.Path).Return(nil)
+	ts.repo.EXPECT().PathInUse(gomock.Any(), original.Path).Return(true, nil)
+
+	n, err := ts.SweepDeleted(ctx, before, 10)
+	if err != nil {
+		t.Fatalf("SweepDeleted() error = %v", err)
+	}
+	if n != 2 {
+		t.Errorf("SweepDeleted() = %d, want 2", n)
+	}
+}
+
+// TestTransform checks that a transform not cached yet is rendered into the cache and served from it.
+func TestTransform(t *testing.T) {
+	ctx := context.Background()
+	ts := newTestService(t, model.QuotaLimits{})
+	content, _ := testPNG(t)
+	img := model.Image{ID: uuid.New(), Path: "original/sha256/photo"}
+	opts := processor.TransformOptions{Width: 2, Height: 2, Mode: "resize", Format: "png"}
+	cachePath := "transforms/" + img.ID.String() + "/" + opts.Key()
+
+	var cached []byte
+	ts.storage.EXPECT().Exists(gomock.Any(), cachePath).Return(false, nil)
+	ts.repo.EXPECT().GetImage(gomock.Any(), img.ID).Return(img, nil)
+	ts.storage.EXPECT().Load(gomock.Any(), img.Path).Return(io.NopCloser(bytes.NewReader(content)), nil)
+	ts.storage.EXPECT().Save(gomock.Any(), cachePath, gomock.Any(), "image/png").
+		DoAndReturn(func(_ context.Context, _ string, src io.Reader, _ string) error {
+			var err error
+			cached, err = io.ReadAll(src)
+			return err
+		})
+	ts.storage.EXPECT().Load(gomock.Any(), cachePath).
+		DoAndReturn(func(context.Context, string) (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(cached)), nil
+		})
+
+	rc, err := ts.Transform(ctx, img.ID, opts)
+	if err != nil {
+		t.Fatalf("Transform() error = %v", err)
+	}
+	defer rc.Close()
+
+	out, err := png.Decode(rc)
+	if err != nil {
+		t.Fatalf("decode transformed image: %v", err)
+	}
+	if b := out.Bounds(); b.Dx() != 2 || b.Dy() != 2 {
+		t.Errorf("transformed image is %dx%d, want 2x2", b.Dx(), b.Dy())
+	}
+}
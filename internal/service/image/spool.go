@@ -0,0 +1,47 @@
+package image
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+// spooledFile is an upload copied to a temporary file, along with its size and SHA-256 hash.
+type spooledFile struct {
+	file *os.File
+	size int64
+	hash string
+}
+
+// spool copies src to a temporary file while hashing it, so that large uploads can be scanned
+// and stored from disk instead of memory. The file must be removed with remove.
+func spool(src io.Reader) (*spooledFile, error) {
+	f, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spool file: %w", err)
+	}
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, h), src)
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	return &spooledFile{file: f, size: size, hash: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// reader returns a new reader of the whole spooled content. Its size is known,
+// so storage backends can upload it without buffering.
+func (s *spooledFile) reader() *io.SectionReader {
+	return io.NewSectionReader(s.file, 0, s.size)
+}
+
+// remove closes and deletes the temporary file.
+func (s *spooledFile) remove() {
+	_ = s.file.Close()
+	_ = os.Remove(s.file.Name())
+}
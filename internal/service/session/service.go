@@ -0,0 +1,398 @@
+package session
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"path"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/image-processor/internal/model"
+	"github.com/aliskhannn/image-processor/internal/processor"
+	"github.com/aliskhannn/image-processor/internal/storage"
+)
+
+// ErrSessionNotFound is returned when a session does not exist or has expired.
+var ErrSessionNotFound = errors.New("session not found")
+
+// imgProcessor defines the interface for decoding images and applying a single action to them.
+type imgProcessor interface {
+	Apply(ctx context.Context, src image.Image, action model.Action) (image.Image, error)
+	Decode(r io.Reader) (image.Image, error)
+}
+
+// actionPolicy defines the interface for checking the actions applied to sessions.
+type actionPolicy interface {
+	Check(action model.Action) error
+}
+
+// repository defines the interface for reading originals and recording committed derivatives.
+type repository interface {
+	SaveImage(ctx context.Context, img model.Image) (uuid.UUID, error)
+	GetImage(ctx context.Context, id uuid.UUID) (model.Image, error)
+}
+
+// state is the in-memory state of a single editing session.
+type state struct {
+	session model.Session
+	image   model.Image // original image record the session was opened on
+	preview image.Image // downscaled working copy with all actions applied
+}
+
+// workspace holds a session. Operations on it run one at a time under mu, on a copy of its
+// state swapped in, under the service lock, only once they succeed.
+type workspace struct {
+	mu sync.Mutex
+	state
+}
+
+// Service manages interactive editing sessions.
+// Previews are rendered on a downscaled copy of the original and kept in a temporary
+// workspace in storage; the full-resolution pipeline only runs on commit.
+type Service struct {
+	fileStorage  storage.Storage
+	imgProcessor imgProcessor
+	repository   repository
+	actions      actionPolicy
+	ttl          time.Duration
+	previewSize  int
+
+	mu         sync.Mutex
+	workspaces map[uuid.UUID]*workspace
+}
+
+// NewService creates a new session Service applying the actions allowed by the policy.
+func NewService(
+	fs storage.Storage, imgP imgProcessor, r repository, actions actionPolicy, ttl time.Duration, previewSize int,
+) *Service {
+	return &Service{
+		fileStorage:  fs,
+		imgProcessor: imgP,
+		repository:   r,
+		actions:      actions,
+		ttl:          ttl,
+		previewSize:  previewSize,
+		workspaces:   make(map[uuid.UUID]*workspace),
+	}
+}
+
+// Open starts a new editing session on the image with the given ID
+// and renders the initial preview.
+func (s *Service) Open(ctx context.Context, imageID uuid.UUID) (model.Session, error) {
+	img, err := s.repository.GetImage(ctx, imageID)
+	if err != nil {
+		return model.Session{}, fmt.Errorf("open session: failed to get image: %w", err)
+	}
+
+	src, err := s.load(ctx, img.Path)
+	if err != nil {
+		return model.Session{}, fmt.Errorf("open session: %w", err)
+	}
+
+	now := time.Now()
+	ws := &workspace{state: state{
+		session: model.Session{
+			ID:        uuid.New(),
+			ImageID:   imageID,
+			Actions:   []model.Action{},
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		image:   img,
+		preview: imaging.Fit(src, s.previewSize, s.previewSize, imaging.Lanczos),
+	}}
+
+	ws.session.PreviewPath, err = s.savePreview(ctx, ws.session.ID, ws.preview)
+	if err != nil {
+		return model.Session{}, fmt.Errorf("open session: %w", err)
+	}
+
+	s.mu.Lock()
+	s.workspaces[ws.session.ID] = ws
+	s.mu.Unlock()
+
+	return ws.session, nil
+}
+
+// Get returns the current state of the session.
+func (s *Service) Get(id uuid.UUID) (model.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ws, ok := s.workspaces[id]
+	if !ok {
+		return model.Session{}, ErrSessionNotFound
+	}
+
+	return ws.session, nil
+}
+
+// Apply appends an action to the session pipeline and refreshes the preview.
+// Actions refused by the policy, as uploads are, return its error, e.g. processor.ErrActionNotAllowed.
+// The session is left unchanged if the action fails.
+func (s *Service) Apply(ctx context.Context, id uuid.UUID, action model.Action) (model.Session, error) {
+	if err := s.actions.Check(action); err != nil {
+		return model.Session{}, fmt.Errorf("apply action: %w", err)
+	}
+
+	ws, st, err := s.acquire(id)
+	if err != nil {
+		return model.Session{}, err
+	}
+	defer ws.mu.Unlock()
+
+	st.preview, err = s.imgProcessor.Apply(ctx, st.preview, action)
+	if err != nil {
+		return model.Session{}, fmt.Errorf("apply action: %w", err)
+	}
+
+	st.session.Actions = append(slices.Clone(st.session.Actions), action)
+	st.session.UpdatedAt = time.Now()
+
+	st.session.PreviewPath, err = s.savePreview(ctx, st.session.ID, st.preview)
+	if err != nil {
+		return model.Session{}, fmt.Errorf("apply action: %w", err)
+	}
+
+	s.mu.Lock()
+	ws.state = st
+	s.mu.Unlock()
+
+	return st.session, nil
+}
+
+// Preview returns a reader for the latest preview of the session.
+func (s *Service) Preview(ctx context.Context, id uuid.UUID) (io.ReadCloser, error) {
+	sess, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := s.fileStorage.Load(ctx, sess.PreviewPath)
+	if err != nil {
+		return nil, fmt.Errorf("preview: failed to load preview: %w", err)
+	}
+
+	return reader, nil
+}
+
+// Commit runs the session pipeline, whose actions were checked when applied, on the full-resolution
+// original, saves the result as a single derivative image and closes the session. The session
+// stays open if the commit fails, so that it can be retried.
+// Returns the ID of the derived image.
+func (s *Service) Commit(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	ws, st, err := s.acquire(id)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer ws.mu.Unlock()
+
+	derivedID, err := s.commit(ctx, st)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	s.release(ws)
+	s.cleanup(ctx, st)
+
+	return derivedID, nil
+}
+
+// commit renders and records the derivative of the session with the given state.
+func (s *Service) commit(ctx context.Context, st state) (uuid.UUID, error) {
+	src, err := s.load(ctx, st.image.Path)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("commit session: %w", err)
+	}
+
+	for _, action := range st.session.Actions {
+		src, err = s.imgProcessor.Apply(ctx, src, action)
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("commit session: failed to apply %s: %w", action.Name, err)
+		}
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := imaging.Encode(buf, src, imaging.JPEG); err != nil {
+		return uuid.Nil, fmt.Errorf("commit session: failed to encode image: %w", err)
+	}
+
+	pipeline, err := json.Marshal(st.session.Actions)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("commit session: failed to marshal pipeline: %w", err)
+	}
+	action := model.Action{
+		Name:   "pipeline",
+		Params: map[string]string{"actions": string(pipeline)},
+	}
+
+	// Named after the original and the pipeline, so that commits of other images or pipelines
+	// never overwrite each other, whatever the filenames.
+	size := int64(buf.Len())
+	dst := path.Join("edited", processor.ResultName(st.session.ImageID, action, imaging.JPEG))
+	if err := s.fileStorage.Save(ctx, dst, buf, "image/jpeg"); err != nil {
+		return uuid.Nil, fmt.Errorf("commit session: failed to save image: %w", err)
+	}
+
+	derivedID, err := s.repository.SaveImage(ctx, model.Image{
+		OriginalID: &st.session.ImageID,
+		Filename:   st.image.Filename,
+		Path:       dst,
+		Action:     action,
+		Status:     model.StatusProcessed,
+		Owner:      st.image.Owner,
+		Size:       size,
+		Format:     "jpeg",
+		Width:      src.Bounds().Dx(),
+		Height:     src.Bounds().Dy(),
+		ColorSpace: processor.ColorSpace(src.ColorModel()),
+		ExpiresAt:  st.image.ExpiresAt,
+	})
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("commit session: failed to save image to db: %w", err)
+	}
+
+	// Tags only help matching objects to records, so the commit doesn't fail on them.
+	if err := s.fileStorage.Tag(ctx, dst, storage.ImageTags(derivedID, &st.session.ImageID)); err != nil {
+		zlog.Logger.Err(err).Str("image_id", derivedID.String()).Msg("failed to tag stored image")
+	}
+
+	return derivedID, nil
+}
+
+// Discard closes the session without producing a derivative.
+func (s *Service) Discard(ctx context.Context, id uuid.UUID) error {
+	ws, st, err := s.acquire(id)
+	if err != nil {
+		return err
+	}
+	defer ws.mu.Unlock()
+
+	s.release(ws)
+	s.cleanup(ctx, st)
+
+	return nil
+}
+
+// Run periodically discards sessions that have been idle longer than the configured TTL.
+// It blocks until the context is canceled.
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.expire(ctx)
+		}
+	}
+}
+
+// expire removes all sessions whose last update is older than the TTL.
+// Sessions with an operation in progress are left for the next pass.
+func (s *Service) expire(ctx context.Context) {
+	deadline := time.Now().Add(-s.ttl)
+
+	var expired []state
+
+	s.mu.Lock()
+	for id, ws := range s.workspaces {
+		if ws.session.UpdatedAt.Before(deadline) && ws.mu.TryLock() {
+			expired = append(expired, ws.state)
+			delete(s.workspaces, id)
+			ws.mu.Unlock()
+		}
+	}
+	s.mu.Unlock()
+
+	for _, st := range expired {
+		zlog.Logger.Info().Str("session_id", st.session.ID.String()).Msg("session expired")
+		s.cleanup(ctx, st)
+	}
+}
+
+// acquire locks the workspace of the session for an operation and returns it along with a copy
+// of its state. The caller must unlock ws.mu once done.
+func (s *Service) acquire(id uuid.UUID) (*workspace, state, error) {
+	s.mu.Lock()
+	ws, ok := s.workspaces[id]
+	s.mu.Unlock()
+	if !ok {
+		return nil, state{}, ErrSessionNotFound
+	}
+
+	ws.mu.Lock()
+
+	// The session may have been closed while waiting for the previous operation.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.workspaces[id] != ws {
+		ws.mu.Unlock()
+		return nil, state{}, ErrSessionNotFound
+	}
+
+	return ws, ws.state, nil
+}
+
+// release removes the workspace from the registry.
+func (s *Service) release(ws *workspace) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.workspaces, ws.session.ID)
+}
+
+// cleanup removes the temporary workspace objects of a session from storage.
+func (s *Service) cleanup(ctx context.Context, ws state) {
+	if ws.session.PreviewPath == "" {
+		return
+	}
+
+	if err := s.fileStorage.Delete(ctx, ws.session.PreviewPath); err != nil {
+		zlog.Logger.Err(err).Str("session_id", ws.session.ID.String()).Msg("failed to delete session preview")
+	}
+}
+
+// load loads and decodes an image from storage.
+func (s *Service) load(ctx context.Context, path string) (image.Image, error) {
+	reader, err := s.fileStorage.Load(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load image: %w", err)
+	}
+	defer reader.Close()
+
+	src, err := s.imgProcessor.Decode(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return src, nil
+}
+
+// savePreview encodes the preview, stores it in the workspace of the session and returns its path.
+func (s *Service) savePreview(ctx context.Context, id uuid.UUID, preview image.Image) (string, error) {
+	buf := bytes.NewBuffer(nil)
+	if err := imaging.Encode(buf, preview, imaging.JPEG); err != nil {
+		return "", fmt.Errorf("failed to encode preview: %w", err)
+	}
+
+	dst := path.Join("sessions", id.String()+".jpg")
+	if err := s.fileStorage.Save(ctx, dst, buf, "image/jpeg"); err != nil {
+		return "", fmt.Errorf("failed to save preview: %w", err)
+	}
+
+	return dst, nil
+}
@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/wb-go/wbf/zlog"
+)
+
+// ErrUnavailable is returned without calling the backend while the circuit breaker is open.
+var ErrUnavailable = errors.New("storage unavailable")
+
+// Breaker wraps a Storage with a circuit breaker: after threshold consecutive failed calls it
+// opens for the cooldown, failing calls fast with ErrUnavailable instead of letting them hang on
+// an unreachable backend. Once the cooldown has passed calls go through again, and the first one
+// failing reopens it. Periodic probes run by Probe close it as soon as the backend recovers.
+type Breaker struct {
+	next      Storage
+	threshold int // consecutive failures opening the breaker; zero never opens it
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int       // consecutive failed calls
+	openUntil time.Time // calls fail fast until then
+	probed    bool      // whether a probe completed yet
+	probeErr  error     // error of the last probe
+}
+
+// NewBreaker creates a new Breaker around the storage.
+// A zero threshold disables the breaker; calls then always go through.
+func NewBreaker(s Storage, threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{next: s, threshold: threshold, cooldown: cooldown}
+}
+
+// Save stores the file unless the breaker is open.
+func (b *Breaker) Save(ctx context.Context, path string, src io.Reader, contentType string) error {
+	if err := b.allow(); err != nil {
+		return err
+	}
+
+	err := b.next.Save(ctx, path, src, contentType)
+	b.record(err)
+
+	return err
+}
+
+// Load opens the file unless the breaker is open.
+func (b *Breaker) Load(ctx context.Context, path string) (io.ReadCloser, error) {
+	if err := b.allow(); err != nil {
+		return nil, err
+	}
+
+	r, err := b.next.Load(ctx, path)
+	b.record(err)
+
+	return r, err
+}
+
+// Exists checks the file unless the breaker is open.
+func (b *Breaker) Exists(ctx context.Context, path string) (bool, error) {
+	if err := b.allow(); err != nil {
+		return false, err
+	}
+
+	ok, err := b.next.Exists(ctx, path)
+	b.record(err)
+
+	return ok, err
+}
+
+// Delete removes the file unless the breaker is open.
+func (b *Breaker) Delete(ctx context.Context, path string) error {
+	if err := b.allow(); err != nil {
+		return err
+	}
+
+	err := b.next.Delete(ctx, path)
+	b.record(err)
+
+	return err
+}
+
+// DeletePrefix removes the files under the prefix unless the breaker is open.
+func (b *Breaker) DeletePrefix(ctx context.Context, prefix string) error {
+	if err := b.allow(); err != nil {
+		return err
+	}
+
+	err := b.next.DeletePrefix(ctx, prefix)
+	b.record(err)
+
+	return err
+}
+
+// Tag tags the file unless the breaker is open.
+func (b *Breaker) Tag(ctx context.Context, path string, tags map[string]string) error {
+	if err := b.allow(); err != nil {
+		return err
+	}
+
+	err := b.next.Tag(ctx, path, tags)
+	b.record(err)
+
+	return err
+}
+
+// Ping checks the backend, bypassing the breaker, and records the outcome.
+func (b *Breaker) Ping(ctx context.Context) error {
+	err := b.next.Ping(ctx)
+	b.record(err)
+
+	return err
+}
+
+// Ready reports the health of the storage for the readiness probe: from the last probe and
+// the breaker state, or by pinging the backend if no probe completed yet.
+func (b *Breaker) Ready(ctx context.Context) error {
+	b.mu.Lock()
+	probed, probeErr, open := b.probed, b.probeErr, time.Now().Before(b.openUntil)
+	b.mu.Unlock()
+
+	switch {
+	case !probed:
+		return b.Ping(ctx)
+	case probeErr != nil:
+		return probeErr
+	case open:
+		return ErrUnavailable
+	default:
+		return nil
+	}
+}
+
+// Probe pings the backend every interval until the context is canceled, recording the
+// outcome for Ready and the breaker. A zero interval disables probing.
+func (b *Breaker) Probe(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pingCtx, cancel := context.WithTimeout(ctx, interval)
+			err := b.Ping(pingCtx)
+			cancel()
+
+			if err != nil && ctx.Err() == nil {
+				zlog.Logger.Warn().Err(err).Msg("storage probe failed")
+				err = fmt.Errorf("%w: %v", ErrUnavailable, err)
+			}
+
+			b.mu.Lock()
+			b.probed = true
+			b.probeErr = err
+			b.mu.Unlock()
+		}
+	}
+}
+
+// allow returns ErrUnavailable while the breaker is open.
+func (b *Breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if time.Now().Before(b.openUntil) {
+		return ErrUnavailable
+	}
+
+	return nil
+}
+
+// record counts the outcome of a call, opening the breaker after threshold consecutive failures.
+// Calls canceled by their caller say nothing about the backend and aren't counted.
+func (b *Breaker) record(err error) {
+	if b.threshold <= 0 || errors.Is(err, context.Canceled) {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		b.failures = 0
+		b.openUntil = time.Time{}
+		return
+	}
+
+	b.failures++
+	if b.failures >= b.threshold {
+		if !time.Now().Before(b.openUntil) {
+			zlog.Logger.Warn().Err(err).Int("failures", b.failures).Msg("storage circuit breaker opened")
+		}
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
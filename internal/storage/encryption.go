@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+
+	"github.com/aliskhannn/image-processor/internal/config"
+)
+
+// Server-side encryption types selected by config.StorageEncryption.Type.
+const (
+	EncryptionS3  = "sse-s3"  // keys managed by the storage
+	EncryptionKMS = "sse-kms" // keys managed by the KMS
+	EncryptionC   = "sse-c"   // customer-provided key
+)
+
+// serverSide returns the server-side encryption of stored objects configured by cfg,
+// or nil if encryption is disabled.
+func serverSide(cfg config.StorageEncryption) (encrypt.ServerSide, error) {
+	switch cfg.Type {
+	case "":
+		return nil, nil
+	case EncryptionS3:
+		return encrypt.NewSSE(), nil
+	case EncryptionKMS:
+		if cfg.KMSKeyID == "" {
+			return nil, fmt.Errorf("storage encryption %s requires a KMS key ID", cfg.Type)
+		}
+
+		// A nil context is omitted from the requests, unlike an empty one.
+		var kmsContext any
+		if len(cfg.KMSContext) > 0 {
+			kmsContext = cfg.KMSContext
+		}
+
+		sse, err := encrypt.NewSSEKMS(cfg.KMSKeyID, kmsContext)
+		if err != nil {
+			return nil, fmt.Errorf("invalid storage KMS encryption: %w", err)
+		}
+
+		return sse, nil
+	case EncryptionC:
+		key, err := base64.StdEncoding.DecodeString(cfg.CustomerKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid storage customer key: %w", err)
+		}
+
+		sse, err := encrypt.NewSSEC(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid storage customer key: %w", err)
+		}
+
+		return sse, nil
+	default:
+		return nil, fmt.Errorf("unknown storage encryption %q", cfg.Type)
+	}
+}
@@ -0,0 +1,101 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
+)
+
+// LifecycleRule is a bucket lifecycle rule for the objects under a prefix.
+type LifecycleRule struct {
+	ID                  string
+	Prefix              string
+	ExpireAfterDays     int    // delete objects this many days after creation; zero never expires them
+	TransitionAfterDays int    // move objects to StorageClass this many days after creation; zero never does
+	StorageClass        string // storage class (tier) objects are transitioned to
+}
+
+// ReconcileLifecycle makes the lifecycle rules of the bucket match rules, replacing rules set by
+// other means; no rules remove the lifecycle configuration. It reports whether the rules changed.
+func (s *Storage) ReconcileLifecycle(ctx context.Context, rules []LifecycleRule) (bool, error) {
+	current, err := s.lifecycleRules(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	desired := slices.Clone(rules)
+	sortRules(desired)
+	if slices.Equal(current, desired) {
+		return false, nil
+	}
+
+	cfg := lifecycle.NewConfiguration()
+	for _, r := range desired {
+		rule := lifecycle.Rule{
+			ID:         r.ID,
+			Status:     "Enabled",
+			RuleFilter: lifecycle.Filter{Prefix: r.Prefix},
+		}
+		if r.ExpireAfterDays > 0 {
+			rule.Expiration = lifecycle.Expiration{Days: lifecycle.ExpirationDays(r.ExpireAfterDays)}
+		}
+		if r.TransitionAfterDays > 0 {
+			rule.Transition = lifecycle.Transition{
+				Days:         lifecycle.ExpirationDays(r.TransitionAfterDays),
+				StorageClass: r.StorageClass,
+			}
+		}
+
+		cfg.Rules = append(cfg.Rules, rule)
+	}
+
+	if err := s.client.SetBucketLifecycle(ctx, s.bucketName, cfg); err != nil {
+		return false, fmt.Errorf("failed to set bucket lifecycle: %w", err)
+	}
+
+	return true, nil
+}
+
+// lifecycleRules returns the enabled lifecycle rules of the bucket, sorted by ID.
+func (s *Storage) lifecycleRules(ctx context.Context) ([]LifecycleRule, error) {
+	cfg, err := s.client.GetBucketLifecycle(ctx, s.bucketName)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchLifecycleConfiguration" {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to get bucket lifecycle: %w", err)
+	}
+
+	var rules []LifecycleRule
+	for _, r := range cfg.Rules {
+		if r.Status != "Enabled" {
+			continue
+		}
+
+		prefix := r.RuleFilter.Prefix
+		if prefix == "" {
+			prefix = r.Prefix
+		}
+
+		rules = append(rules, LifecycleRule{
+			ID:                  r.ID,
+			Prefix:              prefix,
+			ExpireAfterDays:     int(r.Expiration.Days),
+			TransitionAfterDays: int(r.Transition.Days),
+			StorageClass:        r.Transition.StorageClass,
+		})
+	}
+	sortRules(rules)
+
+	return rules, nil
+}
+
+// sortRules sorts lifecycle rules by ID, so that rule sets can be compared.
+func sortRules(rules []LifecycleRule) {
+	slices.SortFunc(rules, func(a, b LifecycleRule) int { return strings.Compare(a.ID, b.ID) })
+}
@@ -4,22 +4,49 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"path/filepath"
+	"iter"
+	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+	"github.com/minio/minio-go/v7/pkg/tags"
 )
 
+// defaultContentType is stored for files saved without a content type.
+const defaultContentType = "application/octet-stream"
+
 // Storage provides an S3-compatible storage backend using MinIO.
 // It stores files in a specified bucket under different subdirectories.
 type Storage struct {
 	client     *minio.Client
 	bucketName string
+	opts       Options
+}
+
+// Options tunes how objects are stored.
+type Options struct {
+	PartSize     uint64 // part size of multipart uploads of objects of unknown size, zero uses the minio default
+	CacheControl string // Cache-Control of stored objects, empty sets none
+	// Encryption is the server-side encryption of stored objects, nil stores them as the bucket defaults.
+	Encryption encrypt.ServerSide
+}
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Path         string
+	Size         int64
+	LastModified time.Time
 }
 
 // NewStorage creates a new Storage instance connected to the specified MinIO server.
 // If the bucket does not exist, it will be created automatically.
-func NewStorage(ctx context.Context, endpoint, accessKey, secretKey, bucketName string, useSSL bool) (*Storage, error) {
+func NewStorage(
+	ctx context.Context,
+	endpoint, accessKey, secretKey, bucketName string,
+	useSSL bool,
+	opts Options,
+) (*Storage, error) {
 	client, err := minio.New(endpoint, &minio.Options{
 		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
 		Secure: useSSL,
@@ -42,27 +69,50 @@ func NewStorage(ctx context.Context, endpoint, accessKey, secretKey, bucketName
 	return &Storage{
 		client:     client,
 		bucketName: bucketName,
+		opts:       opts,
 	}, nil
 }
 
-// Save uploads the provided file reader to the specified subdirectory in the bucket.
-// Returns the object path within the bucket.
-func (s *Storage) Save(ctx context.Context, subdir, filename string, src io.Reader) (string, error) {
-	objectName := filepath.Join(subdir, filename)
+// Save uploads the provided file reader to the object with the given path in the bucket,
+// with the content type and the configured Cache-Control, so that presigned URLs and CDNs
+// serve it as what it is. Readers of known size are uploaded with it, larger objects in
+// multipart uploads; other readers are streamed part by part, so only one part is held in memory.
+func (s *Storage) Save(ctx context.Context, path string, src io.Reader, contentType string) error {
+	if contentType == "" {
+		contentType = defaultContentType
+	}
 
-	_, err := s.client.PutObject(ctx, s.bucketName, objectName, src, -1, minio.PutObjectOptions{
-		ContentType: "application/octet-stream",
+	_, err := s.client.PutObject(ctx, s.bucketName, path, src, objectSize(src), minio.PutObjectOptions{
+		ContentType:          contentType,
+		CacheControl:         s.opts.CacheControl,
+		PartSize:             s.opts.PartSize,
+		ServerSideEncryption: s.opts.Encryption,
 	})
 	if err != nil {
-		return "", fmt.Errorf("failed to save file: %w", err)
+		return fmt.Errorf("failed to save file: %w", err)
 	}
 
-	return objectName, nil
+	return nil
 }
 
-// Load retrieves the file from the specified subdirectory in the bucket and returns a reader.
+// objectSize returns the size of readers that know it, such as bytes.Reader, bytes.Buffer and
+// io.SectionReader, or -1 for readers whose size is unknown.
+func objectSize(src io.Reader) int64 {
+	switch r := src.(type) {
+	case interface{ Len() int }:
+		return int64(r.Len())
+	case interface{ Size() int64 }:
+		return r.Size()
+	default:
+		return -1
+	}
+}
+
+// Load retrieves the object with the given path from the bucket and returns a reader.
 func (s *Storage) Load(ctx context.Context, path string) (io.ReadCloser, error) {
-	obj, err := s.client.GetObject(ctx, s.bucketName, path, minio.GetObjectOptions{})
+	obj, err := s.client.GetObject(ctx, s.bucketName, path, minio.GetObjectOptions{
+		ServerSideEncryption: s.readEncryption(),
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to load file: %w", err)
 	}
@@ -70,7 +120,94 @@ func (s *Storage) Load(ctx context.Context, path string) (io.ReadCloser, error)
 	return obj, nil
 }
 
+// Exists reports whether an object with the given path exists in the bucket.
+func (s *Storage) Exists(ctx context.Context, path string) (bool, error) {
+	_, err := s.client.StatObject(ctx, s.bucketName, path, minio.StatObjectOptions{
+		ServerSideEncryption: s.readEncryption(),
+	})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	return true, nil
+}
+
+// readEncryption returns the encryption to send when reading objects: objects encrypted with
+// a customer-provided key can only be read with it, while other encryptions are only sent on writes.
+func (s *Storage) readEncryption() encrypt.ServerSide {
+	if s.opts.Encryption != nil && s.opts.Encryption.Type() == encrypt.SSEC {
+		return s.opts.Encryption
+	}
+
+	return nil
+}
+
 // Delete removes the specified file from the bucket.
 func (s *Storage) Delete(ctx context.Context, path string) error {
 	return s.client.RemoveObject(ctx, s.bucketName, path, minio.RemoveObjectOptions{})
 }
+
+// DeletePrefix removes all files whose path starts with the given prefix.
+func (s *Storage) DeletePrefix(ctx context.Context, prefix string) error {
+	objects := s.client.ListObjects(ctx, s.bucketName, minio.ListObjectsOptions{Prefix: prefix, Recursive: true})
+
+	for res := range s.client.RemoveObjects(ctx, s.bucketName, objects, minio.RemoveObjectsOptions{}) {
+		if res.Err != nil {
+			return fmt.Errorf("failed to delete %s: %w", res.ObjectName, res.Err)
+		}
+	}
+
+	return nil
+}
+
+// Tag replaces the tags of the object with the given path.
+func (s *Storage) Tag(ctx context.Context, path string, objectTags map[string]string) error {
+	t, err := tags.MapToObjectTags(objectTags)
+	if err != nil {
+		return fmt.Errorf("invalid tags: %w", err)
+	}
+
+	if err := s.client.PutObjectTagging(ctx, s.bucketName, path, t, minio.PutObjectTaggingOptions{}); err != nil {
+		return fmt.Errorf("failed to tag file: %w", err)
+	}
+
+	return nil
+}
+
+// List iterates over the objects whose path starts with prefix in lexicographic order of their paths.
+// Iteration stops at the first listing error, which is yielded with an empty ObjectInfo.
+func (s *Storage) List(ctx context.Context, prefix string) iter.Seq2[ObjectInfo, error] {
+	return func(yield func(ObjectInfo, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel() // stops the listing if the caller stops early
+
+		for obj := range s.client.ListObjects(ctx, s.bucketName, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+			if obj.Err != nil {
+				yield(ObjectInfo{}, fmt.Errorf("failed to list files: %w", obj.Err))
+				return
+			}
+
+			if !yield(ObjectInfo{Path: obj.Key, Size: obj.Size, LastModified: obj.LastModified}, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Ping checks that the storage is reachable and the bucket exists.
+func (s *Storage) Ping(ctx context.Context) error {
+	exists, err := s.client.BucketExists(ctx, s.bucketName)
+	if err != nil {
+		return fmt.Errorf("failed to check bucket: %w", err)
+	}
+
+	if !exists {
+		return fmt.Errorf("bucket %s does not exist", s.bucketName)
+	}
+
+	return nil
+}
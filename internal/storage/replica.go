@@ -0,0 +1,361 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"sync"
+	"time"
+
+	"github.com/wb-go/wbf/retry"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/image-processor/internal/metrics"
+	"github.com/aliskhannn/image-processor/internal/reload"
+	"github.com/aliskhannn/image-processor/internal/storage/file"
+)
+
+// Defaults of the replication.
+const (
+	defaultReplicaQueue   = 1000
+	defaultReplicaWorkers = 1
+	maxDivergenceSamples  = 20 // paths of divergent objects kept in a reconciliation report
+)
+
+// Replicated operations.
+const (
+	opSave         = "save"
+	opDelete       = "delete"
+	opDeletePrefix = "delete_prefix"
+	opTag          = "tag"
+)
+
+// lister defines the interface for listing stored objects, needed by reconciliation.
+type lister interface {
+	List(ctx context.Context, prefix string) iter.Seq2[file.ObjectInfo, error]
+}
+
+// replication is a write or delete of the primary storage pending on the replica.
+type replication struct {
+	op          string
+	path        string // path, or prefix of opDeletePrefix
+	contentType string
+	tags        map[string]string
+}
+
+// Divergence reports the differences between the primary and the replica storage.
+type Divergence struct {
+	Missing      int      // objects stored in the primary storage only
+	Extra        int      // objects stored in the replica only
+	SizeMismatch int      // objects stored in both with different sizes
+	Samples      []string // paths of the first divergent objects
+}
+
+// Total returns the number of divergent objects.
+func (d Divergence) Total() int {
+	return d.Missing + d.Extra + d.SizeMismatch
+}
+
+// add counts a divergent object.
+func (d *Divergence) add(kind, path string) {
+	switch kind {
+	case metrics.DivergenceMissing:
+		d.Missing++
+	case metrics.DivergenceExtra:
+		d.Extra++
+	case metrics.DivergenceSizeMismatch:
+		d.SizeMismatch++
+	}
+
+	if len(d.Samples) < maxDivergenceSamples {
+		d.Samples = append(d.Samples, kind+": "+path)
+	}
+}
+
+// Replicated wraps a primary Storage with a secondary one for disaster recovery. Calls are
+// served by the primary storage; once a write or delete succeeded there, it is replicated to
+// the secondary storage in the background, so that the replica never slows down or fails requests.
+// Replications dropped with a full queue, failing after retries or abandoned on shutdown are
+// counted, and the divergence they leave is reported by Reconcile.
+type Replicated struct {
+	primary   Storage
+	secondary Storage
+	strategy  *reload.Value[retry.Strategy]
+
+	mu     sync.RWMutex
+	closed bool
+	jobs   chan replication
+
+	wg     sync.WaitGroup
+	ctx    context.Context // canceled once the drain timeout of Close passed
+	cancel context.CancelFunc
+}
+
+// NewReplicated creates a new Replicated storage holding up to queueSize pending replications,
+// retried with the strategy. Replication starts with Start.
+func NewReplicated(primary, secondary Storage, queueSize int, s *reload.Value[retry.Strategy]) *Replicated {
+	if queueSize <= 0 {
+		queueSize = defaultReplicaQueue
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Replicated{
+		primary:   primary,
+		secondary: secondary,
+		strategy:  s,
+		jobs:      make(chan replication, queueSize),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// Start starts the given number of workers replicating pending writes and deletes until Close.
+func (r *Replicated) Start(workers int) {
+	if workers <= 0 {
+		workers = defaultReplicaWorkers
+	}
+
+	r.wg.Add(workers)
+	for range workers {
+		go func() {
+			defer r.wg.Done()
+
+			for job := range r.jobs {
+				// Replications abandoned on shutdown are only counted.
+				if r.ctx.Err() != nil {
+					metrics.ReplicationFailed(job.op)
+					continue
+				}
+
+				if err := r.replicate(r.ctx, job); err != nil {
+					metrics.ReplicationFailed(job.op)
+					zlog.Logger.Err(err).Str("op", job.op).Str("path", job.path).Msg("failed to replicate to storage replica")
+				}
+			}
+		}()
+	}
+}
+
+// Close stops accepting replications and waits for the pending ones until the context is done.
+// Replications still pending then are abandoned and left to the reconciliation to report.
+func (r *Replicated) Close(ctx context.Context) error {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.jobs)
+	}
+	r.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		r.cancel()
+		return nil
+	case <-ctx.Done():
+		pending := len(r.jobs)
+		r.cancel()
+		<-done
+
+		return fmt.Errorf("replica: %d pending replications abandoned: %w", pending, ctx.Err())
+	}
+}
+
+// Save stores the file in the primary storage and replicates it.
+func (r *Replicated) Save(ctx context.Context, path string, src io.Reader, contentType string) error {
+	if err := r.primary.Save(ctx, path, src, contentType); err != nil {
+		return err
+	}
+
+	r.enqueue(replication{op: opSave, path: path, contentType: contentType})
+
+	return nil
+}
+
+// Load opens the file from the primary storage.
+func (r *Replicated) Load(ctx context.Context, path string) (io.ReadCloser, error) {
+	return r.primary.Load(ctx, path)
+}
+
+// Exists reports whether the file is stored in the primary storage.
+func (r *Replicated) Exists(ctx context.Context, path string) (bool, error) {
+	return r.primary.Exists(ctx, path)
+}
+
+// Delete removes the file from the primary storage and replicates the deletion.
+func (r *Replicated) Delete(ctx context.Context, path string) error {
+	if err := r.primary.Delete(ctx, path); err != nil {
+		return err
+	}
+
+	r.enqueue(replication{op: opDelete, path: path})
+
+	return nil
+}
+
+// DeletePrefix removes the files from the primary storage and replicates the deletion.
+func (r *Replicated) DeletePrefix(ctx context.Context, prefix string) error {
+	if err := r.primary.DeletePrefix(ctx, prefix); err != nil {
+		return err
+	}
+
+	r.enqueue(replication{op: opDeletePrefix, path: prefix})
+
+	return nil
+}
+
+// Tag tags the file in the primary storage and replicates the tags.
+func (r *Replicated) Tag(ctx context.Context, path string, tags map[string]string) error {
+	if err := r.primary.Tag(ctx, path, tags); err != nil {
+		return err
+	}
+
+	r.enqueue(replication{op: opTag, path: path, tags: tags})
+
+	return nil
+}
+
+// Ping checks that the primary storage is reachable; the replica doesn't affect readiness.
+func (r *Replicated) Ping(ctx context.Context) error {
+	return r.primary.Ping(ctx)
+}
+
+// enqueue queues the replication, dropping it if the queue is full or closed.
+func (r *Replicated) enqueue(job replication) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if !r.closed {
+		select {
+		case r.jobs <- job:
+			return
+		default:
+		}
+	}
+
+	metrics.ReplicationFailed(job.op)
+	zlog.Logger.Warn().Str("op", job.op).Str("path", job.path).Msg("storage replication queue full or closed, replication dropped")
+}
+
+// replicate applies the write or delete to the replica with retries.
+// Files are copied from the primary storage, where they may have changed since.
+func (r *Replicated) replicate(ctx context.Context, job replication) error {
+	return retry.Do(func() error {
+		switch job.op {
+		case opSave:
+			src, err := r.primary.Load(ctx, job.path)
+			if err != nil {
+				return err
+			}
+			defer src.Close()
+
+			return r.secondary.Save(ctx, job.path, src, job.contentType)
+		case opDelete:
+			return r.secondary.Delete(ctx, job.path)
+		case opDeletePrefix:
+			return r.secondary.DeletePrefix(ctx, job.path)
+		case opTag:
+			return r.secondary.Tag(ctx, job.path, job.tags)
+		default:
+			return fmt.Errorf("unknown replication %q", job.op)
+		}
+	}, r.strategy.Load())
+}
+
+// Reconcile compares the objects of both storages by path and size and reports their divergence.
+// Objects changed within the grace period are skipped, as their replication may still be pending.
+// Both listings are streamed in path order, so buckets of any size are compared in constant memory.
+func (r *Replicated) Reconcile(ctx context.Context, grace time.Duration) (Divergence, error) {
+	primary, ok := r.primary.(lister)
+	secondary, ok2 := r.secondary.(lister)
+	if !ok || !ok2 {
+		return Divergence{}, errors.New("reconcile replica: storage can't be listed")
+	}
+
+	cutoff := time.Now().Add(-grace)
+	settled := func(obj file.ObjectInfo) bool { return obj.LastModified.Before(cutoff) }
+
+	nextP, stopP := iter.Pull2(primary.List(ctx, ""))
+	defer stopP()
+	nextS, stopS := iter.Pull2(secondary.List(ctx, ""))
+	defer stopS()
+
+	p, pErr, pOK := nextP()
+	s, sErr, sOK := nextS()
+
+	var d Divergence
+	for pOK || sOK {
+		if pOK && pErr != nil {
+			return Divergence{}, fmt.Errorf("reconcile replica: primary: %w", pErr)
+		}
+		if sOK && sErr != nil {
+			return Divergence{}, fmt.Errorf("reconcile replica: replica: %w", sErr)
+		}
+
+		switch {
+		case !sOK || (pOK && p.Path < s.Path):
+			if settled(p) {
+				d.add(metrics.DivergenceMissing, p.Path)
+			}
+			p, pErr, pOK = nextP()
+		case !pOK || s.Path < p.Path:
+			if settled(s) {
+				d.add(metrics.DivergenceExtra, s.Path)
+			}
+			s, sErr, sOK = nextS()
+		default:
+			if p.Size != s.Size && settled(p) {
+				d.add(metrics.DivergenceSizeMismatch, p.Path)
+			}
+			p, pErr, pOK = nextP()
+			s, sErr, sOK = nextS()
+		}
+	}
+
+	return d, nil
+}
+
+// RunReconciler reconciles the storages every interval until the context is canceled,
+// logging and exporting the divergence found.
+func (r *Replicated) RunReconciler(ctx context.Context, interval, grace time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d, err := r.Reconcile(ctx, grace)
+			if err != nil {
+				if ctx.Err() == nil {
+					zlog.Logger.Err(err).Msg("failed to reconcile storage replica")
+				}
+				continue
+			}
+
+			metrics.SetReplicaDivergence(metrics.DivergenceMissing, d.Missing)
+			metrics.SetReplicaDivergence(metrics.DivergenceExtra, d.Extra)
+			metrics.SetReplicaDivergence(metrics.DivergenceSizeMismatch, d.SizeMismatch)
+
+			if d.Total() == 0 {
+				zlog.Logger.Info().Msg("storage replica in sync")
+				continue
+			}
+
+			zlog.Logger.Warn().
+				Int("missing", d.Missing).
+				Int("extra", d.Extra).
+				Int("size_mismatch", d.SizeMismatch).
+				Strs("samples", d.Samples).
+				Msg("storage replica diverged")
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// Package storage defines the file storage of originals, derived images and other assets,
+// and constructs the configured backend.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/image-processor/internal/config"
+	"github.com/aliskhannn/image-processor/internal/storage/file"
+)
+
+// Backends selected by config.Storage.Type.
+const (
+	TypeMinIO = "minio" // S3-compatible object storage, the default
+)
+
+// Tags of stored images, matching objects to their database records.
+const (
+	TagImageID    = "image-id"    // ID of the image record
+	TagOriginalID = "original-id" // ID of the original of derived images
+	TagKind       = "kind"        // KindOriginal or KindDerived
+
+	KindOriginal = "original"
+	KindDerived  = "derived"
+)
+
+// ImageTags returns the tags of the stored file of an image: an original, or an image derived
+// from originalID if set. Identical originals share one file, tagged with the image stored first.
+func ImageTags(id uuid.UUID, originalID *uuid.UUID) map[string]string {
+	if originalID == nil {
+		return map[string]string{TagImageID: id.String(), TagKind: KindOriginal}
+	}
+
+	return map[string]string{TagImageID: id.String(), TagKind: KindDerived, TagOriginalID: originalID.String()}
+}
+
+//go:generate go run go.uber.org/mock/mockgen@v0.6.0 -destination=mocks/storage.go -package=mocks . Storage
+
+// Storage stores files under slash-separated paths, e.g. "original/photo.jpg".
+// Paths are chosen by the callers and recorded in the database.
+type Storage interface {
+	// Save stores the contents of src under path with its MIME type, replacing any file stored there.
+	Save(ctx context.Context, path string, src io.Reader, contentType string) error
+	// Load returns a reader of the file stored under path.
+	Load(ctx context.Context, path string) (io.ReadCloser, error)
+	// Exists reports whether a file is stored under path.
+	Exists(ctx context.Context, path string) (bool, error)
+	// Delete removes the file stored under path.
+	Delete(ctx context.Context, path string) error
+	// DeletePrefix removes all files whose path starts with prefix.
+	DeletePrefix(ctx context.Context, prefix string) error
+	// Tag replaces the tags of the file stored under path.
+	Tag(ctx context.Context, path string, tags map[string]string) error
+	// Ping checks that the storage is reachable.
+	Ping(ctx context.Context) error
+}
+
+// New creates the storage backend selected by cfg.Type.
+// Managed lifecycle rules are reconciled with the bucket on creation, so that config changes
+// apply on the next start.
+func New(ctx context.Context, cfg config.Storage) (Storage, error) {
+	opts, err := options(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Type {
+	case "", TypeMinIO:
+		s, err := file.NewStorage(ctx, cfg.Endpoint, cfg.AccessKey, cfg.SecretKey, cfg.BucketName, cfg.UseSSL, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		if cfg.Lifecycle.Managed {
+			if err := reconcileLifecycle(ctx, s, cfg.Lifecycle.Rules); err != nil {
+				return nil, err
+			}
+		}
+
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown storage type %q", cfg.Type)
+	}
+}
+
+// NewReplica creates the secondary MinIO/S3 storage of cfg.Replica, which stores objects
+// with the same options as the primary storage.
+func NewReplica(ctx context.Context, cfg config.Storage) (Storage, error) {
+	opts, err := options(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	r := cfg.Replica
+	s, err := file.NewStorage(ctx, r.Endpoint, r.AccessKey, r.SecretKey, r.BucketName, r.UseSSL, opts)
+	if err != nil {
+		return nil, fmt.Errorf("replica: %w", err)
+	}
+
+	return s, nil
+}
+
+// options returns the options objects are stored with.
+func options(cfg config.Storage) (file.Options, error) {
+	sse, err := serverSide(cfg.Encryption)
+	if err != nil {
+		return file.Options{}, err
+	}
+
+	return file.Options{
+		PartSize:     cfg.PartSize,
+		CacheControl: cfg.CacheControl,
+		Encryption:   sse,
+	}, nil
+}
+
+// reconcileLifecycle makes the lifecycle rules of the bucket match the configured ones.
+func reconcileLifecycle(ctx context.Context, s *file.Storage, rules []config.LifecycleRule) error {
+	desired := make([]file.LifecycleRule, 0, len(rules))
+	for _, r := range rules {
+		if r.ID == "" {
+			return fmt.Errorf("storage lifecycle rule for prefix %q has no ID", r.Prefix)
+		}
+		if r.ExpireAfterDays <= 0 && r.TransitionAfterDays <= 0 {
+			return fmt.Errorf("storage lifecycle rule %s neither expires nor transitions objects", r.ID)
+		}
+		if r.TransitionAfterDays > 0 && r.StorageClass == "" {
+			return fmt.Errorf("storage lifecycle rule %s transitions objects without a storage class", r.ID)
+		}
+
+		desired = append(desired, file.LifecycleRule{
+			ID:                  r.ID,
+			Prefix:              r.Prefix,
+			ExpireAfterDays:     r.ExpireAfterDays,
+			TransitionAfterDays: r.TransitionAfterDays,
+			StorageClass:        r.StorageClass,
+		})
+	}
+
+	changed, err := s.ReconcileLifecycle(ctx, desired)
+	if err != nil {
+		return fmt.Errorf("reconcile storage lifecycle: %w", err)
+	}
+
+	if changed {
+		zlog.Logger.Info().Int("rules", len(desired)).Msg("storage lifecycle rules updated")
+	}
+
+	return nil
+}
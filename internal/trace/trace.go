@@ -0,0 +1,110 @@
+// Package trace carries the request ID and the W3C trace context (as used by OpenTelemetry)
+// of a request through contexts and across the Kafka queue.
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Header names used to propagate the trace context over HTTP and Kafka.
+const (
+	RequestIDHeader   = "X-Request-ID" // correlation ID of the request that started the work
+	TraceParentHeader = "traceparent"  // W3C trace context: version-traceid-spanid-flags
+	TraceStateHeader  = "tracestate"   // W3C vendor-specific trace state
+)
+
+// Context holds the correlation data propagated along with a request.
+type Context struct {
+	RequestID   string
+	TraceParent string
+	TraceState  string
+}
+
+// contextKey is the key the trace context is stored under in a context.Context.
+type contextKey struct{}
+
+// WithContext returns a copy of ctx carrying the trace context.
+func WithContext(ctx context.Context, tc Context) context.Context {
+	return context.WithValue(ctx, contextKey{}, tc)
+}
+
+// FromContext returns the trace context carried by ctx, if any.
+func FromContext(ctx context.Context) Context {
+	tc, _ := ctx.Value(contextKey{}).(Context)
+	return tc
+}
+
+// Inject writes the trace context of ctx to a carrier through set, skipping empty values.
+func Inject(ctx context.Context, set func(key, value string)) {
+	tc := FromContext(ctx)
+
+	for key, value := range map[string]string{
+		RequestIDHeader:   tc.RequestID,
+		TraceParentHeader: tc.TraceParent,
+		TraceStateHeader:  tc.TraceState,
+	} {
+		if value != "" {
+			set(key, value)
+		}
+	}
+}
+
+// Extract reads the trace context from a carrier through get and returns ctx carrying it.
+// The work continues the incoming trace in a new span; a missing or invalid trace parent
+// starts a new trace, and a missing request ID gets a new one.
+func Extract(ctx context.Context, get func(key string) string) context.Context {
+	tc := Context{
+		RequestID:   get(RequestIDHeader),
+		TraceParent: ChildSpan(get(TraceParentHeader)),
+		TraceState:  get(TraceStateHeader),
+	}
+	if tc.RequestID == "" {
+		tc.RequestID = uuid.NewString()
+	}
+
+	return WithContext(ctx, tc)
+}
+
+// ChildSpan returns a trace parent for a new span of the trace identified by parent.
+// If parent is not a valid trace parent, a new trace is started instead.
+func ChildSpan(parent string) string {
+	parts := strings.Split(parent, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[3]) != 2 || !isHex(parts[1]) || !isHex(parts[3]) {
+		return "00-" + randomHex(16) + "-" + randomHex(8) + "-01"
+	}
+
+	return "00-" + parts[1] + "-" + randomHex(8) + "-" + parts[3]
+}
+
+// TraceID returns the trace ID of the trace parent, or an empty string if it is invalid.
+func TraceID(traceParent string) string {
+	parts := strings.Split(traceParent, "-")
+	if len(parts) != 4 {
+		return ""
+	}
+
+	return parts[1]
+}
+
+// isHex reports whether s is a non-zero lowercase hex string.
+func isHex(s string) bool {
+	if strings.Trim(s, "0") == "" {
+		return false
+	}
+
+	_, err := hex.DecodeString(s)
+	return err == nil && strings.ToLower(s) == s
+}
+
+// randomHex returns n random bytes encoded as hex.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,95 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/wb-go/wbf/retry"
+
+	"github.com/aliskhannn/image-processor/internal/model"
+	"github.com/aliskhannn/image-processor/internal/reload"
+	"github.com/aliskhannn/image-processor/internal/trace"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of "<timestamp>.<body>" signed with the shared secret.
+const SignatureHeader = "X-Webhook-Signature"
+
+// TimestampHeader carries the unix timestamp included in the signature.
+const TimestampHeader = "X-Webhook-Timestamp"
+
+// Notifier delivers signed webhook events to client callback URLs.
+type Notifier struct {
+	client   *http.Client
+	secret   []byte
+	strategy *reload.Value[retry.Strategy]
+}
+
+// NewNotifier creates a new Notifier.
+// - secret: shared secret used to sign payloads
+// - timeout: timeout of a single delivery attempt
+// - s: retry strategy for failed deliveries
+func NewNotifier(secret string, timeout time.Duration, s *reload.Value[retry.Strategy]) *Notifier {
+	return &Notifier{
+		client:   &http.Client{Timeout: timeout},
+		secret:   []byte(secret),
+		strategy: s,
+	}
+}
+
+// Notify POSTs the event to the callback URL, retrying failed deliveries.
+// Any non-2xx response is treated as a failed delivery.
+func (n *Notifier) Notify(ctx context.Context, url string, event model.WebhookEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	return retry.Do(func() error {
+		return n.deliver(ctx, url, body)
+	}, n.strategy.Load())
+}
+
+// deliver performs a single delivery attempt.
+func (n *Notifier) deliver(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TimestampHeader, ts)
+	req.Header.Set(SignatureHeader, n.sign(ts, body))
+	trace.Inject(ctx, req.Header.Set)
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// sign returns the hex HMAC-SHA256 of "<timestamp>.<body>".
+func (n *Notifier) sign(ts string, body []byte) string {
+	mac := hmac.New(sha256.New, n.secret)
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,39 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS image_changes
+(
+    seq         BIGSERIAL PRIMARY KEY,
+    image_id    UUID      NOT NULL,
+    operation   TEXT      NOT NULL,
+    path        TEXT      NOT NULL,
+    changed_at  TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE OR REPLACE FUNCTION record_image_change() RETURNS TRIGGER AS
+$$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO image_changes (image_id, operation, path) VALUES (OLD.id, 'deleted', OLD.path);
+        RETURN OLD;
+    ELSIF TG_OP = 'UPDATE' THEN
+        INSERT INTO image_changes (image_id, operation, path) VALUES (NEW.id, 'updated', NEW.path);
+    ELSE
+        INSERT INTO image_changes (image_id, operation, path) VALUES (NEW.id, 'created', NEW.path);
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER images_record_change
+    AFTER INSERT OR UPDATE OR DELETE
+    ON images
+    FOR EACH ROW
+EXECUTE FUNCTION record_image_change();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS images_record_change ON images;
+DROP FUNCTION IF EXISTS record_image_change();
+DROP TABLE IF EXISTS image_changes;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS ocr_text TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE images
+    DROP COLUMN IF EXISTS ocr_text;
+-- +goose StatementEnd
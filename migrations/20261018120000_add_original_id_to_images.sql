@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS original_id UUID REFERENCES images (id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_images_original_id ON images (original_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_images_original_id;
+
+ALTER TABLE images
+    DROP COLUMN IF EXISTS original_id;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS stage      TEXT      NOT NULL DEFAULT 'queued',
+    ADD COLUMN IF NOT EXISTS attempts   INT       NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS last_error TEXT,
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP NOT NULL DEFAULT NOW();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE images
+    DROP COLUMN IF EXISTS stage,
+    DROP COLUMN IF EXISTS attempts,
+    DROP COLUMN IF EXISTS last_error,
+    DROP COLUMN IF EXISTS updated_at;
+-- +goose StatementEnd
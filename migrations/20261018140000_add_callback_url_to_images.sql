@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS callback_url TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE images
+    DROP COLUMN IF EXISTS callback_url;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS owner      TEXT   NOT NULL DEFAULT 'anonymous',
+    ADD COLUMN IF NOT EXISTS size_bytes BIGINT NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_images_owner_created_at ON images (owner, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_images_owner_created_at;
+
+ALTER TABLE images
+    DROP COLUMN IF EXISTS size_bytes,
+    DROP COLUMN IF EXISTS owner;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS content_hash TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_images_owner_content_hash ON images (owner, content_hash)
+    WHERE original_id IS NULL AND content_hash IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_images_owner_content_hash;
+
+ALTER TABLE images
+    DROP COLUMN IF EXISTS content_hash;
+-- +goose StatementEnd
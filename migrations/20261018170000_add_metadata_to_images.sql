@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS title       TEXT,
+    ADD COLUMN IF NOT EXISTS description TEXT,
+    ADD COLUMN IF NOT EXISTS tags        JSONB NOT NULL DEFAULT '[]';
+
+CREATE INDEX IF NOT EXISTS idx_images_tags ON images USING GIN (tags jsonb_path_ops);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_images_tags;
+
+ALTER TABLE images
+    DROP COLUMN IF EXISTS tags,
+    DROP COLUMN IF EXISTS description,
+    DROP COLUMN IF EXISTS title;
+-- +goose StatementEnd
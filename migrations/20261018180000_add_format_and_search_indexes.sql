@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS format TEXT;
+
+-- Backfill from the stored file extension.
+UPDATE images
+SET format = CASE lower(substring(path from '\.([^.]+)$'))
+                 WHEN 'jpg' THEN 'jpeg'
+                 WHEN 'jpeg' THEN 'jpeg'
+                 WHEN 'png' THEN 'png'
+                 WHEN 'gif' THEN 'gif'
+                 WHEN 'webp' THEN 'webp'
+                 WHEN 'bmp' THEN 'bmp'
+                 WHEN 'tif' THEN 'tiff'
+                 WHEN 'tiff' THEN 'tiff'
+             END
+WHERE format IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_images_format ON images (format);
+CREATE INDEX IF NOT EXISTS idx_images_size_bytes ON images (size_bytes);
+CREATE INDEX IF NOT EXISTS idx_images_created_at ON images (created_at DESC, id DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_images_created_at;
+DROP INDEX IF EXISTS idx_images_size_bytes;
+DROP INDEX IF EXISTS idx_images_format;
+
+ALTER TABLE images
+    DROP COLUMN IF EXISTS format;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Words in filenames are usually joined by punctuation ("summer_trip-01.jpg"),
+-- so it is replaced with spaces before tokenizing.
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', regexp_replace(coalesce(filename, ''), '[._-]+', ' ', 'g')), 'A') ||
+        setweight(jsonb_to_tsvector('simple', tags, '["string"]'), 'A') ||
+        setweight(to_tsvector('simple', coalesce(ocr_text, '')), 'B')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_images_search_vector ON images USING GIN (search_vector);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_images_search_vector;
+
+ALTER TABLE images
+    DROP COLUMN IF EXISTS search_vector;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_images_expires_at ON images (expires_at)
+    WHERE expires_at IS NOT NULL AND original_id IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_images_expires_at;
+
+ALTER TABLE images
+    DROP COLUMN IF EXISTS expires_at;
+-- +goose StatementEnd
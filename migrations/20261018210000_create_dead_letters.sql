@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS dead_letters
+(
+    id          UUID PRIMARY KEY     DEFAULT gen_random_uuid(),
+    topic       TEXT        NOT NULL,
+    partition   INT         NOT NULL,
+    "offset"    BIGINT      NOT NULL,
+    key         TEXT        NOT NULL DEFAULT '',
+    payload     BYTEA       NOT NULL,
+    error       TEXT        NOT NULL,
+    attempts    INT         NOT NULL,
+    failed_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    replayed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_dead_letters_failed_at ON dead_letters (failed_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS dead_letters;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS failed_at TIMESTAMPTZ;
+
+-- Jobs whose last attempt failed were left pending.
+UPDATE images
+SET status    = 'failed',
+    failed_at = updated_at
+WHERE stage = 'failed' AND status = 'pending';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE images
+    DROP COLUMN IF EXISTS failed_at;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS priority TEXT NOT NULL DEFAULT 'normal';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE images
+    DROP COLUMN IF EXISTS priority;
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS processed_messages
+(
+    message_id   TEXT PRIMARY KEY,
+    image_id     UUID        NOT NULL,
+    derived_id   UUID        NOT NULL,
+    processed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Derived images remember the message that produced them, so that a redelivered
+-- message updates the existing record instead of creating a duplicate.
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS message_id TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_images_message_id ON images (message_id)
+    WHERE message_id IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_images_message_id;
+
+ALTER TABLE images
+    DROP COLUMN IF EXISTS message_id;
+
+DROP TABLE IF EXISTS processed_messages;
+-- +goose StatementEnd
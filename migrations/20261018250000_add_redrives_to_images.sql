@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Number of times a stuck job was re-enqueued by the re-driver; past the limit it is failed instead.
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS redrives INT NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE images
+    DROP COLUMN IF EXISTS redrives;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Processing attempts of queue messages still being worked on, so that a message failing or
+-- crashing the worker on every delivery can be given up on after a cap.
+CREATE TABLE IF NOT EXISTS message_attempts
+(
+    message_id TEXT PRIMARY KEY,
+    attempts   INT         NOT NULL DEFAULT 0,
+    last_error TEXT,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS message_attempts;
+-- +goose StatementEnd
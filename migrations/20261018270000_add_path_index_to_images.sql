@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Originals are stored under content-addressed paths shared by identical uploads, so deleting
+-- an image looks up whether another one still references its file.
+CREATE INDEX IF NOT EXISTS idx_images_path ON images (path);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_images_path;
+-- +goose StatementEnd
@@ -0,0 +1,58 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Bytes and objects stored per owner and top-level storage directory ("original", "processed", ...),
+-- maintained by a trigger on images so that every save and delete is accounted in the same transaction.
+CREATE TABLE IF NOT EXISTS storage_usage
+(
+    owner      TEXT      NOT NULL,
+    subdir     TEXT      NOT NULL,
+    bytes      BIGINT    NOT NULL DEFAULT 0,
+    objects    BIGINT    NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (owner, subdir)
+);
+
+CREATE OR REPLACE FUNCTION account_storage_usage() RETURNS TRIGGER AS
+$$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        UPDATE storage_usage
+        SET bytes      = bytes - OLD.size_bytes,
+            objects    = objects - 1,
+            updated_at = NOW()
+        WHERE owner = OLD.owner
+          AND subdir = split_part(OLD.path, '/', 1);
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        INSERT INTO storage_usage (owner, subdir, bytes, objects)
+        VALUES (NEW.owner, split_part(NEW.path, '/', 1), NEW.size_bytes, 1)
+        ON CONFLICT (owner, subdir) DO UPDATE
+            SET bytes      = storage_usage.bytes + EXCLUDED.bytes,
+                objects    = storage_usage.objects + 1,
+                updated_at = NOW();
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER images_account_storage_usage
+    AFTER INSERT OR DELETE OR UPDATE OF owner, path, size_bytes
+    ON images
+    FOR EACH ROW
+EXECUTE FUNCTION account_storage_usage();
+
+INSERT INTO storage_usage (owner, subdir, bytes, objects)
+SELECT owner, split_part(path, '/', 1), SUM(size_bytes), COUNT(*)
+FROM images
+GROUP BY owner, split_part(path, '/', 1)
+ON CONFLICT (owner, subdir) DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS images_account_storage_usage ON images;
+DROP FUNCTION IF EXISTS account_storage_usage();
+DROP TABLE IF EXISTS storage_usage;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Timing of the last processing attempt, maintained by the worker next to attempts and last_error.
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS started_at  TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS finished_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS duration_ms BIGINT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE images
+    DROP COLUMN IF EXISTS started_at,
+    DROP COLUMN IF EXISTS finished_at,
+    DROP COLUMN IF EXISTS duration_ms;
+-- +goose StatementEnd
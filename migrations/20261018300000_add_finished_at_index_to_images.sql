@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The admin stats group the jobs of originals finished within a window by hour.
+CREATE INDEX IF NOT EXISTS idx_images_finished_at ON images (finished_at) WHERE original_id IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_images_finished_at;
+-- +goose StatementEnd
@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Append-only audit log of image lifecycle events. Events outlive the images they refer to,
+-- so image_id has no foreign key.
+CREATE TABLE IF NOT EXISTS audit_events (
+    id         BIGSERIAL PRIMARY KEY,
+    event      TEXT      NOT NULL,
+    image_id   UUID      NOT NULL,
+    actor      TEXT      NOT NULL,
+    request_id TEXT,
+    details    TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_image_id ON audit_events (image_id, id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at);
+
+-- Recorded events can't be changed or removed.
+CREATE OR REPLACE FUNCTION reject_audit_change() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_append_only
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION reject_audit_change();
+
+CREATE TRIGGER audit_events_no_truncate
+    BEFORE TRUNCATE ON audit_events
+    FOR EACH STATEMENT EXECUTE FUNCTION reject_audit_change();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS audit_events;
+DROP FUNCTION IF EXISTS reject_audit_change();
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Dimensions and color space of stored images, captured when originals are uploaded and results encoded,
+-- so clients learn them without downloading the file. Images stored before are left NULL.
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS width       INT,
+    ADD COLUMN IF NOT EXISTS height      INT,
+    ADD COLUMN IF NOT EXISTS color_space TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE images
+    DROP COLUMN IF EXISTS width,
+    DROP COLUMN IF EXISTS height,
+    DROP COLUMN IF EXISTS color_space;
+-- +goose StatementEnd
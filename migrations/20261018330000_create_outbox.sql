@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Processing tasks stored in the transaction that records their image, published to the queue
+-- by the outbox relay and deleted once sent.
+CREATE TABLE IF NOT EXISTS outbox (
+    id         BIGSERIAL PRIMARY KEY,
+    image_id   UUID      NOT NULL,
+    payload    JSONB     NOT NULL,
+    headers    JSONB     NOT NULL DEFAULT '{}'::jsonb,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS outbox;
+-- +goose StatementEnd
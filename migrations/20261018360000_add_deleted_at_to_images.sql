@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Time an image was soft-deleted. Soft-deleted images keep their record and files but are hidden
+-- from reads, unless listed by admins with include_deleted.
+ALTER TABLE images
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_images_deleted_at ON images (deleted_at)
+    WHERE deleted_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_images_deleted_at;
+
+ALTER TABLE images
+    DROP COLUMN IF EXISTS deleted_at;
+-- +goose StatementEnd
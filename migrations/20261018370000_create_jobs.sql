@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Processing tasks queued with queue.type postgres, for deployments without a broker. Workers claim
+-- them with SELECT ... FOR UPDATE SKIP LOCKED and delete them once handled; failed tasks are
+-- postponed to run_at by the retry delays.
+CREATE TABLE IF NOT EXISTS jobs
+(
+    id         BIGSERIAL PRIMARY KEY,
+    message_id TEXT        NOT NULL,
+    payload    BYTEA       NOT NULL,
+    headers    JSONB       NOT NULL DEFAULT '{}',
+    deliveries INT         NOT NULL DEFAULT 0,
+    run_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_run_at ON jobs (run_at, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS jobs;
+-- +goose StatementEnd
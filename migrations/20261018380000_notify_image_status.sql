@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Notifies the ID of images on the image_status channel when their status or stage changes,
+-- so that the API streams status updates to clients without polling the images table.
+CREATE OR REPLACE FUNCTION notify_image_status() RETURNS TRIGGER AS
+$$
+BEGIN
+    PERFORM pg_notify('image_status', NEW.id::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER images_notify_status
+    AFTER UPDATE OF status, stage
+    ON images
+    FOR EACH ROW
+    WHEN (OLD.status IS DISTINCT FROM NEW.status OR OLD.stage IS DISTINCT FROM NEW.stage)
+EXECUTE FUNCTION notify_image_status();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS images_notify_status ON images;
+DROP FUNCTION IF EXISTS notify_image_status();
+-- +goose StatementEnd
@@ -0,0 +1,195 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Images are partitioned by the month of created_at, so that deletes and retention jobs bloat and
+-- vacuum small monthly partitions instead of one large table, and old months can be detached and
+-- dropped at once. Unique indexes of a partitioned table must include the partition key, so the
+-- primary key becomes (id, created_at), message IDs are kept unique by the repository rather than
+-- by an index, and derived images are deleted by the repository along with their original instead
+-- of by a foreign key cascade.
+ALTER TABLE images RENAME TO images_legacy;
+ALTER TABLE images_legacy RENAME CONSTRAINT images_pkey TO images_legacy_pkey;
+
+CREATE TABLE images
+(
+    LIKE images_legacy INCLUDING DEFAULTS INCLUDING GENERATED
+) PARTITION BY RANGE (created_at);
+
+ALTER TABLE images
+    ADD PRIMARY KEY (id, created_at);
+
+-- Rows outside of any monthly partition, e.g. with a created_at far in the past, land here.
+CREATE TABLE images_default PARTITION OF images DEFAULT;
+
+-- Creates the partition of images holding the month of the given day, if missing.
+-- Returns whether the partition was created.
+CREATE OR REPLACE FUNCTION create_images_partition(day DATE) RETURNS BOOLEAN AS
+$$
+DECLARE
+    month     DATE := date_trunc('month', day)::date;
+    part_name TEXT := 'images_' || to_char(date_trunc('month', day), 'YYYY_MM');
+BEGIN
+    IF to_regclass(part_name) IS NOT NULL THEN
+        RETURN FALSE;
+    END IF;
+
+    EXECUTE format('CREATE TABLE %I PARTITION OF images FOR VALUES FROM (%L) TO (%L)',
+                   part_name, month, (month + INTERVAL '1 month')::date);
+    RETURN TRUE;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Creates the partitions of images for the current month and the given number of months ahead,
+-- if missing. Workers call it on start and every database.partitions.interval (runPartitioner of
+-- cmd/image-processor). Returns the number of partitions created.
+CREATE OR REPLACE FUNCTION ensure_images_partitions(months_ahead INT) RETURNS INT AS
+$$
+DECLARE
+    created INT := 0;
+    i       INT;
+BEGIN
+    FOR i IN 0..greatest(months_ahead, 0)
+        LOOP
+            IF create_images_partition((date_trunc('month', now()) + make_interval(months => i))::date) THEN
+                created := created + 1;
+            END IF;
+        END LOOP;
+
+    RETURN created;
+END;
+$$ LANGUAGE plpgsql;
+
+DO
+$$
+DECLARE
+    month   DATE;
+    columns TEXT;
+BEGIN
+    -- Partitions for the months of the existing images and for the months to come.
+    FOR month IN SELECT DISTINCT date_trunc('month', created_at)::date FROM images_legacy
+        LOOP
+            PERFORM create_images_partition(month);
+        END LOOP;
+    PERFORM ensure_images_partitions(3);
+
+    -- Generated columns (search_vector) are computed again on insert.
+    SELECT string_agg(quote_ident(column_name), ', ' ORDER BY ordinal_position)
+    INTO columns
+    FROM information_schema.columns
+    WHERE table_schema = current_schema()
+      AND table_name = 'images_legacy'
+      AND is_generated = 'NEVER';
+
+    EXECUTE format('INSERT INTO images (%s) SELECT %s FROM images_legacy', columns, columns);
+END;
+$$;
+
+DROP TABLE images_legacy;
+
+CREATE INDEX IF NOT EXISTS idx_images_original_id ON images (original_id);
+CREATE INDEX IF NOT EXISTS idx_images_owner_created_at ON images (owner, created_at);
+CREATE INDEX IF NOT EXISTS idx_images_owner_content_hash ON images (owner, content_hash)
+    WHERE original_id IS NULL AND content_hash IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_images_tags ON images USING GIN (tags jsonb_path_ops);
+CREATE INDEX IF NOT EXISTS idx_images_format ON images (format);
+CREATE INDEX IF NOT EXISTS idx_images_size_bytes ON images (size_bytes);
+CREATE INDEX IF NOT EXISTS idx_images_created_at ON images (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_images_search_vector ON images USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_images_expires_at ON images (expires_at)
+    WHERE expires_at IS NOT NULL AND original_id IS NULL;
+CREATE INDEX IF NOT EXISTS idx_images_message_id ON images (message_id)
+    WHERE message_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_images_path ON images (path);
+CREATE INDEX IF NOT EXISTS idx_images_finished_at ON images (finished_at) WHERE original_id IS NULL;
+CREATE INDEX IF NOT EXISTS idx_images_deleted_at ON images (deleted_at)
+    WHERE deleted_at IS NOT NULL;
+
+CREATE TRIGGER images_record_change
+    AFTER INSERT OR UPDATE OR DELETE
+    ON images
+    FOR EACH ROW
+EXECUTE FUNCTION record_image_change();
+
+CREATE TRIGGER images_account_storage_usage
+    AFTER INSERT OR DELETE OR UPDATE OF owner, path, size_bytes
+    ON images
+    FOR EACH ROW
+EXECUTE FUNCTION account_storage_usage();
+
+CREATE TRIGGER images_notify_status
+    AFTER UPDATE OF status, stage
+    ON images
+    FOR EACH ROW
+    WHEN (OLD.status IS DISTINCT FROM NEW.status OR OLD.stage IS DISTINCT FROM NEW.stage)
+EXECUTE FUNCTION notify_image_status();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE images RENAME TO images_partitioned;
+
+CREATE TABLE images
+(
+    LIKE images_partitioned INCLUDING DEFAULTS INCLUDING GENERATED
+);
+
+DO
+$$
+DECLARE
+    columns TEXT;
+BEGIN
+    SELECT string_agg(quote_ident(column_name), ', ' ORDER BY ordinal_position)
+    INTO columns
+    FROM information_schema.columns
+    WHERE table_schema = current_schema()
+      AND table_name = 'images_partitioned'
+      AND is_generated = 'NEVER';
+
+    EXECUTE format('INSERT INTO images (%s) SELECT %s FROM images_partitioned', columns, columns);
+END;
+$$;
+
+DROP TABLE images_partitioned;
+DROP FUNCTION IF EXISTS ensure_images_partitions(INT);
+DROP FUNCTION IF EXISTS create_images_partition(DATE);
+
+ALTER TABLE images
+    ADD PRIMARY KEY (id),
+    ADD FOREIGN KEY (original_id) REFERENCES images (id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_images_original_id ON images (original_id);
+CREATE INDEX IF NOT EXISTS idx_images_owner_created_at ON images (owner, created_at);
+CREATE INDEX IF NOT EXISTS idx_images_owner_content_hash ON images (owner, content_hash)
+    WHERE original_id IS NULL AND content_hash IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_images_tags ON images USING GIN (tags jsonb_path_ops);
+CREATE INDEX IF NOT EXISTS idx_images_format ON images (format);
+CREATE INDEX IF NOT EXISTS idx_images_size_bytes ON images (size_bytes);
+CREATE INDEX IF NOT EXISTS idx_images_created_at ON images (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_images_search_vector ON images USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_images_expires_at ON images (expires_at)
+    WHERE expires_at IS NOT NULL AND original_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_images_message_id ON images (message_id)
+    WHERE message_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_images_path ON images (path);
+CREATE INDEX IF NOT EXISTS idx_images_finished_at ON images (finished_at) WHERE original_id IS NULL;
+CREATE INDEX IF NOT EXISTS idx_images_deleted_at ON images (deleted_at)
+    WHERE deleted_at IS NOT NULL;
+
+CREATE TRIGGER images_record_change
+    AFTER INSERT OR UPDATE OR DELETE
+    ON images
+    FOR EACH ROW
+EXECUTE FUNCTION record_image_change();
+
+CREATE TRIGGER images_account_storage_usage
+    AFTER INSERT OR DELETE OR UPDATE OF owner, path, size_bytes
+    ON images
+    FOR EACH ROW
+EXECUTE FUNCTION account_storage_usage();
+
+CREATE TRIGGER images_notify_status
+    AFTER UPDATE OF status, stage
+    ON images
+    FOR EACH ROW
+    WHEN (OLD.status IS DISTINCT FROM NEW.status OR OLD.stage IS DISTINCT FROM NEW.stage)
+EXECUTE FUNCTION notify_image_status();
+-- +goose StatementEnd
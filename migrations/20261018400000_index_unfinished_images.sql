@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Unfinished jobs are counted by the ops dashboard and scanned for stuck ones by the re-driver.
+CREATE INDEX IF NOT EXISTS idx_images_unfinished ON images (updated_at)
+    WHERE original_id IS NULL AND deleted_at IS NULL AND stage NOT IN ('done', 'failed');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_images_unfinished;
+-- +goose StatementEnd
@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Sequence values are handed out in call order, not commit order: a reader could see seq N+1
+-- committed, move its cursor past it, and never see the seq N of a slower transaction. Changes are
+-- now recorded unnumbered, keyed by id, and numbered by the change feed reader once committed,
+-- under a lock, so that numbers are only ever handed out above those already read.
+ALTER TABLE image_changes DROP CONSTRAINT image_changes_pkey;
+ALTER TABLE image_changes ADD COLUMN id BIGSERIAL PRIMARY KEY;
+ALTER TABLE image_changes ALTER COLUMN seq DROP DEFAULT, ALTER COLUMN seq DROP NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_image_changes_seq ON image_changes (seq);
+CREATE INDEX IF NOT EXISTS idx_image_changes_unnumbered ON image_changes (id) WHERE seq IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE image_changes c
+SET seq = n.seq
+FROM (SELECT id, nextval('image_changes_seq_seq') AS seq
+      FROM (SELECT id FROM image_changes WHERE seq IS NULL ORDER BY id) pending) n
+WHERE c.id = n.id;
+
+DROP INDEX IF EXISTS idx_image_changes_unnumbered;
+DROP INDEX IF EXISTS idx_image_changes_seq;
+ALTER TABLE image_changes DROP COLUMN id;
+ALTER TABLE image_changes
+    ALTER COLUMN seq SET DEFAULT nextval('image_changes_seq_seq'),
+    ALTER COLUMN seq SET NOT NULL,
+    ADD PRIMARY KEY (seq);
+-- +goose StatementEnd
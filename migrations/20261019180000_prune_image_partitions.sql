@@ -0,0 +1,127 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Returns the creation time held by a UUIDv7 image ID, or -infinity for the random IDs of images
+-- saved before. Images are inserted at the time of their ID, so that lookups by ID bound created_at
+-- with it and only scan the monthly partitions from the image's on.
+CREATE OR REPLACE FUNCTION image_created_at(id UUID) RETURNS TIMESTAMPTZ AS
+$$
+SELECT CASE
+           WHEN substr(id::text, 15, 1) = '7'
+               THEN to_timestamp(('x' || lpad(substr(replace(id::text, '-', ''), 1, 12), 16, '0'))::bit(64)::bigint
+                                     / 1000.0)
+           ELSE '-infinity'::timestamptz
+           END
+$$ LANGUAGE sql IMMUTABLE PARALLEL SAFE;
+
+-- Creates the partition of images holding the month of the given day, if missing, moving the rows
+-- of the month out of images_default, over which a partition can't be created otherwise.
+-- Returns whether the partition was created.
+CREATE OR REPLACE FUNCTION create_images_partition(day DATE) RETURNS BOOLEAN AS
+$$
+DECLARE
+    month     DATE := date_trunc('month', day)::date;
+    month_end DATE := (date_trunc('month', day) + INTERVAL '1 month')::date;
+    part_name TEXT := 'images_' || to_char(date_trunc('month', day), 'YYYY_MM');
+    columns   TEXT;
+BEGIN
+    IF to_regclass(part_name) IS NOT NULL THEN
+        RETURN FALSE;
+    END IF;
+
+    IF NOT EXISTS (SELECT 1 FROM images_default WHERE created_at >= month AND created_at < month_end) THEN
+        EXECUTE format('CREATE TABLE %I PARTITION OF images FOR VALUES FROM (%L) TO (%L)',
+                       part_name, month, month_end);
+        RETURN TRUE;
+    END IF;
+
+    -- The rows are copied to a table attached as the partition afterwards. Moving them changes no
+    -- image, so the triggers recording changes and accounting storage are disabled meanwhile.
+    SELECT string_agg(quote_ident(column_name), ', ' ORDER BY ordinal_position)
+    INTO columns
+    FROM information_schema.columns
+    WHERE table_schema = current_schema()
+      AND table_name = 'images_default'
+      AND is_generated = 'NEVER';
+
+    EXECUTE format('CREATE TABLE %I (LIKE images INCLUDING DEFAULTS INCLUDING GENERATED)', part_name);
+    EXECUTE format('INSERT INTO %I (%s) SELECT %s FROM images_default WHERE created_at >= %L AND created_at < %L',
+                   part_name, columns, columns, month, month_end);
+
+    ALTER TABLE images_default DISABLE TRIGGER USER;
+    DELETE FROM images_default WHERE created_at >= month AND created_at < month_end;
+    ALTER TABLE images_default ENABLE TRIGGER USER;
+
+    EXECUTE format('ALTER TABLE images ATTACH PARTITION %I FOR VALUES FROM (%L) TO (%L)',
+                   part_name, month, month_end);
+    RETURN TRUE;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Creates the partitions of images for the current month, the given number of months ahead and the
+-- months of the rows of images_default, if missing, so that the default partition is emptied.
+-- Workers call it every database.partitions.interval. Returns the number of partitions created.
+CREATE OR REPLACE FUNCTION ensure_images_partitions(months_ahead INT) RETURNS INT AS
+$$
+DECLARE
+    created INT := 0;
+    pending DATE[];
+    month   DATE;
+BEGIN
+    -- The months are read up front: creating partitions alters images_default, which can't be
+    -- altered while a query reads it.
+    pending := ARRAY(SELECT (date_trunc('month', now()) + make_interval(months => i))::date
+                     FROM generate_series(0, greatest(months_ahead, 0)) AS i
+                     UNION
+                     SELECT date_trunc('month', created_at)::date
+                     FROM images_default
+                     WHERE created_at > '-infinity' AND created_at < 'infinity');
+
+    FOREACH month IN ARRAY pending
+        LOOP
+            IF create_images_partition(month) THEN
+                created := created + 1;
+            END IF;
+        END LOOP;
+
+    RETURN created;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION ensure_images_partitions(months_ahead INT) RETURNS INT AS
+$$
+DECLARE
+    created INT := 0;
+    i       INT;
+BEGIN
+    FOR i IN 0..greatest(months_ahead, 0)
+        LOOP
+            IF create_images_partition((date_trunc('month', now()) + make_interval(months => i))::date) THEN
+                created := created + 1;
+            END IF;
+        END LOOP;
+
+    RETURN created;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION create_images_partition(day DATE) RETURNS BOOLEAN AS
+$$
+DECLARE
+    month     DATE := date_trunc('month', day)::date;
+    part_name TEXT := 'images_' || to_char(date_trunc('month', day), 'YYYY_MM');
+BEGIN
+    IF to_regclass(part_name) IS NOT NULL THEN
+        RETURN FALSE;
+    END IF;
+
+    EXECUTE format('CREATE TABLE %I PARTITION OF images FOR VALUES FROM (%L) TO (%L)',
+                   part_name, month, (month + INTERVAL '1 month')::date);
+    RETURN TRUE;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP FUNCTION IF EXISTS image_created_at(UUID);
+-- +goose StatementEnd
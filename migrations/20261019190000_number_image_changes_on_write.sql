@@ -0,0 +1,73 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Changes are numbered as they are recorded rather than by the change feed reader, so that reads
+-- don't write and replicas can serve them. Numbers are taken from a counter row, whose lock is held
+-- until the recording transaction ends: a transaction recording a change waits for those recording
+-- before it to commit, so numbers are handed out in commit order and cursors never skip a slower
+-- transaction.
+CREATE TABLE IF NOT EXISTS image_change_counter
+(
+    id  SMALLINT PRIMARY KEY CHECK (id = 1),
+    seq BIGINT NOT NULL
+);
+
+LOCK TABLE image_changes IN EXCLUSIVE MODE;
+
+UPDATE image_changes c
+SET seq = n.seq
+FROM (SELECT id, nextval('image_changes_seq_seq') AS seq
+      FROM (SELECT id FROM image_changes WHERE seq IS NULL ORDER BY id) pending) n
+WHERE c.id = n.id;
+
+INSERT INTO image_change_counter (id, seq)
+SELECT 1, COALESCE(MAX(seq), 0)
+FROM image_changes;
+
+DROP INDEX IF EXISTS idx_image_changes_unnumbered;
+ALTER TABLE image_changes ALTER COLUMN seq SET NOT NULL;
+
+CREATE OR REPLACE FUNCTION record_image_change() RETURNS TRIGGER AS
+$$
+DECLARE
+    next_seq BIGINT;
+BEGIN
+    UPDATE image_change_counter SET seq = seq + 1 WHERE id = 1 RETURNING seq INTO next_seq;
+
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO image_changes (seq, image_id, operation, path) VALUES (next_seq, OLD.id, 'deleted', OLD.path);
+        RETURN OLD;
+    ELSIF TG_OP = 'UPDATE' THEN
+        INSERT INTO image_changes (seq, image_id, operation, path) VALUES (next_seq, NEW.id, 'updated', NEW.path);
+    ELSE
+        INSERT INTO image_changes (seq, image_id, operation, path) VALUES (next_seq, NEW.id, 'created', NEW.path);
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION record_image_change() RETURNS TRIGGER AS
+$$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO image_changes (image_id, operation, path) VALUES (OLD.id, 'deleted', OLD.path);
+        RETURN OLD;
+    ELSIF TG_OP = 'UPDATE' THEN
+        INSERT INTO image_changes (image_id, operation, path) VALUES (NEW.id, 'updated', NEW.path);
+    ELSE
+        INSERT INTO image_changes (image_id, operation, path) VALUES (NEW.id, 'created', NEW.path);
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+SELECT setval('image_changes_seq_seq', seq, seq > 0)
+FROM image_change_counter;
+
+ALTER TABLE image_changes ALTER COLUMN seq DROP NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_image_changes_unnumbered ON image_changes (id) WHERE seq IS NULL;
+
+DROP TABLE IF EXISTS image_change_counter;
+-- +goose StatementEnd
@@ -0,0 +1,90 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Originals are stored once per content hash, so that several records of an owner may share a
+-- stored object, e.g. expiring uploads of the same content. Objects are now accounted once per
+-- owner: a record is only counted when no other record of its owner has its path. The usage row is
+-- locked before looking for the other records, so that records of a path saved or deleted
+-- concurrently see each other once committed.
+CREATE OR REPLACE FUNCTION account_storage_usage() RETURNS TRIGGER AS
+$$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        UPDATE storage_usage
+        SET updated_at = NOW()
+        WHERE owner = OLD.owner
+          AND subdir = split_part(OLD.path, '/', 1);
+
+        IF NOT EXISTS (SELECT 1 FROM images WHERE owner = OLD.owner AND path = OLD.path AND id <> OLD.id) THEN
+            UPDATE storage_usage
+            SET bytes   = bytes - OLD.size_bytes,
+                objects = objects - 1
+            WHERE owner = OLD.owner
+              AND subdir = split_part(OLD.path, '/', 1);
+        END IF;
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        INSERT INTO storage_usage (owner, subdir)
+        VALUES (NEW.owner, split_part(NEW.path, '/', 1))
+        ON CONFLICT (owner, subdir) DO UPDATE
+            SET updated_at = NOW();
+
+        IF NOT EXISTS (SELECT 1 FROM images WHERE owner = NEW.owner AND path = NEW.path AND id <> NEW.id) THEN
+            UPDATE storage_usage
+            SET bytes   = bytes + NEW.size_bytes,
+                objects = objects + 1
+            WHERE owner = NEW.owner
+              AND subdir = split_part(NEW.path, '/', 1);
+        END IF;
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+LOCK TABLE images IN SHARE MODE;
+
+DELETE FROM storage_usage;
+
+INSERT INTO storage_usage (owner, subdir, bytes, objects)
+SELECT owner, split_part(path, '/', 1), SUM(size_bytes), COUNT(*)
+FROM (SELECT owner, path, MAX(size_bytes) AS size_bytes FROM images GROUP BY owner, path) stored
+GROUP BY owner, split_part(path, '/', 1);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION account_storage_usage() RETURNS TRIGGER AS
+$$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        UPDATE storage_usage
+        SET bytes      = bytes - OLD.size_bytes,
+            objects    = objects - 1,
+            updated_at = NOW()
+        WHERE owner = OLD.owner
+          AND subdir = split_part(OLD.path, '/', 1);
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        INSERT INTO storage_usage (owner, subdir, bytes, objects)
+        VALUES (NEW.owner, split_part(NEW.path, '/', 1), NEW.size_bytes, 1)
+        ON CONFLICT (owner, subdir) DO UPDATE
+            SET bytes      = storage_usage.bytes + EXCLUDED.bytes,
+                objects    = storage_usage.objects + 1,
+                updated_at = NOW();
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+LOCK TABLE images IN SHARE MODE;
+
+DELETE FROM storage_usage;
+
+INSERT INTO storage_usage (owner, subdir, bytes, objects)
+SELECT owner, split_part(path, '/', 1), SUM(size_bytes), COUNT(*)
+FROM images
+GROUP BY owner, split_part(path, '/', 1);
+-- +goose StatementEnd
@@ -0,0 +1,183 @@
+//go:build integration
+
+// Package e2e tests the whole pipeline against a running stack: an image uploaded to the API is
+// enqueued on Kafka, processed by a worker and served along with its derivative.
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	_ "image/jpeg"
+)
+
+// testAPIEnv names the environment variable holding the base URL of the API of the stack under
+// test, e.g. that of docker-compose:
+//
+//	docker compose up -d --build --wait
+//	TEST_API_URL=http://localhost:8080 go test -tags integration ./test/e2e/
+const testAPIEnv = "TEST_API_URL"
+
+// processTimeout bounds the wait for a worker to process the uploaded image.
+const processTimeout = time.Minute
+
+// TestUploadProcessGet uploads an image with a resize action, waits for a worker to process it
+// and downloads the resized derivative.
+func TestUploadProcessGet(t *testing.T) {
+	api := os.Getenv(testAPIEnv)
+	if api == "" {
+		t.Skipf("%s not set", testAPIEnv)
+	}
+
+	var uploaded struct {
+		ID string `json:"id"`
+	}
+	upload(t, api, testPNG(t, 200, 100), `{"action":"resize","params":{"width":"50","height":"25"}}`, &uploaded)
+
+	deadline := time.Now().Add(processTimeout)
+	for {
+		var meta struct {
+			Status string `json:"status"`
+			Error  string `json:"error"`
+		}
+		getJSON(t, api+"/api/image/"+uploaded.ID+"/meta", &meta)
+
+		if meta.Status == "processed" {
+			break
+		}
+		if meta.Status == "failed" {
+			t.Fatalf("processing failed: %s", meta.Error)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("image still %s after %s", meta.Status, processTimeout)
+		}
+		time.Sleep(time.Second)
+	}
+
+	var derived []struct {
+		ID string `json:"id"`
+	}
+	getJSON(t, api+"/api/image/"+uploaded.ID+"/derived", &derived)
+	if len(derived) != 1 {
+		t.Fatalf("got %d derived images, want 1", len(derived))
+	}
+
+	resp := get(t, api+"/api/image/"+derived[0].ID)
+	defer resp.Body.Close()
+
+	img, _, err := image.Decode(resp.Body)
+	if err != nil {
+		t.Fatalf("decode derived image: %v", err)
+	}
+	if got := img.Bounds().Size(); got != (image.Point{X: 50, Y: 25}) {
+		t.Errorf("derived image size = %v, want 50x25", got)
+	}
+}
+
+// testPNG returns a PNG-encoded gradient of the given size.
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode image: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+// upload posts the image with the actions to the upload endpoint and decodes the result into dst.
+func upload(t *testing.T, api string, data []byte, actions string, dst any) {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+
+	part, err := form.CreateFormFile("image", "e2e.png")
+	if err != nil {
+		t.Fatalf("create form file: %v", err)
+	}
+	if _, err := part.Write(data); err != nil {
+		t.Fatalf("write form file: %v", err)
+	}
+	if err := form.WriteField("actions", actions); err != nil {
+		t.Fatalf("write actions: %v", err)
+	}
+	if err := form.Close(); err != nil {
+		t.Fatalf("close form: %v", err)
+	}
+
+	resp, err := http.Post(api+"/api/upload", form.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	defer resp.Body.Close()
+
+	decodeResult(t, resp, dst)
+}
+
+// getJSON gets url and decodes the result of the response into dst.
+func getJSON(t *testing.T, url string, dst any) {
+	t.Helper()
+
+	resp := get(t, url)
+	defer resp.Body.Close()
+
+	decodeResult(t, resp, dst)
+}
+
+// get gets url and fails the test unless it responds with 200 OK.
+func get(t *testing.T, url string) *http.Response {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("get %s: %v", url, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		t.Fatalf("get %s: %s: %s", url, resp.Status, body)
+	}
+
+	return resp
+}
+
+// decodeResult decodes the result wrapped in a successful API response into dst.
+func decodeResult(t *testing.T, resp *http.Response, dst any) {
+	t.Helper()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("%s %s: %s: %s", resp.Request.Method, resp.Request.URL, resp.Status, body)
+	}
+
+	var envelope struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if err := json.Unmarshal(envelope.Result, dst); err != nil {
+		t.Fatalf("decode result %s: %v", envelope.Result, err)
+	}
+}
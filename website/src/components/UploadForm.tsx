@@ -13,6 +13,7 @@ interface UploadedImage {
   path: string;
   status: string;
   preview?: string; // локальный preview
+  variantId?: string; // id обработанного изображения
   action?: {
     name: string;
     params: Record<string, string>;
@@ -58,7 +59,7 @@ export default function UploadForm() {
 
     try {
       const { data } = await axios.post<{ result: UploadedImage }>(
-        "http://localhost:8080/api/upload",
+        "http://localhost:8080/api/v1/upload",
         formData,
         { headers: { "Content-Type": "multipart/form-data" } }
       );
@@ -83,7 +84,7 @@ export default function UploadForm() {
 
   const handleDelete = async (id: string) => {
     try {
-      await axios.delete(`http://localhost:8080/api/image/${id}`);
+      await axios.delete(`http://localhost:8080/api/v1/image/${id}`);
       setUploadedImages((prev) => {
         const imgToDelete = prev.find((img) => img.id === id);
         if (imgToDelete?.preview) URL.revokeObjectURL(imgToDelete.preview);
@@ -116,9 +117,17 @@ export default function UploadForm() {
         const updatedImages = await Promise.all(
           pendingImages.map(async (img) => {
             const { data } = await axios.get<{ result: UploadedImage }>(
-              `http://localhost:8080/api/image/${img.id}/meta`
+              `http://localhost:8080/api/v1/image/${img.id}/meta`
             );
-            return { ...img, ...data.result };
+            if (data.result.status !== "processed") {
+              return { ...img, ...data.result };
+            }
+
+            // результат обработки хранится отдельной записью, связанной с оригиналом
+            const { data: derived } = await axios.get<{ result: UploadedImage[] }>(
+              `http://localhost:8080/api/v1/image/${img.id}/derived`
+            );
+            return { ...img, ...data.result, variantId: derived.result[0]?.id };
           })
         );
 
@@ -175,6 +184,9 @@ export default function UploadForm() {
           <option value="resize">Resize</option>
           <option value="thumbnail">Thumbnail</option>
           <option value="watermark">Watermark</option>
+          <option value="auto_levels">Auto levels</option>
+          <option value="deskew">Deskew</option>
+          <option value="chroma_key">Chroma key</option>
         </select>
 
         {(action.name === "resize" || action.name === "thumbnail") && (
@@ -231,7 +243,7 @@ export default function UploadForm() {
           <div key={img.id} className="border p-2 rounded">
             <img
               key={`${img.id}-${img.status}`}
-              src={`http://localhost:8080/api/image/${img.id}?t=${Date.now()}`}
+              src={`http://localhost:8080/api/v1/image/${img.variantId ?? img.id}?t=${Date.now()}`}
               alt={img.filename}
               className="w-full h-40"
             />